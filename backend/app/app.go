@@ -0,0 +1,125 @@
+// Package app wires the configuration, database, stores and HTTP routes of
+// the Examify backend together and runs the server.
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/Maheshkarri4444/Examify/config"
+	"github.com/Maheshkarri4444/Examify/controllers"
+	"github.com/Maheshkarri4444/Examify/events"
+	"github.com/Maheshkarri4444/Examify/middleware"
+	"github.com/Maheshkarri4444/Examify/routes"
+	"github.com/Maheshkarri4444/Examify/store"
+	"github.com/gin-contrib/cors"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type App struct {
+	cfg     *config.Config
+	client  *mongo.Client
+	server  *http.Server
+	handler *controllers.Handler
+}
+
+// New connects to MongoDB and builds the router. Nothing is started until Run.
+func New(ctx context.Context, cfg *config.Config) (*App, error) {
+	client, err := config.ConnectMongo(ctx, cfg.MongoURI)
+	if err != nil {
+		return nil, err
+	}
+	if err := config.RequireTransactions(ctx, client); err != nil {
+		client.Disconnect(context.Background())
+		return nil, err
+	}
+
+	db := client.Database(cfg.DBName)
+	if err := store.MigrateLegacySheetStatuses(ctx, db); err != nil {
+		client.Disconnect(context.Background())
+		return nil, err
+	}
+	if err := store.EnsureMongoIndexes(ctx, db); err != nil {
+		client.Disconnect(context.Background())
+		return nil, err
+	}
+	stores := store.NewMongoStores(db)
+	bus := events.NewBus()
+	handler := controllers.NewHandler(cfg, stores, bus)
+	auth := middleware.NewAuth(stores.Users, cfg.JWTSecret)
+
+	r := gin.Default()
+	r.Use(cors.New(cors.Config{
+		AllowOrigins:     []string{cfg.FrontendURL},
+		AllowMethods:     []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
+		AllowHeaders:     []string{"Origin", "Content-Type", "Authorization", "Idempotency-Key"},
+		ExposeHeaders:    []string{"Content-Length"},
+		AllowCredentials: true,
+		MaxAge:           12 * time.Hour,
+	}))
+	r.SetTrustedProxies(nil) // Only for development
+
+	routes.AuthRoutes(r, handler)
+	routes.ExamRoutes(r, handler, auth)
+	routes.AiRoutes(r, handler, auth)
+	routes.AdminRoutes(r, handler, auth)
+	routes.AuditRoutes(r, handler, auth)
+	routes.JobRoutes(r, handler, auth)
+
+	server := &http.Server{
+		Addr:              ":" + cfg.Port,
+		Handler:           r,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	// Monitoring streams never go idle, end them so Shutdown can finish
+	server.RegisterOnShutdown(bus.Close)
+
+	return &App{
+		cfg:     cfg,
+		client:  client,
+		server:  server,
+		handler: handler,
+	}, nil
+}
+
+// Run serves HTTP and runs background jobs until ctx is cancelled. It then
+// stops accepting new connections, waits up to ShutdownTimeout for in-flight
+// requests such as exam submissions to finish, hands running jobs back to
+// the queue and disconnects from MongoDB.
+func (a *App) Run(ctx context.Context) error {
+	a.handler.StartJobs()
+
+	errCh := make(chan error, 1)
+	go func() {
+		fmt.Println("Server running on the port: ", a.cfg.Port)
+		if err := a.server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			errCh <- err
+		}
+		close(errCh)
+	}()
+
+	var serveErr error
+	select {
+	case serveErr = <-errCh:
+	case <-ctx.Done():
+		fmt.Println("shutting down, draining in-flight requests")
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), a.cfg.ShutdownTimeout)
+	defer cancel()
+
+	shutdownErr := a.server.Shutdown(shutdownCtx)
+	a.handler.Shutdown()
+	if err := a.client.Disconnect(shutdownCtx); err != nil && shutdownErr == nil {
+		shutdownErr = err
+	}
+
+	if serveErr != nil {
+		return serveErr
+	}
+	return shutdownErr
+}
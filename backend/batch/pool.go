@@ -0,0 +1,63 @@
+// Package batch has the worker pool and retry helpers background jobs use to
+// fan work out to slow external services.
+package batch
+
+import (
+	"context"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// Pool calls fn for every index in [0, n) with at most workers calls at a
+// time. Once ctx is done no new calls start; Pool returns when the calls in
+// flight have returned.
+func Pool(ctx context.Context, workers, n int, fn func(ctx context.Context, i int)) {
+	if workers < 1 {
+		workers = 1
+	}
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				fn(ctx, i)
+			}
+		}()
+	}
+
+feed:
+	for i := 0; i < n; i++ {
+		select {
+		case indexes <- i:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(indexes)
+	wg.Wait()
+}
+
+// Retry calls fn up to attempts times while it fails with an error
+// retryable accepts. Waits double from base, with full jitter so workers
+// that failed together don't retry together.
+func Retry(ctx context.Context, attempts int, base time.Duration, retryable func(error) bool, fn func(ctx context.Context) error) error {
+	var err error
+	for attempt := 0; attempt < attempts; attempt++ {
+		if err = fn(ctx); err == nil || !retryable(err) {
+			return err
+		}
+		if attempt == attempts-1 {
+			break
+		}
+		wait := time.Duration(rand.Int63n(int64(base<<attempt) + 1))
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return err
+}
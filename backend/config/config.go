@@ -0,0 +1,306 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/joho/godotenv"
+)
+
+// Config holds every setting the backend needs. It is loaded once at startup
+// and passed to the parts of the app that need it.
+type Config struct {
+	Port string
+	// MongoURI must point to a replica set or a sharded cluster, answer
+	// sheets are assigned in transactions. A single node replica set
+	// (mongod --replSet rs0, then rs.initiate()) is enough for development.
+	MongoURI     string
+	DBName       string
+	JWTSecret    string
+	FrontendURL  string
+	CookieSecure bool
+	GeminiAPIKey string
+
+	Google GoogleConfig
+	Roles  RoleConfig
+	AI     AIConfig
+
+	// ShutdownTimeout is how long in-flight requests get to finish after
+	// SIGTERM before the server is closed.
+	ShutdownTimeout time.Duration
+	// JobWorkers is how many background jobs this server runs at once.
+	JobWorkers int
+}
+
+type GoogleConfig struct {
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	// AllowedHostedDomains restricts login to these Google Workspace domains.
+	// Empty accepts any domain, including consumer Gmail accounts.
+	AllowedHostedDomains []string
+}
+
+// AIConfig limits and prices the calls to the LLM provider. Quotas count per
+// UTC day, 0 means no limit.
+type AIConfig struct {
+	UserDailyCalls  int64
+	UserDailyTokens int64
+	ExamDailyCalls  int64
+	ExamDailyTokens int64
+	// Prices in USD per million tokens, for the usage report's estimate.
+	PromptTokenPrice   float64
+	ResponseTokenPrice float64
+
+	// RequestTimeout bounds one request to the provider. Rate limits,
+	// server errors and timeouts are tried up to MaxAttempts times, waiting
+	// up to RetryBackoff, doubling, in between.
+	RequestTimeout time.Duration
+	MaxAttempts    int
+	RetryBackoff   time.Duration
+	// After BreakerFailures failed requests in a row the provider isn't
+	// called for BreakerCooldown, 0 failures turns the breaker off.
+	BreakerFailures int
+	BreakerCooldown time.Duration
+	// CacheTTL is how long a grading is reused for identical answers, 0
+	// turns the cache off.
+	CacheTTL time.Duration
+}
+
+// RoleConfig decides the role given to an account on its first login.
+type RoleConfig struct {
+	AdminEmails    []string
+	TeacherEmails  []string
+	TeacherDomains []string
+	StudentDomains []string
+}
+
+// Load reads the configuration. Values come from the process environment,
+// which is first filled from an env file (-env, default .env) without
+// overriding variables that are already set. A few flags override both:
+//
+//	-env               env file to read; the default .env may be missing
+//	-port              HTTP port (PORT)
+//	-shutdown-timeout  grace period for in-flight requests (SHUTDOWN_TIMEOUT)
+func Load(args []string) (*Config, error) {
+	fs := flag.NewFlagSet("examify", flag.ContinueOnError)
+	envFile := fs.String("env", ".env", "env file to load")
+	port := fs.String("port", "", "HTTP port")
+	shutdownTimeout := fs.Duration("shutdown-timeout", 0, "grace period for in-flight requests on shutdown")
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+
+	if err := godotenv.Load(*envFile); err != nil {
+		explicit := false
+		fs.Visit(func(f *flag.Flag) { explicit = explicit || f.Name == "env" })
+		if explicit || !errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("loading %s: %w", *envFile, err)
+		}
+	}
+
+	cfg := &Config{
+		Port:         getEnv("PORT", "8080"),
+		MongoURI:     os.Getenv("MONGODB_URI"),
+		DBName:       os.Getenv("DB_NAME"),
+		JWTSecret:    os.Getenv("JWT_SECRET"),
+		FrontendURL:  strings.TrimRight(getEnv("FRONTEND_URL", "http://localhost:5173"), "/"),
+		CookieSecure: os.Getenv("COOKIE_SECURE") == "true",
+		GeminiAPIKey: os.Getenv("GEMINI_API_KEY"),
+		Google: GoogleConfig{
+			ClientID:             os.Getenv("GOOGLE_CLIENT_ID"),
+			ClientSecret:         os.Getenv("GOOGLE_CLIENT_SECRET"),
+			RedirectURL:          os.Getenv("GOOGLE_REDIRECT_URL"),
+			AllowedHostedDomains: envList("GOOGLE_ALLOWED_HD", ""),
+		},
+		Roles: RoleConfig{
+			AdminEmails:    envList("ADMIN_EMAILS", ""),
+			TeacherEmails:  envList("TEACHER_EMAILS", ""),
+			TeacherDomains: envList("TEACHER_EMAIL_DOMAINS", ""),
+			StudentDomains: envList("STUDENT_EMAIL_DOMAINS", "rguktn.ac.in"),
+		},
+		AI: AIConfig{
+			UserDailyCalls:     500,
+			ExamDailyCalls:     2000,
+			PromptTokenPrice:   0.10,
+			ResponseTokenPrice: 0.40,
+			RequestTimeout:     20 * time.Second,
+			MaxAttempts:        3,
+			RetryBackoff:       time.Second,
+			BreakerFailures:    5,
+			BreakerCooldown:    30 * time.Second,
+			CacheTTL:           7 * 24 * time.Hour,
+		},
+		ShutdownTimeout: 30 * time.Second,
+		JobWorkers:      2,
+	}
+	if cfg.Google.RedirectURL == "" {
+		cfg.Google.RedirectURL = cfg.FrontendURL + "/google/callback"
+	}
+	if value := os.Getenv("SHUTDOWN_TIMEOUT"); value != "" {
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return nil, fmt.Errorf("SHUTDOWN_TIMEOUT: %w", err)
+		}
+		cfg.ShutdownTimeout = d
+	}
+
+	if value := os.Getenv("JOB_WORKERS"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return nil, fmt.Errorf("JOB_WORKERS: %w", err)
+		}
+		cfg.JobWorkers = n
+	}
+
+	quotas := []struct {
+		key   string
+		value *int64
+	}{
+		{"AI_USER_DAILY_CALLS", &cfg.AI.UserDailyCalls},
+		{"AI_USER_DAILY_TOKENS", &cfg.AI.UserDailyTokens},
+		{"AI_EXAM_DAILY_CALLS", &cfg.AI.ExamDailyCalls},
+		{"AI_EXAM_DAILY_TOKENS", &cfg.AI.ExamDailyTokens},
+	}
+	for _, q := range quotas {
+		if value := os.Getenv(q.key); value != "" {
+			n, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", q.key, err)
+			}
+			*q.value = n
+		}
+	}
+	durations := []struct {
+		key   string
+		value *time.Duration
+	}{
+		{"AI_REQUEST_TIMEOUT", &cfg.AI.RequestTimeout},
+		{"AI_RETRY_BACKOFF", &cfg.AI.RetryBackoff},
+		{"AI_BREAKER_COOLDOWN", &cfg.AI.BreakerCooldown},
+		{"AI_CACHE_TTL", &cfg.AI.CacheTTL},
+	}
+	for _, d := range durations {
+		if value := os.Getenv(d.key); value != "" {
+			parsed, err := time.ParseDuration(value)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", d.key, err)
+			}
+			*d.value = parsed
+		}
+	}
+	counts := []struct {
+		key   string
+		value *int
+	}{
+		{"AI_MAX_ATTEMPTS", &cfg.AI.MaxAttempts},
+		{"AI_BREAKER_FAILURES", &cfg.AI.BreakerFailures},
+	}
+	for _, n := range counts {
+		if value := os.Getenv(n.key); value != "" {
+			parsed, err := strconv.Atoi(value)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", n.key, err)
+			}
+			*n.value = parsed
+		}
+	}
+	prices := []struct {
+		key   string
+		value *float64
+	}{
+		{"AI_PROMPT_TOKEN_PRICE", &cfg.AI.PromptTokenPrice},
+		{"AI_RESPONSE_TOKEN_PRICE", &cfg.AI.ResponseTokenPrice},
+	}
+	for _, p := range prices {
+		if value := os.Getenv(p.key); value != "" {
+			f, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", p.key, err)
+			}
+			*p.value = f
+		}
+	}
+
+	if *port != "" {
+		cfg.Port = *port
+	}
+	if *shutdownTimeout != 0 {
+		cfg.ShutdownTimeout = *shutdownTimeout
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// Validate reports every missing or malformed setting at once.
+func (c *Config) Validate() error {
+	var problems []string
+	required := []struct{ key, value string }{
+		{"MONGODB_URI", c.MongoURI},
+		{"DB_NAME", c.DBName},
+		{"JWT_SECRET", c.JWTSecret},
+		{"GOOGLE_CLIENT_ID", c.Google.ClientID},
+		{"GOOGLE_CLIENT_SECRET", c.Google.ClientSecret},
+	}
+	for _, r := range required {
+		if r.value == "" {
+			problems = append(problems, r.key+" is required")
+		}
+	}
+	if p, err := strconv.Atoi(c.Port); err != nil || p <= 0 || p > 65535 {
+		problems = append(problems, fmt.Sprintf("invalid port %q", c.Port))
+	}
+	if c.ShutdownTimeout <= 0 {
+		problems = append(problems, "shutdown timeout must be positive")
+	}
+	if c.JobWorkers < 1 {
+		problems = append(problems, "JOB_WORKERS must be at least 1")
+	}
+	if c.AI.UserDailyCalls < 0 || c.AI.UserDailyTokens < 0 || c.AI.ExamDailyCalls < 0 || c.AI.ExamDailyTokens < 0 {
+		problems = append(problems, "AI quotas can't be negative")
+	}
+	if c.AI.PromptTokenPrice < 0 || c.AI.ResponseTokenPrice < 0 {
+		problems = append(problems, "AI token prices can't be negative")
+	}
+	if c.AI.RequestTimeout <= 0 {
+		problems = append(problems, "AI_REQUEST_TIMEOUT must be positive")
+	}
+	if c.AI.MaxAttempts < 1 {
+		problems = append(problems, "AI_MAX_ATTEMPTS must be at least 1")
+	}
+	if c.AI.RetryBackoff < 0 || c.AI.BreakerFailures < 0 || c.AI.BreakerCooldown < 0 || c.AI.CacheTTL < 0 {
+		problems = append(problems, "AI_RETRY_BACKOFF, AI_BREAKER_FAILURES, AI_BREAKER_COOLDOWN and AI_CACHE_TTL can't be negative")
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("invalid configuration: %s", strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+func getEnv(key, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return fallback
+}
+
+// envList reads a comma separated, case insensitive list from the environment.
+func envList(key, fallback string) []string {
+	value := getEnv(key, fallback)
+	var list []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(strings.ToLower(item)); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
@@ -2,42 +2,50 @@ package config
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"log"
-	"os"
 	"time"
 
-	"github.com/joho/godotenv"
+	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
 )
 
-var Client *mongo.Client = createMongoClient()
-
-func createMongoClient() *mongo.Client {
-	err := godotenv.Load(".env")
-	if err != nil {
-		log.Fatalf("Error loading the file: %v", err)
-	}
-
-	MongodbUri := os.Getenv("MONGODB_URI")
-	if MongodbUri == "" {
-		log.Fatal("Mongodburi is not found in the env variables")
-	}
-
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+// ConnectMongo opens a client and pings the primary so a bad URI fails at
+// startup rather than on the first request.
+func ConnectMongo(ctx context.Context, uri string) (*mongo.Client, error) {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
 	defer cancel()
 
-	client, err := mongo.Connect(ctx, options.Client().ApplyURI(MongodbUri))
+	client, err := mongo.Connect(ctx, options.Client().ApplyURI(uri))
 	if err != nil {
-		log.Fatal("mongo db connection error: ", err)
+		return nil, fmt.Errorf("mongo db connection error: %w", err)
+	}
+	if err := client.Ping(ctx, readpref.Primary()); err != nil {
+		client.Disconnect(context.Background())
+		return nil, fmt.Errorf("mongo db ping failed: %w", err)
 	}
 
 	fmt.Println("connected to mongodb")
-	return client
+	return client, nil
 }
 
-func GetCollection(client *mongo.Client, collectionName string) *mongo.Collection {
-	return client.Database(os.Getenv("DB_NAME")).Collection(collectionName)
-
+// RequireTransactions checks that the server is a replica set member or a
+// mongos. Answer sheets are assigned in multi-document transactions, which a
+// standalone mongod refuses.
+func RequireTransactions(ctx context.Context, client *mongo.Client) error {
+	var hello struct {
+		SetName string `bson:"setName"`
+		Msg     string `bson:"msg"`
+	}
+	err := client.Database("admin").RunCommand(ctx, bson.D{{Key: "hello", Value: 1}}).Decode(&hello)
+	if err != nil {
+		return fmt.Errorf("mongo db hello failed: %w", err)
+	}
+	if hello.SetName == "" && hello.Msg != "isdbgrid" {
+		return errors.New("mongo db is a standalone server but transactions need a replica set: " +
+			"start mongod with --replSet and run rs.initiate(), a single node replica set is enough")
+	}
+	return nil
 }
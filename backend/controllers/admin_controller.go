@@ -0,0 +1,323 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Maheshkarri4444/Examify/models"
+	"github.com/Maheshkarri4444/Examify/store"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// ListUsers lists users, optionally filtered by role and searched by name or
+// email with ?q=. Results are paged with ?page= and ?limit= (max 200).
+func (h *Handler) ListUsers(c *gin.Context) {
+	filter := store.UserFilter{
+		Role:  c.Query("role"),
+		Query: strings.TrimSpace(c.Query("q")),
+	}
+	if filter.Role != "" && !isValidRole(filter.Role) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid role"})
+		return
+	}
+
+	limit, _ := strconv.ParseInt(c.DefaultQuery("limit", "50"), 10, 64)
+	if limit <= 0 || limit > 200 {
+		limit = 50
+	}
+	page, _ := strconv.ParseInt(c.DefaultQuery("page", "1"), 10, 64)
+	if page < 1 {
+		page = 1
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	filter.Skip = (page - 1) * limit
+	filter.Limit = limit
+	users, total, err := h.store.Users.List(ctx, filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch users"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"users": users, "total": total, "page": page, "limit": limit})
+}
+
+// UpdateUserRole promotes or demotes a user. Admins cannot change their own
+// role so the last admin can't lock everyone out by accident.
+func (h *Handler) UpdateUserRole(c *gin.Context) {
+	userID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	var requestBody struct {
+		Role string `json:"role" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&requestBody); err != nil || !isValidRole(requestBody.Role) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Role must be one of student, teacher or admin"})
+		return
+	}
+
+	if userID == c.MustGet("user_id").(primitive.ObjectID) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "You cannot change your own role"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	user, err := h.store.Users.GetByID(ctx, userID)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch user"})
+		}
+		return
+	}
+
+	if err := h.ensureContainerForRole(ctx, user.ContainerID, requestBody.Role); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to prepare user container"})
+		return
+	}
+
+	if err := h.store.Users.SetRole(ctx, userID, requestBody.Role); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update user role"})
+		return
+	}
+
+	h.recordAudit(ctx, c, models.AuditLog{
+		Action:   "user.role_changed",
+		TargetID: userID,
+		Changes:  map[string]models.AuditChange{"role": {Before: user.Role, After: requestBody.Role}},
+		Details:  map[string]interface{}{"email": user.Email},
+	})
+
+	c.JSON(http.StatusOK, gin.H{"message": "User role updated successfully", "user_id": userID, "role": requestBody.Role})
+}
+
+// ImpersonateUser issues a one hour token for another account so staff can
+// reproduce what a student or teacher sees. A reason is mandatory and every
+// session is written to the audit log.
+func (h *Handler) ImpersonateUser(c *gin.Context) {
+	userID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	var requestBody struct {
+		Reason string `json:"reason" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&requestBody); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "A reason is required to impersonate a user"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	user, err := h.store.Users.GetByID(ctx, userID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+	if user.Role == models.RoleAdmin {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Admins cannot be impersonated"})
+		return
+	}
+
+	adminEmail := c.MustGet("email").(string)
+	token, err := h.generateImpersonationJWT(user.Email, adminEmail)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+		return
+	}
+
+	h.recordAudit(ctx, c, models.AuditLog{
+		Action:   "user.impersonated",
+		TargetID: userID,
+		Details:  map[string]interface{}{"email": user.Email, "reason": requestBody.Reason},
+	})
+
+	c.JSON(http.StatusOK, gin.H{"token": token, "user": user, "expires_in": 3600})
+}
+
+// ReassignExam moves an exam, along with its evaluations, from the teacher
+// container that currently owns it to another teacher's container.
+func (h *Handler) ReassignExam(c *gin.Context) {
+	examID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid exam ID"})
+		return
+	}
+
+	var requestBody struct {
+		TeacherEmail string `json:"teacher_email" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&requestBody); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "teacher_email is required"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	teacher, err := h.store.Users.GetByEmail(ctx, strings.ToLower(requestBody.TeacherEmail))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Teacher not found"})
+		return
+	}
+	if teacher.Role != models.RoleTeacher && teacher.Role != models.RoleAdmin {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Target user is not a teacher"})
+		return
+	}
+
+	source, err := h.store.Containers.FindTeacherContainerByExam(ctx, examID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "No teacher container owns this exam"})
+		return
+	}
+	if source.ID == teacher.ContainerID {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Exam already belongs to this teacher"})
+		return
+	}
+
+	evaluationIDs := []primitive.ObjectID{}
+	for _, e := range source.Exams {
+		if e.ExamID == examID {
+			evaluationIDs = append(evaluationIDs, e.EvaluationID...)
+		}
+	}
+
+	if err := h.ensureContainerForRole(ctx, teacher.ContainerID, teacher.Role); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to prepare teacher container"})
+		return
+	}
+
+	if err := h.store.Containers.AddExamToTeacher(ctx, teacher.ContainerID, examID, evaluationIDs); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update target teacher container"})
+		return
+	}
+
+	if err := h.store.Containers.RemoveExamFromTeacher(ctx, source.ID, examID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update source teacher container"})
+		return
+	}
+
+	h.recordAudit(ctx, c, models.AuditLog{
+		Action:   "exam.reassigned",
+		TargetID: examID,
+		ExamID:   examID,
+		Changes:  map[string]models.AuditChange{"teacher_container": {Before: source.ID, After: teacher.ContainerID}},
+		Details:  map[string]interface{}{"to_teacher": teacher.Email},
+	})
+
+	c.JSON(http.StatusOK, gin.H{"message": "Exam reassigned successfully"})
+}
+
+// ForceCloseAnswerSheet submits a started or paused answer sheet on behalf of
+// a student, for example when their browser crashed before they could submit.
+func (h *Handler) ForceCloseAnswerSheet(c *gin.Context) {
+	h.setAnswerSheetClosed(c, true)
+}
+
+// ReopenAnswerSheet lets a student continue a submitted or expired answer
+// sheet, without a timer. Sheets that already have a finished evaluation
+// can't be reopened.
+func (h *Handler) ReopenAnswerSheet(c *gin.Context) {
+	h.setAnswerSheetClosed(c, false)
+}
+
+func (h *Handler) setAnswerSheetClosed(c *gin.Context, closed bool) {
+	answerSheetID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid answer sheet ID"})
+		return
+	}
+
+	var requestBody struct {
+		Reason string `json:"reason" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&requestBody); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "A reason is required"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	action, status := "answer_sheet.force_closed", models.SheetSubmitted
+	if !closed {
+		evaluation, err := h.store.Evaluations.GetByAnswerSheet(ctx, answerSheetID)
+		if err != nil && !errors.Is(err, store.ErrNotFound) {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check evaluations"})
+			return
+		}
+		if evaluation != nil && evaluation.Evaluated {
+			c.JSON(http.StatusConflict, gin.H{"error": "Answer sheet has already been evaluated"})
+			return
+		}
+		action, status = "answer_sheet.reopened", models.SheetStarted
+	}
+
+	change := store.SheetChange{To: status, Reason: requestBody.Reason}
+	if h.transitionSheet(ctx, c, answerSheetID, action, change) == nil {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Answer sheet updated successfully", "status": status})
+}
+
+// GetSystemStats returns the counts staff look at while an exam is running.
+func (h *Handler) GetSystemStats(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	submitted := true
+	evaluated, notEvaluated := true, false
+	counts := []struct {
+		key   string
+		count func() (int64, error)
+	}{
+		{"students", func() (int64, error) { return h.store.Users.CountByRole(ctx, models.RoleStudent) }},
+		{"teachers", func() (int64, error) { return h.store.Users.CountByRole(ctx, models.RoleTeacher) }},
+		{"admins", func() (int64, error) { return h.store.Users.CountByRole(ctx, models.RoleAdmin) }},
+		{"exams", func() (int64, error) { return h.store.Exams.Count(ctx) }},
+		{"active_attempts", func() (int64, error) {
+			return h.store.AnswerSheets.Count(ctx, store.AnswerSheetFilter{Statuses: []models.SheetStatus{models.SheetStarted, models.SheetPaused}})
+		}},
+		{"not_started_attempts", func() (int64, error) {
+			return h.store.AnswerSheets.Count(ctx, store.AnswerSheetFilter{Statuses: []models.SheetStatus{models.SheetAssigned}})
+		}},
+		{"submitted_attempts", func() (int64, error) {
+			return h.store.AnswerSheets.Count(ctx, store.AnswerSheetFilter{Submitted: &submitted})
+		}},
+		{"pending_evaluations", func() (int64, error) {
+			return h.store.Evaluations.Count(ctx, store.EvaluationFilter{Evaluated: &notEvaluated})
+		}},
+		{"finished_evaluations", func() (int64, error) {
+			return h.store.Evaluations.Count(ctx, store.EvaluationFilter{Evaluated: &evaluated})
+		}},
+	}
+
+	stats := gin.H{}
+	for _, count := range counts {
+		n, err := count.count()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to count " + count.key})
+			return
+		}
+		stats[count.key] = n
+	}
+
+	c.JSON(http.StatusOK, stats)
+}
@@ -0,0 +1,301 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/Maheshkarri4444/Examify/batch"
+	"github.com/Maheshkarri4444/Examify/models"
+	"github.com/Maheshkarri4444/Examify/queue"
+	"github.com/Maheshkarri4444/Examify/store"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	jobAIPreEvaluation = "ai_pre_evaluation"
+	// preEvaluationAttempts is how often a pre-evaluation with failed
+	// questions is run again. Each run only grades what is still missing.
+	preEvaluationAttempts = 3
+	// preEvaluationWorkers bounds the AI calls a pre-evaluation makes at
+	// once, so one exam can't use up the provider's rate limit.
+	preEvaluationWorkers = 4
+	aiCallTimeout        = time.Minute
+)
+
+// preEvaluationPayload is what a pre-evaluation job needs to run after the
+// request that started it is gone.
+type preEvaluationPayload struct {
+	ExamID         primitive.ObjectID `bson:"exam_id"`
+	ActorEmail     string             `bson:"actor_email"`
+	ActorRole      string             `bson:"actor_role"`
+	ImpersonatedBy string             `bson:"impersonated_by,omitempty"`
+}
+
+// preEvaluationTask is one answered question waiting for an AI suggestion.
+type preEvaluationTask struct {
+	evaluationID primitive.ObjectID
+	index        int
+	examType     string
+	question     models.Question
+	answers      []models.Answer
+}
+
+// StartAIPreEvaluation queues a job that creates evaluations for every
+// submitted answer sheet of an exam and asks the AI to grade each answered
+// question. The AI's feedback and score are stored as suggestions next to
+// each question; marks and finalizing stay with the teacher.
+func (h *Handler) StartAIPreEvaluation(c *gin.Context) {
+	examID, err := primitive.ObjectIDFromHex(c.Param("examid"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid exam ID"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if !h.teacherOwnsExam(ctx, c.MustGet("container_id").(primitive.ObjectID), examID) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+		return
+	}
+
+	actor := withActor(c, models.AuditLog{})
+	key := idempotencyKey(c, jobAIPreEvaluation)
+	job, created, err := h.jobs.Enqueue(ctx, models.Job{
+		Kind:           jobAIPreEvaluation,
+		ExamID:         examID,
+		CreatedBy:      actor.ActorEmail,
+		IdempotencyKey: key,
+		UniqueKey:      jobAIPreEvaluation + ":" + examID.Hex(),
+	}, preEvaluationPayload{
+		ExamID:         examID,
+		ActorEmail:     actor.ActorEmail,
+		ActorRole:      actor.ActorRole,
+		ImpersonatedBy: actor.ImpersonatedBy,
+	})
+	if errors.Is(err, store.ErrConflict) {
+		c.JSON(http.StatusConflict, gin.H{"error": "A pre-evaluation was just started for this exam, try again"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start pre-evaluation"})
+		return
+	}
+	if !created {
+		if key != "" && job.IdempotencyKey == key {
+			c.JSON(http.StatusOK, gin.H{"message": "Pre-evaluation already requested", "job": job})
+			return
+		}
+		c.JSON(http.StatusConflict, gin.H{"error": "A pre-evaluation is already running for this exam", "job": job})
+		return
+	}
+
+	h.recordAudit(ctx, c, models.AuditLog{
+		Action:   "exam.ai_pre_evaluation_started",
+		TargetID: examID,
+		ExamID:   examID,
+		Details:  map[string]interface{}{"job_id": job.ID},
+	})
+
+	c.JSON(http.StatusAccepted, gin.H{"message": "Pre-evaluation started", "job": job})
+}
+
+// GetAIPreEvaluation returns the latest pre-evaluation job of an exam.
+func (h *Handler) GetAIPreEvaluation(c *gin.Context) {
+	examID, err := primitive.ObjectIDFromHex(c.Param("examid"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid exam ID"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if !h.teacherOwnsExam(ctx, c.MustGet("container_id").(primitive.ObjectID), examID) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+		return
+	}
+
+	jobs, err := h.store.Jobs.List(ctx, store.JobFilter{Kind: jobAIPreEvaluation, ExamID: &examID, Limit: 1})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch jobs"})
+		return
+	}
+	if len(jobs) == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "No pre-evaluation has been run for this exam"})
+		return
+	}
+	c.JSON(http.StatusOK, jobs[0])
+}
+
+// CancelAIPreEvaluation stops a pre-evaluation of the teacher's exam.
+// Suggestions already stored are kept.
+func (h *Handler) CancelAIPreEvaluation(c *gin.Context) {
+	jobID, err := primitive.ObjectIDFromHex(c.Param("jobid"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid job ID"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	job, err := h.store.Jobs.GetByID(ctx, jobID)
+	if err != nil || job.Kind != jobAIPreEvaluation {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Job not found"})
+		return
+	}
+	if !h.teacherOwnsExam(ctx, c.MustGet("container_id").(primitive.ObjectID), job.ExamID) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+		return
+	}
+
+	h.cancelJob(ctx, c, job)
+}
+
+// preEvaluateExam runs a pre-evaluation job. Questions that already have AI
+// feedback and finalized evaluations are skipped, so a retry only grades
+// what is still missing.
+func (h *Handler) preEvaluateExam(ctx context.Context, task *queue.Task) error {
+	var payload preEvaluationPayload
+	if err := task.Decode(&payload); err != nil {
+		return err
+	}
+	actor := models.AuditLog{
+		ActorEmail:     payload.ActorEmail,
+		ActorRole:      payload.ActorRole,
+		ImpersonatedBy: payload.ImpersonatedBy,
+		ExamID:         payload.ExamID,
+	}
+
+	tasks, err := h.preEvaluationTasks(ctx, payload.ExamID, actor)
+	if err != nil {
+		return err
+	}
+	calibration, err := h.examCalibration(ctx, payload.ExamID)
+	if err != nil {
+		return err
+	}
+	task.SetTotal(len(tasks))
+
+	ctx = withLLMCaller(ctx, llmCaller{
+		Email:   payload.ActorEmail,
+		Role:    payload.ActorRole,
+		ExamID:  payload.ExamID,
+		Purpose: purposePreEvaluation,
+	})
+	var (
+		mu        sync.Mutex
+		lastError error
+	)
+	batch.Pool(ctx, preEvaluationWorkers, len(tasks), func(ctx context.Context, i int) {
+		question := tasks[i]
+		// The client retries rate limits and outages itself, what is left
+		// failing is retried with the job
+		callCtx, cancel := context.WithTimeout(ctx, aiCallTimeout)
+		defer cancel()
+		grading, err := h.gradeAnswer(callCtx, question.examType, question.question, question.answers, false)
+		if err == nil {
+			grading.Calibrate(calibration)
+			dbCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+			defer cancel()
+			err = h.store.Evaluations.SetAISuggestion(dbCtx, question.evaluationID, question.index, grading)
+			// Finalized by the teacher in the meantime, nothing to suggest
+			if errors.Is(err, store.ErrNotFound) {
+				err = nil
+			}
+		}
+		if err != nil {
+			mu.Lock()
+			lastError = err
+			mu.Unlock()
+		}
+		task.StepDone(err)
+	})
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	progress := task.Progress()
+	auditCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	entry := actor
+	entry.Action = "exam.ai_pre_evaluation_finished"
+	entry.TargetID = payload.ExamID
+	entry.Details = map[string]interface{}{
+		"job_id": task.Job.ID, "attempt": task.Job.Attempts,
+		"total": progress.Total, "done": progress.Done, "failed": progress.Failed,
+	}
+	h.insertAudit(auditCtx, entry)
+
+	// Running again before the quota resets would fail the same way
+	var quota *QuotaExceededError
+	if errors.As(lastError, &quota) {
+		return queue.Permanent(fmt.Errorf("%d of %d questions failed: %w", progress.Failed, progress.Total, lastError))
+	}
+	if lastError != nil {
+		return fmt.Errorf("%d of %d questions failed, last error: %w", progress.Failed, progress.Total, lastError)
+	}
+	return nil
+}
+
+// preEvaluationTasks makes sure every submitted sheet of the exam has an
+// evaluation and lists the answered questions still missing AI feedback.
+func (h *Handler) preEvaluationTasks(ctx context.Context, examID primitive.ObjectID, actor models.AuditLog) ([]preEvaluationTask, error) {
+	dbCtx, cancel := context.WithTimeout(ctx, time.Minute)
+	defer cancel()
+
+	sheets, err := h.store.AnswerSheets.List(dbCtx, store.AnswerSheetFilter{
+		ExamID:   &examID,
+		Statuses: []models.SheetStatus{models.SheetSubmitted, models.SheetExpired},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var tasks []preEvaluationTask
+	for i := range sheets {
+		sheet := &sheets[i]
+		evaluation, err := h.store.Evaluations.GetByAnswerSheet(dbCtx, sheet.ID)
+		if errors.Is(err, store.ErrNotFound) {
+			evaluation, err = h.createEvaluation(dbCtx, sheet)
+			if err == nil {
+				entry := actor
+				entry.Action = "evaluation.created"
+				entry.TargetID = evaluation.ID
+				entry.StudentEmail = evaluation.Email
+				entry.Details = map[string]interface{}{"answer_sheet_id": sheet.ID, "source": "ai_pre_evaluation"}
+				h.insertAudit(dbCtx, entry)
+			}
+		}
+		if err != nil {
+			return nil, err
+		}
+		if evaluation.Evaluated {
+			continue
+		}
+		keys, err := h.answerKeys(dbCtx, evaluation)
+		if err != nil {
+			return nil, err
+		}
+
+		for index, question := range evaluation.Data {
+			if question.AIEvaluation != "" || !hasAnswer(question.Answers) {
+				continue
+			}
+			tasks = append(tasks, preEvaluationTask{
+				evaluationID: evaluation.ID,
+				index:        index,
+				examType:     evaluation.ExamType,
+				question:     keyedQuestion(keys, question.Question),
+				answers:      question.Answers,
+			})
+		}
+	}
+	return tasks, nil
+}
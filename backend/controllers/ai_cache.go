@@ -0,0 +1,84 @@
+package controllers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Maheshkarri4444/Examify/models"
+	"github.com/Maheshkarri4444/Examify/store"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// gradingCacheKey hashes everything a grading depends on: the template, its
+// body too so a changed builtin one doesn't hit old entries, the model, the
+// question with its answer key and the normalized answers.
+func gradingCacheKey(tmpl models.PromptTemplate, model string, data gradingPromptData) string {
+	answers := make([]models.Answer, len(data.Answers))
+	for i, answer := range data.Answers {
+		answers[i] = models.Answer{Type: strings.ToLower(answer.Type), Ans: normalizeAnswer(answer.Ans)}
+	}
+	key, _ := json.Marshal(struct {
+		Template, Body, Model                       string
+		Version                                     int
+		ExamType, Question, Level, Reference, Notes string
+		Answers                                     []models.Answer
+	}{
+		tmpl.Name, tmpl.Body, model, tmpl.Version,
+		data.ExamType, data.Question, data.Level, data.ReferenceAnswer, data.GradingNotes,
+		answers,
+	})
+	sum := sha256.Sum256(key)
+	return hex.EncodeToString(sum[:])
+}
+
+// normalizeAnswer drops what doesn't change an answer: line endings,
+// trailing spaces and blank lines. Indentation stays, it matters in Python.
+func normalizeAnswer(ans string) string {
+	var lines []string
+	for _, line := range strings.Split(strings.ReplaceAll(ans, "\r\n", "\n"), "\n") {
+		if line = strings.TrimRight(line, " \t\r"); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// cachedGrading returns the grading stored under key, nil on a miss. A
+// failing cache only costs a model call.
+func (h *Handler) cachedGrading(ctx context.Context, key string) *models.AIGrading {
+	if h.cfg.AI.CacheTTL <= 0 {
+		return nil
+	}
+	entry, err := h.store.AICache.Get(ctx, key)
+	if err != nil {
+		if !errors.Is(err, store.ErrNotFound) {
+			fmt.Println("ai cache error: ", err)
+		}
+		return nil
+	}
+	grading := entry.Grading
+	grading.Cached = true
+	return &grading
+}
+
+func (h *Handler) cacheGrading(ctx context.Context, key string, grading *models.AIGrading) {
+	if h.cfg.AI.CacheTTL <= 0 {
+		return
+	}
+	t := time.Now()
+	err := h.store.AICache.Put(ctx, &models.AIGradingCacheEntry{
+		Key:       key,
+		Grading:   *grading,
+		CreatedAt: primitive.NewDateTimeFromTime(t),
+		ExpiresAt: primitive.NewDateTimeFromTime(t.Add(h.cfg.AI.CacheTTL)),
+	})
+	if err != nil {
+		fmt.Println("ai cache error: ", err)
+	}
+}
@@ -0,0 +1,100 @@
+package controllers
+
+import (
+	"testing"
+
+	"github.com/Maheshkarri4444/Examify/models"
+)
+
+func TestNormalizeAnswer(t *testing.T) {
+	tests := []struct {
+		name string
+		ans  string
+		want string
+	}{
+		{name: "unchanged", ans: "def f(x):\n    return x", want: "def f(x):\n    return x"},
+		{name: "windows line endings", ans: "a\r\nb\r\n", want: "a\nb"},
+		{name: "lone carriage return at a line end", ans: "a\r\nb\r", want: "a\nb"},
+		{name: "trailing spaces and tabs", ans: "a  \t\nb\t", want: "a\nb"},
+		{name: "blank lines", ans: "\n\na\n   \n\nb\n\n", want: "a\nb"},
+		{name: "indentation stays", ans: "if x:\n\tpass\n  y", want: "if x:\n\tpass\n  y"},
+		{name: "spaces inside a line stay", ans: "a  =  1", want: "a  =  1"},
+		{name: "only whitespace", ans: " \r\n\t\n", want: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := normalizeAnswer(tt.ans); got != tt.want {
+				t.Errorf("normalizeAnswer(%q) = %q, want %q", tt.ans, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGradingCacheKey(t *testing.T) {
+	baseTemplate := models.PromptTemplate{Name: "grading", Version: 1, Body: "Grade {{.Question}}"}
+	base := func() gradingPromptData {
+		return gradingPromptData{
+			ExamType:        models.ExamInternal,
+			Question:        "Reverse a list",
+			Level:           "easy",
+			ReferenceAnswer: "return xs[::-1]",
+			GradingNotes:    "Any approach",
+			AnswerType:      "python",
+			Answers: []models.Answer{
+				{Type: "python", Ans: "def rev(xs):\n    return xs[::-1]"},
+				{Type: "explanation", Ans: "Slicing backwards"},
+			},
+		}
+	}
+	want := gradingCacheKey(baseTemplate, "test-model", base())
+
+	tests := []struct {
+		name     string
+		tmpl     func(*models.PromptTemplate)
+		model    string
+		data     func(*gradingPromptData)
+		wantSame bool
+	}{
+		{name: "identical", wantSame: true},
+		{
+			name:     "windows line endings and trailing spaces",
+			data:     func(d *gradingPromptData) { d.Answers[0].Ans = "def rev(xs):  \r\n\r\n    return xs[::-1]\r\n" },
+			wantSame: true,
+		},
+		{
+			name:     "answer type case",
+			data:     func(d *gradingPromptData) { d.Answers[0].Type = "Python" },
+			wantSame: true,
+		},
+		{name: "indentation", data: func(d *gradingPromptData) { d.Answers[0].Ans = "def rev(xs):\n  return xs[::-1]" }},
+		{name: "answer text", data: func(d *gradingPromptData) { d.Answers[1].Ans = "Slicing forwards" }},
+		{name: "answer order", data: func(d *gradingPromptData) { d.Answers[0], d.Answers[1] = d.Answers[1], d.Answers[0] }},
+		{name: "missing answer", data: func(d *gradingPromptData) { d.Answers = d.Answers[:1] }},
+		{name: "question", data: func(d *gradingPromptData) { d.Question = "Reverse a string" }},
+		{name: "level", data: func(d *gradingPromptData) { d.Level = "hard" }},
+		{name: "reference answer", data: func(d *gradingPromptData) { d.ReferenceAnswer = "return list(reversed(xs))" }},
+		{name: "grading notes", data: func(d *gradingPromptData) { d.GradingNotes = "Slicing only" }},
+		{name: "exam type", data: func(d *gradingPromptData) { d.ExamType = models.ExamExternal }},
+		{name: "model", model: "other-model"},
+		{name: "template version", tmpl: func(tmpl *models.PromptTemplate) { tmpl.Version = 2 }},
+		{name: "template body", tmpl: func(tmpl *models.PromptTemplate) { tmpl.Body = "Grade strictly {{.Question}}" }},
+		{name: "template name", tmpl: func(tmpl *models.PromptTemplate) { tmpl.Name = "grading_python" }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpl, model, data := baseTemplate, "test-model", base()
+			if tt.tmpl != nil {
+				tt.tmpl(&tmpl)
+			}
+			if tt.model != "" {
+				model = tt.model
+			}
+			if tt.data != nil {
+				tt.data(&data)
+			}
+			if got := gradingCacheKey(tmpl, model, data); (got == want) != tt.wantSame {
+				t.Errorf("key = %s, base key = %s, want them the same: %v", got, want, tt.wantSame)
+			}
+		})
+	}
+}
@@ -0,0 +1,272 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"math"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/Maheshkarri4444/Examify/models"
+	"github.com/Maheshkarri4444/Examify/store"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// minCalibrationPairs is how many graded questions it takes before a
+// calibration is suggested, fewer say more about the students than the AI.
+const minCalibrationPairs = 10
+
+// agreementStats compares AI percentages with the teacher's marks, both
+// out of 100.
+type agreementStats struct {
+	Count int     `json:"count"`
+	MAE   float64 `json:"mean_absolute_error"`
+	// Bias is the mean of AI minus teacher, positive when the AI is more
+	// generous.
+	Bias float64 `json:"bias"`
+	// Correlation is Pearson's, missing while either side doesn't vary.
+	Correlation *float64 `json:"correlation,omitempty"`
+}
+
+type agreement struct {
+	ai, teacher []float64
+}
+
+func (a *agreement) add(ai, teacher float64) {
+	a.ai = append(a.ai, ai)
+	a.teacher = append(a.teacher, teacher)
+}
+
+func (a *agreement) stats() agreementStats {
+	stats := agreementStats{Count: len(a.ai)}
+	if stats.Count == 0 {
+		return stats
+	}
+	n := float64(stats.Count)
+	var absErr, diff float64
+	for i := range a.ai {
+		absErr += math.Abs(a.ai[i] - a.teacher[i])
+		diff += a.ai[i] - a.teacher[i]
+	}
+	stats.MAE = round2(absErr / n)
+	stats.Bias = round2(diff / n)
+
+	_, _, covariance, varAI, varTeacher := a.moments()
+	if varAI > 0 && varTeacher > 0 {
+		r := round2(covariance / math.Sqrt(varAI*varTeacher))
+		stats.Correlation = &r
+	}
+	return stats
+}
+
+// moments returns the means, the covariance and the variances of the pairs.
+func (a *agreement) moments() (meanAI, meanTeacher, covariance, varAI, varTeacher float64) {
+	n := float64(len(a.ai))
+	for i := range a.ai {
+		meanAI += a.ai[i]
+		meanTeacher += a.teacher[i]
+	}
+	meanAI /= n
+	meanTeacher /= n
+	for i := range a.ai {
+		dAI, dTeacher := a.ai[i]-meanAI, a.teacher[i]-meanTeacher
+		covariance += dAI * dTeacher
+		varAI += dAI * dAI
+		varTeacher += dTeacher * dTeacher
+	}
+	return meanAI, meanTeacher, covariance / n, varAI / n, varTeacher / n
+}
+
+// fit is the least squares line from AI percentages to teacher marks, the
+// calibration that would have matched the teacher best. It is nil with too
+// few pairs or when the AI's scores don't go up with the teacher's.
+func (a *agreement) fit() *models.AICalibration {
+	if len(a.ai) < minCalibrationPairs {
+		return nil
+	}
+	meanAI, meanTeacher, covariance, varAI, _ := a.moments()
+	if varAI == 0 || covariance <= 0 {
+		return nil
+	}
+	scale := covariance / varAI
+	return &models.AICalibration{Scale: round2(scale), Offset: round2(meanTeacher - scale*meanAI)}
+}
+
+func round2(v float64) float64 {
+	return math.Round(v*100) / 100
+}
+
+// GetAICalibrationReport compares the AI's suggestions with the marks of the
+// exam's finalized evaluations: for whole answer sheets, the score the AI
+// gave at submission against the teacher's percentage, and for single
+// questions, the AI's grading against the marks. Question scores are taken
+// before calibration, so the report keeps describing the AI itself.
+func (h *Handler) GetAICalibrationReport(c *gin.Context) {
+	examID, err := primitive.ObjectIDFromHex(c.Param("examid"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid exam ID"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if !h.teacherOwnsExam(ctx, c.MustGet("container_id").(primitive.ObjectID), examID) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+		return
+	}
+	exam, err := h.store.Exams.GetByID(ctx, examID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Exam not found"})
+		return
+	}
+
+	evaluated := true
+	evaluations, err := h.store.Evaluations.List(ctx, store.EvaluationFilter{ExamID: &examID, Evaluated: &evaluated})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch evaluations"})
+		return
+	}
+
+	var sheets, questions agreement
+	byQuestion := map[string]*agreement{}
+	byType := map[string]*agreement{}
+	for _, evaluation := range evaluations {
+		if evaluation.AIScore != nil && len(evaluation.Data) > 0 {
+			sheets.add(*evaluation.AIScore, float64(evaluation.TotalMarks)/float64(len(evaluation.Data)))
+		}
+
+		for _, item := range evaluation.Data {
+			var ai float64
+			switch {
+			case item.AIGrading != nil:
+				ai = float64(item.AIGrading.UncalibratedScore())
+			case item.AIScore != nil:
+				ai = float64(*item.AIScore)
+			default:
+				continue
+			}
+			teacher := float64(item.Marks)
+			questions.add(ai, teacher)
+
+			if byQuestion[item.Question] == nil {
+				byQuestion[item.Question] = &agreement{}
+			}
+			byQuestion[item.Question].add(ai, teacher)
+			for _, answer := range item.Answers {
+				if byType[answer.Type] == nil {
+					byType[answer.Type] = &agreement{}
+				}
+				byType[answer.Type].add(ai, teacher)
+			}
+		}
+	}
+
+	type questionStats struct {
+		Question string `json:"question"`
+		agreementStats
+	}
+	questionReport := make([]questionStats, 0, len(byQuestion))
+	for question, a := range byQuestion {
+		questionReport = append(questionReport, questionStats{Question: question, agreementStats: a.stats()})
+	}
+	// Worst first, that is where the grading notes need work
+	sort.Slice(questionReport, func(i, j int) bool {
+		if questionReport[i].MAE != questionReport[j].MAE {
+			return questionReport[i].MAE > questionReport[j].MAE
+		}
+		return questionReport[i].Question < questionReport[j].Question
+	})
+
+	type typeStats struct {
+		Type string `json:"type"`
+		agreementStats
+	}
+	typeReport := make([]typeStats, 0, len(byType))
+	for t, a := range byType {
+		typeReport = append(typeReport, typeStats{Type: t, agreementStats: a.stats()})
+	}
+	sort.Slice(typeReport, func(i, j int) bool { return typeReport[i].Type < typeReport[j].Type })
+
+	c.JSON(http.StatusOK, gin.H{
+		"exam_id":               examID,
+		"evaluations":           len(evaluations),
+		"calibration":           exam.AICalibration,
+		"suggested_calibration": questions.fit(),
+		"sheets":                sheets.stats(),
+		"questions":             questions.stats(),
+		"by_question":           questionReport,
+		"by_type":               typeReport,
+	})
+}
+
+// SetAICalibration sets the scale and offset applied to the AI's future
+// suggestions for the exam. A scale of 1 with no offset removes it.
+// Suggestions already stored keep their score.
+func (h *Handler) SetAICalibration(c *gin.Context) {
+	examID, err := primitive.ObjectIDFromHex(c.Param("examid"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid exam ID"})
+		return
+	}
+
+	var request struct {
+		Scale  float64 `json:"scale" binding:"required,gt=0,lte=5"`
+		Offset float64 `json:"offset" binding:"gte=-100,lte=100"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if !h.teacherOwnsExam(ctx, c.MustGet("container_id").(primitive.ObjectID), examID) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+		return
+	}
+	exam, err := h.store.Exams.GetByID(ctx, examID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Exam not found"})
+		return
+	}
+
+	var calibration *models.AICalibration
+	if request.Scale != 1 || request.Offset != 0 {
+		calibration = &models.AICalibration{
+			Scale:     request.Scale,
+			Offset:    request.Offset,
+			UpdatedBy: c.MustGet("email").(string),
+			UpdatedAt: primitive.NewDateTimeFromTime(time.Now()),
+		}
+	}
+	if err := h.store.Exams.SetAICalibration(ctx, examID, calibration); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save calibration"})
+		return
+	}
+
+	h.recordAudit(ctx, c, models.AuditLog{
+		Action:   "exam.ai_calibration_updated",
+		TargetID: examID,
+		ExamID:   examID,
+		Changes:  map[string]models.AuditChange{"ai_calibration": {Before: exam.AICalibration, After: calibration}},
+	})
+
+	c.JSON(http.StatusOK, gin.H{"message": "Calibration saved", "calibration": calibration})
+}
+
+// examCalibration returns the calibration of an exam, nil when it has none
+// or is gone.
+func (h *Handler) examCalibration(ctx context.Context, examID primitive.ObjectID) (*models.AICalibration, error) {
+	exam, err := h.store.Exams.GetByID(ctx, examID)
+	if errors.Is(err, store.ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return exam.AICalibration, nil
+}
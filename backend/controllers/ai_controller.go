@@ -1,109 +1,242 @@
 package controllers
 
 import (
-	"bytes"
 	"context"
 	"encoding/json"
-	"fmt"
-	"io/ioutil"
+	"errors"
 	"net/http"
-	"os"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/Maheshkarri4444/Examify/llm"
+	"github.com/Maheshkarri4444/Examify/store"
 	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
 type ChatRequest struct {
-	Prompt      string   `json:"prompt" binding:"required"`
+	Prompt      string   `json:"prompt" binding:"required,max=20000"`
 	ChatHistory []string `json:"chatHistory"`
+	// ExamID charges the call to an exam's quota as well.
+	ExamID string `json:"exam_id"`
 }
 
 type ChatResponse struct {
 	Response string `json:"response"`
 }
 
-func RunChat(prompt string, chatHistory []string) (string, error) {
-	apiKey := os.Getenv("GEMINI_API_KEY")
-	if apiKey == "" {
-		return "", fmt.Errorf("API key is missing")
+// aiRepairs is how often malformed JSON output is sent back to the model,
+// together with what is wrong with it, before giving up.
+const aiRepairs = 2
+
+// InvalidAIOutputError is JSON output that was still malformed after the
+// repair attempts.
+type InvalidAIOutputError struct {
+	Problems []string
+}
+
+func (e *InvalidAIOutputError) Error() string {
+	return "invalid AI output: " + strings.Join(e.Problems, "; ")
+}
+
+// geminiModel answers every AI request.
+const geminiModel = "gemini-2.0-flash"
+
+// RunChat answers a free form prompt.
+func (h *Handler) RunChat(ctx context.Context, prompt string, chatHistory []string) (string, error) {
+	return h.generate(ctx, prompt, nil)
+}
+
+// generateValid asks for JSON following schema and checks it with parse,
+// which returns what is wrong with the output, if anything. Invalid output
+// is sent back to the model with its problems up to aiRepairs times.
+func generateValid[T any](ctx context.Context, h *Handler, prompt string, schema map[string]interface{}, parse func(output string) (T, []string)) (T, error) {
+	request := prompt
+	var problems []string
+	for attempt := 0; attempt <= aiRepairs; attempt++ {
+		output, err := h.generate(ctx, request, schema)
+		if err != nil {
+			var zero T
+			return zero, err
+		}
+		var value T
+		if value, problems = parse(output); problems == nil {
+			return value, nil
+		}
+		request = repairPrompt(prompt, output, problems)
 	}
+	var zero T
+	return zero, &InvalidAIOutputError{Problems: problems}
+}
 
-	// Use the correct model: gemini-2.0-flash
-	url := fmt.Sprintf("https://generativelanguage.googleapis.com/v1/models/gemini-2.0-flash:generateContent?key=%s", apiKey)
+// repairPrompt sends invalid output back to the model with what is wrong.
+func repairPrompt(prompt, output string, problems []string) string {
+	var b strings.Builder
+	b.WriteString(prompt)
+	b.WriteString("\n\nYour previous response was:\n")
+	b.WriteString(output)
+	b.WriteString("\n\nIt is not valid because:\n")
+	for _, problem := range problems {
+		b.WriteString("- " + problem + "\n")
+	}
+	b.WriteString("\nRespond again with corrected JSON only.")
+	return b.String()
+}
+
+// decodeAIObject decodes the JSON object in output into v, ignoring any
+// text the model put around it. It returns the problem if there is one.
+func decodeAIObject(output string, v interface{}) string {
+	start, end := strings.Index(output, "{"), strings.LastIndex(output, "}")
+	if start < 0 || end < start {
+		return "the response is not a JSON object"
+	}
+	if err := json.Unmarshal([]byte(output[start:end+1]), v); err != nil {
+		return "the response is not valid JSON: " + err.Error()
+	}
+	return ""
+}
 
-	// Prepare JSON payload
-	requestBody := map[string]interface{}{
-		"contents": []map[string]interface{}{
-			{
-				"role":  "user",
-				"parts": []map[string]string{{"text": prompt}},
-			},
-		},
+// aiFailed answers a failed AI call with the status that says what went
+// wrong: 429 for a used up quota, 503 while the provider is unavailable, 504
+// on timeouts, 422 when the safety filters blocked the answer and 502 when
+// the provider failed or answered nonsense. Anything else, like a failing
+// store, is a 500 with message.
+func (h *Handler) aiFailed(c *gin.Context, err error, message string) {
+	if quotaExceeded(c, err) {
+		return
+	}
+	var (
+		invalid     *InvalidAIOutputError
+		blocked     *llm.BlockedError
+		providerErr *llm.ProviderError
+		networkErr  *llm.NetworkError
+	)
+	switch {
+	case errors.Is(err, llm.ErrCircuitOpen):
+		c.Header("Retry-After", strconv.Itoa(int(h.cfg.AI.BreakerCooldown.Seconds())+1))
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "The AI is unavailable right now, try again shortly"})
+	case errors.Is(err, llm.ErrNotConfigured):
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "AI is not configured on this server"})
+	case errors.As(err, &providerErr) && providerErr.StatusCode == http.StatusTooManyRequests:
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "The AI is busy, try again shortly"})
+	case errors.Is(err, llm.ErrTimeout), errors.Is(err, context.DeadlineExceeded):
+		c.JSON(http.StatusGatewayTimeout, gin.H{"error": "The AI took too long to answer, try again"})
+	case errors.As(err, &blocked):
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "The AI's safety filters blocked this request", "reason": blocked.Reason})
+	case errors.As(err, &invalid):
+		c.JSON(http.StatusBadGateway, gin.H{"error": message, "problems": invalid.Problems})
+	case errors.As(err, &providerErr), errors.As(err, &networkErr),
+		errors.Is(err, llm.ErrEmptyResponse), errors.Is(err, llm.ErrTruncated), errors.Is(err, llm.ErrMalformedResponse):
+		c.JSON(http.StatusBadGateway, gin.H{"error": message})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": message})
 	}
+}
 
-	jsonData, err := json.Marshal(requestBody)
-	if err != nil {
-		return "", fmt.Errorf("failed to encode request body: %w", err)
+func (h *Handler) GetChatResponse(c *gin.Context) {
+	var request ChatRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Prompt is required"})
+		return
+	}
+	var examID primitive.ObjectID
+	if request.ExamID != "" {
+		var err error
+		if examID, err = primitive.ObjectIDFromHex(request.ExamID); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid exam ID"})
+			return
+		}
 	}
 
-	// Make HTTP request
-	req, err := http.NewRequestWithContext(context.Background(), "POST", url, bytes.NewBuffer(jsonData))
+	ctx, cancel := context.WithTimeout(withLLMCaller(c.Request.Context(), requestCaller(c, examID, purposeChat)), aiCallTimeout)
+	defer cancel()
+	responseText, err := h.RunChat(ctx, request.Prompt, request.ChatHistory)
 	if err != nil {
-		return "", fmt.Errorf("failed to create request: %w", err)
+		h.aiFailed(c, err, "Failed to get a response from the AI")
+		return
 	}
 
-	req.Header.Set("Content-Type", "application/json")
+	c.JSON(http.StatusOK, ChatResponse{Response: responseText})
+}
 
-	client := &http.Client{}
-	resp, err := client.Do(req)
+// GradeQuestionWithAI asks the AI for a structured grading of one question
+// of an evaluation and stores it as a suggestion next to the question. The
+// grading of an identical answer is reused unless ?fresh=true.
+func (h *Handler) GradeQuestionWithAI(c *gin.Context) {
+	evaluationID, err := primitive.ObjectIDFromHex(c.Param("evaluationid"))
 	if err != nil {
-		return "", fmt.Errorf("request failed: %w", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid evaluation ID"})
+		return
 	}
-	defer resp.Body.Close()
-
-	// Read response
-	body, err := ioutil.ReadAll(resp.Body)
+	index, err := strconv.Atoi(c.Param("index"))
 	if err != nil {
-		return "", fmt.Errorf("failed to read response body: %w", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid question index"})
+		return
 	}
 
-	// Log the full API response for debugging
-	// fmt.Println("API Response:", string(body))
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
 
-	// Correct the response parsing
-	var responseMap map[string]interface{}
-	err = json.Unmarshal(body, &responseMap)
+	evaluation, err := h.store.Evaluations.GetByID(ctx, evaluationID)
 	if err != nil {
-		return "", fmt.Errorf("failed to parse response: %w", err)
-	}
-
-	// Check if 'candidates' exist in the response
-	if candidates, ok := responseMap["candidates"].([]interface{}); ok && len(candidates) > 0 {
-		if candidate, ok := candidates[0].(map[string]interface{}); ok {
-			if content, ok := candidate["content"].(map[string]interface{}); ok {
-				if parts, ok := content["parts"].([]interface{}); ok && len(parts) > 0 {
-					if text, ok := parts[0].(map[string]interface{})["text"].(string); ok {
-						return text, nil
-					}
-				}
-			}
-		}
+		c.JSON(http.StatusNotFound, gin.H{"error": "Evaluation not found"})
+		return
+	}
+	if !h.teacherOwnsExam(ctx, c.MustGet("container_id").(primitive.ObjectID), evaluation.ExamID) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+		return
+	}
+	if evaluation.Evaluated {
+		c.JSON(http.StatusConflict, gin.H{"error": "Evaluation has already been finalized"})
+		return
+	}
+	if index < 0 || index >= len(evaluation.Data) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid question index"})
+		return
+	}
+	question := evaluation.Data[index]
+	if !hasAnswer(question.Answers) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "The question was not answered"})
+		return
 	}
 
-	return "No valid response from AI", nil
-}
+	keys, err := h.answerKeys(ctx, evaluation)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch the answer key"})
+		return
+	}
+	calibration, err := h.examCalibration(ctx, evaluation.ExamID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch exam"})
+		return
+	}
 
-func GetChatResponse(c *gin.Context) {
-	var request ChatRequest
-	if err := c.ShouldBindJSON(&request); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Prompt is required"})
+	aiCtx, cancelAI := context.WithTimeout(withLLMCaller(c.Request.Context(), requestCaller(c, evaluation.ExamID, purposeGrading)), aiCallTimeout)
+	defer cancelAI()
+	grading, err := h.gradeAnswer(aiCtx, evaluation.ExamType, keyedQuestion(keys, question.Question), question.Answers, c.Query("fresh") == "true")
+	var invalid *InvalidAIOutputError
+	if errors.As(err, &invalid) {
+		c.JSON(http.StatusBadGateway, gin.H{"error": "The AI did not return a valid grading, try again", "problems": invalid.Problems})
+		return
+	}
+	if err != nil {
+		h.aiFailed(c, err, "Failed to get AI grading")
 		return
 	}
+	grading.Calibrate(calibration)
 
-	responseText, err := RunChat(request.Prompt, request.ChatHistory)
+	saveCtx, cancelSave := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancelSave()
+	err = h.store.Evaluations.SetAISuggestion(saveCtx, evaluationID, index, grading)
+	if errors.Is(err, store.ErrNotFound) {
+		c.JSON(http.StatusConflict, gin.H{"error": "Evaluation has already been finalized"})
+		return
+	}
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal Server Error"})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save AI grading"})
 		return
 	}
 
-	c.JSON(http.StatusOK, ChatResponse{Response: responseText})
+	c.JSON(http.StatusOK, grading)
 }
@@ -0,0 +1,205 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/Maheshkarri4444/Examify/models"
+	"github.com/Maheshkarri4444/Examify/store"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// aiGradingSchema is the JSON a grading answer has to follow, in the
+// OpenAPI subset Gemini accepts as responseSchema.
+var aiGradingSchema = map[string]interface{}{
+	"type": "OBJECT",
+	"properties": map[string]interface{}{
+		"score": map[string]interface{}{"type": "INTEGER", "description": "Percentage from 0 to 100, the sum of the criteria points"},
+		"criteria": map[string]interface{}{
+			"type": "ARRAY",
+			"items": map[string]interface{}{
+				"type": "OBJECT",
+				"properties": map[string]interface{}{
+					"name":       map[string]interface{}{"type": "STRING"},
+					"points":     map[string]interface{}{"type": "NUMBER"},
+					"max_points": map[string]interface{}{"type": "NUMBER"},
+					"comment":    map[string]interface{}{"type": "STRING"},
+				},
+				"required": []string{"name", "points", "max_points"},
+			},
+		},
+		"feedback":   map[string]interface{}{"type": "STRING", "description": "2-3 sentences for the teacher"},
+		"confidence": map[string]interface{}{"type": "NUMBER", "description": "How sure the grading is, from 0 to 1"},
+	},
+	"required": []string{"score", "criteria", "feedback", "confidence"},
+}
+
+// aiGradingOutput mirrors aiGradingSchema. Pointers tell missing numbers
+// from zeros.
+type aiGradingOutput struct {
+	Score      *float64            `json:"score"`
+	Criteria   []aiCriterionOutput `json:"criteria"`
+	Feedback   string              `json:"feedback"`
+	Confidence *float64            `json:"confidence"`
+}
+
+type aiCriterionOutput struct {
+	Name      string   `json:"name"`
+	Points    *float64 `json:"points"`
+	MaxPoints *float64 `json:"max_points"`
+	Comment   string   `json:"comment"`
+}
+
+// gradeAnswer asks the AI to grade an answer with the prompt template that
+// fits the exam and answer type, and validates the result. An identical
+// answer graded before is answered from the cache unless fresh is set; a
+// fresh grading replaces the cached one.
+func (h *Handler) gradeAnswer(ctx context.Context, examType string, question models.Question, answers []models.Answer, fresh bool) (*models.AIGrading, error) {
+	data := gradingData(examType, question, answers)
+	tmpl, err := h.gradingTemplate(ctx, examType, data.AnswerType)
+	if err != nil {
+		return nil, err
+	}
+	key := gradingCacheKey(tmpl, h.ai.Model(), data)
+	if !fresh {
+		if grading := h.cachedGrading(ctx, key); grading != nil {
+			return grading, nil
+		}
+	}
+
+	prompt, err := renderGradingPrompt(tmpl, data)
+	if err != nil {
+		return nil, err
+	}
+	grading, err := generateValid(ctx, h, prompt, aiGradingSchema, parseAIGrading)
+	if err != nil {
+		return nil, err
+	}
+	grading.Model = h.ai.Model()
+	grading.Template = tmpl.Name
+	grading.TemplateVersion = tmpl.Version
+	grading.GradedAt = primitive.NewDateTimeFromTime(time.Now())
+	h.cacheGrading(ctx, key, grading)
+	return grading, nil
+}
+
+// parseAIGrading validates grading output against aiGradingSchema and the
+// rules of the prompt. Small slips are repaired instead of reported: text
+// around the JSON, a confidence given as a percentage and a score that
+// doesn't match the criteria, which are the more specific of the two.
+func parseAIGrading(output string) (*models.AIGrading, []string) {
+	var out aiGradingOutput
+	if problem := decodeAIObject(output, &out); problem != "" {
+		return nil, []string{problem}
+	}
+
+	var problems []string
+	grading := &models.AIGrading{Feedback: strings.TrimSpace(out.Feedback)}
+	if grading.Feedback == "" {
+		problems = append(problems, "feedback is missing")
+	}
+
+	if len(out.Criteria) == 0 {
+		problems = append(problems, "criteria are missing")
+	}
+	var points, maxPoints float64
+	for i, criterion := range out.Criteria {
+		name := strings.TrimSpace(criterion.Name)
+		switch {
+		case name == "":
+			problems = append(problems, fmt.Sprintf("criteria[%d].name is missing", i))
+		case criterion.MaxPoints == nil || *criterion.MaxPoints <= 0:
+			problems = append(problems, fmt.Sprintf("criteria[%d].max_points must be positive", i))
+		case criterion.Points == nil || *criterion.Points < 0 || *criterion.Points > *criterion.MaxPoints:
+			problems = append(problems, fmt.Sprintf("criteria[%d].points must be between 0 and max_points", i))
+		default:
+			points += *criterion.Points
+			maxPoints += *criterion.MaxPoints
+			grading.Criteria = append(grading.Criteria, models.AICriterion{
+				Name:      name,
+				Points:    *criterion.Points,
+				MaxPoints: *criterion.MaxPoints,
+				Comment:   strings.TrimSpace(criterion.Comment),
+			})
+		}
+	}
+	if len(out.Criteria) > 0 && len(grading.Criteria) == len(out.Criteria) && math.Abs(maxPoints-100) > 0.5 {
+		problems = append(problems, fmt.Sprintf("the max_points of the criteria add up to %g instead of 100", maxPoints))
+	}
+
+	if out.Score == nil {
+		problems = append(problems, "score is missing")
+	} else if *out.Score < 0 || *out.Score > 100 {
+		problems = append(problems, "score must be between 0 and 100")
+	}
+
+	switch {
+	case out.Confidence == nil:
+		problems = append(problems, "confidence is missing")
+	case *out.Confidence > 1 && *out.Confidence <= 100:
+		grading.Confidence = *out.Confidence / 100
+	case *out.Confidence < 0 || *out.Confidence > 100:
+		problems = append(problems, "confidence must be between 0 and 1")
+	default:
+		grading.Confidence = *out.Confidence
+	}
+
+	if problems != nil {
+		return nil, problems
+	}
+	grading.Score = int(math.Round(points))
+	return grading, nil
+}
+
+// answerKeys returns the questions an evaluation was taken from, by question
+// text, so the grader gets their reference answers and grading notes. They
+// come from the question paper, or the exam for vivas which have none. A
+// missing paper or exam only means grading without keys.
+func (h *Handler) answerKeys(ctx context.Context, evaluation *models.Evaluation) (map[string]models.Question, error) {
+	var questions []models.Question
+	if evaluation.QPaperID.IsZero() {
+		exam, err := h.store.Exams.GetByID(ctx, evaluation.ExamID)
+		if err != nil && !errors.Is(err, store.ErrNotFound) {
+			return nil, err
+		}
+		if exam != nil {
+			questions = exam.Questions
+		}
+	} else {
+		paper, err := h.store.Exams.GetQuestionPaper(ctx, evaluation.QPaperID)
+		if err != nil && !errors.Is(err, store.ErrNotFound) {
+			return nil, err
+		}
+		if paper != nil {
+			questions = paper.Questions
+		}
+	}
+
+	keys := make(map[string]models.Question, len(questions))
+	for _, q := range questions {
+		keys[q.Question] = q
+	}
+	return keys, nil
+}
+
+// keyedQuestion is the question of an evaluation with its answer key, if
+// keys has one.
+func keyedQuestion(keys map[string]models.Question, question string) models.Question {
+	if q, ok := keys[question]; ok {
+		return q
+	}
+	return models.Question{Question: question}
+}
+
+func hasAnswer(answers []models.Answer) bool {
+	for _, answer := range answers {
+		if strings.TrimSpace(answer.Ans) != "" {
+			return true
+		}
+	}
+	return false
+}
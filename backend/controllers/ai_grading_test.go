@@ -0,0 +1,181 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/Maheshkarri4444/Examify/llm"
+	"github.com/Maheshkarri4444/Examify/models"
+)
+
+const validGrading = `{
+	"score": 70,
+	"criteria": [
+		{"name": "correctness", "points": 50, "max_points": 60, "comment": " mostly right "},
+		{"name": "style", "points": 20.4, "max_points": 40}
+	],
+	"feedback": " Handles the empty list too. ",
+	"confidence": 0.8
+}`
+
+func TestParseAIGrading(t *testing.T) {
+	tests := []struct {
+		name         string
+		output       string
+		want         *models.AIGrading
+		wantProblems []string
+	}{
+		{
+			name:   "valid",
+			output: validGrading,
+			want: &models.AIGrading{
+				Score: 70,
+				Criteria: []models.AICriterion{
+					{Name: "correctness", Points: 50, MaxPoints: 60, Comment: "mostly right"},
+					{Name: "style", Points: 20.4, MaxPoints: 40},
+				},
+				Feedback:   "Handles the empty list too.",
+				Confidence: 0.8,
+			},
+		},
+		{
+			name:   "text around the JSON",
+			output: "Here is the grading:\n```json\n" + validGrading + "\n```",
+			want: &models.AIGrading{
+				Score: 70,
+				Criteria: []models.AICriterion{
+					{Name: "correctness", Points: 50, MaxPoints: 60, Comment: "mostly right"},
+					{Name: "style", Points: 20.4, MaxPoints: 40},
+				},
+				Feedback:   "Handles the empty list too.",
+				Confidence: 0.8,
+			},
+		},
+		{
+			name:   "confidence as a percentage, score from the criteria",
+			output: `{"score": 12, "criteria": [{"name": "all", "points": 90, "max_points": 100}], "feedback": "good", "confidence": 85}`,
+			want: &models.AIGrading{
+				Score:      90,
+				Criteria:   []models.AICriterion{{Name: "all", Points: 90, MaxPoints: 100}},
+				Feedback:   "good",
+				Confidence: 0.85,
+			},
+		},
+		{
+			name:         "not JSON",
+			output:       "The answer is fine.",
+			wantProblems: []string{"the response is not a JSON object"},
+		},
+		{
+			name:   "everything missing",
+			output: `{}`,
+			wantProblems: []string{
+				"feedback is missing", "criteria are missing", "score is missing", "confidence is missing",
+			},
+		},
+		{
+			name: "bad criteria",
+			output: `{"score": 50, "feedback": "ok", "confidence": 0.5, "criteria": [
+				{"points": 10, "max_points": 10},
+				{"name": "logic", "points": 10, "max_points": 0},
+				{"name": "style", "points": 30, "max_points": 20}
+			]}`,
+			wantProblems: []string{
+				"criteria[0].name is missing",
+				"criteria[1].max_points must be positive",
+				"criteria[2].points must be between 0 and max_points",
+			},
+		},
+		{
+			name:         "criteria not out of 100",
+			output:       `{"score": 50, "feedback": "ok", "confidence": 0.5, "criteria": [{"name": "all", "points": 5, "max_points": 10}]}`,
+			wantProblems: []string{"the max_points of the criteria add up to 10 instead of 100"},
+		},
+		{
+			name:   "out of range",
+			output: `{"score": 150, "feedback": "ok", "confidence": -1, "criteria": [{"name": "all", "points": 5, "max_points": 100}]}`,
+			wantProblems: []string{
+				"score must be between 0 and 100", "confidence must be between 0 and 1",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, problems := parseAIGrading(tt.output)
+			if !reflect.DeepEqual(problems, tt.wantProblems) {
+				t.Fatalf("problems = %q, want %q", problems, tt.wantProblems)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("grading = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGenerateValid(t *testing.T) {
+	tests := []struct {
+		name         string
+		replies      []string // "" is a provider error
+		wantCalls    int
+		wantScore    int
+		wantProblems []string
+		wantErr      bool
+	}{
+		{name: "valid at once", replies: []string{validGrading}, wantCalls: 1, wantScore: 70},
+		{name: "repaired", replies: []string{`{"score": 70}`, validGrading}, wantCalls: 2, wantScore: 70},
+		{
+			name:         "never valid",
+			replies:      []string{"no idea"},
+			wantCalls:    aiRepairs + 1,
+			wantProblems: []string{"the response is not a JSON object"},
+		},
+		{name: "provider fails during a repair", replies: []string{"no idea", ""}, wantCalls: 2, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, _ := newTestHandler(t)
+			model := useFakeModel(t, h, tt.replies...)
+
+			grading, err := generateValid(context.Background(), h, "Grade this.", aiGradingSchema, parseAIGrading)
+			prompts := model.Prompts()
+			if len(prompts) != tt.wantCalls {
+				t.Errorf("made %d calls, want %d", len(prompts), tt.wantCalls)
+			}
+
+			var invalid *InvalidAIOutputError
+			var providerErr *llm.ProviderError
+			switch {
+			case tt.wantProblems != nil:
+				if !errors.As(err, &invalid) || !reflect.DeepEqual(invalid.Problems, tt.wantProblems) {
+					t.Errorf("err = %v, want the problems %q", err, tt.wantProblems)
+				}
+			case tt.wantErr:
+				if !errors.As(err, &providerErr) {
+					t.Errorf("err = %v, want the provider's error", err)
+				}
+			case err != nil:
+				t.Fatal(err)
+			case grading.Score != tt.wantScore:
+				t.Errorf("score = %d, want %d", grading.Score, tt.wantScore)
+			}
+
+			// Every repair asks again with the original prompt, the broken
+			// output and what is wrong with it
+			for i := 1; i < len(prompts); i++ {
+				previous := tt.replies[min(i, len(tt.replies))-1]
+				_, problems := parseAIGrading(previous)
+				if !strings.HasPrefix(prompts[i], "Grade this.") || !strings.Contains(prompts[i], previous) {
+					t.Errorf("repair %d doesn't carry the prompt and the previous output:\n%s", i, prompts[i])
+				}
+				for _, problem := range problems {
+					if !strings.Contains(prompts[i], "- "+problem) {
+						t.Errorf("repair %d doesn't mention %q", i, problem)
+					}
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,171 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/Maheshkarri4444/Examify/models"
+)
+
+// maxGeneratedQuestions bounds one generation request, longer lists get
+// cut off or repetitive.
+const maxGeneratedQuestions = 20
+
+// questionDraftSchema is the JSON generated questions have to follow, in the
+// OpenAPI subset Gemini accepts as responseSchema.
+var questionDraftSchema = map[string]interface{}{
+	"type": "OBJECT",
+	"properties": map[string]interface{}{
+		"questions": map[string]interface{}{
+			"type": "ARRAY",
+			"items": map[string]interface{}{
+				"type": "OBJECT",
+				"properties": map[string]interface{}{
+					"question":         map[string]interface{}{"type": "STRING"},
+					"types":            map[string]interface{}{"type": "ARRAY", "items": map[string]interface{}{"type": "STRING", "enum": models.QuestionTypes}},
+					"level":            map[string]interface{}{"type": "STRING", "enum": models.QuestionLevels},
+					"reference_answer": map[string]interface{}{"type": "STRING"},
+					"grading_notes":    map[string]interface{}{"type": "STRING"},
+				},
+				"required": []string{"question", "types", "level", "reference_answer"},
+			},
+		},
+	},
+	"required": []string{"questions"},
+}
+
+type questionDraftsOutput struct {
+	Questions []models.Question `json:"questions"`
+}
+
+// questionSpec is what a teacher asks the AI to draft.
+type questionSpec struct {
+	Topic string
+	Types []string
+	// Count holds how many questions of each level to draft.
+	Count map[string]int
+}
+
+func (s questionSpec) total() int {
+	total := 0
+	for _, n := range s.Count {
+		total += n
+	}
+	return total
+}
+
+// questionPrompt asks the AI to draft exam questions with answer keys.
+func questionPrompt(spec questionSpec) string {
+	var b strings.Builder
+	b.WriteString("You are helping a teacher write exam questions.\n\n")
+	b.WriteString("Topic: " + spec.Topic + "\n")
+	b.WriteString("Answer types the students can use: " + strings.Join(spec.Types, ", ") + "\n")
+	b.WriteString("Write exactly these questions:\n")
+	for _, level := range models.QuestionLevels {
+		if spec.Count[level] > 0 {
+			b.WriteString(fmt.Sprintf("- %d %s\n", spec.Count[level], level))
+		}
+	}
+	b.WriteString("\nEach question must be self-contained and different from the others. ")
+	b.WriteString("Give each one the answer types a student needs to answer it, taken from the list above, ")
+	b.WriteString("a complete reference answer and short grading notes on what earns or loses marks.\n\n")
+	b.WriteString("Respond with JSON only, in this shape:\n")
+	b.WriteString(`{"questions": [{"question": "...", "types": ["` + spec.Types[0] + `"], "level": "easy", "reference_answer": "...", "grading_notes": "..."}]}`)
+	return b.String()
+}
+
+// generateQuestions asks the AI for question drafts and validates them.
+func (h *Handler) generateQuestions(ctx context.Context, spec questionSpec) ([]models.Question, error) {
+	return generateValid(ctx, h, questionPrompt(spec), questionDraftSchema, func(output string) ([]models.Question, []string) {
+		return parseQuestionDrafts(output, spec)
+	})
+}
+
+// parseQuestionDrafts validates generated questions against the spec.
+// Invalid questions and questions beyond the requested count of a level are
+// dropped as long as enough valid ones are left.
+func parseQuestionDrafts(output string, spec questionSpec) ([]models.Question, []string) {
+	var out questionDraftsOutput
+	if problem := decodeAIObject(output, &out); problem != "" {
+		return nil, []string{problem}
+	}
+
+	var rejected []string
+	var drafts []models.Question
+	seen := map[string]bool{}
+	perLevel := map[string]int{}
+	for i, q := range out.Questions {
+		q = normalizeQuestion(q)
+		issues := questionProblems(q)
+		for _, t := range q.Types {
+			if !slices.Contains(spec.Types, t) {
+				issues = append(issues, fmt.Sprintf("type %q was not asked for", t))
+			}
+		}
+		if q.ReferenceAnswer == "" {
+			issues = append(issues, "reference_answer is missing")
+		}
+		if seen[strings.ToLower(q.Question)] {
+			issues = append(issues, "it repeats an earlier question")
+		}
+		for _, issue := range issues {
+			rejected = append(rejected, fmt.Sprintf("questions[%d]: %s", i, issue))
+		}
+		if issues != nil || perLevel[q.Level] >= spec.Count[q.Level] {
+			continue
+		}
+		seen[strings.ToLower(q.Question)] = true
+		perLevel[q.Level]++
+		drafts = append(drafts, q)
+	}
+
+	var problems []string
+	for _, level := range models.QuestionLevels {
+		if perLevel[level] < spec.Count[level] {
+			problems = append(problems, fmt.Sprintf("%d %s questions were asked for, got %d valid ones", spec.Count[level], level, perLevel[level]))
+		}
+	}
+	if problems != nil {
+		return nil, append(rejected, problems...)
+	}
+	return drafts, nil
+}
+
+// normalizeQuestion trims a question and lower cases its types and level.
+func normalizeQuestion(q models.Question) models.Question {
+	q.Question = strings.TrimSpace(q.Question)
+	q.Level = strings.ToLower(strings.TrimSpace(q.Level))
+	q.ReferenceAnswer = strings.TrimSpace(q.ReferenceAnswer)
+	q.GradingNotes = strings.TrimSpace(q.GradingNotes)
+	types := make([]string, 0, len(q.Types))
+	for _, t := range q.Types {
+		t = strings.ToLower(strings.TrimSpace(t))
+		if !slices.Contains(types, t) {
+			types = append(types, t)
+		}
+	}
+	q.Types = types
+	return q
+}
+
+// questionProblems lists what makes a question unusable in an exam.
+func questionProblems(q models.Question) []string {
+	var problems []string
+	if q.Question == "" {
+		problems = append(problems, "question is missing")
+	}
+	if len(q.Types) == 0 {
+		problems = append(problems, "types are missing")
+	}
+	for _, t := range q.Types {
+		if !slices.Contains(models.QuestionTypes, t) {
+			problems = append(problems, fmt.Sprintf("type %q is not one of %s", t, strings.Join(models.QuestionTypes, ", ")))
+		}
+	}
+	if !slices.Contains(models.QuestionLevels, q.Level) {
+		problems = append(problems, "level must be easy, medium or hard")
+	}
+	return problems
+}
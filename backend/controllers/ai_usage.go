@@ -0,0 +1,284 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Maheshkarri4444/Examify/models"
+	"github.com/Maheshkarri4444/Examify/store"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// What an LLM call was made for, as recorded in its usage.
+const (
+	purposeChat               = "chat"
+	purposeGrading            = "grading"
+	purposePreEvaluation      = "pre_evaluation"
+	purposeQuestionGeneration = "question_generation"
+)
+
+// llmCaller is who an LLM call is made for, it is charged to their quotas.
+type llmCaller struct {
+	Email   string
+	Role    string
+	ExamID  primitive.ObjectID
+	Purpose string
+}
+
+type llmCallerKey struct{}
+
+func withLLMCaller(ctx context.Context, caller llmCaller) context.Context {
+	return context.WithValue(ctx, llmCallerKey{}, caller)
+}
+
+func llmCallerFrom(ctx context.Context) llmCaller {
+	caller, _ := ctx.Value(llmCallerKey{}).(llmCaller)
+	return caller
+}
+
+// requestCaller is the signed in user of a request.
+func requestCaller(c *gin.Context, examID primitive.ObjectID, purpose string) llmCaller {
+	return llmCaller{
+		Email:   c.GetString("email"),
+		Role:    c.GetString("role"),
+		ExamID:  examID,
+		Purpose: purpose,
+	}
+}
+
+// QuotaExceededError is a call refused because a daily quota is used up.
+type QuotaExceededError struct {
+	Scope string // "user" or "exam"
+	Unit  string // "calls" or "tokens"
+	Limit int64
+}
+
+func (e *QuotaExceededError) Error() string {
+	return fmt.Sprintf("daily AI quota of %d %s per %s exceeded", e.Limit, e.Unit, e.Scope)
+}
+
+// utcDay returns the start of t's UTC day, quotas reset then.
+func utcDay(t time.Time) time.Time {
+	return t.UTC().Truncate(24 * time.Hour)
+}
+
+// checkAIQuota refuses a call once the caller or their exam used up a daily
+// quota. Calls without a caller, like the system's own, are not limited.
+//
+// Usage is counted here and recorded by generate once the call is over, so
+// calls running at the same time all pass on the same count: a quota can be
+// overshot by one call per concurrent caller, the job workers plus the
+// requests in flight. Token quotas are overshot by whatever the last calls
+// use as well, their size is only known afterwards.
+func (h *Handler) checkAIQuota(ctx context.Context, caller llmCaller) error {
+	since := utcDay(time.Now())
+	check := func(scope string, filter store.LLMUsageFilter, calls, tokens int64) error {
+		if calls == 0 && tokens == 0 {
+			return nil
+		}
+		filter.Since = since
+		totals, err := h.store.LLMUsage.Totals(ctx, filter, store.UsageTotal)
+		if err != nil {
+			return fmt.Errorf("failed to check AI quota: %w", err)
+		}
+		var used store.LLMUsageTotals
+		if len(totals) > 0 {
+			used = totals[0]
+		}
+		if calls > 0 && used.Calls >= calls {
+			return &QuotaExceededError{Scope: scope, Unit: "calls", Limit: calls}
+		}
+		if tokens > 0 && used.TotalTokens >= tokens {
+			return &QuotaExceededError{Scope: scope, Unit: "tokens", Limit: tokens}
+		}
+		return nil
+	}
+
+	limits := h.cfg.AI
+	if caller.Email != "" {
+		if err := check("user", store.LLMUsageFilter{UserEmail: caller.Email}, limits.UserDailyCalls, limits.UserDailyTokens); err != nil {
+			return err
+		}
+	}
+	if !caller.ExamID.IsZero() {
+		examID := caller.ExamID
+		if err := check("exam", store.LLMUsageFilter{ExamID: &examID}, limits.ExamDailyCalls, limits.ExamDailyTokens); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// generate sends one prompt to the model on behalf of the caller in ctx.
+// The call is refused when a quota is used up, and recorded when it reached
+// the provider. See checkAIQuota for how far concurrent calls overshoot.
+func (h *Handler) generate(ctx context.Context, prompt string, schema map[string]interface{}) (string, error) {
+	caller := llmCallerFrom(ctx)
+	if err := h.checkAIQuota(ctx, caller); err != nil {
+		return "", err
+	}
+
+	start := time.Now()
+	response, err := h.ai.Generate(ctx, prompt, schema)
+	if response.Attempts == 0 {
+		return "", err
+	}
+	call := &models.LLMCall{
+		UserEmail:      caller.Email,
+		UserRole:       caller.Role,
+		ExamID:         caller.ExamID,
+		Purpose:        caller.Purpose,
+		Model:          h.ai.Model(),
+		PromptTokens:   response.Usage.PromptTokens,
+		ResponseTokens: response.Usage.ResponseTokens,
+		TotalTokens:    response.Usage.TotalTokens,
+		LatencyMS:      time.Since(start).Milliseconds(),
+		Attempts:       response.Attempts,
+		FinishReason:   response.FinishReason,
+		Status:         models.LLMCallOK,
+		CreatedAt:      primitive.NewDateTimeFromTime(start),
+	}
+	if err != nil {
+		call.Status = models.LLMCallError
+		call.Error = err.Error()
+	}
+
+	// The call is paid for even when the request that made it is gone
+	recordCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if recordErr := h.store.LLMUsage.Record(recordCtx, call); recordErr != nil {
+		fmt.Println("llm usage error: ", recordErr)
+	}
+	if err != nil {
+		return "", err
+	}
+	return response.Text, nil
+}
+
+// quotaExceeded answers 429 when err is a used up quota, with Retry-After
+// set to when it resets.
+func quotaExceeded(c *gin.Context, err error) bool {
+	var quota *QuotaExceededError
+	if !errors.As(err, &quota) {
+		return false
+	}
+	reset := utcDay(time.Now()).Add(24 * time.Hour)
+	c.Header("Retry-After", strconv.Itoa(int(time.Until(reset).Seconds())+1))
+	c.JSON(http.StatusTooManyRequests, gin.H{
+		"error":    fmt.Sprintf("The daily AI limit of %d %s for this %s has been reached, try again tomorrow", quota.Limit, quota.Unit, quota.Scope),
+		"scope":    quota.Scope,
+		"unit":     quota.Unit,
+		"limit":    quota.Limit,
+		"reset_at": reset,
+	})
+	return true
+}
+
+// GetAIUsageReport sums the LLM calls, grouped by ?group_by=user, exam,
+// purpose or day, between ?from= and ?to= (inclusive UTC dates,
+// YYYY-MM-DD, the last 30 days by default), optionally filtered by ?email=,
+// ?exam_id= and ?purpose=. Costs are estimates from the configured prices.
+func (h *Handler) GetAIUsageReport(c *gin.Context) {
+	group := store.UsageGroup(c.DefaultQuery("group_by", string(store.UsageByUser)))
+	switch group {
+	case store.UsageByUser, store.UsageByExam, store.UsageByPurpose, store.UsageByDay:
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "group_by must be user, exam, purpose or day"})
+		return
+	}
+
+	until := utcDay(time.Now()).Add(24 * time.Hour)
+	if to := c.Query("to"); to != "" {
+		day, err := time.Parse("2006-01-02", to)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "to must be a date like 2006-01-02"})
+			return
+		}
+		until = day.Add(24 * time.Hour)
+	}
+	since := until.AddDate(0, 0, -30)
+	if from := c.Query("from"); from != "" {
+		day, err := time.Parse("2006-01-02", from)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "from must be a date like 2006-01-02"})
+			return
+		}
+		since = day
+	}
+	if !since.Before(until) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "from must not be after to"})
+		return
+	}
+
+	filter := store.LLMUsageFilter{
+		UserEmail: strings.ToLower(c.Query("email")),
+		Purpose:   c.Query("purpose"),
+		Since:     since,
+		Until:     until,
+	}
+	if examID := c.Query("exam_id"); examID != "" {
+		objID, err := primitive.ObjectIDFromHex(examID)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid exam ID"})
+			return
+		}
+		filter.ExamID = &objID
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	groups, err := h.store.LLMUsage.Totals(ctx, filter, group)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch AI usage"})
+		return
+	}
+
+	type usageRow struct {
+		store.LLMUsageTotals
+		AvgLatencyMS     int64   `json:"avg_latency_ms"`
+		EstimatedCostUSD float64 `json:"estimated_cost_usd"`
+	}
+	row := func(t store.LLMUsageTotals) usageRow {
+		r := usageRow{LLMUsageTotals: t}
+		if t.Calls > 0 {
+			r.AvgLatencyMS = t.LatencyMS / t.Calls
+		}
+		cost := float64(t.PromptTokens)*h.cfg.AI.PromptTokenPrice + float64(t.ResponseTokens)*h.cfg.AI.ResponseTokenPrice
+		r.EstimatedCostUSD = math.Round(cost/1e6*1e4) / 1e4
+		return r
+	}
+
+	var total store.LLMUsageTotals
+	rows := make([]usageRow, len(groups))
+	for i, g := range groups {
+		rows[i] = row(g)
+		total.Calls += g.Calls
+		total.Errors += g.Errors
+		total.PromptTokens += g.PromptTokens
+		total.ResponseTokens += g.ResponseTokens
+		total.TotalTokens += g.TotalTokens
+		total.LatencyMS += g.LatencyMS
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"from":     since.Format("2006-01-02"),
+		"to":       until.AddDate(0, 0, -1).Format("2006-01-02"),
+		"group_by": group,
+		"groups":   rows,
+		"total":    row(total),
+		"quotas": gin.H{
+			"user_daily_calls":  h.cfg.AI.UserDailyCalls,
+			"user_daily_tokens": h.cfg.AI.UserDailyTokens,
+			"exam_daily_calls":  h.cfg.AI.ExamDailyCalls,
+			"exam_daily_tokens": h.cfg.AI.ExamDailyTokens,
+		},
+	})
+}
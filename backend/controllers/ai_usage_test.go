@@ -0,0 +1,77 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/Maheshkarri4444/Examify/config"
+	"github.com/Maheshkarri4444/Examify/models"
+	"github.com/Maheshkarri4444/Examify/store"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestGenerateQuota(t *testing.T) {
+	examID := primitive.NewObjectID()
+	tests := []struct {
+		name      string
+		limits    config.AIConfig
+		caller    llmCaller
+		wantCalls int
+		wantQuota *QuotaExceededError
+	}{
+		{
+			name:      "user calls",
+			limits:    config.AIConfig{UserDailyCalls: 2},
+			caller:    llmCaller{Email: "teacher@example.com", Role: models.RoleTeacher, Purpose: purposeChat},
+			wantCalls: 2,
+			wantQuota: &QuotaExceededError{Scope: "user", Unit: "calls", Limit: 2},
+		},
+		{
+			// The fake model uses 15 tokens a call
+			name:      "exam tokens",
+			limits:    config.AIConfig{ExamDailyTokens: 20},
+			caller:    llmCaller{ExamID: examID, Purpose: purposePreEvaluation},
+			wantCalls: 2,
+			wantQuota: &QuotaExceededError{Scope: "exam", Unit: "tokens", Limit: 20},
+		},
+		{
+			name:      "system calls",
+			limits:    config.AIConfig{UserDailyCalls: 1, ExamDailyCalls: 1},
+			caller:    llmCaller{Purpose: purposeGrading},
+			wantCalls: 3,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, stores := newTestHandler(t)
+			h.cfg.AI = tt.limits
+			model := useFakeModel(t, h, "ok")
+			ctx := withLLMCaller(context.Background(), tt.caller)
+
+			var err error
+			for i := 0; i < 3 && err == nil; i++ {
+				_, err = h.generate(ctx, "Hello", nil)
+			}
+			var quota *QuotaExceededError
+			switch {
+			case tt.wantQuota == nil && err != nil:
+				t.Fatal(err)
+			case tt.wantQuota != nil && (!errors.As(err, &quota) || *quota != *tt.wantQuota):
+				t.Fatalf("err = %v, want %v", err, tt.wantQuota)
+			}
+			if got := len(model.Prompts()); got != tt.wantCalls {
+				t.Errorf("model got %d calls, want %d", got, tt.wantCalls)
+			}
+
+			// Only the calls that reached the model are charged
+			totals, err := stores.LLMUsage.Totals(context.Background(), store.LLMUsageFilter{}, store.UsageTotal)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(totals) != 1 || totals[0].Calls != int64(tt.wantCalls) {
+				t.Errorf("recorded %+v, want %d calls", totals, tt.wantCalls)
+			}
+		})
+	}
+}
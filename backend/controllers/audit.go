@@ -0,0 +1,137 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Maheshkarri4444/Examify/models"
+	"github.com/Maheshkarri4444/Examify/store"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// recordAudit appends an entry to the audit log. The actor is taken from the
+// authenticated request. Failures are logged and never fail the request.
+func (h *Handler) recordAudit(ctx context.Context, c *gin.Context, entry models.AuditLog) {
+	h.insertAudit(ctx, withActor(c, entry))
+}
+
+// withActor fills in who is making the request. Background jobs take it
+// before the request ends and call insertAudit themselves.
+func withActor(c *gin.Context, entry models.AuditLog) models.AuditLog {
+	if email, ok := c.Get("email"); ok {
+		entry.ActorEmail, _ = email.(string)
+	}
+	if role, ok := c.Get("role"); ok {
+		entry.ActorRole, _ = role.(string)
+	}
+	if admin, ok := c.Get("impersonated_by"); ok {
+		entry.ImpersonatedBy, _ = admin.(string)
+	}
+	return entry
+}
+
+func (h *Handler) insertAudit(ctx context.Context, entry models.AuditLog) {
+	entry.ID = primitive.NewObjectID()
+	entry.CreatedAt = primitive.NewDateTimeFromTime(time.Now())
+	if err := h.store.Audit.Insert(ctx, &entry); err != nil {
+		fmt.Println("audit log error: ", err)
+	}
+}
+
+// auditChanges compares two documents field by field, using their bson
+// representation, and returns only the fields that differ.
+func auditChanges(before, after interface{}) map[string]models.AuditChange {
+	beforeDoc, afterDoc := toBsonM(before), toBsonM(after)
+
+	changes := map[string]models.AuditChange{}
+	for key, value := range afterDoc {
+		if old, ok := beforeDoc[key]; !ok || !reflect.DeepEqual(old, value) {
+			changes[key] = models.AuditChange{Before: beforeDoc[key], After: value}
+		}
+	}
+	for key, old := range beforeDoc {
+		if _, ok := afterDoc[key]; !ok {
+			changes[key] = models.AuditChange{Before: old, After: nil}
+		}
+	}
+	return changes
+}
+
+func toBsonM(v interface{}) bson.M {
+	doc := bson.M{}
+	if v == nil {
+		return doc
+	}
+	data, err := bson.Marshal(v)
+	if err != nil {
+		return doc
+	}
+	bson.Unmarshal(data, &doc)
+	return doc
+}
+
+// teacherOwnsExam reports whether the exam is listed in the teacher's container.
+func (h *Handler) teacherOwnsExam(ctx context.Context, containerID, examID primitive.ObjectID) bool {
+	owns, err := h.store.Containers.TeacherOwnsExam(ctx, containerID, examID)
+	return err == nil && owns
+}
+
+// GetAuditLogs lists audit entries newest first, filtered by ?exam_id=,
+// ?student_email=, ?target_id= and ?action=. Teachers must filter by one of
+// their own exams; admins can query everything.
+func (h *Handler) GetAuditLogs(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	filter := store.AuditFilter{
+		StudentEmail: strings.ToLower(c.Query("student_email")),
+		Action:       c.Query("action"),
+	}
+	if examID := c.Query("exam_id"); examID != "" {
+		objID, err := primitive.ObjectIDFromHex(examID)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid exam ID"})
+			return
+		}
+		filter.ExamID = &objID
+	}
+	if targetID := c.Query("target_id"); targetID != "" {
+		objID, err := primitive.ObjectIDFromHex(targetID)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid target ID"})
+			return
+		}
+		filter.TargetID = &objID
+	}
+
+	if c.MustGet("role").(string) != models.RoleAdmin {
+		if filter.ExamID == nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "exam_id is required"})
+			return
+		}
+		if !h.teacherOwnsExam(ctx, c.MustGet("container_id").(primitive.ObjectID), *filter.ExamID) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+			return
+		}
+	}
+
+	filter.Limit, _ = strconv.ParseInt(c.DefaultQuery("limit", "100"), 10, 64)
+	if filter.Limit <= 0 || filter.Limit > 500 {
+		filter.Limit = 100
+	}
+
+	logs, err := h.store.Audit.List(ctx, filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch audit logs"})
+		return
+	}
+
+	c.JSON(http.StatusOK, logs)
+}
@@ -2,112 +2,131 @@ package controllers
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
 	"net/http"
-	"os"
 	"strings"
 	"time"
 
-	"github.com/Maheshkarri4444/Examify/config"
 	"github.com/Maheshkarri4444/Examify/models"
+	"github.com/Maheshkarri4444/Examify/store"
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt"
-	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
-	"go.mongodb.org/mongo-driver/mongo"
 	"golang.org/x/oauth2"
-	"golang.org/x/oauth2/google"
 )
 
-var userCollection *mongo.Collection = config.GetCollection(config.Client, "users")
-var studentContainerCollection *mongo.Collection = config.GetCollection(config.Client, "student_containers")
-var teacherContainerCollection *mongo.Collection = config.GetCollection(config.Client, "teacher_containers")
-
-var googleOauthConfig = &oauth2.Config{
-	ClientID:     os.Getenv("GOOGLE_CLIENT_ID"),
-	ClientSecret: os.Getenv("GOOGLE_CLIENT_SECRET"),
-	RedirectURL:  "http://localhost:5173/google/callback",
-	Scopes:       []string{"email", "profile"},
-	Endpoint:     google.Endpoint,
-}
-
-func generateJWT(email string) (string, error) {
+func (h *Handler) generateJWT(email string) (string, error) {
 	claims := jwt.MapClaims{
 		"email": email,
 		"exp":   time.Now().Add(time.Hour * 24).Unix(),
 	}
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString([]byte(os.Getenv("JWT_SECRET")))
+	return token.SignedString([]byte(h.cfg.JWTSecret))
 }
 
-func GoogleLogin(c *gin.Context) {
-	url := googleOauthConfig.AuthCodeURL("state-token", oauth2.AccessTypeOffline)
+// generateImpersonationJWT issues a short-lived token for a support session.
+// The admin's email travels in the token so every action can be attributed.
+func (h *Handler) generateImpersonationJWT(email, adminEmail string) (string, error) {
+	claims := jwt.MapClaims{
+		"email":           email,
+		"impersonated_by": adminEmail,
+		"exp":             time.Now().Add(time.Hour).Unix(),
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	return token.SignedString([]byte(h.cfg.JWTSecret))
+}
+
+func (h *Handler) GoogleLogin(c *gin.Context) {
+	state, err := randomToken(32)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start login"})
+		return
+	}
+	nonce, err := randomToken(32)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start login"})
+		return
+	}
+	verifier := oauth2.GenerateVerifier()
+
+	err = h.setOAuthStateCookie(c, oauthState{
+		State:    state,
+		Verifier: verifier,
+		Nonce:    nonce,
+		Expires:  time.Now().Add(oauthStateTTL).Unix(),
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start login"})
+		return
+	}
+
+	url := h.oauth.AuthCodeURL(state,
+		oauth2.AccessTypeOffline,
+		oauth2.S256ChallengeOption(verifier),
+		oauth2.SetAuthURLParam("nonce", nonce),
+	)
 	c.Redirect(http.StatusFound, url)
 }
 
-func GoogleCallback(c *gin.Context) {
+func (h *Handler) GoogleCallback(c *gin.Context) {
+	if errParam := c.Query("error"); errParam != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Google login was not completed", "details": errParam})
+		return
+	}
+
 	code := c.Query("code")
 	if code == "" {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Missing authorization code"})
+		return
 	}
 
-	token, err := googleOauthConfig.Exchange(context.TODO(), code)
+	st, err := h.readOAuthState(c, c.Query("state"))
+	// The state cookie is single use, whatever the outcome
+	h.clearOAuthStateCookie(c)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to exchange code", "details": err.Error()})
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid login state", "details": err.Error()})
 		return
 	}
 
-	userInfoResp, err := http.Get("https://www.googleapis.com/oauth2/v2/userinfo?access_token=" + token.AccessToken)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	token, err := h.oauth.Exchange(ctx, code, oauth2.VerifierOption(st.Verifier))
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch user info", "details": err.Error()})
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to exchange code", "details": err.Error()})
 		return
 	}
-	defer userInfoResp.Body.Close()
 
-	var userInfo map[string]interface{}
-	if err := json.NewDecoder(userInfoResp.Body).Decode(&userInfo); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to decode user info", "details": err.Error()})
+	rawIDToken, ok := token.Extra("id_token").(string)
+	if !ok || rawIDToken == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID token missing from Google response"})
 		return
 	}
 
-	email, ok := userInfo["email"].(string)
-	if !ok {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Email not found in user info"})
+	idClaims, err := h.verifyGoogleIDToken(ctx, rawIDToken, st.Nonce)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid Google identity", "details": err.Error()})
 		return
 	}
-	name, _ := userInfo["name"].(string)
-	googleID, _ := userInfo["id"].(string)
-	image, _ := userInfo["picture"].(string) //url of image
 
-	role := "teacher"
-	if strings.HasSuffix(email, "@rguktn.ac.in") {
-		role = "student"
-	}
+	email := strings.ToLower(idClaims.Email)
+	name := idClaims.Name
+	googleID := idClaims.Subject
+	image := idClaims.Picture //url of image
 
+	existing, err := h.store.Users.GetByEmail(ctx, email)
 	var user models.User
-	err = userCollection.FindOne(context.TODO(), bson.M{"email": email}).Decode(&user)
+	if errors.Is(err, store.ErrNotFound) {
+		role, allowed := h.roleForNewUser(email)
+		if !allowed {
+			c.JSON(http.StatusForbidden, gin.H{"error": "This account is not allowed to use Examify"})
+			return
+		}
 
-	if err == mongo.ErrNoDocuments {
 		containerID := primitive.NewObjectID()
-		if role == "student" {
-			studentContainer := models.StudentContainer{
-				ID: containerID,
-				QuestionPapers: []struct {
-					ExamID          primitive.ObjectID `bson:"exam_id" json:"exam_id"`
-					QuestionPaperID primitive.ObjectID `bson:"question_paper_id" json:"question_paper_id"`
-					AnswerSheetID   primitive.ObjectID `bson:"answer_sheet_id" json:"answer_sheet_id"`
-				}{},
-			}
-			studentContainerCollection.InsertOne(context.TODO(), studentContainer)
-		} else {
-			teacherContainer := models.TeacherContainer{
-				ID: containerID,
-				Exams: []struct {
-					ExamID       primitive.ObjectID   `bson:"exam_id" json:"exam_id"`
-					EvaluationID []primitive.ObjectID `bson:"evaluation_id" json:"evaluation_id"`
-				}{},
-			}
-			teacherContainerCollection.InsertOne(context.TODO(), teacherContainer)
+		if err := h.ensureContainerForRole(ctx, containerID, role); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create user container", "details": err.Error()})
+			return
 		}
 
 		user = models.User{
@@ -121,8 +140,7 @@ func GoogleCallback(c *gin.Context) {
 			CreatedAt:   primitive.NewDateTimeFromTime(time.Now()),
 			UpdatedAt:   primitive.NewDateTimeFromTime(time.Now()),
 		}
-		_, err := userCollection.InsertOne(context.TODO(), user)
-		if err != nil {
+		if err := h.store.Users.Create(ctx, &user); err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create user", "details": err.Error()})
 			return
 		}
@@ -130,27 +148,22 @@ func GoogleCallback(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error", "details": err.Error()})
 		return
 	} else {
-		updateFields := bson.M{
-			"$set": bson.M{
-				"role":       role,
-				"image":      image,
-				"updated_at": primitive.NewDateTimeFromTime(time.Now()),
-			},
-		}
-		_, err := userCollection.UpdateOne(context.TODO(), bson.M{"email": email}, updateFields)
-		if err != nil {
+		// Roles are managed by admins once an account exists, so login only
+		// refreshes the profile fields coming from Google.
+		if err := h.store.Users.UpdateProfile(ctx, existing.ID, image); err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update user", "details": err.Error()})
 			return
 		}
 
-		err = userCollection.FindOne(context.TODO(), bson.M{"email": email}).Decode(&user)
+		updated, err := h.store.Users.GetByID(ctx, existing.ID)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch updated user", "details": err.Error()})
 			return
 		}
+		user = *updated
 	}
 
-	jwtToken, err := generateJWT(email)
+	jwtToken, err := h.generateJWT(email)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate JWT", "details": err.Error()})
 		return
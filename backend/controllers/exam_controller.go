@@ -2,22 +2,22 @@ package controllers
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
 	"math/rand"
 	"net/http"
 	"time"
 
-	"github.com/Maheshkarri4444/Examify/config"
+	"github.com/Maheshkarri4444/Examify/events"
 	"github.com/Maheshkarri4444/Examify/models"
+	"github.com/Maheshkarri4444/Examify/store"
 	"github.com/gin-gonic/gin"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
-	"go.mongodb.org/mongo-driver/mongo"
 )
 
-var examCollection *mongo.Collection = config.GetCollection(config.Client, "exams")
-
-func CreateExam(c *gin.Context) {
+func (h *Handler) CreateExam(c *gin.Context) {
 	var exam models.Exam
 	if err := c.ShouldBindJSON(&exam); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
@@ -28,11 +28,14 @@ func CreateExam(c *gin.Context) {
 	exam.ID = primitive.NewObjectID()
 	exam.Sets = []primitive.ObjectID{}
 	exam.AnswerSheets = []primitive.ObjectID{}
+	exam.SetCounter = 0
+	exam.Open = false
+	exam.AICalibration = nil
 
 	// Insert into DB
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
-	_, err := examCollection.InsertOne(ctx, exam)
+	err := h.store.Exams.Create(ctx, &exam)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create exam"})
 		return
@@ -40,18 +43,23 @@ func CreateExam(c *gin.Context) {
 
 	// Add exam ID to teacher's container
 	containerID := c.MustGet("container_id").(primitive.ObjectID)
-	filter := bson.M{"_id": containerID}
-	update := bson.M{"$push": bson.M{"exams": bson.M{"exam_id": exam.ID}}}
-	_, err = teacherContainerCollection.UpdateOne(ctx, filter, update)
+	err = h.store.Containers.AddExamToTeacher(ctx, containerID, exam.ID, nil)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update teacher container"})
 		return
 	}
 
+	h.recordAudit(ctx, c, models.AuditLog{
+		Action:   "exam.created",
+		TargetID: exam.ID,
+		ExamID:   exam.ID,
+		Changes:  auditChanges(nil, exam),
+	})
+
 	c.JSON(http.StatusOK, gin.H{"message": "Exam created successfully", "exam_id": exam.ID})
 }
 
-func UpdateExam(c *gin.Context) {
+func (h *Handler) UpdateExam(c *gin.Context) {
 	var exam models.Exam
 	if err := c.ShouldBindJSON(&exam); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
@@ -60,80 +68,126 @@ func UpdateExam(c *gin.Context) {
 
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
-	filter := bson.M{"_id": exam.ID}
-	update := bson.M{
-		"$set": bson.M{
-			"exam_name":       exam.ExamName,
-			"exam_type":       exam.ExamType,
-			"available_dates": exam.AvailableDates,
-			"duration":        exam.Duration,
-			"questions":       exam.Questions,
-		},
+
+	existingExam, err := h.store.Exams.GetByID(ctx, exam.ID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Exam not found"})
+		return
 	}
-	_, err := examCollection.UpdateOne(ctx, filter, update)
+
+	fields := bson.M{
+		"exam_name":       exam.ExamName,
+		"exam_type":       exam.ExamType,
+		"available_dates": exam.AvailableDates,
+		"duration":        exam.Duration,
+		"questions":       exam.Questions,
+	}
+	err = h.store.Exams.Update(ctx, &exam)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update exam"})
 		return
 	}
+	if err := h.syncAnswerKeys(ctx, existingExam.Sets, exam.Questions); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update the answer keys of the question papers"})
+		return
+	}
+
+	before := bson.M{}
+	existingDoc := toBsonM(existingExam)
+	for key := range fields {
+		before[key] = existingDoc[key]
+	}
+	h.recordAudit(ctx, c, models.AuditLog{
+		Action:   "exam.updated",
+		TargetID: exam.ID,
+		ExamID:   exam.ID,
+		Changes:  auditChanges(before, fields),
+	})
 
 	c.JSON(http.StatusOK, gin.H{"message": "Exam updated successfully"})
 }
 
-func GetAllExams(c *gin.Context) {
-	var exams []models.Exam
+// syncAnswerKeys copies the reference answers and grading notes of the
+// exam's questions into the question papers made from them, matching the
+// questions by text. The papers' questions themselves stay as handed out.
+func (h *Handler) syncAnswerKeys(ctx context.Context, sets []primitive.ObjectID, questions []models.Question) error {
+	keys := make(map[string]models.Question, len(questions))
+	for _, q := range questions {
+		keys[q.Question] = q
+	}
+	for _, setID := range sets {
+		paper, err := h.store.Exams.GetQuestionPaper(ctx, setID)
+		if errors.Is(err, store.ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		changed := false
+		for i, q := range paper.Questions {
+			key := keys[q.Question]
+			if q.ReferenceAnswer != key.ReferenceAnswer || q.GradingNotes != key.GradingNotes {
+				paper.Questions[i].ReferenceAnswer = key.ReferenceAnswer
+				paper.Questions[i].GradingNotes = key.GradingNotes
+				changed = true
+			}
+		}
+		if changed {
+			if err := h.store.Exams.SetPaperQuestions(ctx, setID, paper.Questions); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (h *Handler) GetAllExams(c *gin.Context) {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
-	cursor, err := examCollection.Find(ctx, bson.M{})
+	exams, err := h.store.Exams.List(ctx)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch exams"})
 		return
 	}
-	defer cursor.Close(ctx)
 
-	for cursor.Next(ctx) {
-		var exam models.Exam
-		if err := cursor.Decode(&exam); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error decoding exam"})
-			return
+	if c.MustGet("role") == models.RoleStudent {
+		for i := range exams {
+			exams[i] = exams[i].WithoutAnswerKeys()
 		}
-		exams = append(exams, exam)
 	}
-
 	c.JSON(http.StatusOK, exams)
 }
 
-func GetExamById(c *gin.Context) {
+func (h *Handler) GetExamById(c *gin.Context) {
 	id, err := primitive.ObjectIDFromHex(c.Query("id"))
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid exam ID"})
 		return
 	}
 
-	var exam models.Exam
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
-	err = examCollection.FindOne(ctx, bson.M{"_id": id}).Decode(&exam)
+	exam, err := h.store.Exams.GetByID(ctx, id)
 	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Exam not found"})
 		return
 	}
 
+	if c.MustGet("role") == models.RoleStudent {
+		c.JSON(http.StatusOK, exam.WithoutAnswerKeys())
+		return
+	}
 	c.JSON(http.StatusOK, exam)
 }
 
-func GetExamsByTeacherContainer(c *gin.Context) {
+func (h *Handler) GetExamsByTeacherContainer(c *gin.Context) {
 	containerID := c.MustGet("container_id").(primitive.ObjectID)
-	var teacherContainer struct {
-		Exams []struct {
-			ExamID primitive.ObjectID `bson:"exam_id"`
-		} `bson:"exams"`
-	}
 
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
 	// Fetch teacher's container
-	err := teacherContainerCollection.FindOne(ctx, bson.M{"_id": containerID}).Decode(&teacherContainer)
+	teacherContainer, err := h.store.Containers.GetTeacherContainer(ctx, containerID)
 	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Teacher container not found"})
 		return
@@ -146,21 +200,15 @@ func GetExamsByTeacherContainer(c *gin.Context) {
 	}
 
 	// Fetch exams from database
-	cursor, err := examCollection.Find(ctx, bson.M{"_id": bson.M{"$in": examIDs}})
+	teacherExams, err := h.store.Exams.ListByIDs(ctx, examIDs)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch exams"})
 		return
 	}
-	defer cursor.Close(ctx)
 
 	// Filter exams (remove those with answer sheets)
 	var exams []models.Exam
-	for cursor.Next(ctx) {
-		var exam models.Exam
-		if err := cursor.Decode(&exam); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error decoding exam"})
-			return
-		}
+	for _, exam := range teacherExams {
 		// fmt.Println("exam answersheets: ", exam.AnswerSheets)
 		// Only add exams that have NO answer sheets
 		if len(exam.AnswerSheets) == 0 {
@@ -171,19 +219,14 @@ func GetExamsByTeacherContainer(c *gin.Context) {
 	c.JSON(http.StatusOK, exams)
 }
 
-func GetFinishedExamsByTeacherContainerID(c *gin.Context) {
+func (h *Handler) GetFinishedExamsByTeacherContainerID(c *gin.Context) {
 	containerID := c.MustGet("container_id").(primitive.ObjectID)
-	var teacherContainer struct {
-		Exams []struct {
-			ExamID primitive.ObjectID `bson:"exam_id"`
-		} `bson:"exams"`
-	}
 
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
 	// Fetch teacher's container
-	err := teacherContainerCollection.FindOne(ctx, bson.M{"_id": containerID}).Decode(&teacherContainer)
+	teacherContainer, err := h.store.Containers.GetTeacherContainer(ctx, containerID)
 	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Teacher container not found"})
 		return
@@ -196,22 +239,15 @@ func GetFinishedExamsByTeacherContainerID(c *gin.Context) {
 	}
 
 	// Fetch exams from database
-	cursor, err := examCollection.Find(ctx, bson.M{"_id": bson.M{"$in": examIDs}})
+	teacherExams, err := h.store.Exams.ListByIDs(ctx, examIDs)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch exams"})
 		return
 	}
-	defer cursor.Close(ctx)
 
 	// Filter exams (keep only those with at least one answer sheet)
 	var finishedExams []models.Exam
-	for cursor.Next(ctx) {
-		var exam models.Exam
-		if err := cursor.Decode(&exam); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error decoding exam"})
-			return
-		}
-
+	for _, exam := range teacherExams {
 		// Only add exams that have at least one answer sheet
 		if len(exam.AnswerSheets) > 0 {
 			finishedExams = append(finishedExams, exam)
@@ -221,8 +257,6 @@ func GetFinishedExamsByTeacherContainerID(c *gin.Context) {
 	c.JSON(http.StatusOK, finishedExams)
 }
 
-var questionPaperCollection *mongo.Collection = config.GetCollection(config.Client, "question_papers")
-
 type CreateSetsRequest struct {
 	ExamID  string `json:"exam_id" binding:"required"`
 	NumSets int    `json:"num_sets" binding:"required"`
@@ -231,7 +265,7 @@ type CreateSetsRequest struct {
 	Easy    int    `json:"easy" binding:"required"`
 }
 
-func CreateSetsForExam(c *gin.Context) {
+func (h *Handler) CreateSetsForExam(c *gin.Context) {
 	var req CreateSetsRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
@@ -249,9 +283,8 @@ func CreateSetsForExam(c *gin.Context) {
 	defer cancel()
 
 	// Fetch exam from DB
-	var exam models.Exam
 	// fmt.Println("examid: ", examID)
-	err = examCollection.FindOne(ctx, bson.M{"_id": examID}).Decode(&exam)
+	exam, err := h.store.Exams.GetByID(ctx, examID)
 	// fmt.Println("exam: ", exam)
 	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Exam not found"})
@@ -295,7 +328,7 @@ func CreateSetsForExam(c *gin.Context) {
 		}
 
 		// Insert into DB
-		_, err := questionPaperCollection.InsertOne(ctx, questionPaper)
+		err := h.store.Exams.CreateQuestionPaper(ctx, &questionPaper)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create question paper"})
 			return
@@ -304,18 +337,26 @@ func CreateSetsForExam(c *gin.Context) {
 		// Store Question Paper ID
 		createdSets = append(createdSets, questionPaper.ID)
 	}
-	_, err = examCollection.UpdateOne(ctx, bson.M{"_id": examID}, bson.M{"$set": bson.M{"sets": []primitive.ObjectID{}}})
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reset existing question paper sets"})
-		return
-	}
 	// Update Exam Document with Created Sets
-	_, err = examCollection.UpdateOne(ctx, bson.M{"_id": examID}, bson.M{"$set": bson.M{"sets": createdSets}})
+	err = h.store.Exams.SetSets(ctx, examID, createdSets)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update exam with question paper sets"})
 		return
 	}
 
+	h.recordAudit(ctx, c, models.AuditLog{
+		Action:   "exam.sets_generated",
+		TargetID: examID,
+		ExamID:   examID,
+		Changes:  map[string]models.AuditChange{"sets": {Before: exam.Sets, After: createdSets}},
+		Details: map[string]interface{}{
+			"num_sets": req.NumSets,
+			"hard":     req.Hard,
+			"medium":   req.Medium,
+			"easy":     req.Easy,
+		},
+	})
+
 	c.JSON(http.StatusOK, gin.H{"message": "Question papers created successfully", "sets": createdSets})
 }
 
@@ -326,7 +367,7 @@ func getRandomQuestions(questions []models.Question, count int) []models.Questio
 	return questions[:count]
 }
 
-func GetQuestionPaperByID(c *gin.Context) {
+func (h *Handler) GetQuestionPaperByID(c *gin.Context) {
 	// Get the question paper ID from the URL
 	qpaperID := c.Param("id")
 
@@ -341,10 +382,9 @@ func GetQuestionPaperByID(c *gin.Context) {
 	defer cancel()
 
 	// Fetch the question paper from MongoDB
-	var questionPaper models.QuestionPaper
-	err = questionPaperCollection.FindOne(ctx, bson.M{"_id": objectID}).Decode(&questionPaper)
+	questionPaper, err := h.store.Exams.GetQuestionPaper(ctx, objectID)
 	if err != nil {
-		if err == mongo.ErrNoDocuments {
+		if errors.Is(err, store.ErrNotFound) {
 			c.JSON(http.StatusNotFound, gin.H{"error": "Question paper not found"})
 		} else {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch question paper"})
@@ -352,13 +392,15 @@ func GetQuestionPaperByID(c *gin.Context) {
 		return
 	}
 
-	// Return the question paper details
+	// Return the question paper details, students never see the answer keys
+	if c.MustGet("role") == models.RoleStudent {
+		c.JSON(http.StatusOK, questionPaper.WithoutAnswerKeys())
+		return
+	}
 	c.JSON(http.StatusOK, questionPaper)
 }
 
-var answerSheetCollection *mongo.Collection = config.GetCollection(config.Client, "answersheets")
-
-func GetAvailableExamsByDate(c *gin.Context) {
+func (h *Handler) GetAvailableExamsByDate(c *gin.Context) {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
@@ -377,40 +419,24 @@ func GetAvailableExamsByDate(c *gin.Context) {
 
 	// Step 1: Get all exams available today
 	today := time.Now().UTC().Truncate(24 * time.Hour)
-	startOfDay := primitive.NewDateTimeFromTime(today)
-	endOfDay := primitive.NewDateTimeFromTime(today.Add(24 * time.Hour))
-
-	examFilter := bson.M{
-		"available_dates": bson.M{
-			"$elemMatch": bson.M{
-				"$gte": startOfDay,
-				"$lt":  endOfDay,
-			},
-		},
-	}
-
-	cursor, err := examCollection.Find(ctx, examFilter)
+	allExams, err := h.store.Exams.ListAvailableBetween(ctx, today, today.Add(24*time.Hour))
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch exams"})
 		return
 	}
-	defer cursor.Close(ctx)
-
-	var allExams []models.Exam
-	if err = cursor.All(ctx, &allExams); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to parse exams"})
-		return
-	}
 
 	// Collect all available exam IDs
 	availableExamIDs := make(map[primitive.ObjectID]models.Exam)
 	for _, exam := range allExams {
+		// Vivas are run by the teacher, students have nothing to open
+		if exam.ExamType == models.ExamViva {
+			continue
+		}
 		availableExamIDs[exam.ID] = exam
 	}
 
 	// Step 2: Fetch student's container
-	var studentContainer models.StudentContainer
-	err = studentContainerCollection.FindOne(ctx, bson.M{"_id": containerID}).Decode(&studentContainer)
+	studentContainer, err := h.store.Containers.GetStudentContainer(ctx, containerID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch student container"})
 		return
@@ -420,10 +446,9 @@ func GetAvailableExamsByDate(c *gin.Context) {
 	for _, qp := range studentContainer.QuestionPapers {
 		// If the exam ID is in today's available exams, check its answer sheet
 		if _, exists := availableExamIDs[qp.ExamID]; exists {
-			var answerSheet models.AnswerSheet
-			err := answerSheetCollection.FindOne(ctx, bson.M{"_id": qp.AnswerSheetID}).Decode(&answerSheet)
-			if err == nil && answerSheet.Submitted {
-				// If the answer sheet is submitted, remove this exam from available list
+			answerSheet, err := h.store.AnswerSheets.GetByID(ctx, qp.AnswerSheetID)
+			if err == nil && answerSheet.Status.Closed() {
+				// If the answer sheet is closed, remove this exam from available list
 				delete(availableExamIDs, qp.ExamID)
 			}
 		}
@@ -437,17 +462,36 @@ func GetAvailableExamsByDate(c *gin.Context) {
 			"exam_name": exam.ExamName,
 			"exam_type": exam.ExamType,
 			"duration":  exam.Duration,
+			"open":      exam.Open,
 		})
 	}
 
 	c.JSON(http.StatusOK, examsResponse)
 }
 
-func AssignSetAndCreateAnswerSheet(c *gin.Context) {
+// emptyAnswers is the blank answer sheet of a paper, one answer per type of
+// each question.
+func emptyAnswers(paper *models.QuestionPaper) []models.AnswerData {
+	answers := make([]models.AnswerData, len(paper.Questions))
+	for i, q := range paper.Questions {
+		answers[i].Question = q.Question
+		answers[i].Answers = make([]models.Answer, len(q.Types))
+		for j, qType := range q.Types {
+			answers[i].Answers[j] = models.Answer{Type: qType, Ans: ""}
+		}
+	}
+	return answers
+}
+
+// AssignSetAndCreateAnswerSheet gives the student a question paper for the
+// exam. When the exam has seating maps the body must carry the student's
+// seat ({slot, room, row, number}) and the set is chosen so that no adjacent
+// seat has the same one.
+func (h *Handler) AssignSetAndCreateAnswerSheet(c *gin.Context) {
 	examID := c.Param("qpaperid")
-	userID, _ := c.Get("user_id")
-	userEmail, _ := c.Get("email")
-	userName, _ := c.Get("name")
+	userEmail := c.MustGet("email").(string)
+	userName := c.MustGet("name").(string)
+	containerID := c.MustGet("container_id").(primitive.ObjectID)
 
 	examObjID, err := primitive.ObjectIDFromHex(examID)
 	if err != nil {
@@ -455,162 +499,228 @@ func AssignSetAndCreateAnswerSheet(c *gin.Context) {
 		return
 	}
 
-	// Fetch user details to get ContainerID
-	var user struct {
-		ContainerID primitive.ObjectID `bson:"contianer_id"`
-	}
-	err = userCollection.FindOne(context.TODO(), bson.M{"_id": userID}).Decode(&user)
-	if err != nil {
-		fmt.Println("user collection error")
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "User not found"})
-		return
-	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
 
-	// Fetch student's container
-	var studentContainer models.StudentContainer
-	err = studentContainerCollection.FindOne(context.TODO(), bson.M{"_id": user.ContainerID}).Decode(&studentContainer)
-	if err != nil {
-		fmt.Println("student container collection error")
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch student container"})
+	// A student has a single answer sheet per exam, return it if it exists
+	existingAnswerSheet, err := h.store.AnswerSheets.GetByExamAndEmail(ctx, examObjID, userEmail)
+	if err == nil {
+		c.JSON(http.StatusOK, toBsonM(existingAnswerSheet))
 		return
 	}
-
-	// Check if the exam is already assigned
-	for _, assignedExam := range studentContainer.QuestionPapers {
-		if assignedExam.ExamID == examObjID {
-			// Fetch the existing answer sheet
-			var existingAnswerSheet bson.M
-			err := answerSheetCollection.FindOne(context.TODO(), bson.M{
-				"_id":       assignedExam.AnswerSheetID,
-				"email":     userEmail,
-				"submitted": false,
-			}).Decode(&existingAnswerSheet)
-
-			if err == nil {
-				c.JSON(http.StatusOK, existingAnswerSheet)
-				return
-			}
-		}
+	if !errors.Is(err, store.ErrNotFound) {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch answer sheet"})
+		return
 	}
 
 	// Fetch exam details
-	var exam struct {
-		Name         string               `bson:"exam_name"`
-		ExamType     string               `bson:"exam_type"`
-		Sets         []primitive.ObjectID `bson:"sets"`
-		AnswerSheets []primitive.ObjectID `bson:"answer_sheets"`
-		Duration     int64                `bson:"duration"`
-	}
-	err = examCollection.FindOne(context.TODO(), bson.M{"_id": examObjID}).Decode(&exam)
+	exam, err := h.store.Exams.GetByID(ctx, examObjID)
 	if err != nil {
 		fmt.Println("exam collection error")
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Exam not found"})
 		return
 	}
-
-	// Find unassigned question paper
-	assignedQPaperMap := make(map[primitive.ObjectID]bool)
-	for _, ansSheetID := range exam.AnswerSheets {
-		var ansSheet struct {
-			QPaperID primitive.ObjectID `bson:"qpaper_id"`
-		}
-		answerSheetCollection.FindOne(context.TODO(), bson.M{"_id": ansSheetID}).Decode(&ansSheet)
-		assignedQPaperMap[ansSheet.QPaperID] = true
+	if exam.ExamType == models.ExamViva {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Viva exams have no answer sheets"})
+		return
 	}
-
-	var selectedQPaperID primitive.ObjectID
-	for _, set := range exam.Sets {
-		if !assignedQPaperMap[set] {
-			selectedQPaperID = set
-			break
-		}
+	if len(exam.Sets) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "No question papers have been created for this exam"})
+		return
 	}
 
-	// If no unique question paper found, assign a random one
-	if selectedQPaperID.IsZero() {
-		rand.Seed(time.Now().UnixNano())
-		selectedQPaperID = exam.Sets[rand.Intn(len(exam.Sets))]
+	var requestBody struct {
+		Seat *models.SeatAssignment `json:"seat"`
+	}
+	if err := c.ShouldBindJSON(&requestBody); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
 	}
+	seat := requestBody.Seat
 
-	// Fetch question paper
-	var qPaper models.QuestionPaper
-	err = questionPaperCollection.FindOne(context.TODO(), bson.M{"_id": selectedQPaperID}).Decode(&qPaper)
+	seatingMaps, err := h.store.Seating.ListByExam(ctx, examObjID)
 	if err != nil {
-		fmt.Println("question papercollection error")
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Question paper not found"})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch seating maps"})
 		return
 	}
+	if len(seatingMaps) > 0 && seat == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Please enter your seat", "code": "seat_required"})
+		return
+	}
+	if len(seatingMaps) == 0 {
+		seat = nil
+	}
 
-	answers := make([]struct {
-		Question string `bson:"question" json:"question"`
-		Answers  []struct {
-			Type string `bson:"type" json:"type"`
-			Ans  string `bson:"ans" json:"ans"`
-		} `bson:"answers" json:"answers"`
-	}, len(qPaper.Questions))
-
-	for i, q := range qPaper.Questions {
-		answers[i].Question = q.Question
-		answers[i].Answers = make([]struct {
-			Type string `bson:"type" json:"type"`
-			Ans  string `bson:"ans" json:"ans"`
-		}, len(q.Types))
-
-		for j, qType := range q.Types {
-			answers[i].Answers[j] = struct {
-				Type string `bson:"type" json:"type"`
-				Ans  string `bson:"ans" json:"ans"`
-			}{Type: qType, Ans: ""}
+	if seat != nil {
+		message, err := h.validateSeat(ctx, examObjID, *seat)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check seat"})
+			return
+		}
+		if message != "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": message, "code": "invalid_seat"})
+			return
 		}
 	}
 
-	answerSheet := bson.M{
-		"student_name": userName,
-		"email":        userEmail,
-		"exam_name":    exam.Name,
-		"exam_id":      examObjID,
-		"exam_type":    exam.ExamType,
-		"qpaper_id":    selectedQPaperID,
-		"set":          qPaper.Set,
-		"status":       "didnotstart",
-		"submitted":    false,
-		"data":         answers,
-		"duration":     exam.Duration,
+	// Hand out the sets round-robin, the counter is shared by every
+	// concurrent login so the sets stay evenly distributed
+	counter, err := h.store.Exams.NextSetCounter(ctx, examObjID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to pick a question paper"})
+		return
 	}
-	if exam.ExamType == "internal" {
-		answerSheet["status"] = "internal"
+	papers := make(map[primitive.ObjectID]*models.QuestionPaper, len(exam.Sets))
+	for _, set := range exam.Sets {
+		if papers[set], err = h.store.Exams.GetQuestionPaper(ctx, set); err != nil {
+			fmt.Println("question papercollection error")
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Question paper not found"})
+			return
+		}
 	}
 
-	// Insert answer sheet
-	result, err := answerSheetCollection.InsertOne(context.TODO(), answerSheet)
+	// Internal exams run until the teacher closes them, a zero duration
+	// tells the client not to start a timer
+	duration := exam.Duration
+	if exam.ExamType == models.ExamInternal {
+		duration = 0
+	}
+
+	answerSheet := models.AnswerSheet{
+		ID:          primitive.NewObjectID(),
+		StudentName: userName,
+		Email:       userEmail,
+		ExamName:    exam.ExamName,
+		ExamID:      examObjID,
+		ExamType:    exam.ExamType,
+		Status:      models.SheetAssigned,
+		Submitted:   false,
+		Duration:    duration,
+		Seat:        seat,
+		Transitions: []models.SheetTransition{{
+			To: models.SheetAssigned,
+			At: primitive.NewDateTimeFromTime(time.Now()),
+			By: userEmail,
+		}},
+	}
+
+	// The students sitting around the seat must not get the same paper.
+	// Their sets are read in the same transaction that takes the seat, so
+	// neighbours logging in together still get different ones.
+	pick := func(sheet *models.AnswerSheet, nearby []models.AnswerSheet) error {
+		neighbourSets := map[primitive.ObjectID]int{}
+		for _, other := range nearby {
+			if *other.Seat == *sheet.Seat {
+				return store.ErrConflict
+			}
+			if seatsAdjacent(*other.Seat, *sheet.Seat) {
+				neighbourSets[other.QPaperID]++
+			}
+		}
+		set := pickSet(exam.Sets, counter, neighbourSets)
+		sheet.QPaperID = set
+		sheet.Set = papers[set].Set
+		sheet.Data = emptyAnswers(papers[set])
+		return nil
+	}
+
+	// Insert the answer sheet and link it to the exam and the student's
+	// container atomically. A concurrent request that got there first wins
+	// and its sheet is returned instead.
+	assigned, created, err := h.store.AnswerSheets.Assign(ctx, &answerSheet, containerID, pick)
+	if errors.Is(err, store.ErrConflict) {
+		c.JSON(http.StatusConflict, gin.H{"error": "This seat is already taken", "code": "seat_taken"})
+		return
+	}
 	if err != nil {
-		fmt.Println("answersheet collection error")
+		fmt.Println("answersheet assign error: ", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create answer sheet"})
 		return
 	}
-	ansSheetID := result.InsertedID.(primitive.ObjectID)
-
-	// Update exam data to store answer sheet ID
-	examCollection.UpdateOne(context.TODO(), bson.M{"_id": examObjID}, bson.M{"$push": bson.M{"answer_sheets": ansSheetID}})
+	if !created {
+		c.JSON(http.StatusOK, toBsonM(assigned))
+		return
+	}
+	h.events.Publish(events.Event{
+		Type:          events.SheetAssigned,
+		ExamID:        answerSheet.ExamID,
+		AnswerSheetID: answerSheet.ID,
+		StudentEmail:  answerSheet.Email,
+		Data:          monitorSheet(&answerSheet, time.Now()),
+	})
+
+	h.recordAudit(ctx, c, models.AuditLog{
+		Action:       "answer_sheet.assigned",
+		TargetID:     answerSheet.ID,
+		ExamID:       examObjID,
+		StudentEmail: userEmail,
+		Changes:      map[string]models.AuditChange{"status": {Before: nil, After: answerSheet.Status}},
+		Details:      map[string]interface{}{"qpaper_id": answerSheet.QPaperID, "set": answerSheet.Set, "seat": seat},
+	})
+
+	// The frontend reads the bson keys (_id, qpaper_id) of the sheet.
+	c.JSON(http.StatusOK, toBsonM(answerSheet))
+}
 
-	// Update student's container with assigned question paper
-	_, err = studentContainerCollection.UpdateOne(context.TODO(),
-		bson.M{"_id": studentContainer.ID},
-		bson.M{"$push": bson.M{"question_papers": bson.M{
-			"exam_id":           examObjID,
-			"question_paper_id": selectedQPaperID,
-			"answer_sheet_id":   ansSheetID,
-		}}},
-	)
+// transitionSheet moves an answer sheet through the state machine and records
+// the change in the audit log. On failure it writes the error response and
+// returns nil.
+func (h *Handler) transitionSheet(ctx context.Context, c *gin.Context, id primitive.ObjectID, action string, change store.SheetChange) *models.AnswerSheet {
+	change.By = c.MustGet("email").(string)
+	previous, err := h.moveSheet(ctx, id, change)
+	if errors.Is(err, store.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Answer sheet not found"})
+		return nil
+	}
+	if errors.Is(err, store.ErrConflict) {
+		if !change.StaleBefore.IsZero() {
+			current, getErr := h.store.AnswerSheets.GetByID(ctx, id)
+			if getErr == nil && current.Status.CanTransition(change.To) && heldByOtherSession(current, change.SessionID, time.Now()) {
+				c.JSON(http.StatusConflict, gin.H{"error": "This exam is open on another device", "code": "session_conflict"})
+				return nil
+			}
+		}
+		c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("Answer sheet can't be moved to %s from its current state", change.To)})
+		return nil
+	}
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update student container"})
-		return
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update answer sheet"})
+		return nil
+	}
+
+	entry := models.AuditLog{
+		Action:       action,
+		TargetID:     id,
+		ExamID:       previous.ExamID,
+		StudentEmail: previous.Email,
+		Changes: map[string]models.AuditChange{
+			"status":    {Before: previous.Status, After: change.To},
+			"submitted": {Before: previous.Submitted, After: change.To.Submitted()},
+		},
+	}
+	if change.Reason != "" {
+		entry.Details = map[string]interface{}{"reason": change.Reason}
 	}
+	h.recordAudit(ctx, c, entry)
+	return previous
+}
 
-	c.JSON(http.StatusOK, answerSheet)
+// ownSheet loads an answer sheet of the signed in student. It writes the
+// error response and returns nil when the sheet is not theirs.
+func (h *Handler) ownSheet(ctx context.Context, c *gin.Context, id primitive.ObjectID) *models.AnswerSheet {
+	answerSheet, err := h.store.AnswerSheets.GetByID(ctx, id)
+	if err != nil || answerSheet.Email != c.MustGet("email").(string) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Answer sheet not found"})
+		return nil
+	}
+	return answerSheet
 }
 
-func StartExam(c *gin.Context) {
+// StartExam moves an assigned sheet to started. Starting a sheet that is
+// already running is a no-op, so reloading the page is safe; closed sheets
+// can't be restarted. Internal exams can only be started while the teacher
+// keeps them open.
+func (h *Handler) StartExam(c *gin.Context) {
 	answerSheetID := c.Param("answerSheetId")
 	objID, err := primitive.ObjectIDFromHex(answerSheetID)
 	if err != nil {
@@ -618,19 +728,49 @@ func StartExam(c *gin.Context) {
 		return
 	}
 
-	// Update the status to "started"
-	update := bson.M{"$set": bson.M{"status": "started"}}
-	_, err = answerSheetCollection.UpdateOne(context.TODO(), bson.M{"_id": objID}, update)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	answerSheet := h.ownSheet(ctx, c, objID)
+	if answerSheet == nil {
+		return
+	}
+	switch answerSheet.Status {
+	case models.SheetStarted:
+		if h.expireIfOverdue(ctx, c, answerSheet) {
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "Exam already started"})
+		return
+	case models.SheetAssigned:
+	default:
+		c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("Exam can't be started, the answer sheet is %s", answerSheet.Status)})
+		return
+	}
+
+	exam, err := h.store.Exams.GetByID(ctx, answerSheet.ExamID)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start the exam"})
+		c.JSON(http.StatusNotFound, gin.H{"error": "Exam not found"})
+		return
+	}
+	if exam.Paused {
+		c.JSON(http.StatusConflict, gin.H{"error": "The exam is paused by the teacher", "code": "exam_paused"})
+		return
+	}
+	if exam.ExamType == models.ExamInternal && !exam.Open {
+		c.JSON(http.StatusConflict, gin.H{"error": "The teacher has not opened this exam yet", "code": "exam_closed"})
+		return
+	}
+
+	if h.transitionSheet(ctx, c, objID, "answer_sheet.started", store.SheetChange{To: models.SheetStarted}) == nil {
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{"message": "Exam started successfully"})
 }
 
-// Submit Exam - Updates the status to "ended", marks submitted as true, and stores the answers
-func SubmitExam(c *gin.Context) {
+// Submit Exam - Moves a started sheet to submitted and stores the answers
+func (h *Handler) SubmitExam(c *gin.Context) {
 	answerSheetID := c.Param("answerSheetId")
 	objID, err := primitive.ObjectIDFromHex(answerSheetID)
 	if err != nil {
@@ -640,14 +780,9 @@ func SubmitExam(c *gin.Context) {
 
 	// Define request body structure
 	var requestBody struct {
-		Answers []struct {
-			Question string `json:"question"`
-			Answers  []struct {
-				Type string `json:"type"`
-				Ans  string `json:"ans"`
-			} `json:"answers"`
-		} `json:"answers"`
-		AIScore *float64 `json:"ai_score,omitempty"`
+		Answers   []models.AnswerData `json:"answers"`
+		AIScore   *float64            `json:"ai_score,omitempty"`
+		SessionID string              `json:"session_id"`
 	}
 
 	// Bind request body
@@ -656,55 +791,80 @@ func SubmitExam(c *gin.Context) {
 		return
 	}
 
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
 	// Find the existing answer sheet
-	var answerSheet models.AnswerSheet
-	err = answerSheetCollection.FindOne(context.TODO(), bson.M{"_id": objID}).Decode(&answerSheet)
-	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Answer sheet not found"})
+	answerSheet := h.ownSheet(ctx, c, objID)
+	if answerSheet == nil {
 		return
 	}
 
 	// Ensure the exam is in progress before submitting
-	if answerSheet.Status != "started" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Exam has not been started or is already submitted"})
-		return
-	}
-
-	// Map submitted answers to the existing answer sheet structure
-	for i, q := range answerSheet.Data {
-		for _, submittedQ := range requestBody.Answers {
-			if q.Question == submittedQ.Question {
-				for j, ans := range q.Answers {
-					for _, submittedAns := range submittedQ.Answers {
-						if ans.Type == submittedAns.Type {
-							answerSheet.Data[i].Answers[j].Ans = submittedAns.Ans
-						}
-					}
-				}
-			}
-		}
+	if answerSheet.Status != models.SheetStarted {
+		c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("Exam can't be submitted, the answer sheet is %s", answerSheet.Status)})
+		return
 	}
-
-	// Update the answer sheet in the database
-	update := bson.M{
-		"$set": bson.M{
-			"status":    "ended",
-			"submitted": true,
-			"data":      answerSheet.Data,
-			"ai_score":  requestBody.AIScore,
-		},
+	if h.expireIfOverdue(ctx, c, answerSheet) {
+		return
 	}
+	mergeAnswers(answerSheet.Data, requestBody.Answers)
 
-	_, err = answerSheetCollection.UpdateOne(context.TODO(), bson.M{"_id": objID}, update)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to submit the exam"})
+	// The transition only applies if the sheet is still started and no
+	// other device holds it, so a double submit, a concurrent pause or a
+	// session that lost the sheet can't overwrite each other
+	change := store.SheetChange{
+		To:          models.SheetSubmitted,
+		Data:        answerSheet.Data,
+		AIScore:     requestBody.AIScore,
+		SessionID:   requestBody.SessionID,
+		StaleBefore: time.Now().Add(-sessionTimeout),
+	}
+	if h.transitionSheet(ctx, c, objID, "answer_sheet.submitted", change) == nil {
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{"message": "Exam submitted successfully"})
 }
 
-func GetAnswerSheetByID(c *gin.Context) {
+// submitGrace is how long after its deadline a sheet can still be submitted,
+// it covers the time the request takes to arrive.
+const submitGrace = time.Minute
+
+// expireIfOverdue moves a started sheet whose deadline has passed to expired
+// and writes the response. It reports false when the sheet still has time.
+func (h *Handler) expireIfOverdue(ctx context.Context, c *gin.Context, answerSheet *models.AnswerSheet) bool {
+	left, timed := answerSheet.Remaining(time.Now().Add(-submitGrace))
+	if !timed || left > 0 {
+		return false
+	}
+
+	_, err := h.moveSheet(ctx, answerSheet.ID, store.SheetChange{
+		To:     models.SheetExpired,
+		By:     c.MustGet("email").(string),
+		Reason: "Time ran out",
+	})
+	if err != nil && !errors.Is(err, store.ErrConflict) {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update answer sheet"})
+		return true
+	}
+	if err == nil {
+		h.recordAudit(ctx, c, models.AuditLog{
+			Action:       "answer_sheet.expired",
+			TargetID:     answerSheet.ID,
+			ExamID:       answerSheet.ExamID,
+			StudentEmail: answerSheet.Email,
+			Changes: map[string]models.AuditChange{
+				"status": {Before: answerSheet.Status, After: models.SheetExpired},
+			},
+		})
+	}
+
+	c.JSON(http.StatusConflict, gin.H{"error": "Time is up, the answer sheet has expired", "code": "expired"})
+	return true
+}
+
+func (h *Handler) GetAnswerSheetByID(c *gin.Context) {
 	answerSheetID := c.Param("id")
 	ansSheetObjID, err := primitive.ObjectIDFromHex(answerSheetID)
 	if err != nil {
@@ -712,17 +872,29 @@ func GetAnswerSheetByID(c *gin.Context) {
 		return
 	}
 
-	var answerSheet models.AnswerSheet
-	err = answerSheetCollection.FindOne(context.TODO(), bson.M{"_id": ansSheetObjID}).Decode(&answerSheet)
-	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Answer sheet not found"})
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	answerSheet := h.ownSheet(ctx, c, ansSheetObjID)
+	if answerSheet == nil {
 		return
 	}
 
-	c.JSON(http.StatusOK, answerSheet)
+	// The server's clock is the one that counts, clients count down from
+	// remaining_seconds instead of trusting their own time
+	response := struct {
+		*models.AnswerSheet
+		RemainingSeconds *int64 `json:"remaining_seconds,omitempty"`
+	}{AnswerSheet: answerSheet}
+	if left, timed := answerSheet.Remaining(time.Now()); timed {
+		seconds := int64(left.Seconds())
+		response.RemainingSeconds = &seconds
+	}
+
+	c.JSON(http.StatusOK, response)
 }
 
-func GetAllAnswerSheetsByExamID(c *gin.Context) {
+func (h *Handler) GetAllAnswerSheetsByExamID(c *gin.Context) {
 	examID := c.Param("examid") // Get exam ID from URL params
 	// fmt.Println("getallanswersheets function called")
 
@@ -733,50 +905,33 @@ func GetAllAnswerSheetsByExamID(c *gin.Context) {
 		return
 	}
 
-	// Define MongoDB filter
-	filter := bson.M{"exam_id": objID}
-
-	// Query MongoDB
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	cursor, err := answerSheetCollection.Find(ctx, filter)
+	sheets, err := h.store.AnswerSheets.List(ctx, store.AnswerSheetFilter{ExamID: &objID})
 	if err != nil {
 		fmt.Println("error here at cursor")
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch answer sheets"})
 		return
 	}
-	defer cursor.Close(ctx)
 
 	// Fetch evaluated answer sheets where evaluated is true
-	evaluatedSheetsCursor, err := evaluationCollection.Find(ctx, bson.M{"evaluated": true})
+	evaluated := true
+	evaluations, err := h.store.Evaluations.List(ctx, store.EvaluationFilter{ExamID: &objID, Evaluated: &evaluated})
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch evaluated answer sheets"})
 		return
 	}
-	defer evaluatedSheetsCursor.Close(ctx)
 
 	evaluatedSheetIDs := make(map[primitive.ObjectID]bool)
-
-	for evaluatedSheetsCursor.Next(ctx) {
-		var evaluation models.Evaluation
-		if err := evaluatedSheetsCursor.Decode(&evaluation); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error decoding evaluation"})
-			return
-		}
+	for _, evaluation := range evaluations {
 		evaluatedSheetIDs[evaluation.AnswerSheetID] = true
 	}
 
 	// Prepare answer sheets list excluding evaluated ones
 	var answerSheets []bson.M
 
-	for cursor.Next(ctx) {
-		var answerSheet models.AnswerSheet
-		if err := cursor.Decode(&answerSheet); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error decoding answer sheet"})
-			return
-		}
-
+	for _, answerSheet := range sheets {
 		if _, evaluated := evaluatedSheetIDs[answerSheet.ID]; evaluated {
 			continue
 		}
@@ -796,9 +951,7 @@ func GetAllAnswerSheetsByExamID(c *gin.Context) {
 	c.JSON(http.StatusOK, answerSheets)
 }
 
-var evaluationCollection *mongo.Collection = config.GetCollection(config.Client, "evaluations")
-
-func CreateEvaluationByAnswerSheetID(c *gin.Context) {
+func (h *Handler) CreateEvaluationByAnswerSheetID(c *gin.Context) {
 	answerSheetID := c.Param("answersheetid")
 
 	// Convert answerSheetID to ObjectID
@@ -812,8 +965,7 @@ func CreateEvaluationByAnswerSheetID(c *gin.Context) {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	var existingEvaluation models.Evaluation
-	err = evaluationCollection.FindOne(ctx, bson.M{"answer_sheet_id": objID}).Decode(&existingEvaluation)
+	existingEvaluation, err := h.store.Evaluations.GetByAnswerSheet(ctx, objID)
 	if err == nil {
 		// Evaluation already exists, return its ID
 		c.JSON(http.StatusOK, gin.H{"message": "Evaluation already exists", "evaluation_id": existingEvaluation.ID})
@@ -821,14 +973,36 @@ func CreateEvaluationByAnswerSheetID(c *gin.Context) {
 	}
 
 	// Fetch the AnswerSheet
-	var answerSheet models.AnswerSheet
-	err = answerSheetCollection.FindOne(ctx, bson.M{"_id": objID}).Decode(&answerSheet)
+	answerSheet, err := h.store.AnswerSheets.GetByID(ctx, objID)
 	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Answer sheet not found"})
 		return
 	}
+	if !answerSheet.Status.Submitted() {
+		c.JSON(http.StatusConflict, gin.H{"error": "Answer sheet has not been submitted yet"})
+		return
+	}
+
+	evaluation, err := h.createEvaluation(ctx, answerSheet)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create evaluation"})
+		return
+	}
+
+	h.recordAudit(ctx, c, models.AuditLog{
+		Action:       "evaluation.created",
+		TargetID:     evaluation.ID,
+		ExamID:       evaluation.ExamID,
+		StudentEmail: evaluation.Email,
+		Details:      map[string]interface{}{"answer_sheet_id": evaluation.AnswerSheetID},
+	})
+
+	c.JSON(http.StatusOK, gin.H{"message": "Evaluation created successfully", "evaluation_id": evaluation.ID})
+}
 
-	// Prepare evaluation data
+// createEvaluation copies a submitted answer sheet into a new, ungraded
+// evaluation and links it to the exam in the teacher's container.
+func (h *Handler) createEvaluation(ctx context.Context, answerSheet *models.AnswerSheet) (*models.Evaluation, error) {
 	evaluation := models.Evaluation{
 		ID:            primitive.NewObjectID(),
 		AnswerSheetID: answerSheet.ID,
@@ -839,30 +1013,14 @@ func CreateEvaluationByAnswerSheetID(c *gin.Context) {
 		QPaperID:      answerSheet.QPaperID,
 		Set:           answerSheet.Set,
 		AIScore:       answerSheet.AIScore,
-		Data: []struct {
-			Question string `bson:"question" json:"question"`
-			Answers  []struct {
-				Type string `bson:"type" json:"type"`
-				Ans  string `bson:"ans" json:"ans"`
-			} `bson:"answers" json:"answers"`
-			AIEvaluation string `bson:"ai_evaluation" json:"ai_evaluation"`
-			Marks        int    `bson:"marks" json:"marks"`
-		}{},
-		TotalMarks: 0,
-		Evaluated:  false,
+		Data:          []models.EvaluationData{},
+		TotalMarks:    0,
+		Evaluated:     false,
 	}
 
 	// Copy data from answer sheet and add empty evaluation fields
 	for _, q := range answerSheet.Data {
-		evalData := struct {
-			Question string `bson:"question" json:"question"`
-			Answers  []struct {
-				Type string `bson:"type" json:"type"`
-				Ans  string `bson:"ans" json:"ans"`
-			} `bson:"answers" json:"answers"`
-			AIEvaluation string `bson:"ai_evaluation" json:"ai_evaluation"`
-			Marks        int    `bson:"marks" json:"marks"`
-		}{
+		evalData := models.EvaluationData{
 			Question: q.Question,
 			Answers:  q.Answers,
 			Marks:    0, // Marks field empty initially
@@ -871,26 +1029,18 @@ func CreateEvaluationByAnswerSheetID(c *gin.Context) {
 		evaluation.Data = append(evaluation.Data, evalData)
 	}
 
-	// Insert evaluation into DB
-	res, err := evaluationCollection.InsertOne(ctx, evaluation)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create evaluation"})
-		return
+	if err := h.store.Evaluations.Create(ctx, &evaluation); err != nil {
+		return nil, err
 	}
 
 	// Get teacher container and update with new evaluation ID
-	teacherContainerFilter := bson.M{"exams.exam_id": answerSheet.ExamID}
-	update := bson.M{"$push": bson.M{"exams.$.evaluation_id": evaluation.ID}}
-	_, err = teacherContainerCollection.UpdateOne(ctx, teacherContainerFilter, update)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update teacher container"})
-		return
+	if err := h.store.Containers.AddEvaluationToExam(ctx, answerSheet.ExamID, evaluation.ID); err != nil {
+		return nil, err
 	}
-
-	c.JSON(http.StatusOK, gin.H{"message": "Evaluation created successfully", "evaluation_id": res.InsertedID})
+	return &evaluation, nil
 }
 
-func GetEvaluationByID(c *gin.Context) {
+func (h *Handler) GetEvaluationByID(c *gin.Context) {
 	evaluationID := c.Param("evaluationid")
 
 	// Convert evaluationID to ObjectID
@@ -904,8 +1054,7 @@ func GetEvaluationByID(c *gin.Context) {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	var evaluation models.Evaluation
-	err = evaluationCollection.FindOne(ctx, bson.M{"_id": objID}).Decode(&evaluation)
+	evaluation, err := h.store.Evaluations.GetByID(ctx, objID)
 	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Evaluation not found"})
 		return
@@ -915,7 +1064,7 @@ func GetEvaluationByID(c *gin.Context) {
 	c.JSON(http.StatusOK, evaluation)
 }
 
-func UpdateEvaluation(c *gin.Context) {
+func (h *Handler) UpdateEvaluation(c *gin.Context) {
 	evaluationID := c.Param("evaluationId")
 
 	// Convert evaluationID to ObjectID
@@ -927,17 +1076,9 @@ func UpdateEvaluation(c *gin.Context) {
 
 	// Parse request body
 	var requestBody struct {
-		Data []struct {
-			Question     string `json:"question"`
-			Marks        int    `json:"marks"`
-			AIEvaluation string `json:"ai_evaluation"`
-			Answers      []struct {
-				Type string `json:"type"`
-				Ans  string `json:"ans"`
-			} `json:"answers"`
-		} `json:"data"`
-		TotalMarks int  `json:"total_marks"`
-		Evaluated  bool `json:"evaluated"`
+		Data       []models.EvaluationData `json:"data"`
+		TotalMarks int                     `json:"total_marks"`
+		Evaluated  bool                    `json:"evaluated"`
 	}
 
 	if err := c.ShouldBindJSON(&requestBody); err != nil {
@@ -949,32 +1090,68 @@ func UpdateEvaluation(c *gin.Context) {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	update := bson.M{
-		"$set": bson.M{
-			"data":        requestBody.Data,
-			"total_marks": requestBody.TotalMarks,
-			"evaluated":   requestBody.Evaluated,
-		},
+	existingEvaluation, err := h.store.Evaluations.GetByID(ctx, objID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Evaluation not found"})
+		return
 	}
 
-	_, err = evaluationCollection.UpdateOne(ctx, bson.M{"_id": objID}, update)
+	fields := bson.M{
+		"data":        requestBody.Data,
+		"total_marks": requestBody.TotalMarks,
+		"evaluated":   requestBody.Evaluated,
+	}
+	err = h.store.Evaluations.UpdateGrading(ctx, objID, requestBody.Data, requestBody.TotalMarks, requestBody.Evaluated)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update evaluation"})
 		return
 	}
 
+	h.recordAudit(ctx, c, models.AuditLog{
+		Action:       "evaluation.updated",
+		TargetID:     objID,
+		ExamID:       existingEvaluation.ExamID,
+		StudentEmail: existingEvaluation.Email,
+		Changes: auditChanges(bson.M{
+			"data":        existingEvaluation.Data,
+			"total_marks": existingEvaluation.TotalMarks,
+			"evaluated":   existingEvaluation.Evaluated,
+		}, fields),
+	})
+
+	// Every finalized grading is kept as a version so later revisions never
+	// lose the original marks
+	if requestBody.Evaluated {
+		if _, err := h.saveEvaluationVersion(ctx, c, objID, "Evaluation finalized", nil); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save evaluation version"})
+			return
+		}
+
+		// A sheet that is already evaluated stays that way on regrading,
+		// vivas have no sheet at all
+		if !existingEvaluation.AnswerSheetID.IsZero() {
+			_, err := h.moveSheet(ctx, existingEvaluation.AnswerSheetID, store.SheetChange{
+				To: models.SheetEvaluated,
+				By: c.MustGet("email").(string),
+			})
+			if err != nil && !errors.Is(err, store.ErrConflict) {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update answer sheet"})
+				return
+			}
+		}
+	}
+
 	c.JSON(http.StatusOK, gin.H{"message": "Evaluation updated successfully"})
 }
 
-func GetEvaluatedExamsByTeacherContainer(c *gin.Context) {
+func (h *Handler) GetEvaluatedExamsByTeacherContainer(c *gin.Context) {
 	containerID := c.MustGet("container_id").(primitive.ObjectID)
 
-	var teacherContainer models.TeacherContainer
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
 	// Fetch the teacher's container
-	err := teacherContainerCollection.FindOne(ctx, bson.M{"_id": containerID}).Decode(&teacherContainer)
+	teacherContainer, err := h.store.Containers.GetTeacherContainer(ctx, containerID)
 	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Teacher container not found"})
 		return
@@ -992,17 +1169,15 @@ func GetEvaluatedExamsByTeacherContainer(c *gin.Context) {
 
 		for _, evalID := range exam.EvaluationID {
 			// fmt.Println("evalId: ", evalID)
-			var evaluation models.Evaluation
-			err := evaluationCollection.FindOne(ctx, bson.M{"_id": evalID, "evaluated": true}).Decode(&evaluation)
-			if err == nil { // Only append if the document exists
-				evaluations = append(evaluations, evaluation)
+			evaluation, err := h.store.Evaluations.GetByID(ctx, evalID)
+			if err == nil && evaluation.Evaluated { // Only append if the document exists
+				evaluations = append(evaluations, *evaluation)
 			}
 		}
 		// fmt.Println("evalutions data: ", evaluations)
 		if len(evaluations) > 0 {
 			// Fetch exam details
-			var examDetails models.Exam
-			err := examCollection.FindOne(ctx, bson.M{"_id": exam.ExamID}).Decode(&examDetails)
+			examDetails, err := h.store.Exams.GetByID(ctx, exam.ExamID)
 			if err != nil {
 				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch exam details"})
 				return
@@ -1021,7 +1196,7 @@ func GetEvaluatedExamsByTeacherContainer(c *gin.Context) {
 	c.JSON(http.StatusOK, evaluatedExams)
 }
 
-func GetAllStudentDetailsAndMarksByExamID(c *gin.Context) {
+func (h *Handler) GetAllStudentDetailsAndMarksByExamID(c *gin.Context) {
 	examID := c.Param("examid")
 
 	// Convert examID to ObjectID
@@ -1035,20 +1210,15 @@ func GetAllStudentDetailsAndMarksByExamID(c *gin.Context) {
 	defer cancel()
 
 	// Fetch only evaluated evaluations for the given exam ID
-	cursor, err := evaluationCollection.Find(ctx, bson.M{"exam_id": objID, "evaluated": true})
+	evaluated := true
+	evaluations, err := h.store.Evaluations.List(ctx, store.EvaluationFilter{ExamID: &objID, Evaluated: &evaluated})
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch evaluations"})
 		return
 	}
-	defer cursor.Close(ctx)
 
 	var studentDetails []bson.M
-	for cursor.Next(ctx) {
-		var evaluation models.Evaluation
-		if err := cursor.Decode(&evaluation); err != nil {
-			continue // Skip decoding errors and process remaining records
-		}
-
+	for _, evaluation := range evaluations {
 		studentDetails = append(studentDetails, bson.M{
 			"student_name": evaluation.StudentName,
 			"email":        evaluation.Email,
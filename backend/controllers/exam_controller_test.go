@@ -0,0 +1,90 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/Maheshkarri4444/Examify/models"
+	"github.com/Maheshkarri4444/Examify/store"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// takeoverBeforeTransition lets the phone take the sheet over just before
+// each transition reaches the store.
+type takeoverBeforeTransition struct {
+	store.AnswerSheetStore
+}
+
+func (s *takeoverBeforeTransition) Transition(ctx context.Context, id primitive.ObjectID, change store.SheetChange) (*models.AnswerSheet, error) {
+	beat := store.SheetHeartbeat{
+		Session:  models.SheetSession{ID: "phone", LastSeen: primitive.NewDateTimeFromTime(time.Now())},
+		Takeover: true,
+	}
+	if _, err := s.AnswerSheetStore.Heartbeat(ctx, id, beat); err != nil {
+		return nil, err
+	}
+	return s.AnswerSheetStore.Transition(ctx, id, change)
+}
+
+func TestSubmitExamSession(t *testing.T) {
+	tests := []struct {
+		name       string
+		holder     string        // the session holding the sheet, if any
+		lastSeen   time.Duration // how long ago the holder was seen
+		takeover   bool          // another device takes over during the submit
+		wantCode   int
+		wantStatus models.SheetStatus
+	}{
+		{name: "no session", wantCode: http.StatusOK, wantStatus: models.SheetSubmitted},
+		{name: "own session", holder: "laptop", wantCode: http.StatusOK, wantStatus: models.SheetSubmitted},
+		{name: "held by another device", holder: "phone", wantCode: http.StatusConflict, wantStatus: models.SheetStarted},
+		{name: "other device gone", holder: "phone", lastSeen: 2 * sessionTimeout, wantCode: http.StatusOK, wantStatus: models.SheetSubmitted},
+		{name: "taken over during the submit", holder: "laptop", takeover: true, wantCode: http.StatusConflict, wantStatus: models.SheetStarted},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, stores := newTestHandler(t)
+			student := testUser{Email: "student@example.com", Role: models.RoleStudent}
+			sheet := &models.AnswerSheet{
+				ExamID: primitive.NewObjectID(),
+				Email:  student.Email,
+				Status: models.SheetStarted,
+				Data:   []models.AnswerData{{Question: "q1", Answers: []models.Answer{{Type: "js"}}}},
+			}
+			if tt.holder != "" {
+				sheet.Session = &models.SheetSession{ID: tt.holder, LastSeen: primitive.NewDateTimeFromTime(time.Now().Add(-tt.lastSeen))}
+			}
+			if err := stores.AnswerSheets.Create(context.Background(), sheet); err != nil {
+				t.Fatal(err)
+			}
+			if tt.takeover {
+				stores.AnswerSheets = &takeoverBeforeTransition{stores.AnswerSheets}
+			}
+
+			body := map[string]interface{}{
+				"session_id": "laptop",
+				"answers":    []models.AnswerData{{Question: "q1", Answers: []models.Answer{{Type: "js", Ans: "1"}}}},
+			}
+			w := serve(t, student, http.MethodPost, "/submit-exam/:answerSheetId", "/submit-exam/"+sheet.ID.Hex(), body, h.SubmitExam)
+			if w.Code != tt.wantCode {
+				t.Fatalf("code = %d, want %d: %s", w.Code, tt.wantCode, w.Body)
+			}
+			if tt.wantCode == http.StatusConflict {
+				var response struct {
+					Code string `json:"code"`
+				}
+				json.Unmarshal(w.Body.Bytes(), &response)
+				if response.Code != "session_conflict" {
+					t.Errorf("code = %q, want session_conflict", response.Code)
+				}
+			}
+			got, _ := stores.AnswerSheets.GetByID(context.Background(), sheet.ID)
+			if got.Status != tt.wantStatus {
+				t.Errorf("status = %s, want %s", got.Status, tt.wantStatus)
+			}
+		})
+	}
+}
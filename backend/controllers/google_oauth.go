@@ -0,0 +1,248 @@
+package controllers
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt"
+)
+
+const (
+	oauthStateCookie = "examify_oauth_state"
+	oauthStateTTL    = 10 * time.Minute
+	googleCertsURL   = "https://www.googleapis.com/oauth2/v3/certs"
+)
+
+// oauthState is what we remember about a login between the redirect to
+// Google and the callback. It lives in a signed, short-lived cookie.
+type oauthState struct {
+	State    string `json:"state"`
+	Verifier string `json:"verifier"`
+	Nonce    string `json:"nonce"`
+	Expires  int64  `json:"exp"`
+}
+
+// googleIDClaims are the ID token claims we rely on after verification.
+type googleIDClaims struct {
+	Subject       string
+	Email         string
+	EmailVerified bool
+	HostedDomain  string
+	Name          string
+	Picture       string
+}
+
+func randomToken(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func (h *Handler) signOAuthState(payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(h.cfg.JWTSecret))
+	mac.Write([]byte(oauthStateCookie))
+	mac.Write(payload)
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func (h *Handler) setOAuthStateCookie(c *gin.Context, st oauthState) error {
+	payload, err := json.Marshal(st)
+	if err != nil {
+		return err
+	}
+	value := base64.RawURLEncoding.EncodeToString(payload) + "." + h.signOAuthState(payload)
+
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(oauthStateCookie, value, int(oauthStateTTL.Seconds()), "/auth", "", h.cfg.CookieSecure, true)
+	return nil
+}
+
+func (h *Handler) clearOAuthStateCookie(c *gin.Context) {
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(oauthStateCookie, "", -1, "/auth", "", h.cfg.CookieSecure, true)
+}
+
+// readOAuthState loads the state cookie, checks its signature and expiry and
+// compares it against the state echoed back by Google.
+func (h *Handler) readOAuthState(c *gin.Context, returnedState string) (*oauthState, error) {
+	value, err := c.Cookie(oauthStateCookie)
+	if err != nil || value == "" {
+		return nil, fmt.Errorf("login session not found or expired")
+	}
+
+	parts := strings.SplitN(value, ".", 2)
+	if len(parts) != 2 {
+		return nil, fmt.Errorf("malformed login session")
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return nil, fmt.Errorf("malformed login session")
+	}
+	if !hmac.Equal([]byte(parts[1]), []byte(h.signOAuthState(payload))) {
+		return nil, fmt.Errorf("login session signature mismatch")
+	}
+
+	var st oauthState
+	if err := json.Unmarshal(payload, &st); err != nil {
+		return nil, fmt.Errorf("malformed login session")
+	}
+	if time.Now().Unix() > st.Expires {
+		return nil, fmt.Errorf("login session expired")
+	}
+	if returnedState == "" || subtle.ConstantTimeCompare([]byte(st.State), []byte(returnedState)) != 1 {
+		return nil, fmt.Errorf("state mismatch")
+	}
+	return &st, nil
+}
+
+var googleKeys = struct {
+	sync.Mutex
+	keys    map[string]*rsa.PublicKey
+	fetched time.Time
+}{}
+
+// googlePublicKey returns the RSA key Google used to sign an ID token. Keys are
+// cached for an hour and refetched when an unknown key id shows up.
+func googlePublicKey(ctx context.Context, kid string) (*rsa.PublicKey, error) {
+	googleKeys.Lock()
+	defer googleKeys.Unlock()
+
+	if key, ok := googleKeys.keys[kid]; ok && time.Since(googleKeys.fetched) < time.Hour {
+		return key, nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, googleCertsURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch google certs: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch google certs: status %d", resp.StatusCode)
+	}
+
+	var jwks struct {
+		Keys []struct {
+			Kid string `json:"kid"`
+			Kty string `json:"kty"`
+			N   string `json:"n"`
+			E   string `json:"e"`
+		} `json:"keys"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&jwks); err != nil {
+		return nil, fmt.Errorf("failed to decode google certs: %w", err)
+	}
+
+	keys := make(map[string]*rsa.PublicKey)
+	for _, k := range jwks.Keys {
+		if k.Kty != "RSA" {
+			continue
+		}
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			continue
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil {
+			continue
+		}
+		keys[k.Kid] = &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}
+	}
+	googleKeys.keys = keys
+	googleKeys.fetched = time.Now()
+
+	key, ok := keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("unknown signing key %q", kid)
+	}
+	return key, nil
+}
+
+// verifyGoogleIDToken checks the signature, issuer, audience, expiry and nonce
+// of an ID token and enforces verified email and the hosted domain policy.
+func (h *Handler) verifyGoogleIDToken(ctx context.Context, rawIDToken, nonce string) (*googleIDClaims, error) {
+	token, err := jwt.Parse(rawIDToken, func(t *jwt.Token) (interface{}, error) {
+		if _, ok := t.Method.(*jwt.SigningMethodRSA); !ok {
+			return nil, fmt.Errorf("unexpected signing method %v", t.Header["alg"])
+		}
+		kid, _ := t.Header["kid"].(string)
+		return googlePublicKey(ctx, kid)
+	})
+	if err != nil || !token.Valid {
+		return nil, fmt.Errorf("invalid id token: %v", err)
+	}
+
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok {
+		return nil, fmt.Errorf("invalid id token claims")
+	}
+
+	iss, _ := claims["iss"].(string)
+	if iss != "accounts.google.com" && iss != "https://accounts.google.com" {
+		return nil, fmt.Errorf("unexpected issuer %q", iss)
+	}
+	if !claims.VerifyAudience(h.cfg.Google.ClientID, true) {
+		return nil, fmt.Errorf("id token audience mismatch")
+	}
+	if !claims.VerifyExpiresAt(time.Now().Unix(), true) {
+		return nil, fmt.Errorf("id token expired")
+	}
+	if tokenNonce, _ := claims["nonce"].(string); subtle.ConstantTimeCompare([]byte(tokenNonce), []byte(nonce)) != 1 {
+		return nil, fmt.Errorf("id token nonce mismatch")
+	}
+
+	idClaims := &googleIDClaims{}
+	idClaims.Subject, _ = claims["sub"].(string)
+	idClaims.Email, _ = claims["email"].(string)
+	idClaims.HostedDomain, _ = claims["hd"].(string)
+	idClaims.Name, _ = claims["name"].(string)
+	idClaims.Picture, _ = claims["picture"].(string)
+	switch v := claims["email_verified"].(type) {
+	case bool:
+		idClaims.EmailVerified = v
+	case string:
+		idClaims.EmailVerified = v == "true"
+	}
+
+	if idClaims.Email == "" {
+		return nil, fmt.Errorf("email not present in id token")
+	}
+	if !idClaims.EmailVerified {
+		return nil, fmt.Errorf("email address is not verified")
+	}
+	if domains := h.cfg.Google.AllowedHostedDomains; len(domains) > 0 {
+		allowed := false
+		for _, d := range domains {
+			if strings.EqualFold(idClaims.HostedDomain, d) {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return nil, fmt.Errorf("hosted domain %q is not allowed", idClaims.HostedDomain)
+		}
+	}
+
+	return idClaims, nil
+}
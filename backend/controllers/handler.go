@@ -0,0 +1,60 @@
+package controllers
+
+import (
+	"github.com/Maheshkarri4444/Examify/config"
+	"github.com/Maheshkarri4444/Examify/events"
+	"github.com/Maheshkarri4444/Examify/llm"
+	"github.com/Maheshkarri4444/Examify/queue"
+	"github.com/Maheshkarri4444/Examify/store"
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/google"
+)
+
+// Handler serves the HTTP API. All persistence goes through its stores, so
+// it can run against MongoDB or the in-memory stores in tests.
+type Handler struct {
+	cfg    *config.Config
+	store  *store.Stores
+	oauth  *oauth2.Config
+	events *events.Bus
+	jobs   *queue.Queue
+	ai     *llm.Client
+}
+
+func NewHandler(cfg *config.Config, stores *store.Stores, bus *events.Bus) *Handler {
+	h := &Handler{
+		cfg:    cfg,
+		store:  stores,
+		events: bus,
+		jobs:   queue.New(stores.Jobs, cfg.JobWorkers),
+		ai: llm.New(llm.Config{
+			APIKey:          cfg.GeminiAPIKey,
+			Model:           geminiModel,
+			Timeout:         cfg.AI.RequestTimeout,
+			Attempts:        cfg.AI.MaxAttempts,
+			Backoff:         cfg.AI.RetryBackoff,
+			BreakerFailures: cfg.AI.BreakerFailures,
+			BreakerCooldown: cfg.AI.BreakerCooldown,
+		}),
+		oauth: &oauth2.Config{
+			ClientID:     cfg.Google.ClientID,
+			ClientSecret: cfg.Google.ClientSecret,
+			RedirectURL:  cfg.Google.RedirectURL,
+			Scopes:       []string{"openid", "email", "profile"},
+			Endpoint:     google.Endpoint,
+		},
+	}
+	h.jobs.Register(jobAIPreEvaluation, preEvaluationAttempts, h.preEvaluateExam)
+	return h
+}
+
+// StartJobs starts the background job workers.
+func (h *Handler) StartJobs() {
+	h.jobs.Start()
+}
+
+// Shutdown stops the job workers. Jobs they were running go back to the
+// queue and continue after the next start.
+func (h *Handler) Shutdown() {
+	h.jobs.Shutdown()
+}
@@ -0,0 +1,128 @@
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/Maheshkarri4444/Examify/config"
+	"github.com/Maheshkarri4444/Examify/events"
+	"github.com/Maheshkarri4444/Examify/llm"
+	"github.com/Maheshkarri4444/Examify/models"
+	"github.com/Maheshkarri4444/Examify/store"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func init() {
+	gin.SetMode(gin.TestMode)
+}
+
+// newTestHandler returns a handler backed by the in-memory stores.
+func newTestHandler(t *testing.T) (*Handler, *store.Stores) {
+	t.Helper()
+	stores := store.NewMemoryStores()
+	h := NewHandler(&config.Config{JobWorkers: 1}, stores, events.NewBus())
+	t.Cleanup(h.Shutdown)
+	return h, stores
+}
+
+// testUser is who a test request is made by, in place of the auth
+// middleware.
+type testUser struct {
+	Name        string
+	Email       string
+	Role        string
+	ContainerID primitive.ObjectID
+}
+
+func newTeacher(t *testing.T, stores *store.Stores, email string) testUser {
+	t.Helper()
+	teacher := testUser{Email: email, Role: models.RoleTeacher, ContainerID: primitive.NewObjectID()}
+	if err := stores.Containers.EnsureTeacherContainer(context.Background(), teacher.ContainerID); err != nil {
+		t.Fatal(err)
+	}
+	return teacher
+}
+
+// serve runs one request against handler, registered on route.
+func serve(t *testing.T, user testUser, method, route, path string, body interface{}, handler gin.HandlerFunc) *httptest.ResponseRecorder {
+	t.Helper()
+	r := gin.New()
+	r.Handle(method, route, func(c *gin.Context) {
+		c.Set("name", user.Name)
+		c.Set("email", user.Email)
+		c.Set("role", user.Role)
+		c.Set("container_id", user.ContainerID)
+		c.Next()
+	}, handler)
+
+	var payload bytes.Buffer
+	if body != nil {
+		if err := json.NewEncoder(&payload).Encode(body); err != nil {
+			t.Fatal(err)
+		}
+	}
+	req := httptest.NewRequest(method, path, &payload)
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+// fakeModel stands in for the Gemini API. It answers with replies in order,
+// repeating the last one, and keeps the prompts it was sent.
+type fakeModel struct {
+	mu      sync.Mutex
+	replies []string
+	prompts []string
+}
+
+// useFakeModel points the handler's AI client at a fake model answering
+// with replies. An empty reply is a 500 from the provider.
+func useFakeModel(t *testing.T, h *Handler, replies ...string) *fakeModel {
+	t.Helper()
+	model := &fakeModel{replies: replies}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request struct {
+			Contents []struct {
+				Parts []struct {
+					Text string `json:"text"`
+				} `json:"parts"`
+			} `json:"contents"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			t.Error(err)
+		}
+		model.mu.Lock()
+		model.prompts = append(model.prompts, request.Contents[0].Parts[0].Text)
+		reply := model.replies[min(len(model.prompts), len(model.replies))-1]
+		model.mu.Unlock()
+
+		if reply == "" {
+			http.Error(w, "internal error", http.StatusInternalServerError)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"candidates": []map[string]interface{}{{
+				"content":      map[string]interface{}{"parts": []map[string]string{{"text": reply}}},
+				"finishReason": "STOP",
+			}},
+			"usageMetadata": map[string]int{"promptTokenCount": 10, "candidatesTokenCount": 5, "totalTokenCount": 15},
+		})
+	}))
+	t.Cleanup(srv.Close)
+	h.ai = llm.New(llm.Config{APIKey: "key", Model: "test-model", BaseURL: srv.URL})
+	return model
+}
+
+// Prompts returns the prompts the model was sent so far.
+func (m *fakeModel) Prompts() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]string(nil), m.prompts...)
+}
@@ -0,0 +1,172 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/Maheshkarri4444/Examify/events"
+	"github.com/Maheshkarri4444/Examify/models"
+	"github.com/Maheshkarri4444/Examify/store"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// sessionTimeout is how long a session keeps its hold on an answer sheet
+// without heartbeats. Clients beat every 15 seconds, so this allows two
+// missed beats before another device may take over.
+const sessionTimeout = 45 * time.Second
+
+// Heartbeat tells the server the student's browser is still there. It claims
+// the answer sheet for one session, identified by a random session_id the
+// client keeps for the attempt, and autosaves the answers sent with it. A
+// second device gets 409 session_conflict while the first one is alive,
+// unless it asks to take over, which is logged and shown to the teacher.
+func (h *Handler) Heartbeat(c *gin.Context) {
+	objID, err := primitive.ObjectIDFromHex(c.Param("answerSheetId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid answerSheet ID"})
+		return
+	}
+
+	var requestBody struct {
+		SessionID   string              `json:"session_id" binding:"required,max=100"`
+		Fingerprint string              `json:"fingerprint" binding:"max=256"`
+		Takeover    bool                `json:"takeover"`
+		Answers     []models.AnswerData `json:"answers"`
+	}
+	if err := c.ShouldBindJSON(&requestBody); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "A session_id is required"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	answerSheet := h.ownSheet(ctx, c, objID)
+	if answerSheet == nil {
+		return
+	}
+	if answerSheet.Status != models.SheetStarted && answerSheet.Status != models.SheetPaused {
+		c.JSON(http.StatusConflict, gin.H{"error": "Exam is not in progress", "code": "not_running", "status": answerSheet.Status})
+		return
+	}
+	if answerSheet.Status == models.SheetStarted && h.expireIfOverdue(ctx, c, answerSheet) {
+		return
+	}
+
+	now := time.Now()
+	session := models.SheetSession{
+		ID:          requestBody.SessionID,
+		Fingerprint: requestBody.Fingerprint,
+		IP:          c.ClientIP(),
+		UserAgent:   c.Request.UserAgent(),
+		StartedAt:   primitive.NewDateTimeFromTime(now),
+		LastSeen:    primitive.NewDateTimeFromTime(now),
+	}
+	if answerSheet.Session != nil && answerSheet.Session.ID == session.ID {
+		session.StartedAt = answerSheet.Session.StartedAt
+	}
+	beat := store.SheetHeartbeat{
+		Session:     session,
+		StaleBefore: now.Add(-sessionTimeout),
+		Takeover:    requestBody.Takeover,
+	}
+	// Answers can only change while the clock runs
+	if answerSheet.Status == models.SheetStarted && requestBody.Answers != nil {
+		beat.Data = mergeAnswers(answerSheet.Data, requestBody.Answers)
+	}
+
+	previous, err := h.store.AnswerSheets.Heartbeat(ctx, objID, beat)
+	if errors.Is(err, store.ErrConflict) {
+		// The teacher may have paused or closed the sheet since it was read
+		current, err := h.store.AnswerSheets.GetByID(ctx, objID)
+		if err == nil && current.Status != answerSheet.Status {
+			if current.Status != models.SheetStarted && current.Status != models.SheetPaused {
+				c.JSON(http.StatusConflict, gin.H{"error": "Exam is not in progress", "code": "not_running", "status": current.Status})
+				return
+			}
+			c.JSON(http.StatusConflict, gin.H{"error": "Exam was paused or resumed, try again", "code": "status_changed", "status": current.Status})
+			return
+		}
+		h.events.Publish(events.Event{
+			Type:          events.Proctoring,
+			ExamID:        answerSheet.ExamID,
+			AnswerSheetID: objID,
+			StudentEmail:  answerSheet.Email,
+			Data:          gin.H{"type": "concurrent_session", "ip": session.IP, "fingerprint": session.Fingerprint},
+		})
+		c.JSON(http.StatusConflict, gin.H{"error": "This exam is open on another device", "code": "session_conflict"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record heartbeat"})
+		return
+	}
+
+	if previous.Session != nil && previous.Session.ID != session.ID {
+		h.recordAudit(ctx, c, models.AuditLog{
+			Action:       "answer_sheet.session_changed",
+			TargetID:     objID,
+			ExamID:       answerSheet.ExamID,
+			StudentEmail: answerSheet.Email,
+			Changes: map[string]models.AuditChange{
+				"ip":          {Before: previous.Session.IP, After: session.IP},
+				"fingerprint": {Before: previous.Session.Fingerprint, After: session.Fingerprint},
+			},
+			Details: map[string]interface{}{"takeover": requestBody.Takeover},
+		})
+		h.events.Publish(events.Event{
+			Type:          events.Proctoring,
+			ExamID:        answerSheet.ExamID,
+			AnswerSheetID: objID,
+			StudentEmail:  answerSheet.Email,
+			Data:          gin.H{"type": "session_changed", "ip": session.IP, "takeover": requestBody.Takeover},
+		})
+	}
+	h.events.Publish(events.Event{
+		Type:          events.Heartbeat,
+		ExamID:        answerSheet.ExamID,
+		AnswerSheetID: objID,
+		StudentEmail:  answerSheet.Email,
+		Data:          gin.H{"ip": session.IP, "saved": beat.Data != nil},
+		At:            now,
+	})
+
+	response := gin.H{"status": previous.Status, "saved": beat.Data != nil}
+	if left, timed := previous.Remaining(now); timed {
+		response["remaining_seconds"] = int64(left.Seconds())
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// heldByOtherSession reports whether a live session other than sessionID
+// holds the sheet.
+func heldByOtherSession(sheet *models.AnswerSheet, sessionID string, now time.Time) bool {
+	if sheet.Session == nil || sheet.Session.ID == sessionID {
+		return false
+	}
+	return now.Sub(sheet.Session.LastSeen.Time()) < sessionTimeout
+}
+
+// mergeAnswers copies the submitted answers into data, matching questions by
+// text and answers by type, and returns data. Anything that doesn't match
+// the sheet's questions is ignored.
+func mergeAnswers(data, submitted []models.AnswerData) []models.AnswerData {
+	for i, q := range data {
+		for _, submittedQ := range submitted {
+			if q.Question != submittedQ.Question {
+				continue
+			}
+			for j, ans := range q.Answers {
+				for _, submittedAns := range submittedQ.Answers {
+					if ans.Type == submittedAns.Type {
+						data[i].Answers[j].Ans = submittedAns.Ans
+					}
+				}
+			}
+		}
+	}
+	return data
+}
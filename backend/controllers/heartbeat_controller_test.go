@@ -0,0 +1,87 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/Maheshkarri4444/Examify/events"
+	"github.com/Maheshkarri4444/Examify/models"
+	"github.com/Maheshkarri4444/Examify/store"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// racingHeartbeats moves the sheet to status just before each heartbeat
+// reaches the store, as a teacher pausing or closing the exam would.
+type racingHeartbeats struct {
+	store.AnswerSheetStore
+	status models.SheetStatus
+}
+
+func (s *racingHeartbeats) Heartbeat(ctx context.Context, id primitive.ObjectID, beat store.SheetHeartbeat) (*models.AnswerSheet, error) {
+	if _, err := s.AnswerSheetStore.Transition(ctx, id, store.SheetChange{To: s.status}); err != nil {
+		return nil, err
+	}
+	return s.AnswerSheetStore.Heartbeat(ctx, id, beat)
+}
+
+func TestHeartbeatRace(t *testing.T) {
+	tests := []struct {
+		name     string
+		status   models.SheetStatus // the sheet moves to before the heartbeat lands
+		wantCode string
+	}{
+		{name: "paused", status: models.SheetPaused, wantCode: "status_changed"},
+		{name: "closed", status: models.SheetSubmitted, wantCode: "not_running"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, stores := newTestHandler(t)
+			student := testUser{Email: "student@example.com", Role: models.RoleStudent}
+			sheet := &models.AnswerSheet{
+				ExamID: primitive.NewObjectID(),
+				Email:  student.Email,
+				Status: models.SheetStarted,
+				Data:   []models.AnswerData{{Question: "q1", Answers: []models.Answer{}}},
+			}
+			if err := stores.AnswerSheets.Create(context.Background(), sheet); err != nil {
+				t.Fatal(err)
+			}
+			stores.AnswerSheets = &racingHeartbeats{AnswerSheetStore: stores.AnswerSheets, status: tt.status}
+			sub, unsubscribe := h.events.Subscribe(sheet.ExamID)
+			defer unsubscribe()
+
+			body := map[string]interface{}{
+				"session_id": "laptop",
+				"answers":    []models.AnswerData{{Question: "q1", Answers: []models.Answer{{Type: "js", Ans: "1"}}}},
+			}
+			w := serve(t, student, http.MethodPost, "/heartbeat/:answerSheetId", "/heartbeat/"+sheet.ID.Hex(), body, h.Heartbeat)
+			if w.Code != http.StatusConflict {
+				t.Fatalf("code = %d, want %d: %s", w.Code, http.StatusConflict, w.Body)
+			}
+			var response struct {
+				Code string `json:"code"`
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+				t.Fatal(err)
+			}
+			if response.Code != tt.wantCode {
+				t.Errorf("code = %q, want %q", response.Code, tt.wantCode)
+			}
+
+			got, _ := stores.AnswerSheets.GetByID(context.Background(), sheet.ID)
+			if len(got.Data[0].Answers) != 0 {
+				t.Errorf("answers were saved on a %s sheet", got.Status)
+			}
+			select {
+			case e := <-sub:
+				if e.Type == events.Proctoring {
+					t.Errorf("reported %v, want no proctoring event for a status change", e.Data)
+				}
+			case <-time.After(10 * time.Millisecond):
+			}
+		})
+	}
+}
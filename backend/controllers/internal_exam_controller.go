@@ -0,0 +1,95 @@
+package controllers
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/Maheshkarri4444/Examify/events"
+	"github.com/Maheshkarri4444/Examify/models"
+	"github.com/Maheshkarri4444/Examify/store"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// OpenInternalExam lets students start and submit an internal exam. Internal
+// exams have no timer, they stay open until the teacher closes them.
+func (h *Handler) OpenInternalExam(c *gin.Context) {
+	h.setInternalExamOpen(c, true)
+}
+
+// CloseInternalExam stops new attempts and submits every sheet that is still
+// in progress.
+func (h *Handler) CloseInternalExam(c *gin.Context) {
+	h.setInternalExamOpen(c, false)
+}
+
+func (h *Handler) setInternalExamOpen(c *gin.Context, open bool) {
+	examID, err := primitive.ObjectIDFromHex(c.Param("examid"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid exam ID"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if !h.teacherOwnsExam(ctx, c.MustGet("container_id").(primitive.ObjectID), examID) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+		return
+	}
+
+	exam, err := h.store.Exams.GetByID(ctx, examID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Exam not found"})
+		return
+	}
+	if exam.ExamType != models.ExamInternal {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Only internal exams are opened and closed by the teacher"})
+		return
+	}
+
+	if err := h.store.Exams.SetOpen(ctx, examID, open); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update exam"})
+		return
+	}
+
+	action := "exam.opened"
+	var closedSheets int
+	if !open {
+		action = "exam.closed"
+		var failed []primitive.ObjectID
+		closedSheets, failed, err = h.transitionExamSheets(ctx, examID, runningSheetStatuses, store.SheetChange{
+			To:     models.SheetSubmitted,
+			By:     c.MustGet("email").(string),
+			Reason: "Exam closed by the teacher",
+		})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to submit running answer sheets"})
+			return
+		}
+		if len(failed) > 0 {
+			// The exam is already closed, closing it again submits the rest
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error":                "Failed to submit some running answer sheets, try again",
+				"submitted_sheets":     closedSheets,
+				"failed_answer_sheets": failed,
+			})
+			return
+		}
+	}
+
+	entry := models.AuditLog{
+		Action:   action,
+		TargetID: examID,
+		ExamID:   examID,
+		Changes:  map[string]models.AuditChange{"open": {Before: exam.Open, After: open}},
+	}
+	if !open {
+		entry.Details = map[string]interface{}{"submitted_sheets": closedSheets}
+	}
+	h.recordAudit(ctx, c, entry)
+	h.events.Publish(events.Event{Type: events.ExamUpdated, ExamID: examID, Data: gin.H{"open": open}})
+
+	c.JSON(http.StatusOK, gin.H{"message": "Exam updated successfully", "open": open, "submitted_sheets": closedSheets})
+}
@@ -0,0 +1,166 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Maheshkarri4444/Examify/models"
+	"github.com/Maheshkarri4444/Examify/store"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// idempotencyKey scopes the client's Idempotency-Key header to the signed in
+// user and the kind of job, so a retried request returns the job the first
+// one created. It is empty when the header is missing.
+func idempotencyKey(c *gin.Context, kind string) string {
+	key := strings.TrimSpace(c.GetHeader("Idempotency-Key"))
+	if key == "" {
+		return ""
+	}
+	return kind + ":" + c.MustGet("email").(string) + ":" + key
+}
+
+// GetJob returns a background job to the user who started it, or an admin.
+func (h *Handler) GetJob(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	job := h.visibleJob(ctx, c)
+	if job == nil {
+		return
+	}
+	c.JSON(http.StatusOK, job)
+}
+
+// CancelJob stops a background job of the user who started it, or of anyone
+// for an admin.
+func (h *Handler) CancelJob(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	job := h.visibleJob(ctx, c)
+	if job == nil {
+		return
+	}
+	h.cancelJob(ctx, c, job)
+}
+
+// ListJobs lets admins look at the queue, e.g. ?status=dead for the jobs
+// that gave up.
+func (h *Handler) ListJobs(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	filter := store.JobFilter{
+		Kind:      c.Query("kind"),
+		CreatedBy: strings.ToLower(c.Query("created_by")),
+	}
+	if status := c.Query("status"); status != "" {
+		filter.Statuses = []models.JobStatus{models.JobStatus(status)}
+	}
+	if examID := c.Query("exam_id"); examID != "" {
+		objID, err := primitive.ObjectIDFromHex(examID)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid exam ID"})
+			return
+		}
+		filter.ExamID = &objID
+	}
+	filter.Limit, _ = strconv.ParseInt(c.DefaultQuery("limit", "100"), 10, 64)
+	if filter.Limit <= 0 || filter.Limit > 500 {
+		filter.Limit = 100
+	}
+
+	jobs, err := h.store.Jobs.List(ctx, filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch jobs"})
+		return
+	}
+	c.JSON(http.StatusOK, jobs)
+}
+
+// RequeueJob runs a dead or cancelled job again with a fresh set of
+// attempts.
+func (h *Handler) RequeueJob(c *gin.Context) {
+	jobID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid job ID"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	job, err := h.store.Jobs.GetByID(ctx, jobID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Job not found"})
+		return
+	}
+
+	err = h.store.Jobs.Requeue(ctx, jobID)
+	if errors.Is(err, store.ErrConflict) {
+		c.JSON(http.StatusConflict, gin.H{"error": "Only dead or cancelled jobs can be requeued, and not while the same work is queued again"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to requeue job"})
+		return
+	}
+
+	h.recordAudit(ctx, c, models.AuditLog{
+		Action:   "job.requeued",
+		TargetID: jobID,
+		ExamID:   job.ExamID,
+		Changes:  map[string]models.AuditChange{"status": {Before: job.Status, After: models.JobQueued}},
+		Details:  map[string]interface{}{"kind": job.Kind, "last_error": job.LastError},
+	})
+
+	c.JSON(http.StatusOK, gin.H{"message": "Job requeued"})
+}
+
+// visibleJob loads the job in the :id parameter if the signed in user may
+// see it. It writes the error response and returns nil otherwise.
+func (h *Handler) visibleJob(ctx context.Context, c *gin.Context) *models.Job {
+	jobID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid job ID"})
+		return nil
+	}
+	job, err := h.store.Jobs.GetByID(ctx, jobID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Job not found"})
+		return nil
+	}
+	if c.MustGet("role").(string) != models.RoleAdmin && job.CreatedBy != c.MustGet("email").(string) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+		return nil
+	}
+	return job
+}
+
+func (h *Handler) cancelJob(ctx context.Context, c *gin.Context, job *models.Job) {
+	cancelled, err := h.store.Jobs.Cancel(ctx, job.ID)
+	if errors.Is(err, store.ErrConflict) {
+		c.JSON(http.StatusConflict, gin.H{"error": "Job has already finished", "job": job})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to cancel job"})
+		return
+	}
+
+	h.recordAudit(ctx, c, models.AuditLog{
+		Action:   "job.cancelled",
+		TargetID: job.ID,
+		ExamID:   job.ExamID,
+		Details:  map[string]interface{}{"kind": job.Kind, "status": job.Status, "progress": cancelled.Progress},
+	})
+
+	// A running job stops at its next heartbeat
+	c.JSON(http.StatusOK, gin.H{"message": "Job cancelled", "job": cancelled})
+}
@@ -0,0 +1,251 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/Maheshkarri4444/Examify/events"
+	"github.com/Maheshkarri4444/Examify/models"
+	"github.com/Maheshkarri4444/Examify/store"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+var runningSheetStatuses = []models.SheetStatus{models.SheetStarted, models.SheetPaused}
+
+// sheetMoveTimeout is the time transitionExamSheets allows per sheet.
+const sheetMoveTimeout = 200 * time.Millisecond
+
+// PauseExam stops the clock of every running sheet of an exam and keeps new
+// students from starting until the exam is resumed.
+func (h *Handler) PauseExam(c *gin.Context) {
+	h.setExamPaused(c, true)
+}
+
+// ResumeExam restarts the clock of every paused sheet of an exam.
+func (h *Handler) ResumeExam(c *gin.Context) {
+	h.setExamPaused(c, false)
+}
+
+func (h *Handler) setExamPaused(c *gin.Context, paused bool) {
+	examID, err := primitive.ObjectIDFromHex(c.Param("examid"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid exam ID"})
+		return
+	}
+	reason, ok := bindReason(c)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if !h.teacherOwnsExam(ctx, c.MustGet("container_id").(primitive.ObjectID), examID) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+		return
+	}
+	exam, err := h.store.Exams.GetByID(ctx, examID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Exam not found"})
+		return
+	}
+
+	if err := h.store.Exams.SetPaused(ctx, examID, paused); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update exam"})
+		return
+	}
+
+	action, from, to := "exam.paused", models.SheetStarted, models.SheetPaused
+	if !paused {
+		action, from, to = "exam.resumed", models.SheetPaused, models.SheetStarted
+	}
+	count, failed, err := h.transitionExamSheets(ctx, examID, []models.SheetStatus{from}, store.SheetChange{
+		To:     to,
+		By:     c.MustGet("email").(string),
+		Reason: reason,
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update answer sheets"})
+		return
+	}
+	if len(failed) > 0 {
+		// The exam is already paused or resumed, trying again moves the rest
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":                "Failed to update some answer sheets, try again",
+			"answer_sheets":        count,
+			"failed_answer_sheets": failed,
+		})
+		return
+	}
+
+	h.events.Publish(events.Event{Type: events.ExamUpdated, ExamID: examID, Data: gin.H{"paused": paused}})
+	h.recordAudit(ctx, c, models.AuditLog{
+		Action:   action,
+		TargetID: examID,
+		ExamID:   examID,
+		Changes:  map[string]models.AuditChange{"paused": {Before: exam.Paused, After: paused}},
+		Details:  map[string]interface{}{"reason": reason, "answer_sheets": count},
+	})
+
+	c.JSON(http.StatusOK, gin.H{"message": "Exam updated successfully", "paused": paused, "answer_sheets": count})
+}
+
+// PauseAnswerSheet stops the clock of one student.
+func (h *Handler) PauseAnswerSheet(c *gin.Context) {
+	h.controlSheet(c, models.SheetPaused, "answer_sheet.paused")
+}
+
+// ResumeAnswerSheet restarts the clock of one student, the time spent paused
+// is added to their deadline.
+func (h *Handler) ResumeAnswerSheet(c *gin.Context) {
+	h.controlSheet(c, models.SheetStarted, "answer_sheet.resumed")
+}
+
+// ForceSubmitAnswerSheet submits a running sheet on behalf of the student.
+func (h *Handler) ForceSubmitAnswerSheet(c *gin.Context) {
+	h.controlSheet(c, models.SheetSubmitted, "answer_sheet.force_closed")
+}
+
+// VoidAnswerSheet cancels an attempt, for example after malpractice. Voided
+// sheets are never evaluated.
+func (h *Handler) VoidAnswerSheet(c *gin.Context) {
+	h.controlSheet(c, models.SheetVoided, "answer_sheet.voided")
+}
+
+func (h *Handler) controlSheet(c *gin.Context, to models.SheetStatus, action string) {
+	reason, ok := bindReason(c)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	sheet := h.teacherSheet(ctx, c)
+	if sheet == nil {
+		return
+	}
+	if h.transitionSheet(ctx, c, sheet.ID, action, store.SheetChange{To: to, Reason: reason}) == nil {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Answer sheet updated successfully", "status": to})
+}
+
+// ExtendAnswerSheet gives one student extra minutes, for example after a
+// power failure or as an accessibility accommodation.
+func (h *Handler) ExtendAnswerSheet(c *gin.Context) {
+	var requestBody struct {
+		Minutes int64  `json:"minutes" binding:"required,min=1,max=600"`
+		Reason  string `json:"reason" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&requestBody); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Minutes between 1 and 600 and a reason are required"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	sheet := h.teacherSheet(ctx, c)
+	if sheet == nil {
+		return
+	}
+
+	previous, err := h.store.AnswerSheets.Extend(ctx, sheet.ID, requestBody.Minutes)
+	if errors.Is(err, store.ErrConflict) {
+		c.JSON(http.StatusConflict, gin.H{"error": "Only running timed answer sheets can be extended"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to extend answer sheet"})
+		return
+	}
+
+	deadline := primitive.NewDateTimeFromTime(previous.Deadline.Time().Add(time.Duration(requestBody.Minutes) * time.Minute))
+	h.events.Publish(events.Event{
+		Type:          events.SheetExtended,
+		ExamID:        sheet.ExamID,
+		AnswerSheetID: sheet.ID,
+		StudentEmail:  sheet.Email,
+		Data:          gin.H{"deadline": deadline, "minutes": requestBody.Minutes, "reason": requestBody.Reason},
+	})
+	h.recordAudit(ctx, c, models.AuditLog{
+		Action:       "answer_sheet.extended",
+		TargetID:     sheet.ID,
+		ExamID:       sheet.ExamID,
+		StudentEmail: sheet.Email,
+		Changes: map[string]models.AuditChange{
+			"deadline":      {Before: previous.Deadline, After: deadline},
+			"extra_minutes": {Before: previous.ExtraMinutes, After: previous.ExtraMinutes + requestBody.Minutes},
+		},
+		Details: map[string]interface{}{"reason": requestBody.Reason},
+	})
+
+	c.JSON(http.StatusOK, gin.H{"message": "Answer sheet extended successfully", "deadline": deadline})
+}
+
+// teacherSheet loads the answer sheet named by the :id parameter if it
+// belongs to one of the signed in teacher's exams. It writes the error
+// response and returns nil otherwise.
+func (h *Handler) teacherSheet(ctx context.Context, c *gin.Context) *models.AnswerSheet {
+	id, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid answer sheet ID"})
+		return nil
+	}
+	sheet, err := h.store.AnswerSheets.GetByID(ctx, id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Answer sheet not found"})
+		return nil
+	}
+	if !h.teacherOwnsExam(ctx, c.MustGet("container_id").(primitive.ObjectID), sheet.ExamID) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+		return nil
+	}
+	return sheet
+}
+
+// transitionExamSheets applies change to every sheet of the exam in one of
+// the from states and returns how many moved and the sheets that couldn't be
+// moved. Sheets that changed state in the meantime are skipped. A large exam
+// doesn't fit in a request's 10 seconds, so the moves get their own deadline
+// sized to the batch, and one failed sheet doesn't stop the rest.
+func (h *Handler) transitionExamSheets(ctx context.Context, examID primitive.ObjectID, from []models.SheetStatus, change store.SheetChange) (int, []primitive.ObjectID, error) {
+	sheets, err := h.store.AnswerSheets.List(ctx, store.AnswerSheetFilter{ExamID: &examID, Statuses: from})
+	if err != nil {
+		return 0, nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second+time.Duration(len(sheets))*sheetMoveTimeout)
+	defer cancel()
+
+	moved := 0
+	var failed []primitive.ObjectID
+	for _, sheet := range sheets {
+		_, err := h.moveSheet(ctx, sheet.ID, change)
+		if errors.Is(err, store.ErrConflict) {
+			continue
+		}
+		if err != nil {
+			failed = append(failed, sheet.ID)
+			continue
+		}
+		moved++
+	}
+	return moved, failed, nil
+}
+
+func bindReason(c *gin.Context) (string, bool) {
+	var requestBody struct {
+		Reason string `json:"reason" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&requestBody); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "A reason is required"})
+		return "", false
+	}
+	return requestBody.Reason, true
+}
@@ -0,0 +1,173 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/Maheshkarri4444/Examify/events"
+	"github.com/Maheshkarri4444/Examify/models"
+	"github.com/Maheshkarri4444/Examify/store"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// seedRunningExam creates an exam owned by teacher with one sheet in each of
+// statuses.
+func seedRunningExam(t *testing.T, stores *store.Stores, teacher testUser, examType string, statuses ...models.SheetStatus) (*models.Exam, []primitive.ObjectID) {
+	t.Helper()
+	ctx := context.Background()
+	exam := &models.Exam{ExamName: "lab", ExamType: examType, Open: true}
+	if err := stores.Exams.Create(ctx, exam); err != nil {
+		t.Fatal(err)
+	}
+	if err := stores.Containers.AddExamToTeacher(ctx, teacher.ContainerID, exam.ID, nil); err != nil {
+		t.Fatal(err)
+	}
+	var ids []primitive.ObjectID
+	for i, status := range statuses {
+		sheet := &models.AnswerSheet{ExamID: exam.ID, Email: fmt.Sprintf("s%d@example.com", i), Status: status}
+		if err := stores.AnswerSheets.Create(ctx, sheet); err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, sheet.ID)
+	}
+	return exam, ids
+}
+
+// failingTransitions refuses to move the sheets in fail.
+type failingTransitions struct {
+	store.AnswerSheetStore
+	fail map[primitive.ObjectID]bool
+}
+
+func (s *failingTransitions) Transition(ctx context.Context, id primitive.ObjectID, change store.SheetChange) (*models.AnswerSheet, error) {
+	if s.fail[id] {
+		return nil, errors.New("connection reset")
+	}
+	return s.AnswerSheetStore.Transition(ctx, id, change)
+}
+
+func TestSetExamPaused(t *testing.T) {
+	statuses := []models.SheetStatus{models.SheetStarted, models.SheetPaused, models.SheetStarted, models.SheetSubmitted}
+	tests := []struct {
+		name       string
+		path       string
+		fail       []int // indexes of the sheets whose transition fails
+		wantCode   int
+		wantMoved  int
+		wantFailed int
+		wantAfter  []models.SheetStatus
+	}{
+		{
+			name:      "pause",
+			path:      "pause",
+			wantCode:  http.StatusOK,
+			wantMoved: 2,
+			wantAfter: []models.SheetStatus{models.SheetPaused, models.SheetPaused, models.SheetPaused, models.SheetSubmitted},
+		},
+		{
+			name:      "resume",
+			path:      "resume",
+			wantCode:  http.StatusOK,
+			wantMoved: 1,
+			wantAfter: []models.SheetStatus{models.SheetStarted, models.SheetStarted, models.SheetStarted, models.SheetSubmitted},
+		},
+		{
+			name:       "one sheet fails",
+			path:       "pause",
+			fail:       []int{0},
+			wantCode:   http.StatusInternalServerError,
+			wantMoved:  1,
+			wantFailed: 1,
+			wantAfter:  []models.SheetStatus{models.SheetStarted, models.SheetPaused, models.SheetPaused, models.SheetSubmitted},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, stores := newTestHandler(t)
+			teacher := newTeacher(t, stores, "teacher@example.com")
+			exam, ids := seedRunningExam(t, stores, teacher, models.ExamExternal, statuses...)
+			failing := &failingTransitions{AnswerSheetStore: stores.AnswerSheets, fail: map[primitive.ObjectID]bool{}}
+			for _, i := range tt.fail {
+				failing.fail[ids[i]] = true
+			}
+			stores.AnswerSheets = failing
+
+			sub, unsubscribe := h.events.Subscribe(exam.ID)
+			defer unsubscribe()
+
+			handler := h.PauseExam
+			if tt.path == "resume" {
+				handler = h.ResumeExam
+			}
+			body := map[string]interface{}{"reason": "fire drill"}
+			w := serve(t, teacher, http.MethodPost, "/control/:examid/"+tt.path, "/control/"+exam.ID.Hex()+"/"+tt.path, body, handler)
+			if w.Code != tt.wantCode {
+				t.Fatalf("code = %d, want %d: %s", w.Code, tt.wantCode, w.Body)
+			}
+			var response struct {
+				AnswerSheets int                  `json:"answer_sheets"`
+				Failed       []primitive.ObjectID `json:"failed_answer_sheets"`
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+				t.Fatal(err)
+			}
+			if response.AnswerSheets != tt.wantMoved || len(response.Failed) != tt.wantFailed {
+				t.Errorf("moved %d and failed %v, want %d and %d", response.AnswerSheets, response.Failed, tt.wantMoved, tt.wantFailed)
+			}
+
+			for i, id := range ids {
+				sheet, _ := stores.AnswerSheets.GetByID(context.Background(), id)
+				if sheet.Status != tt.wantAfter[i] {
+					t.Errorf("sheet %d: status = %s, want %s", i, sheet.Status, tt.wantAfter[i])
+				}
+			}
+
+			transitions := 0
+			for done := false; !done; {
+				select {
+				case e := <-sub:
+					if e.Type == events.SheetTransition {
+						transitions++
+					}
+				case <-time.After(10 * time.Millisecond):
+					done = true
+				}
+			}
+			if transitions != tt.wantMoved {
+				t.Errorf("published %d sheet transitions, want one per moved sheet", transitions)
+			}
+		})
+	}
+}
+
+func TestCloseInternalExamReportsFailedSheets(t *testing.T) {
+	h, stores := newTestHandler(t)
+	teacher := newTeacher(t, stores, "teacher@example.com")
+	exam, ids := seedRunningExam(t, stores, teacher, models.ExamInternal, models.SheetStarted, models.SheetPaused, models.SheetStarted)
+	failing := &failingTransitions{AnswerSheetStore: stores.AnswerSheets, fail: map[primitive.ObjectID]bool{ids[1]: true}}
+	stores.AnswerSheets = failing
+
+	path := "/internal/" + exam.ID.Hex() + "/close"
+	w := serve(t, teacher, http.MethodPost, "/internal/:examid/close", path, nil, h.CloseInternalExam)
+	if w.Code != http.StatusInternalServerError {
+		t.Fatalf("code = %d, want %d: %s", w.Code, http.StatusInternalServerError, w.Body)
+	}
+
+	// Closing again once the store recovers submits the sheet that was left
+	delete(failing.fail, ids[1])
+	w = serve(t, teacher, http.MethodPost, "/internal/:examid/close", path, nil, h.CloseInternalExam)
+	if w.Code != http.StatusOK {
+		t.Fatalf("code = %d, want %d: %s", w.Code, http.StatusOK, w.Body)
+	}
+	for i, id := range ids {
+		sheet, _ := stores.AnswerSheets.GetByID(context.Background(), id)
+		if sheet.Status != models.SheetSubmitted {
+			t.Errorf("sheet %d: status = %s, want %s", i, sheet.Status, models.SheetSubmitted)
+		}
+	}
+}
@@ -0,0 +1,192 @@
+package controllers
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/Maheshkarri4444/Examify/events"
+	"github.com/Maheshkarri4444/Examify/middleware"
+	"github.com/Maheshkarri4444/Examify/models"
+	"github.com/Maheshkarri4444/Examify/store"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// monitorPing keeps idle monitoring streams from being cut by proxies.
+const monitorPing = 15 * time.Second
+
+// moveSheet transitions an answer sheet and tells the teachers watching the
+// exam about it.
+func (h *Handler) moveSheet(ctx context.Context, id primitive.ObjectID, change store.SheetChange) (*models.AnswerSheet, error) {
+	previous, err := h.store.AnswerSheets.Transition(ctx, id, change)
+	if err != nil {
+		return nil, err
+	}
+	h.events.Publish(events.Event{
+		Type:          events.SheetTransition,
+		ExamID:        previous.ExamID,
+		AnswerSheetID: id,
+		StudentEmail:  previous.Email,
+		Data:          gin.H{"from": previous.Status, "to": change.To, "by": change.By, "reason": change.Reason},
+	})
+	return previous, nil
+}
+
+// monitorSheet is what the monitoring dashboard shows for one student.
+func monitorSheet(sheet *models.AnswerSheet, now time.Time) gin.H {
+	row := gin.H{
+		"id":           sheet.ID,
+		"student_name": sheet.StudentName,
+		"email":        sheet.Email,
+		"status":       sheet.Status,
+		"set":          sheet.Set,
+		"seat":         sheet.Seat,
+		"started_at":   sheet.StartedAt,
+		"deadline":     sheet.Deadline,
+	}
+	if left, timed := sheet.Remaining(now); timed {
+		row["remaining_seconds"] = int64(left.Seconds())
+	}
+	if sheet.Session != nil {
+		row["last_seen"] = sheet.Session.LastSeen
+		row["ip"] = sheet.Session.IP
+	}
+	return row
+}
+
+// IssueMonitorTicket returns the ticket MonitorExam's stream is opened with.
+// Browsers can't set headers on an EventSource, and a ticket in the URL
+// can't be reused the way a login token could.
+func (h *Handler) IssueMonitorTicket(c *gin.Context) {
+	examID, err := primitive.ObjectIDFromHex(c.Param("examid"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid exam ID"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if !h.teacherOwnsExam(ctx, c.MustGet("container_id").(primitive.ObjectID), examID) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+		return
+	}
+
+	ticket, err := middleware.SignMonitorTicket(h.cfg.JWTSecret, c.MustGet("email").(string), examID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to issue ticket"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"ticket": ticket, "expires_in": int(middleware.MonitorTicketTTL.Seconds())})
+}
+
+// MonitorExam streams what happens during an exam as server-sent events. The
+// first event is a snapshot of every answer sheet, after that sheet changes,
+// heartbeats and proctoring events arrive as they happen. The stream is
+// opened with a ticket from IssueMonitorTicket passed as ?ticket=.
+func (h *Handler) MonitorExam(c *gin.Context) {
+	examID, err := primitive.ObjectIDFromHex(c.Param("examid"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid exam ID"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if !h.teacherOwnsExam(ctx, c.MustGet("container_id").(primitive.ObjectID), examID) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+		return
+	}
+
+	// Subscribe before taking the snapshot so nothing falls in between
+	stream, unsubscribe := h.events.Subscribe(examID)
+	defer unsubscribe()
+
+	sheets, err := h.store.AnswerSheets.List(ctx, store.AnswerSheetFilter{ExamID: &examID})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch answer sheets"})
+		return
+	}
+	now := time.Now()
+	snapshot := make([]gin.H, 0, len(sheets))
+	for i := range sheets {
+		snapshot = append(snapshot, monitorSheet(&sheets[i], now))
+	}
+
+	c.Header("Cache-Control", "no-cache")
+	c.Header("X-Accel-Buffering", "no")
+	c.SSEvent("snapshot", events.Event{Type: "snapshot", ExamID: examID, Data: snapshot, At: now})
+	c.Writer.Flush()
+
+	ping := time.NewTicker(monitorPing)
+	defer ping.Stop()
+	for {
+		select {
+		case <-c.Request.Context().Done():
+			return
+		case e, ok := <-stream:
+			if !ok {
+				return
+			}
+			c.SSEvent(e.Type, e)
+		case at := <-ping.C:
+			c.SSEvent("ping", gin.H{"at": at})
+		}
+		c.Writer.Flush()
+	}
+}
+
+// RecordProctoringEvent lets the exam page report what the student's browser
+// noticed, like leaving the tab. Events are kept in the audit log and pushed
+// to the monitoring stream.
+func (h *Handler) RecordProctoringEvent(c *gin.Context) {
+	objID, err := primitive.ObjectIDFromHex(c.Param("answerSheetId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid answerSheet ID"})
+		return
+	}
+
+	var requestBody struct {
+		Type   string `json:"type" binding:"required,oneof=tab_hidden tab_visible window_blur fullscreen_exit copy paste"`
+		Detail string `json:"detail" binding:"max=500"`
+	}
+	if err := c.ShouldBindJSON(&requestBody); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid proctoring event"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	answerSheet := h.ownSheet(ctx, c, objID)
+	if answerSheet == nil {
+		return
+	}
+	if answerSheet.Status != models.SheetStarted && answerSheet.Status != models.SheetPaused {
+		c.JSON(http.StatusConflict, gin.H{"error": "Exam is not in progress"})
+		return
+	}
+
+	details := map[string]interface{}{"type": requestBody.Type}
+	if requestBody.Detail != "" {
+		details["detail"] = requestBody.Detail
+	}
+	h.recordAudit(ctx, c, models.AuditLog{
+		Action:       "proctoring." + requestBody.Type,
+		TargetID:     objID,
+		ExamID:       answerSheet.ExamID,
+		StudentEmail: answerSheet.Email,
+		Details:      details,
+	})
+	h.events.Publish(events.Event{
+		Type:          events.Proctoring,
+		ExamID:        answerSheet.ExamID,
+		AnswerSheetID: objID,
+		StudentEmail:  answerSheet.Email,
+		Data:          details,
+	})
+
+	c.JSON(http.StatusOK, gin.H{"message": "Event recorded"})
+}
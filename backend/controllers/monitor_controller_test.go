@@ -0,0 +1,53 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/Maheshkarri4444/Examify/middleware"
+	"github.com/Maheshkarri4444/Examify/models"
+)
+
+func TestIssueMonitorTicket(t *testing.T) {
+	tests := []struct {
+		name     string
+		other    bool // asked for by a teacher who doesn't own the exam
+		wantCode int
+	}{
+		{name: "owner", wantCode: http.StatusOK},
+		{name: "other teacher", other: true, wantCode: http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, stores := newTestHandler(t)
+			h.cfg.JWTSecret = "secret"
+			teacher := newTeacher(t, stores, "teacher@example.com")
+			exam, _ := seedRunningExam(t, stores, teacher, models.ExamExternal)
+			if tt.other {
+				teacher = newTeacher(t, stores, "other@example.com")
+			}
+
+			w := serve(t, teacher, http.MethodPost, "/monitor/:examid/ticket", "/monitor/"+exam.ID.Hex()+"/ticket", nil, h.IssueMonitorTicket)
+			if w.Code != tt.wantCode {
+				t.Fatalf("code = %d, want %d: %s", w.Code, tt.wantCode, w.Body)
+			}
+			if w.Code != http.StatusOK {
+				return
+			}
+			var response struct {
+				Ticket string `json:"ticket"`
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+				t.Fatal(err)
+			}
+			claims, err := middleware.NewAuth(stores.Users, "secret").VerifyJWT(response.Ticket)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if claims["aud"] != middleware.MonitorAudience || claims["exam_id"] != exam.ID.Hex() || claims["email"] != teacher.Email {
+				t.Errorf("claims = %v, want a monitor ticket for %s on exam %s", claims, teacher.Email, exam.ID.Hex())
+			}
+		})
+	}
+}
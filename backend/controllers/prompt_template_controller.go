@@ -0,0 +1,209 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/Maheshkarri4444/Examify/models"
+	"github.com/Maheshkarri4444/Examify/store"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+var templateNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,49}$`)
+
+// sampleGradingData is what a template is tried with before it is saved.
+var sampleGradingData = gradingPromptData{
+	ExamType:        models.ExamExternal,
+	Question:        "Write a function that returns the sum of an array of numbers.",
+	Level:           "easy",
+	ReferenceAnswer: "function sum(a) { return a.reduce((s, n) => s + n, 0); }",
+	GradingNotes:    "Full marks for any correct loop or reduce.",
+	AnswerType:      "js",
+	Answers:         []models.Answer{{Type: "js", Ans: "function sum(a) { let s = 0; for (const n of a) s += n; return s; }"}},
+}
+
+// GetPromptTemplates lists the newest version of every grading template,
+// together with the builtin one used when none fits.
+func (h *Handler) GetPromptTemplates(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	templates, err := h.store.Prompts.Latest(ctx)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch prompt templates"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"templates": templates, "builtin": builtinGradingTemplate})
+}
+
+// GetPromptTemplateVersions lists every version of a template, newest first.
+func (h *Handler) GetPromptTemplateVersions(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	versions, err := h.store.Prompts.Versions(ctx, c.Param("name"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch prompt template"})
+		return
+	}
+	if len(versions) == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Prompt template not found"})
+		return
+	}
+	c.JSON(http.StatusOK, versions)
+}
+
+// CreatePromptTemplate saves the next version of a grading template. Earlier
+// versions stay as they are, so gradings keep pointing at the prompt they
+// were made with. Saving with retired set takes the template out of use.
+func (h *Handler) CreatePromptTemplate(c *gin.Context) {
+	var request struct {
+		Name       string `json:"name" binding:"required"`
+		ExamType   string `json:"exam_type" binding:"omitempty,oneof=external internal viva"`
+		AnswerType string `json:"answer_type"`
+		Body       string `json:"body" binding:"required,max=20000"`
+		Retired    bool   `json:"retired"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	request.Name = strings.ToLower(strings.TrimSpace(request.Name))
+	if !templateNamePattern.MatchString(request.Name) || request.Name == builtinTemplate {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Name must be up to 50 lower case letters, digits, - or _, and not builtin"})
+		return
+	}
+	request.AnswerType = strings.ToLower(strings.TrimSpace(request.AnswerType))
+	if request.AnswerType != "" && !slices.Contains(models.QuestionTypes, request.AnswerType) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown answer type"})
+		return
+	}
+
+	t := models.PromptTemplate{
+		Name:       request.Name,
+		ExamType:   request.ExamType,
+		AnswerType: request.AnswerType,
+		Body:       request.Body,
+		Retired:    request.Retired,
+		CreatedBy:  c.MustGet("email").(string),
+		CreatedAt:  primitive.NewDateTimeFromTime(time.Now()),
+	}
+	if _, err := renderGradingPrompt(t, sampleGradingData); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid template: " + err.Error()})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	err := h.store.Prompts.Create(ctx, &t)
+	if errors.Is(err, store.ErrConflict) {
+		c.JSON(http.StatusConflict, gin.H{"error": "Another version of this template was just saved, try again"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save prompt template"})
+		return
+	}
+
+	h.recordAudit(ctx, c, models.AuditLog{
+		Action:   "prompt_template.created",
+		TargetID: t.ID,
+		Details:  map[string]interface{}{"name": t.Name, "version": t.Version, "retired": t.Retired},
+	})
+
+	c.JSON(http.StatusCreated, t)
+}
+
+// PreviewGradingPrompt renders the grading prompt for one answered question
+// of an evaluation, as the AI would get it. It uses the template that fits
+// unless a name and version, or an unsaved body, are given.
+func (h *Handler) PreviewGradingPrompt(c *gin.Context) {
+	var request struct {
+		EvaluationID string `json:"evaluation_id" binding:"required"`
+		Index        int    `json:"index" binding:"min=0"`
+		Name         string `json:"name"`
+		Version      int    `json:"version" binding:"min=0"`
+		Body         string `json:"body" binding:"max=20000"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	evaluationID, err := primitive.ObjectIDFromHex(request.EvaluationID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid evaluation ID"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	evaluation, err := h.store.Evaluations.GetByID(ctx, evaluationID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Evaluation not found"})
+		return
+	}
+	if !h.teacherOwnsExam(ctx, c.MustGet("container_id").(primitive.ObjectID), evaluation.ExamID) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+		return
+	}
+	if request.Index >= len(evaluation.Data) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid question index"})
+		return
+	}
+	keys, err := h.answerKeys(ctx, evaluation)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch the answer key"})
+		return
+	}
+	item := evaluation.Data[request.Index]
+	data := gradingData(evaluation.ExamType, keyedQuestion(keys, item.Question), item.Answers)
+
+	var tmpl models.PromptTemplate
+	switch {
+	case request.Body != "":
+		tmpl = models.PromptTemplate{Name: "draft", Body: request.Body}
+	case request.Name == builtinTemplate:
+		tmpl = builtinGradingTemplate
+	case request.Name != "" && request.Version > 0:
+		t, err := h.store.Prompts.Get(ctx, request.Name, request.Version)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Prompt template not found"})
+			return
+		}
+		tmpl = *t
+	case request.Name != "":
+		versions, err := h.store.Prompts.Versions(ctx, request.Name)
+		if err != nil || len(versions) == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Prompt template not found"})
+			return
+		}
+		tmpl = versions[0]
+	default:
+		if tmpl, err = h.gradingTemplate(ctx, data.ExamType, data.AnswerType); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch prompt templates"})
+			return
+		}
+	}
+
+	prompt, err := renderGradingPrompt(tmpl, data)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid template: " + err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"template":    tmpl.Name,
+		"version":     tmpl.Version,
+		"exam_type":   data.ExamType,
+		"answer_type": data.AnswerType,
+		"prompt":      prompt,
+	})
+}
@@ -0,0 +1,127 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"text/template"
+
+	"github.com/Maheshkarri4444/Examify/models"
+)
+
+// builtinTemplate names the grading prompt used when no stored template
+// fits. It has version 0 and can't be saved over.
+const builtinTemplate = "builtin"
+
+var builtinGradingTemplate = models.PromptTemplate{
+	Name: builtinTemplate,
+	Body: `Please grade the following answer to this question.
+
+Question: {{.Question}}
+
+{{with .ReferenceAnswer}}Reference answer from the teacher:
+{{.}}
+
+{{end}}{{with .GradingNotes}}Grading notes from the teacher:
+{{.}}
+
+{{end}}Answers:
+{{range .Answers}}{{upper .Type}}: {{.Ans}}
+
+{{end}}{{if or .ReferenceAnswer .GradingNotes}}Compare the answer with the reference answer and follow the grading notes. A different approach that is correct deserves full credit.
+{{end}}Split the grade into 2 to 4 criteria that fit the question, such as correctness, completeness and clarity.
+Give brief feedback on the answer quality and correctness (2-3 sentences maximum) and your confidence in the grade from 0 to 1.`,
+}
+
+// gradingResponseFormat follows every rendered template, templates decide
+// how to grade but not what the answer looks like.
+const gradingResponseFormat = "\n\nThe max_points of the criteria must add up to 100 and score must be the sum of their points.\n" +
+	"Respond with JSON only, in this shape:\n" +
+	`{"score": 70, "criteria": [{"name": "Correctness", "points": 40, "max_points": 50, "comment": "..."}, {"name": "Completeness", "points": 30, "max_points": 50, "comment": "..."}], "feedback": "...", "confidence": 0.8}`
+
+// gradingPromptData is what grading templates are executed with.
+type gradingPromptData struct {
+	ExamType        string
+	Question        string
+	Level           string
+	ReferenceAnswer string
+	GradingNotes    string
+	// AnswerType is the type of the first answer, it picks the template.
+	AnswerType string
+	// Answers only holds the answered types.
+	Answers []models.Answer
+}
+
+func gradingData(examType string, question models.Question, answers []models.Answer) gradingPromptData {
+	data := gradingPromptData{
+		ExamType:        examType,
+		Question:        question.Question,
+		Level:           question.Level,
+		ReferenceAnswer: strings.TrimSpace(question.ReferenceAnswer),
+		GradingNotes:    strings.TrimSpace(question.GradingNotes),
+	}
+	for _, answer := range answers {
+		if strings.TrimSpace(answer.Ans) != "" {
+			data.Answers = append(data.Answers, answer)
+		}
+	}
+	if len(data.Answers) > 0 {
+		data.AnswerType = data.Answers[0].Type
+	}
+	return data
+}
+
+var promptFuncs = template.FuncMap{
+	"upper": strings.ToUpper,
+	"lower": strings.ToLower,
+	"trim":  strings.TrimSpace,
+}
+
+// renderGradingPrompt executes a grading template and appends the response
+// format.
+func renderGradingPrompt(t models.PromptTemplate, data gradingPromptData) (string, error) {
+	tmpl, err := template.New(t.Name).Funcs(promptFuncs).Parse(t.Body)
+	if err != nil {
+		return "", fmt.Errorf("prompt template %s v%d: %w", t.Name, t.Version, err)
+	}
+	var b strings.Builder
+	if err := tmpl.Execute(&b, data); err != nil {
+		return "", fmt.Errorf("prompt template %s v%d: %w", t.Name, t.Version, err)
+	}
+	return strings.TrimSpace(b.String()) + gradingResponseFormat, nil
+}
+
+// pickGradingTemplate returns the template most specific to the exam type
+// and answer type. Matching the exam type counts for more than matching the
+// answer type, ties go to the newest. Without a fit it is the builtin one.
+func pickGradingTemplate(templates []models.PromptTemplate, examType, answerType string) models.PromptTemplate {
+	best, bestScore := builtinGradingTemplate, -1
+	for _, t := range templates {
+		if t.Retired ||
+			(t.ExamType != "" && t.ExamType != examType) ||
+			(t.AnswerType != "" && t.AnswerType != answerType) {
+			continue
+		}
+		score := 0
+		if t.ExamType != "" {
+			score += 2
+		}
+		if t.AnswerType != "" {
+			score++
+		}
+		if score > bestScore || (score == bestScore && t.CreatedAt > best.CreatedAt) {
+			best, bestScore = t, score
+		}
+	}
+	return best
+}
+
+// gradingTemplate returns the newest template that fits the exam and answer
+// type.
+func (h *Handler) gradingTemplate(ctx context.Context, examType, answerType string) (models.PromptTemplate, error) {
+	templates, err := h.store.Prompts.Latest(ctx)
+	if err != nil {
+		return models.PromptTemplate{}, fmt.Errorf("failed to fetch prompt templates: %w", err)
+	}
+	return pickGradingTemplate(templates, examType, answerType), nil
+}
@@ -0,0 +1,211 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/Maheshkarri4444/Examify/models"
+	"github.com/Maheshkarri4444/Examify/store"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// GenerateQuestionDrafts asks the AI to draft questions on a topic, with
+// reference answers and grading notes. Nothing is saved, the teacher reviews
+// the drafts and accepts the ones to keep with AcceptQuestionDrafts.
+func (h *Handler) GenerateQuestionDrafts(c *gin.Context) {
+	var request struct {
+		Topic  string   `json:"topic" binding:"required,max=200"`
+		Types  []string `json:"types" binding:"required,min=1"`
+		Easy   int      `json:"easy" binding:"min=0"`
+		Medium int      `json:"medium" binding:"min=0"`
+		Hard   int      `json:"hard" binding:"min=0"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	spec := questionSpec{
+		Topic: strings.TrimSpace(request.Topic),
+		Count: map[string]int{"easy": request.Easy, "medium": request.Medium, "hard": request.Hard},
+	}
+	for _, t := range request.Types {
+		t = strings.ToLower(strings.TrimSpace(t))
+		if !slices.Contains(models.QuestionTypes, t) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Unknown question type %q", t)})
+			return
+		}
+		if !slices.Contains(spec.Types, t) {
+			spec.Types = append(spec.Types, t)
+		}
+	}
+	if total := spec.total(); total < 1 || total > maxGeneratedQuestions {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Ask for between 1 and %d questions", maxGeneratedQuestions)})
+		return
+	}
+
+	aiCtx, cancel := context.WithTimeout(withLLMCaller(c.Request.Context(), requestCaller(c, primitive.NilObjectID, purposeQuestionGeneration)), aiCallTimeout)
+	defer cancel()
+	drafts, err := h.generateQuestions(aiCtx, spec)
+	var invalid *InvalidAIOutputError
+	if errors.As(err, &invalid) {
+		c.JSON(http.StatusBadGateway, gin.H{"error": "The AI did not return valid questions, try again", "problems": invalid.Problems})
+		return
+	}
+	if err != nil {
+		h.aiFailed(c, err, "Failed to generate questions")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"questions": drafts, "topic": spec.Topic, "model": geminiModel})
+}
+
+// AcceptQuestionDrafts adds the drafts the teacher picked, possibly edited,
+// to one of their exams or to their question bank. Drafts an exam already
+// has are skipped.
+func (h *Handler) AcceptQuestionDrafts(c *gin.Context) {
+	var request struct {
+		Target    string            `json:"target" binding:"required,oneof=exam bank"`
+		ExamID    string            `json:"exam_id"`
+		Topic     string            `json:"topic" binding:"max=200"`
+		Questions []models.Question `json:"questions" binding:"required,min=1,max=100"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var problems []string
+	for i, q := range request.Questions {
+		request.Questions[i] = normalizeQuestion(q)
+		for _, problem := range questionProblems(request.Questions[i]) {
+			problems = append(problems, fmt.Sprintf("questions[%d]: %s", i, problem))
+		}
+	}
+	if problems != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid questions", "problems": problems})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	containerID := c.MustGet("container_id").(primitive.ObjectID)
+	if request.Target == "bank" {
+		t := primitive.NewDateTimeFromTime(time.Now())
+		questions := make([]models.BankQuestion, len(request.Questions))
+		for i, q := range request.Questions {
+			questions[i] = models.BankQuestion{
+				ContainerID: containerID,
+				Question:    q,
+				Topic:       strings.TrimSpace(request.Topic),
+				Source:      models.QuestionSourceAI,
+				CreatedBy:   c.MustGet("email").(string),
+				CreatedAt:   t,
+			}
+		}
+		if err := h.store.QuestionBank.Insert(ctx, questions); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save questions"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "Questions added to the question bank", "questions": questions})
+		return
+	}
+
+	examID, err := primitive.ObjectIDFromHex(request.ExamID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid exam ID"})
+		return
+	}
+	if !h.teacherOwnsExam(ctx, containerID, examID) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+		return
+	}
+	exam, err := h.store.Exams.GetByID(ctx, examID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Exam not found"})
+		return
+	}
+
+	// Answer keys are matched to papers by question text, it has to stay
+	// unique within the exam
+	existing := map[string]bool{}
+	for _, q := range exam.Questions {
+		existing[strings.ToLower(q.Question)] = true
+	}
+	var added []models.Question
+	skipped := 0
+	for _, q := range request.Questions {
+		if existing[strings.ToLower(q.Question)] {
+			skipped++
+			continue
+		}
+		existing[strings.ToLower(q.Question)] = true
+		added = append(added, q)
+	}
+	if len(added) == 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "The exam already has all of these questions"})
+		return
+	}
+
+	if err := h.store.Exams.AddQuestions(ctx, examID, added); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add questions"})
+		return
+	}
+
+	h.recordAudit(ctx, c, models.AuditLog{
+		Action:   "exam.questions_added",
+		TargetID: examID,
+		ExamID:   examID,
+		Details:  map[string]interface{}{"count": len(added), "skipped": skipped, "source": models.QuestionSourceAI},
+	})
+
+	c.JSON(http.StatusOK, gin.H{"message": "Questions added to the exam", "added": len(added), "skipped": skipped})
+}
+
+// GetQuestionBank lists the teacher's bank, filtered by ?topic=, ?level=
+// and ?type=.
+func (h *Handler) GetQuestionBank(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	questions, err := h.store.QuestionBank.List(ctx, store.QuestionBankFilter{
+		ContainerID: c.MustGet("container_id").(primitive.ObjectID),
+		Topic:       strings.TrimSpace(c.Query("topic")),
+		Level:       c.Query("level"),
+		Type:        c.Query("type"),
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch the question bank"})
+		return
+	}
+	c.JSON(http.StatusOK, questions)
+}
+
+func (h *Handler) DeleteBankQuestion(c *gin.Context) {
+	id, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid question ID"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	err = h.store.QuestionBank.Delete(ctx, c.MustGet("container_id").(primitive.ObjectID), id)
+	if errors.Is(err, store.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Question not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete question"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Question deleted"})
+}
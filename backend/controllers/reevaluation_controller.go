@@ -0,0 +1,368 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/Maheshkarri4444/Examify/models"
+	"github.com/Maheshkarri4444/Examify/store"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// saveEvaluationVersion bumps the evaluation's version and stores a snapshot
+// of its current marks under that version number.
+func (h *Handler) saveEvaluationVersion(ctx context.Context, c *gin.Context, evaluationID primitive.ObjectID, reason string, reEvaluationID *primitive.ObjectID) (int, error) {
+	evaluation, err := h.store.Evaluations.IncrementVersion(ctx, evaluationID)
+	if err != nil {
+		return 0, err
+	}
+
+	createdBy, _ := c.Get("email")
+	version := models.EvaluationVersion{
+		ID:             primitive.NewObjectID(),
+		EvaluationID:   evaluation.ID,
+		ExamID:         evaluation.ExamID,
+		Email:          evaluation.Email,
+		Version:        evaluation.Version,
+		Data:           evaluation.Data,
+		TotalMarks:     evaluation.TotalMarks,
+		Reason:         reason,
+		ReEvaluationID: reEvaluationID,
+		CreatedBy:      fmt.Sprint(createdBy),
+		CreatedAt:      primitive.NewDateTimeFromTime(time.Now()),
+	}
+	if err := h.store.Evaluations.CreateVersion(ctx, &version); err != nil {
+		return 0, err
+	}
+	return evaluation.Version, nil
+}
+
+// GetMyResults returns the student's finalized evaluations, which always
+// reflect the latest approved version.
+func (h *Handler) GetMyResults(c *gin.Context) {
+	email := c.MustGet("email").(string)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	evaluated := true
+	results, err := h.store.Evaluations.List(ctx, store.EvaluationFilter{Email: email, Evaluated: &evaluated})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch results"})
+		return
+	}
+
+	c.JSON(http.StatusOK, results)
+}
+
+func (h *Handler) CreateReEvaluationRequest(c *gin.Context) {
+	evaluationID, err := primitive.ObjectIDFromHex(c.Param("evaluationid"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid evaluation ID"})
+		return
+	}
+
+	var requestBody struct {
+		QuestionIndex *int   `json:"question_index" binding:"required"`
+		Reason        string `json:"reason" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&requestBody); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "question_index and reason are required"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	email := c.MustGet("email").(string)
+	evaluation, err := h.store.Evaluations.GetByID(ctx, evaluationID)
+	if err != nil || evaluation.Email != email {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Evaluation not found"})
+		return
+	}
+	if !evaluation.Evaluated {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Results for this exam are not out yet"})
+		return
+	}
+
+	index := *requestBody.QuestionIndex
+	if index < 0 || index >= len(evaluation.Data) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid question index"})
+		return
+	}
+
+	pending, err := h.store.ReEvaluations.HasPending(ctx, evaluationID, index)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check existing requests"})
+		return
+	}
+	if pending {
+		c.JSON(http.StatusConflict, gin.H{"error": "A re-evaluation request for this question is already pending"})
+		return
+	}
+
+	request := models.ReEvaluationRequest{
+		ID:            primitive.NewObjectID(),
+		EvaluationID:  evaluationID,
+		ExamID:        evaluation.ExamID,
+		ExamName:      evaluation.ExamName,
+		StudentName:   evaluation.StudentName,
+		Email:         evaluation.Email,
+		QuestionIndex: index,
+		Question:      evaluation.Data[index].Question,
+		Reason:        requestBody.Reason,
+		Status:        "pending",
+		OriginalMarks: evaluation.Data[index].Marks,
+		CreatedAt:     primitive.NewDateTimeFromTime(time.Now()),
+	}
+	if err := h.store.ReEvaluations.Create(ctx, &request); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create re-evaluation request"})
+		return
+	}
+
+	h.recordAudit(ctx, c, models.AuditLog{
+		Action:       "re_evaluation.requested",
+		TargetID:     request.ID,
+		ExamID:       request.ExamID,
+		StudentEmail: request.Email,
+		Details:      map[string]interface{}{"evaluation_id": evaluationID, "question_index": index, "reason": request.Reason},
+	})
+
+	c.JSON(http.StatusOK, gin.H{"message": "Re-evaluation requested successfully", "request": request})
+}
+
+func (h *Handler) GetMyReEvaluationRequests(c *gin.Context) {
+	email := c.MustGet("email").(string)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	requests, err := h.store.ReEvaluations.List(ctx, store.ReEvaluationFilter{Email: email})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch re-evaluation requests"})
+		return
+	}
+
+	c.JSON(http.StatusOK, requests)
+}
+
+// GetReEvaluationQueue lists re-evaluation requests for the teacher's exams,
+// pending ones by default. Use ?status= and ?exam_id= to narrow it down.
+func (h *Handler) GetReEvaluationQueue(c *gin.Context) {
+	containerID := c.MustGet("container_id").(primitive.ObjectID)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	teacherContainer, err := h.store.Containers.GetTeacherContainer(ctx, containerID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Teacher container not found"})
+		return
+	}
+
+	examIDs := make([]primitive.ObjectID, 0)
+	for _, e := range teacherContainer.Exams {
+		examIDs = append(examIDs, e.ExamID)
+	}
+
+	filter := store.ReEvaluationFilter{
+		ExamIDs: examIDs,
+		Status:  c.DefaultQuery("status", "pending"),
+	}
+	if examID := c.Query("exam_id"); examID != "" {
+		objID, err := primitive.ObjectIDFromHex(examID)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid exam ID"})
+			return
+		}
+		if !h.teacherOwnsExam(ctx, containerID, objID) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+			return
+		}
+		filter.ExamIDs = []primitive.ObjectID{objID}
+	}
+
+	requests, err := h.store.ReEvaluations.List(ctx, filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch re-evaluation requests"})
+		return
+	}
+
+	c.JSON(http.StatusOK, requests)
+}
+
+// ResolveReEvaluationRequest approves or rejects a request. Approving with
+// revised marks updates the evaluation and stores a new version of it; the
+// previous versions, including the original marks, are kept.
+func (h *Handler) ResolveReEvaluationRequest(c *gin.Context) {
+	requestID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request ID"})
+		return
+	}
+
+	var requestBody struct {
+		Status   string `json:"status" binding:"required,oneof=approved rejected"`
+		Marks    *int   `json:"marks"`
+		Response string `json:"response"`
+	}
+	if err := c.ShouldBindJSON(&requestBody); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Status must be approved or rejected"})
+		return
+	}
+	if requestBody.Status == "approved" && requestBody.Marks == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Revised marks are required to approve a request"})
+		return
+	}
+	if requestBody.Marks != nil && (*requestBody.Marks < 0 || *requestBody.Marks > models.MaxQuestionMarks) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Marks must be between 0 and %d", models.MaxQuestionMarks)})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	request, err := h.store.ReEvaluations.GetByID(ctx, requestID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Re-evaluation request not found"})
+		return
+	}
+	if request.Status != "pending" {
+		c.JSON(http.StatusConflict, gin.H{"error": "Re-evaluation request is already resolved"})
+		return
+	}
+	if !h.teacherOwnsExam(ctx, c.MustGet("container_id").(primitive.ObjectID), request.ExamID) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+		return
+	}
+
+	evaluation, err := h.store.Evaluations.GetByID(ctx, request.EvaluationID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Evaluation not found"})
+		return
+	}
+	// The evaluation may have lost questions since the request was filed, it
+	// can still be rejected
+	if requestBody.Status == "approved" && (request.QuestionIndex < 0 || request.QuestionIndex >= len(evaluation.Data)) {
+		c.JSON(http.StatusConflict, gin.H{"error": "The contested question is no longer in the evaluation"})
+		return
+	}
+
+	// Claim the request first so two teachers can't resolve it twice
+	resolution := store.ReEvaluationResolution{
+		Status:     requestBody.Status,
+		Response:   requestBody.Response,
+		ResolvedBy: c.MustGet("email").(string),
+	}
+	if requestBody.Status == "approved" {
+		resolution.RevisedMarks = requestBody.Marks
+	}
+	claimed, err := h.store.ReEvaluations.Resolve(ctx, requestID, resolution)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update re-evaluation request"})
+		return
+	}
+	if !claimed {
+		c.JSON(http.StatusConflict, gin.H{"error": "Re-evaluation request is already resolved"})
+		return
+	}
+
+	version := evaluation.Version
+	if requestBody.Status == "approved" {
+		currentMarks := evaluation.Data[request.QuestionIndex].Marks
+		marksSet := false
+		// Put the marks and the request back when the approval can't be
+		// applied in full, so it can be resolved again
+		undo := func() {
+			undoCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			if marksSet {
+				if err := h.store.Evaluations.SetQuestionMarks(undoCtx, evaluation.ID, request.QuestionIndex, currentMarks); err != nil {
+					fmt.Println("re-evaluation undo error: ", err)
+				}
+			}
+			if err := h.store.ReEvaluations.Reopen(undoCtx, request.ID); err != nil {
+				fmt.Println("re-evaluation undo error: ", err)
+			}
+		}
+
+		// Keep the marks the student is contesting if they were never versioned
+		if evaluation.Version == 0 {
+			if _, err := h.saveEvaluationVersion(ctx, c, evaluation.ID, "Original evaluation", nil); err != nil {
+				undo()
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save evaluation version"})
+				return
+			}
+		}
+
+		err = h.store.Evaluations.SetQuestionMarks(ctx, evaluation.ID, request.QuestionIndex, *requestBody.Marks)
+		if err != nil {
+			undo()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update evaluation"})
+			return
+		}
+		marksSet = true
+
+		version, err = h.saveEvaluationVersion(ctx, c, evaluation.ID, "Re-evaluation: "+request.Reason, &request.ID)
+		if err != nil {
+			undo()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save evaluation version"})
+			return
+		}
+
+		h.recordAudit(ctx, c, models.AuditLog{
+			Action:       "evaluation.revised",
+			TargetID:     evaluation.ID,
+			ExamID:       evaluation.ExamID,
+			StudentEmail: evaluation.Email,
+			Changes: map[string]models.AuditChange{
+				fmt.Sprintf("data.%d.marks", request.QuestionIndex): {Before: currentMarks, After: *requestBody.Marks},
+				"total_marks": {Before: evaluation.TotalMarks, After: evaluation.TotalMarks + *requestBody.Marks - currentMarks},
+			},
+			Details: map[string]interface{}{"re_evaluation_id": request.ID, "version": version},
+		})
+	}
+
+	h.recordAudit(ctx, c, models.AuditLog{
+		Action:       "re_evaluation." + requestBody.Status,
+		TargetID:     request.ID,
+		ExamID:       request.ExamID,
+		StudentEmail: request.Email,
+		Changes:      map[string]models.AuditChange{"status": {Before: "pending", After: requestBody.Status}},
+		Details:      map[string]interface{}{"response": requestBody.Response},
+	})
+
+	c.JSON(http.StatusOK, gin.H{"message": "Re-evaluation request " + requestBody.Status, "version": version})
+}
+
+func (h *Handler) GetEvaluationVersions(c *gin.Context) {
+	evaluationID, err := primitive.ObjectIDFromHex(c.Param("evaluationid"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid evaluation ID"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	evaluation, err := h.store.Evaluations.GetByID(ctx, evaluationID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Evaluation not found"})
+		return
+	}
+	if !h.teacherOwnsExam(ctx, c.MustGet("container_id").(primitive.ObjectID), evaluation.ExamID) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+		return
+	}
+
+	versions, err := h.store.Evaluations.ListVersions(ctx, evaluationID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch evaluation versions"})
+		return
+	}
+
+	c.JSON(http.StatusOK, versions)
+}
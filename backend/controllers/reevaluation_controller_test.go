@@ -0,0 +1,187 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/Maheshkarri4444/Examify/models"
+	"github.com/Maheshkarri4444/Examify/store"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// seedReEvaluation creates an evaluated exam of teacher with a pending
+// request to re-evaluate its first question, marked 4 of a total of 10.
+func seedReEvaluation(t *testing.T, stores *store.Stores, teacher testUser) (*models.Evaluation, *models.ReEvaluationRequest) {
+	t.Helper()
+	ctx := context.Background()
+	examID := primitive.NewObjectID()
+	if err := stores.Containers.AddExamToTeacher(ctx, teacher.ContainerID, examID, nil); err != nil {
+		t.Fatal(err)
+	}
+	evaluation := &models.Evaluation{
+		ExamID:     examID,
+		Email:      "student@example.com",
+		Data:       []models.EvaluationData{{Question: "q1", Marks: 4}, {Question: "q2", Marks: 6}},
+		TotalMarks: 10,
+		Evaluated:  true,
+	}
+	if err := stores.Evaluations.Create(ctx, evaluation); err != nil {
+		t.Fatal(err)
+	}
+	request := &models.ReEvaluationRequest{
+		ID:            primitive.NewObjectID(),
+		EvaluationID:  evaluation.ID,
+		ExamID:        examID,
+		Email:         evaluation.Email,
+		QuestionIndex: 0,
+		Reason:        "the loop is correct",
+		Status:        "pending",
+		OriginalMarks: 4,
+	}
+	if err := stores.ReEvaluations.Create(ctx, request); err != nil {
+		t.Fatal(err)
+	}
+	return evaluation, request
+}
+
+func TestResolveReEvaluationRequest(t *testing.T) {
+	tests := []struct {
+		name       string
+		other      bool // resolved by a teacher who doesn't own the exam
+		removed    bool // the contested question was removed from the evaluation
+		body       map[string]interface{}
+		wantCode   int
+		wantStatus string
+		wantMarks  int
+		wantTotal  int
+	}{
+		{name: "approve", body: map[string]interface{}{"status": "approved", "marks": 9}, wantCode: http.StatusOK, wantStatus: "approved", wantMarks: 9, wantTotal: 15},
+		{name: "approve with zero", body: map[string]interface{}{"status": "approved", "marks": 0}, wantCode: http.StatusOK, wantStatus: "approved", wantMarks: 0, wantTotal: 6},
+		{name: "reject", body: map[string]interface{}{"status": "rejected", "response": "marked fairly"}, wantCode: http.StatusOK, wantStatus: "rejected", wantMarks: 4, wantTotal: 10},
+		{name: "approve without marks", body: map[string]interface{}{"status": "approved"}, wantCode: http.StatusBadRequest, wantStatus: "pending", wantMarks: 4, wantTotal: 10},
+		{name: "negative marks", body: map[string]interface{}{"status": "approved", "marks": -1}, wantCode: http.StatusBadRequest, wantStatus: "pending", wantMarks: 4, wantTotal: 10},
+		{name: "marks above the maximum", body: map[string]interface{}{"status": "approved", "marks": models.MaxQuestionMarks + 1}, wantCode: http.StatusBadRequest, wantStatus: "pending", wantMarks: 4, wantTotal: 10},
+		{name: "unknown status", body: map[string]interface{}{"status": "maybe"}, wantCode: http.StatusBadRequest, wantStatus: "pending", wantMarks: 4, wantTotal: 10},
+		{name: "approve a removed question", removed: true, body: map[string]interface{}{"status": "approved", "marks": 9}, wantCode: http.StatusConflict, wantStatus: "pending", wantMarks: 4, wantTotal: 10},
+		{name: "reject a removed question", removed: true, body: map[string]interface{}{"status": "rejected"}, wantCode: http.StatusOK, wantStatus: "rejected", wantMarks: 4, wantTotal: 10},
+		{name: "other teacher", other: true, body: map[string]interface{}{"status": "approved", "marks": 9}, wantCode: http.StatusForbidden, wantStatus: "pending", wantMarks: 4, wantTotal: 10},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, stores := newTestHandler(t)
+			teacher := newTeacher(t, stores, "teacher@example.com")
+			evaluation, request := seedReEvaluation(t, stores, teacher)
+			if tt.removed {
+				request.ID = primitive.NewObjectID()
+				request.QuestionIndex = len(evaluation.Data)
+				if err := stores.ReEvaluations.Create(context.Background(), request); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if tt.other {
+				teacher = newTeacher(t, stores, "other@example.com")
+			}
+
+			w := serve(t, teacher, http.MethodPut, "/re-evaluation/:id", "/re-evaluation/"+request.ID.Hex(), tt.body, h.ResolveReEvaluationRequest)
+			if w.Code != tt.wantCode {
+				t.Fatalf("code = %d, want %d: %s", w.Code, tt.wantCode, w.Body)
+			}
+
+			ctx := context.Background()
+			got, _ := stores.ReEvaluations.GetByID(ctx, request.ID)
+			if got.Status != tt.wantStatus {
+				t.Errorf("request status = %q, want %q", got.Status, tt.wantStatus)
+			}
+			updated, _ := stores.Evaluations.GetByID(ctx, evaluation.ID)
+			if updated.Data[0].Marks != tt.wantMarks || updated.TotalMarks != tt.wantTotal {
+				t.Errorf("marks = %d of %d, want %d of %d", updated.Data[0].Marks, updated.TotalMarks, tt.wantMarks, tt.wantTotal)
+			}
+		})
+	}
+}
+
+func TestResolveReEvaluationRequestAlreadyResolved(t *testing.T) {
+	h, stores := newTestHandler(t)
+	teacher := newTeacher(t, stores, "teacher@example.com")
+	_, request := seedReEvaluation(t, stores, teacher)
+
+	path := "/re-evaluation/" + request.ID.Hex()
+	body := map[string]interface{}{"status": "approved", "marks": 9}
+	if w := serve(t, teacher, http.MethodPut, "/re-evaluation/:id", path, body, h.ResolveReEvaluationRequest); w.Code != http.StatusOK {
+		t.Fatalf("first resolve: code = %d: %s", w.Code, w.Body)
+	}
+	if w := serve(t, teacher, http.MethodPut, "/re-evaluation/:id", path, body, h.ResolveReEvaluationRequest); w.Code != http.StatusConflict {
+		t.Fatalf("second resolve: code = %d, want %d", w.Code, http.StatusConflict)
+	}
+
+	versions, _ := stores.Evaluations.ListVersions(context.Background(), request.EvaluationID)
+	if len(versions) != 2 {
+		t.Errorf("got %d versions, want the original and the revised one", len(versions))
+	}
+}
+
+// failingVersions fails to store evaluation versions.
+type failingVersions struct {
+	store.EvaluationStore
+}
+
+func (failingVersions) CreateVersion(ctx context.Context, version *models.EvaluationVersion) error {
+	return errors.New("write failed")
+}
+
+func TestResolveReEvaluationRequestUndoesFailedApproval(t *testing.T) {
+	h, stores := newTestHandler(t)
+	teacher := newTeacher(t, stores, "teacher@example.com")
+	evaluation, request := seedReEvaluation(t, stores, teacher)
+
+	// Version the original marks so the failure comes after they changed
+	if _, err := stores.Evaluations.IncrementVersion(context.Background(), evaluation.ID); err != nil {
+		t.Fatal(err)
+	}
+	stores.Evaluations = failingVersions{stores.Evaluations}
+
+	body := map[string]interface{}{"status": "approved", "marks": 9}
+	w := serve(t, teacher, http.MethodPut, "/re-evaluation/:id", "/re-evaluation/"+request.ID.Hex(), body, h.ResolveReEvaluationRequest)
+	if w.Code != http.StatusInternalServerError {
+		t.Fatalf("code = %d, want %d", w.Code, http.StatusInternalServerError)
+	}
+
+	ctx := context.Background()
+	got, _ := stores.ReEvaluations.GetByID(ctx, request.ID)
+	if got.Status != "pending" || got.RevisedMarks != nil || got.ResolvedAt != nil {
+		t.Errorf("request = %+v, want it pending again", got)
+	}
+	updated, _ := stores.Evaluations.GetByID(ctx, evaluation.ID)
+	if updated.Data[0].Marks != 4 || updated.TotalMarks != 10 {
+		t.Errorf("marks = %d of %d, want 4 of 10 back", updated.Data[0].Marks, updated.TotalMarks)
+	}
+}
+
+func TestGetEvaluationVersions(t *testing.T) {
+	h, stores := newTestHandler(t)
+	owner := newTeacher(t, stores, "teacher@example.com")
+	other := newTeacher(t, stores, "other@example.com")
+	evaluation, _ := seedReEvaluation(t, stores, owner)
+
+	tests := []struct {
+		name     string
+		user     testUser
+		id       string
+		wantCode int
+	}{
+		{name: "owner", user: owner, id: evaluation.ID.Hex(), wantCode: http.StatusOK},
+		{name: "other teacher", user: other, id: evaluation.ID.Hex(), wantCode: http.StatusForbidden},
+		{name: "unknown evaluation", user: owner, id: primitive.NewObjectID().Hex(), wantCode: http.StatusNotFound},
+		{name: "invalid id", user: owner, id: "nope", wantCode: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := serve(t, tt.user, http.MethodGet, "/evaluation-versions/:evaluationid", "/evaluation-versions/"+tt.id, nil, h.GetEvaluationVersions)
+			if w.Code != tt.wantCode {
+				t.Errorf("code = %d, want %d: %s", w.Code, tt.wantCode, w.Body)
+			}
+		})
+	}
+}
@@ -0,0 +1,53 @@
+package controllers
+
+import (
+	"context"
+	"strings"
+
+	"github.com/Maheshkarri4444/Examify/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func listContains(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
+
+// roleForNewUser decides the role given to an account the first time it logs
+// in, following config.RoleConfig. Explicit allowlists win over domain rules
+// and emails matching none of the rules are not allowed to sign up.
+func (h *Handler) roleForNewUser(email string) (string, bool) {
+	email = strings.ToLower(email)
+	domain := email[strings.LastIndex(email, "@")+1:]
+
+	rules := h.cfg.Roles
+	switch {
+	case listContains(rules.AdminEmails, email):
+		return models.RoleAdmin, true
+	case listContains(rules.TeacherEmails, email):
+		return models.RoleTeacher, true
+	case listContains(rules.TeacherDomains, domain):
+		return models.RoleTeacher, true
+	case listContains(rules.StudentDomains, domain):
+		return models.RoleStudent, true
+	}
+	return "", false
+}
+
+func isValidRole(role string) bool {
+	return role == models.RoleStudent || role == models.RoleTeacher || role == models.RoleAdmin
+}
+
+// ensureContainerForRole makes sure the container referenced by a user exists
+// in the collection matching the role. Students and staff keep the same
+// container ID, so promoting a student keeps the link to their attempts.
+func (h *Handler) ensureContainerForRole(ctx context.Context, containerID primitive.ObjectID, role string) error {
+	if role == models.RoleStudent {
+		return h.store.Containers.EnsureStudentContainer(ctx, containerID)
+	}
+	return h.store.Containers.EnsureTeacherContainer(ctx, containerID)
+}
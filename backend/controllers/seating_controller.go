@@ -0,0 +1,184 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Maheshkarri4444/Examify/models"
+	"github.com/Maheshkarri4444/Examify/store"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// UpsertSeatingMap uploads the seats of one lab room for an exam slot. A
+// second upload for the same slot and room replaces the first.
+func (h *Handler) UpsertSeatingMap(c *gin.Context) {
+	examID, err := primitive.ObjectIDFromHex(c.Param("examid"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid exam ID"})
+		return
+	}
+
+	var requestBody struct {
+		Slot  string        `json:"slot" binding:"required"`
+		Room  string        `json:"room" binding:"required"`
+		Seats []models.Seat `json:"seats" binding:"required,min=1,dive"`
+	}
+	if err := c.ShouldBindJSON(&requestBody); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "slot, room and at least one seat with row and number are required"})
+		return
+	}
+
+	seen := map[models.Seat]bool{}
+	for _, seat := range requestBody.Seats {
+		if seen[seat] {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Seats must not repeat"})
+			return
+		}
+		seen[seat] = true
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if !h.teacherOwnsExam(ctx, c.MustGet("container_id").(primitive.ObjectID), examID) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+		return
+	}
+
+	seating := models.SeatingMap{
+		ExamID:    examID,
+		Slot:      strings.TrimSpace(requestBody.Slot),
+		Room:      strings.TrimSpace(requestBody.Room),
+		Seats:     requestBody.Seats,
+		UpdatedBy: c.MustGet("email").(string),
+		UpdatedAt: primitive.NewDateTimeFromTime(time.Now()),
+	}
+	previous, err := h.store.Seating.Get(ctx, examID, seating.Slot, seating.Room)
+	if err != nil && !errors.Is(err, store.ErrNotFound) {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch seating map"})
+		return
+	}
+	if err := h.store.Seating.Upsert(ctx, &seating); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save seating map"})
+		return
+	}
+
+	var before interface{}
+	if previous != nil {
+		before = previous.Seats
+	}
+	h.recordAudit(ctx, c, models.AuditLog{
+		Action:   "seating.updated",
+		TargetID: seating.ID,
+		ExamID:   examID,
+		Changes:  map[string]models.AuditChange{"seats": {Before: before, After: seating.Seats}},
+		Details:  map[string]interface{}{"slot": seating.Slot, "room": seating.Room},
+	})
+
+	c.JSON(http.StatusOK, gin.H{"message": "Seating map saved successfully", "seating": seating})
+}
+
+func (h *Handler) GetSeatingMaps(c *gin.Context) {
+	examID, err := primitive.ObjectIDFromHex(c.Param("examid"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid exam ID"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if !h.teacherOwnsExam(ctx, c.MustGet("container_id").(primitive.ObjectID), examID) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+		return
+	}
+
+	maps, err := h.store.Seating.ListByExam(ctx, examID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch seating maps"})
+		return
+	}
+
+	c.JSON(http.StatusOK, maps)
+}
+
+// GetSeatingOptions lists the slots and rooms of an exam so students can
+// tell where they are sitting. An empty list means no seat is needed.
+func (h *Handler) GetSeatingOptions(c *gin.Context) {
+	examID, err := primitive.ObjectIDFromHex(c.Param("examid"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid exam ID"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	maps, err := h.store.Seating.ListByExam(ctx, examID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch seating maps"})
+		return
+	}
+
+	options := []gin.H{}
+	for _, m := range maps {
+		options = append(options, gin.H{"slot": m.Slot, "room": m.Room})
+	}
+	c.JSON(http.StatusOK, options)
+}
+
+// validateSeat checks that the seat exists in the seating map of its slot and
+// room. It returns a message for the student when it does not.
+func (h *Handler) validateSeat(ctx context.Context, examID primitive.ObjectID, seat models.SeatAssignment) (string, error) {
+	seating, err := h.store.Seating.Get(ctx, examID, seat.Slot, seat.Room)
+	if errors.Is(err, store.ErrNotFound) {
+		return "Unknown slot or room for this exam", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	for _, s := range seating.Seats {
+		if s.Row == seat.Row && s.Number == seat.Number {
+			return "", nil
+		}
+	}
+	return "This seat does not exist in the room", nil
+}
+
+// seatsAdjacent reports whether two seats of the same room touch, side by
+// side, front to back or diagonally.
+func seatsAdjacent(a, b models.SeatAssignment) bool {
+	if a.Slot != b.Slot || a.Room != b.Room || (a.Row == b.Row && a.Number == b.Number) {
+		return false
+	}
+	return abs(a.Row-b.Row) <= 1 && abs(a.Number-b.Number) <= 1
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+// pickSet starts at the round-robin position given by counter and returns the
+// first set none of the neighbours has. When every set is already taken
+// around the seat it falls back to the set used by the fewest neighbours.
+func pickSet(sets []primitive.ObjectID, counter int64, neighbourSets map[primitive.ObjectID]int) primitive.ObjectID {
+	start := int((counter - 1) % int64(len(sets)))
+	best := sets[start]
+	for i := 0; i < len(sets); i++ {
+		set := sets[(start+i)%len(sets)]
+		if neighbourSets[set] == 0 {
+			return set
+		}
+		if neighbourSets[set] < neighbourSets[best] {
+			best = set
+		}
+	}
+	return best
+}
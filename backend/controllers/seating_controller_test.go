@@ -0,0 +1,162 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"testing"
+
+	"github.com/Maheshkarri4444/Examify/models"
+	"github.com/Maheshkarri4444/Examify/store"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestSeatsAdjacent(t *testing.T) {
+	seat := func(row, number int) models.SeatAssignment {
+		return models.SeatAssignment{Slot: "morning", Room: "lab1", Row: row, Number: number}
+	}
+	tests := []struct {
+		name string
+		a, b models.SeatAssignment
+		want bool
+	}{
+		{name: "side by side", a: seat(2, 2), b: seat(2, 3), want: true},
+		{name: "front to back", a: seat(2, 2), b: seat(1, 2), want: true},
+		{name: "diagonal", a: seat(2, 2), b: seat(3, 1), want: true},
+		{name: "same seat", a: seat(2, 2), b: seat(2, 2), want: false},
+		{name: "two seats apart", a: seat(2, 2), b: seat(2, 4), want: false},
+		{name: "two rows apart", a: seat(1, 2), b: seat(3, 2), want: false},
+		{name: "other room", a: seat(2, 2), b: models.SeatAssignment{Slot: "morning", Room: "lab2", Row: 2, Number: 3}, want: false},
+		{name: "other slot", a: seat(2, 2), b: models.SeatAssignment{Slot: "evening", Room: "lab1", Row: 2, Number: 3}, want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := seatsAdjacent(tt.a, tt.b); got != tt.want {
+				t.Errorf("seatsAdjacent(%v, %v) = %v, want %v", tt.a, tt.b, got, tt.want)
+			}
+			if got := seatsAdjacent(tt.b, tt.a); got != tt.want {
+				t.Errorf("seatsAdjacent(%v, %v) = %v, want %v", tt.b, tt.a, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPickSet(t *testing.T) {
+	a, b, c := primitive.NewObjectID(), primitive.NewObjectID(), primitive.NewObjectID()
+	sets := []primitive.ObjectID{a, b, c}
+	tests := []struct {
+		name       string
+		counter    int64
+		neighbours map[primitive.ObjectID]int
+		want       primitive.ObjectID
+	}{
+		{name: "first login", counter: 1, want: a},
+		{name: "round robin", counter: 2, want: b},
+		{name: "wraps around", counter: 4, want: a},
+		{name: "skips a neighbour's set", counter: 1, neighbours: map[primitive.ObjectID]int{a: 1}, want: b},
+		{name: "skips every neighbour's set", counter: 2, neighbours: map[primitive.ObjectID]int{b: 2, c: 1}, want: a},
+		{name: "least used when all are taken", counter: 1, neighbours: map[primitive.ObjectID]int{a: 3, b: 1, c: 2}, want: b},
+		{name: "ties keep the round robin position", counter: 3, neighbours: map[primitive.ObjectID]int{a: 1, b: 1, c: 1}, want: c},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := pickSet(sets, tt.counter, tt.neighbours); got != tt.want {
+				t.Errorf("pickSet = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// seedSeatedExam creates an exam with two sets and one row of seats.
+func seedSeatedExam(t *testing.T, stores *store.Stores, seats int) *models.Exam {
+	t.Helper()
+	ctx := context.Background()
+	exam := &models.Exam{ExamName: "lab", ExamType: models.ExamExternal, Duration: 60}
+	for set := 1; set <= 2; set++ {
+		paper := &models.QuestionPaper{Set: set, Questions: []models.Question{{Question: fmt.Sprint("q", set), Types: []string{"js"}}}}
+		if err := stores.Exams.CreateQuestionPaper(ctx, paper); err != nil {
+			t.Fatal(err)
+		}
+		exam.Sets = append(exam.Sets, paper.ID)
+	}
+	if err := stores.Exams.Create(ctx, exam); err != nil {
+		t.Fatal(err)
+	}
+	seating := &models.SeatingMap{ExamID: exam.ID, Slot: "morning", Room: "lab1"}
+	for number := 1; number <= seats; number++ {
+		seating.Seats = append(seating.Seats, models.Seat{Row: 1, Number: number})
+	}
+	if err := stores.Seating.Upsert(ctx, seating); err != nil {
+		t.Fatal(err)
+	}
+	return exam
+}
+
+func TestAssignSetKeepsConcurrentNeighboursApart(t *testing.T) {
+	// Pairs of neighbours with an empty seat between the pairs, so two sets
+	// are always enough whatever order the students come in
+	pairs := [][2]int{{1, 2}, {4, 5}, {7, 8}, {10, 11}}
+	h, stores := newTestHandler(t)
+	exam := seedSeatedExam(t, stores, 11)
+
+	var wg sync.WaitGroup
+	for _, pair := range pairs {
+		for _, number := range pair {
+			student := testUser{
+				Name:        fmt.Sprint("student ", number),
+				Email:       fmt.Sprintf("s%d@example.com", number),
+				Role:        models.RoleStudent,
+				ContainerID: primitive.NewObjectID(),
+			}
+			if err := stores.Containers.EnsureStudentContainer(context.Background(), student.ContainerID); err != nil {
+				t.Fatal(err)
+			}
+			body := map[string]interface{}{"seat": models.SeatAssignment{Slot: "morning", Room: "lab1", Row: 1, Number: number}}
+
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				w := serve(t, student, http.MethodPost, "/assign/:qpaperid", "/assign/"+exam.ID.Hex(), body, h.AssignSetAndCreateAnswerSheet)
+				if w.Code != http.StatusOK {
+					t.Errorf("%s: code = %d: %s", student.Email, w.Code, w.Body)
+				}
+			}()
+		}
+	}
+	wg.Wait()
+
+	sheets, err := stores.AnswerSheets.List(context.Background(), store.AnswerSheetFilter{ExamID: &exam.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sheets) != 2*len(pairs) {
+		t.Fatalf("got %d sheets, want %d", len(sheets), 2*len(pairs))
+	}
+	setAt := map[int]primitive.ObjectID{}
+	for _, sheet := range sheets {
+		setAt[sheet.Seat.Number] = sheet.QPaperID
+	}
+	for _, pair := range pairs {
+		if setAt[pair[0]] == setAt[pair[1]] {
+			t.Errorf("seats %d and %d both got set %v", pair[0], pair[1], setAt[pair[0]])
+		}
+	}
+}
+
+func TestAssignSetSeatTaken(t *testing.T) {
+	h, stores := newTestHandler(t)
+	exam := seedSeatedExam(t, stores, 2)
+
+	body := map[string]interface{}{"seat": models.SeatAssignment{Slot: "morning", Room: "lab1", Row: 1, Number: 1}}
+	for i, wantCode := range []int{http.StatusOK, http.StatusConflict} {
+		student := testUser{Email: fmt.Sprintf("s%d@example.com", i), Role: models.RoleStudent, ContainerID: primitive.NewObjectID()}
+		if err := stores.Containers.EnsureStudentContainer(context.Background(), student.ContainerID); err != nil {
+			t.Fatal(err)
+		}
+		w := serve(t, student, http.MethodPost, "/assign/:qpaperid", "/assign/"+exam.ID.Hex(), body, h.AssignSetAndCreateAnswerSheet)
+		if w.Code != wantCode {
+			t.Errorf("%s: code = %d, want %d: %s", student.Email, w.Code, wantCode, w.Body)
+		}
+	}
+}
@@ -0,0 +1,250 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Maheshkarri4444/Examify/models"
+	"github.com/Maheshkarri4444/Examify/store"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// StartVivaSession opens the evaluation a teacher fills in while examining a
+// student orally. There is no answer sheet; the evaluation lists the exam's
+// questions, or the ones picked with question_indexes. Starting a viva twice
+// for the same student returns the existing evaluation.
+func (h *Handler) StartVivaSession(c *gin.Context) {
+	examID, err := primitive.ObjectIDFromHex(c.Param("examid"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid exam ID"})
+		return
+	}
+
+	var requestBody struct {
+		Email           string `json:"email" binding:"required,email"`
+		QuestionIndexes []int  `json:"question_indexes"`
+	}
+	if err := c.ShouldBindJSON(&requestBody); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "A student email is required"})
+		return
+	}
+	email := strings.ToLower(strings.TrimSpace(requestBody.Email))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	exam, ok := h.ownVivaExam(ctx, c, examID)
+	if !ok {
+		return
+	}
+
+	existing, err := h.store.Evaluations.List(ctx, store.EvaluationFilter{ExamID: &examID, Email: email})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch evaluations"})
+		return
+	}
+	if len(existing) > 0 {
+		c.JSON(http.StatusOK, gin.H{"message": "Viva already started", "evaluation": existing[0]})
+		return
+	}
+
+	student, err := h.store.Users.GetByEmail(ctx, email)
+	if err != nil || student.Role != models.RoleStudent {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Student not found"})
+		return
+	}
+
+	questions := exam.Questions
+	if len(requestBody.QuestionIndexes) > 0 {
+		questions = make([]models.Question, 0, len(requestBody.QuestionIndexes))
+		for _, i := range requestBody.QuestionIndexes {
+			if i < 0 || i >= len(exam.Questions) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid question index"})
+				return
+			}
+			questions = append(questions, exam.Questions[i])
+		}
+	}
+	if len(questions) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "The exam has no questions"})
+		return
+	}
+
+	evaluation := models.Evaluation{
+		ID:          primitive.NewObjectID(),
+		StudentName: student.Name,
+		Email:       student.Email,
+		ExamName:    exam.ExamName,
+		ExamID:      exam.ID,
+		ExamType:    models.ExamViva,
+		Examiner:    c.MustGet("email").(string),
+		Data:        make([]models.EvaluationData, len(questions)),
+	}
+	for i, q := range questions {
+		evaluation.Data[i] = models.EvaluationData{Question: q.Question, Answers: []models.Answer{}}
+	}
+
+	if err := h.store.Evaluations.Create(ctx, &evaluation); err != nil {
+		if errors.Is(err, store.ErrConflict) {
+			// Another request started the same viva since the check above
+			existing, err := h.store.Evaluations.List(ctx, store.EvaluationFilter{ExamID: &examID, Email: email})
+			if err == nil && len(existing) > 0 {
+				c.JSON(http.StatusOK, gin.H{"message": "Viva already started", "evaluation": existing[0]})
+				return
+			}
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create evaluation"})
+		return
+	}
+	if err := h.store.Containers.AddEvaluationToExam(ctx, exam.ID, evaluation.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update teacher container"})
+		return
+	}
+
+	h.recordAudit(ctx, c, models.AuditLog{
+		Action:       "evaluation.created",
+		TargetID:     evaluation.ID,
+		ExamID:       evaluation.ExamID,
+		StudentEmail: evaluation.Email,
+		Details:      map[string]interface{}{"exam_type": models.ExamViva},
+	})
+
+	c.JSON(http.StatusOK, gin.H{"message": "Viva started successfully", "evaluation": evaluation})
+}
+
+// RecordVivaMarks stores the oral marks and remarks of one question while the
+// viva is running. Finalized vivas are changed through re-evaluation.
+func (h *Handler) RecordVivaMarks(c *gin.Context) {
+	evaluationID, err := primitive.ObjectIDFromHex(c.Param("evaluationid"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid evaluation ID"})
+		return
+	}
+	index, err := strconv.Atoi(c.Param("index"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid question index"})
+		return
+	}
+
+	var requestBody struct {
+		Marks   *int   `json:"marks" binding:"required"`
+		Remarks string `json:"remarks"`
+	}
+	if err := c.ShouldBindJSON(&requestBody); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Marks are required"})
+		return
+	}
+	if *requestBody.Marks < 0 || *requestBody.Marks > models.MaxQuestionMarks {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Marks must be between 0 and %d", models.MaxQuestionMarks)})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	evaluation, err := h.store.Evaluations.GetByID(ctx, evaluationID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Evaluation not found"})
+		return
+	}
+	if evaluation.ExamType != models.ExamViva {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Evaluation is not a viva"})
+		return
+	}
+	if !h.teacherOwnsExam(ctx, c.MustGet("container_id").(primitive.ObjectID), evaluation.ExamID) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+		return
+	}
+	if evaluation.Evaluated {
+		c.JSON(http.StatusConflict, gin.H{"error": "Viva has already been finalized"})
+		return
+	}
+	if index < 0 || index >= len(evaluation.Data) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid question index"})
+		return
+	}
+
+	remarks := strings.TrimSpace(requestBody.Remarks)
+	previous := evaluation.Data[index]
+	err = h.store.Evaluations.GradeQuestion(ctx, evaluationID, index, previous.Marks, *requestBody.Marks, remarks)
+	if errors.Is(err, store.ErrNotFound) {
+		// Finalized or marked by someone else since it was read
+		if current, getErr := h.store.Evaluations.GetByID(ctx, evaluationID); getErr == nil && current.Evaluated {
+			c.JSON(http.StatusConflict, gin.H{"error": "Viva has already been finalized"})
+			return
+		}
+		c.JSON(http.StatusConflict, gin.H{"error": "Marks were changed by another request, reload and try again"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save marks"})
+		return
+	}
+
+	h.recordAudit(ctx, c, models.AuditLog{
+		Action:       "evaluation.viva_marked",
+		TargetID:     evaluationID,
+		ExamID:       evaluation.ExamID,
+		StudentEmail: evaluation.Email,
+		Changes: map[string]models.AuditChange{
+			"marks":   {Before: previous.Marks, After: *requestBody.Marks},
+			"remarks": {Before: previous.Remarks, After: remarks},
+		},
+		Details: map[string]interface{}{"question_index": index},
+	})
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":     "Marks saved successfully",
+		"total_marks": evaluation.TotalMarks + *requestBody.Marks - previous.Marks,
+	})
+}
+
+// GetVivaEvaluations lists the vivas held so far for an exam, finalized or
+// not.
+func (h *Handler) GetVivaEvaluations(c *gin.Context) {
+	examID, err := primitive.ObjectIDFromHex(c.Param("examid"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid exam ID"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if _, ok := h.ownVivaExam(ctx, c, examID); !ok {
+		return
+	}
+
+	evaluations, err := h.store.Evaluations.List(ctx, store.EvaluationFilter{ExamID: &examID})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch evaluations"})
+		return
+	}
+
+	c.JSON(http.StatusOK, evaluations)
+}
+
+// ownVivaExam loads a viva exam of the signed in teacher. It writes the error
+// response and returns false otherwise.
+func (h *Handler) ownVivaExam(ctx context.Context, c *gin.Context, examID primitive.ObjectID) (*models.Exam, bool) {
+	if !h.teacherOwnsExam(ctx, c.MustGet("container_id").(primitive.ObjectID), examID) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+		return nil, false
+	}
+	exam, err := h.store.Exams.GetByID(ctx, examID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Exam not found"})
+		return nil, false
+	}
+	if exam.ExamType != models.ExamViva {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Exam is not a viva"})
+		return nil, false
+	}
+	return exam, true
+}
@@ -0,0 +1,257 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"testing"
+
+	"github.com/Maheshkarri4444/Examify/models"
+	"github.com/Maheshkarri4444/Examify/store"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// seedViva creates a viva exam owned by teacher and a student to examine.
+func seedViva(t *testing.T, stores *store.Stores, teacher testUser) *models.Exam {
+	t.Helper()
+	ctx := context.Background()
+	exam := &models.Exam{
+		ExamName:  "oral",
+		ExamType:  models.ExamViva,
+		Questions: []models.Question{{Question: "q1"}, {Question: "q2"}},
+	}
+	if err := stores.Exams.Create(ctx, exam); err != nil {
+		t.Fatal(err)
+	}
+	if err := stores.Containers.AddExamToTeacher(ctx, teacher.ContainerID, exam.ID, nil); err != nil {
+		t.Fatal(err)
+	}
+	student := &models.User{Name: "student", Email: "student@example.com", Role: models.RoleStudent}
+	if err := stores.Users.Create(ctx, student); err != nil {
+		t.Fatal(err)
+	}
+	return exam
+}
+
+func TestStartVivaSession(t *testing.T) {
+	tests := []struct {
+		name          string
+		other         bool // started by a teacher who doesn't own the exam
+		body          map[string]interface{}
+		wantCode      int
+		wantQuestions int
+	}{
+		{name: "all questions", body: map[string]interface{}{"email": "student@example.com"}, wantCode: http.StatusOK, wantQuestions: 2},
+		{name: "picked questions", body: map[string]interface{}{"email": "Student@Example.com", "question_indexes": []int{1}}, wantCode: http.StatusOK, wantQuestions: 1},
+		{name: "bad question index", body: map[string]interface{}{"email": "student@example.com", "question_indexes": []int{2}}, wantCode: http.StatusBadRequest},
+		{name: "unknown student", body: map[string]interface{}{"email": "nobody@example.com"}, wantCode: http.StatusNotFound},
+		{name: "no email", body: map[string]interface{}{}, wantCode: http.StatusBadRequest},
+		{name: "other teacher", other: true, body: map[string]interface{}{"email": "student@example.com"}, wantCode: http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, stores := newTestHandler(t)
+			teacher := newTeacher(t, stores, "teacher@example.com")
+			exam := seedViva(t, stores, teacher)
+			if tt.other {
+				teacher = newTeacher(t, stores, "other@example.com")
+			}
+
+			w := serve(t, teacher, http.MethodPost, "/viva/:examid/start", "/viva/"+exam.ID.Hex()+"/start", tt.body, h.StartVivaSession)
+			if w.Code != tt.wantCode {
+				t.Fatalf("code = %d, want %d: %s", w.Code, tt.wantCode, w.Body)
+			}
+			evaluations, err := stores.Evaluations.List(context.Background(), store.EvaluationFilter{ExamID: &exam.ID})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.wantQuestions == 0 {
+				if len(evaluations) != 0 {
+					t.Errorf("got %d evaluations, want none", len(evaluations))
+				}
+				return
+			}
+			if len(evaluations) != 1 || len(evaluations[0].Data) != tt.wantQuestions {
+				t.Fatalf("got %+v, want one evaluation of %d questions", evaluations, tt.wantQuestions)
+			}
+			if evaluations[0].Examiner != teacher.Email || evaluations[0].ExamType != models.ExamViva {
+				t.Errorf("examiner = %q, exam type = %q", evaluations[0].Examiner, evaluations[0].ExamType)
+			}
+		})
+	}
+}
+
+// barrierUsers holds every student lookup until n of them are waiting, so
+// that concurrent requests all get past their existence checks first.
+type barrierUsers struct {
+	store.UserStore
+	arrived sync.WaitGroup
+}
+
+func (u *barrierUsers) GetByEmail(ctx context.Context, email string) (*models.User, error) {
+	u.arrived.Done()
+	u.arrived.Wait()
+	return u.UserStore.GetByEmail(ctx, email)
+}
+
+func TestStartVivaSessionTwiceReturnsTheSameEvaluation(t *testing.T) {
+	const requests = 10
+	h, stores := newTestHandler(t)
+	teacher := newTeacher(t, stores, "teacher@example.com")
+	exam := seedViva(t, stores, teacher)
+	users := &barrierUsers{UserStore: stores.Users}
+	users.arrived.Add(requests)
+	stores.Users = users
+	body := map[string]interface{}{"email": "student@example.com"}
+
+	var wg sync.WaitGroup
+	for i := 0; i < requests; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			w := serve(t, teacher, http.MethodPost, "/viva/:examid/start", "/viva/"+exam.ID.Hex()+"/start", body, h.StartVivaSession)
+			if w.Code != http.StatusOK {
+				t.Errorf("code = %d: %s", w.Code, w.Body)
+			}
+		}()
+	}
+	wg.Wait()
+
+	evaluations, err := stores.Evaluations.List(context.Background(), store.EvaluationFilter{ExamID: &exam.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(evaluations) != 1 {
+		t.Errorf("got %d evaluations, want one", len(evaluations))
+	}
+	container, err := stores.Containers.GetTeacherContainer(context.Background(), teacher.ContainerID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	linked := 0
+	for _, exam := range container.Exams {
+		linked += len(exam.EvaluationID)
+	}
+	if linked != 1 {
+		t.Errorf("teacher container links %d evaluations, want one", linked)
+	}
+}
+
+// startViva starts the viva of seedViva's student and returns its evaluation.
+func startViva(t *testing.T, h *Handler, teacher testUser, exam *models.Exam) *models.Evaluation {
+	t.Helper()
+	body := map[string]interface{}{"email": "student@example.com"}
+	w := serve(t, teacher, http.MethodPost, "/viva/:examid/start", "/viva/"+exam.ID.Hex()+"/start", body, h.StartVivaSession)
+	var response struct {
+		Evaluation models.Evaluation `json:"evaluation"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil || w.Code != http.StatusOK {
+		t.Fatalf("starting the viva: code = %d, err = %v: %s", w.Code, err, w.Body)
+	}
+	return &response.Evaluation
+}
+
+func TestRecordVivaMarks(t *testing.T) {
+	tests := []struct {
+		name      string
+		index     string
+		body      map[string]interface{}
+		wantCode  int
+		wantMarks int
+	}{
+		{name: "marks", index: "1", body: map[string]interface{}{"marks": 80, "remarks": " clear "}, wantCode: http.StatusOK, wantMarks: 80},
+		{name: "full marks", index: "0", body: map[string]interface{}{"marks": models.MaxQuestionMarks}, wantCode: http.StatusOK, wantMarks: models.MaxQuestionMarks},
+		{name: "zero", index: "0", body: map[string]interface{}{"marks": 0}, wantCode: http.StatusOK},
+		{name: "above the maximum", index: "0", body: map[string]interface{}{"marks": models.MaxQuestionMarks + 1}, wantCode: http.StatusBadRequest},
+		{name: "negative", index: "0", body: map[string]interface{}{"marks": -1}, wantCode: http.StatusBadRequest},
+		{name: "no marks", index: "0", body: map[string]interface{}{"remarks": "ok"}, wantCode: http.StatusBadRequest},
+		{name: "bad question index", index: "2", body: map[string]interface{}{"marks": 5}, wantCode: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, stores := newTestHandler(t)
+			teacher := newTeacher(t, stores, "teacher@example.com")
+			evaluation := startViva(t, h, teacher, seedViva(t, stores, teacher))
+
+			path := "/viva/evaluation/" + evaluation.ID.Hex() + "/question/" + tt.index
+			w := serve(t, teacher, http.MethodPut, "/viva/evaluation/:evaluationid/question/:index", path, tt.body, h.RecordVivaMarks)
+			if w.Code != tt.wantCode {
+				t.Fatalf("code = %d, want %d: %s", w.Code, tt.wantCode, w.Body)
+			}
+			got, err := stores.Evaluations.GetByID(context.Background(), evaluation.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.TotalMarks != tt.wantMarks {
+				t.Errorf("total marks = %d, want %d", got.TotalMarks, tt.wantMarks)
+			}
+		})
+	}
+}
+
+// racingGrades lets race change the evaluation just before each grading
+// reaches the store, as another teacher saving or finalizing would.
+type racingGrades struct {
+	store.EvaluationStore
+	race func(ctx context.Context, s store.EvaluationStore, id primitive.ObjectID) error
+}
+
+func (s *racingGrades) GradeQuestion(ctx context.Context, id primitive.ObjectID, index, previousMarks, marks int, remarks string) error {
+	if err := s.race(ctx, s.EvaluationStore, id); err != nil {
+		return err
+	}
+	return s.EvaluationStore.GradeQuestion(ctx, id, index, previousMarks, marks, remarks)
+}
+
+func TestRecordVivaMarksRace(t *testing.T) {
+	tests := []struct {
+		name      string
+		race      func(ctx context.Context, s store.EvaluationStore, id primitive.ObjectID) error
+		wantError string
+		wantMarks int
+	}{
+		{
+			name: "marked by another teacher",
+			race: func(ctx context.Context, s store.EvaluationStore, id primitive.ObjectID) error {
+				return s.GradeQuestion(ctx, id, 0, 0, 30, "other")
+			},
+			wantError: "Marks were changed by another request, reload and try again",
+			wantMarks: 30,
+		},
+		{
+			name: "finalized",
+			race: func(ctx context.Context, s store.EvaluationStore, id primitive.ObjectID) error {
+				evaluation, err := s.GetByID(ctx, id)
+				if err != nil {
+					return err
+				}
+				return s.UpdateGrading(ctx, id, evaluation.Data, evaluation.TotalMarks, true)
+			},
+			wantError: "Viva has already been finalized",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, stores := newTestHandler(t)
+			teacher := newTeacher(t, stores, "teacher@example.com")
+			evaluation := startViva(t, h, teacher, seedViva(t, stores, teacher))
+			stores.Evaluations = &racingGrades{EvaluationStore: stores.Evaluations, race: tt.race}
+
+			path := "/viva/evaluation/" + evaluation.ID.Hex() + "/question/0"
+			body := map[string]interface{}{"marks": 80}
+			w := serve(t, teacher, http.MethodPut, "/viva/evaluation/:evaluationid/question/:index", path, body, h.RecordVivaMarks)
+			var response struct {
+				Error string `json:"error"`
+			}
+			json.Unmarshal(w.Body.Bytes(), &response)
+			if w.Code != http.StatusConflict || response.Error != tt.wantError {
+				t.Fatalf("code = %d, error = %q, want %d, %q", w.Code, response.Error, http.StatusConflict, tt.wantError)
+			}
+			got, _ := stores.Evaluations.GetByID(context.Background(), evaluation.ID)
+			if got.Data[0].Marks != tt.wantMarks || got.TotalMarks != tt.wantMarks {
+				t.Errorf("marks = %d of %d, want the other request's %d", got.Data[0].Marks, got.TotalMarks, tt.wantMarks)
+			}
+		})
+	}
+}
@@ -0,0 +1,110 @@
+// Package events is an in-process publish/subscribe bus that carries what
+// happens during an exam to the teachers watching it live.
+package events
+
+import (
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	SheetAssigned   = "sheet.assigned"
+	SheetTransition = "sheet.transition"
+	SheetExtended   = "sheet.extended"
+	ExamUpdated     = "exam.updated"
+	Heartbeat       = "heartbeat"
+	Proctoring      = "proctoring"
+)
+
+// subscriberBuffer is how many events a subscriber may fall behind before it
+// is dropped.
+const subscriberBuffer = 64
+
+type Event struct {
+	Type          string             `json:"type"`
+	ExamID        primitive.ObjectID `json:"exam_id"`
+	AnswerSheetID primitive.ObjectID `json:"answer_sheet_id"`
+	StudentEmail  string             `json:"student_email,omitempty"`
+	Data          interface{}        `json:"data,omitempty"`
+	At            time.Time          `json:"at"`
+}
+
+// Bus fans events out to the subscribers of their exam. Publishing never
+// blocks: a subscriber that can't keep up is closed, so the client
+// reconnects and starts again from a fresh snapshot instead of silently
+// missing events.
+type Bus struct {
+	mu     sync.Mutex
+	subs   map[primitive.ObjectID]map[chan Event]struct{}
+	closed bool
+}
+
+func NewBus() *Bus {
+	return &Bus{subs: map[primitive.ObjectID]map[chan Event]struct{}{}}
+}
+
+// Subscribe returns the events of one exam. The channel is closed when
+// cancel is called, when the subscriber falls behind or when the bus closes.
+func (b *Bus) Subscribe(examID primitive.ObjectID) (<-chan Event, func()) {
+	ch := make(chan Event, subscriberBuffer)
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		close(ch)
+		return ch, func() {}
+	}
+	if b.subs[examID] == nil {
+		b.subs[examID] = map[chan Event]struct{}{}
+	}
+	b.subs[examID][ch] = struct{}{}
+
+	return ch, func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		b.remove(examID, ch)
+	}
+}
+
+func (b *Bus) Publish(e Event) {
+	if e.At.IsZero() {
+		e.At = time.Now()
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for ch := range b.subs[e.ExamID] {
+		select {
+		case ch <- e:
+		default:
+			b.remove(e.ExamID, ch)
+		}
+	}
+}
+
+// Close ends every subscription, open streams finish so the server can shut
+// down.
+func (b *Bus) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for examID, subs := range b.subs {
+		for ch := range subs {
+			b.remove(examID, ch)
+		}
+	}
+	b.closed = true
+}
+
+// remove must be called with mu held.
+func (b *Bus) remove(examID primitive.ObjectID, ch chan Event) {
+	if _, ok := b.subs[examID][ch]; !ok {
+		return
+	}
+	delete(b.subs[examID], ch)
+	if len(b.subs[examID]) == 0 {
+		delete(b.subs, examID)
+	}
+	close(ch)
+}
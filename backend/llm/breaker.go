@@ -0,0 +1,67 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// breaker opens after threshold failures in a row and stays open for
+// cooldown. Then it lets a single probe through: success closes it, another
+// failure opens it again. A threshold of 0 turns it off.
+type breaker struct {
+	threshold int
+	cooldown  time.Duration
+
+	mu        sync.Mutex
+	failures  int
+	openUntil time.Time
+	probing   bool
+}
+
+// allow reports whether a call may go ahead, and whether it is the probe of
+// an open breaker.
+func (b *breaker) allow() (allowed, probe bool) {
+	if b.threshold <= 0 {
+		return true, false
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.failures < b.threshold {
+		return true, false
+	}
+	if time.Now().Before(b.openUntil) || b.probing {
+		return false, false
+	}
+	b.probing = true
+	return true, true
+}
+
+// done records the outcome of a call allow let through, probe is what allow
+// said about it. Only failures a retry could fix count, a cancelled call
+// counts for nothing. While the breaker is open only the probe decides,
+// calls let through before it opened are ignored.
+func (b *breaker) done(probe bool, err error) {
+	if b.threshold <= 0 {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if probe {
+		b.probing = false
+	} else if b.failures >= b.threshold {
+		return
+	}
+	if errors.Is(err, context.Canceled) {
+		return
+	}
+	if !Retryable(err) {
+		b.failures = 0
+		return
+	}
+	b.failures++
+	if b.failures >= b.threshold {
+		b.openUntil = time.Now().Add(b.cooldown)
+	}
+}
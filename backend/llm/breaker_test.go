@@ -0,0 +1,136 @@
+package llm
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+)
+
+const testCooldown = 50 * time.Millisecond
+
+var (
+	errOutage   = &ProviderError{StatusCode: http.StatusServiceUnavailable}
+	errBadInput = &ProviderError{StatusCode: http.StatusBadRequest}
+)
+
+// call is one step through the breaker: allow is expected to return
+// allowed, and when it does the call ends with err. cooldown waits the
+// cooldown out instead.
+type call struct {
+	allowed  bool
+	err      error
+	cooldown bool
+}
+
+func TestBreaker(t *testing.T) {
+	wait := call{cooldown: true}
+	tests := []struct {
+		name      string
+		threshold int
+		calls     []call
+	}{
+		{
+			name:      "opens after the threshold",
+			threshold: 2,
+			calls:     []call{{allowed: true, err: errOutage}, {allowed: true, err: errOutage}, {allowed: false}},
+		},
+		{
+			name:      "success starts the count over",
+			threshold: 2,
+			calls:     []call{{allowed: true, err: errOutage}, {allowed: true}, {allowed: true, err: errOutage}, {allowed: true}},
+		},
+		{
+			name:      "an error retrying won't fix starts the count over",
+			threshold: 2,
+			calls:     []call{{allowed: true, err: errOutage}, {allowed: true, err: errBadInput}, {allowed: true, err: errOutage}, {allowed: true}},
+		},
+		{
+			name:      "a cancelled call counts for nothing",
+			threshold: 2,
+			calls: []call{
+				{allowed: true, err: errOutage}, {allowed: true, err: context.Canceled},
+				{allowed: true, err: &NetworkError{Err: context.DeadlineExceeded}}, {allowed: false},
+			},
+		},
+		{
+			name:      "a successful probe closes it",
+			threshold: 2,
+			calls: []call{
+				{allowed: true, err: errOutage}, {allowed: true, err: ErrTimeout}, {allowed: false},
+				wait, {allowed: true}, {allowed: true, err: errOutage}, {allowed: true},
+			},
+		},
+		{
+			name:      "a failed probe opens it again",
+			threshold: 2,
+			calls: []call{
+				{allowed: true, err: errOutage}, {allowed: true, err: errOutage},
+				wait, {allowed: true, err: errOutage}, {allowed: false},
+				wait, {allowed: true}, {allowed: true},
+			},
+		},
+		{
+			name:      "a cancelled probe lets the next one through",
+			threshold: 1,
+			calls:     []call{{allowed: true, err: errOutage}, wait, {allowed: true, err: context.Canceled}, {allowed: true, err: errOutage}, {allowed: false}},
+		},
+		{
+			name:      "off",
+			threshold: 0,
+			calls:     []call{{allowed: true, err: errOutage}, {allowed: true, err: errOutage}, {allowed: true, err: errOutage}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := &breaker{threshold: tt.threshold, cooldown: testCooldown}
+			for i, c := range tt.calls {
+				if c.cooldown {
+					time.Sleep(testCooldown + 10*time.Millisecond)
+					continue
+				}
+				allowed, probe := b.allow()
+				if allowed != c.allowed {
+					t.Fatalf("call %d: allowed = %v, want %v", i, allowed, c.allowed)
+				}
+				if allowed {
+					b.done(probe, c.err)
+				}
+			}
+		})
+	}
+}
+
+func TestBreakerSingleProbe(t *testing.T) {
+	b := &breaker{threshold: 1, cooldown: testCooldown}
+	// Calls let through while it was still closed, finishing late
+	for i := 0; i < 3; i++ {
+		if allowed, probe := b.allow(); !allowed || probe {
+			t.Fatalf("call %d: allowed = %v, probe = %v before any failure", i, allowed, probe)
+		}
+	}
+	b.done(false, errOutage)
+	time.Sleep(testCooldown + 10*time.Millisecond)
+
+	allowed, probe := b.allow()
+	if !allowed || !probe {
+		t.Fatalf("allowed = %v, probe = %v after the cooldown, want the probe", allowed, probe)
+	}
+	// Everything else waits for the probe's outcome, stragglers included
+	refused := func(after string) {
+		t.Helper()
+		if allowed, _ := b.allow(); allowed {
+			t.Fatalf("a call went through while the probe was running, after %s", after)
+		}
+	}
+	refused("the probe started")
+	b.done(false, errOutage)
+	refused("a straggler failed")
+	b.done(false, errBadInput)
+	refused("a straggler's error retrying won't fix")
+
+	b.done(true, nil)
+	if allowed, probe := b.allow(); !allowed || probe {
+		t.Errorf("allowed = %v, probe = %v after the probe succeeded, want it closed", allowed, probe)
+	}
+}
@@ -0,0 +1,80 @@
+package llm
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+var (
+	// ErrNotConfigured means there is no API key.
+	ErrNotConfigured = errors.New("llm: API key is missing")
+	// ErrCircuitOpen means the provider failed too often lately and is not
+	// called until the breaker's cooldown is over.
+	ErrCircuitOpen = errors.New("llm: provider is unavailable, circuit open")
+	// ErrTimeout means an attempt took longer than the configured timeout.
+	ErrTimeout = errors.New("llm: request timed out")
+	// ErrEmptyResponse means the provider answered without any text.
+	ErrEmptyResponse = errors.New("llm: no valid response")
+	// ErrTruncated means the answer hit the output token limit.
+	ErrTruncated = errors.New("llm: response cut off at the token limit")
+	// ErrMalformedResponse means the provider's answer could not be read.
+	ErrMalformedResponse = errors.New("llm: malformed response")
+)
+
+// ProviderError is a non 200 answer from the provider.
+type ProviderError struct {
+	StatusCode int
+	Body       string
+}
+
+func (e *ProviderError) Error() string {
+	return fmt.Sprintf("llm: provider returned %d: %s", e.StatusCode, e.Body)
+}
+
+// NetworkError is a request that didn't get an answer.
+type NetworkError struct {
+	Err error
+}
+
+func (e *NetworkError) Error() string { return "llm: request failed: " + e.Err.Error() }
+func (e *NetworkError) Unwrap() error { return e.Err }
+
+// BlockedError is a prompt or response the provider's safety filters
+// stopped. Asking again with the same prompt gets the same answer.
+type BlockedError struct {
+	// Reason is the provider's block or finish reason, like SAFETY.
+	Reason string
+	// Prompt is set when the prompt itself was blocked.
+	Prompt  bool
+	Ratings []SafetyRating
+}
+
+func (e *BlockedError) Error() string {
+	what := "response"
+	if e.Prompt {
+		what = "prompt"
+	}
+	var flagged []string
+	for _, rating := range e.Ratings {
+		if rating.Blocked || rating.Probability == "HIGH" || rating.Probability == "MEDIUM" {
+			flagged = append(flagged, rating.Category)
+		}
+	}
+	if len(flagged) > 0 {
+		return fmt.Sprintf("llm: %s blocked (%s: %s)", what, e.Reason, strings.Join(flagged, ", "))
+	}
+	return fmt.Sprintf("llm: %s blocked (%s)", what, e.Reason)
+}
+
+// Retryable reports whether a call may succeed when tried again: rate
+// limits, provider outages, timeouts and network failures.
+func Retryable(err error) bool {
+	var providerErr *ProviderError
+	if errors.As(err, &providerErr) {
+		return providerErr.StatusCode == http.StatusTooManyRequests || providerErr.StatusCode >= 500
+	}
+	var networkErr *NetworkError
+	return errors.As(err, &networkErr) || errors.Is(err, ErrTimeout)
+}
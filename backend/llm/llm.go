@@ -0,0 +1,237 @@
+// Package llm is the client for the Gemini API. Every attempt gets its own
+// timeout, rate limits and outages are retried with jittered backoff, and a
+// circuit breaker stops calling a provider that keeps failing. What went
+// wrong comes back as a typed error callers can tell apart.
+package llm
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/Maheshkarri4444/Examify/batch"
+)
+
+const defaultBaseURL = "https://generativelanguage.googleapis.com/v1beta"
+
+type Config struct {
+	APIKey string
+	Model  string
+	// BaseURL defaults to the v1beta API, which is needed for
+	// responseSchema.
+	BaseURL string
+	// Timeout bounds one attempt, the caller's context bounds them all.
+	Timeout time.Duration
+	// Attempts is how often a call is tried on rate limits, server errors
+	// and timeouts. Waits double from Backoff, with full jitter.
+	Attempts int
+	Backoff  time.Duration
+	// After BreakerFailures failed attempts in a row calls fail fast with
+	// ErrCircuitOpen for BreakerCooldown, then one call is let through to
+	// probe the provider.
+	BreakerFailures int
+	BreakerCooldown time.Duration
+}
+
+type Client struct {
+	cfg     Config
+	http    *http.Client
+	breaker *breaker
+}
+
+func New(cfg Config) *Client {
+	if cfg.BaseURL == "" {
+		cfg.BaseURL = defaultBaseURL
+	}
+	if cfg.Attempts < 1 {
+		cfg.Attempts = 1
+	}
+	return &Client{
+		cfg:     cfg,
+		http:    &http.Client{},
+		breaker: &breaker{threshold: cfg.BreakerFailures, cooldown: cfg.BreakerCooldown},
+	}
+}
+
+// Model is the model the client asks.
+func (c *Client) Model() string {
+	return c.cfg.Model
+}
+
+// Usage is the token use the provider reports for a call.
+type Usage struct {
+	PromptTokens   int64
+	ResponseTokens int64
+	TotalTokens    int64
+}
+
+type Response struct {
+	Text         string
+	FinishReason string
+	Usage        Usage
+	// Attempts is how many requests the call took.
+	Attempts int
+}
+
+// Generate sends one prompt and returns the text of the first candidate.
+// With a schema the model is asked for JSON matching it. The response is
+// never nil, with an error it still tells how many requests were made and
+// the usage of the last answer.
+func (c *Client) Generate(ctx context.Context, prompt string, schema map[string]interface{}) (*Response, error) {
+	response := &Response{}
+	if c.cfg.APIKey == "" {
+		return response, ErrNotConfigured
+	}
+
+	body := map[string]interface{}{
+		"contents": []map[string]interface{}{
+			{
+				"role":  "user",
+				"parts": []map[string]string{{"text": prompt}},
+			},
+		},
+	}
+	if schema != nil {
+		body["generationConfig"] = map[string]interface{}{
+			"responseMimeType": "application/json",
+			"responseSchema":   schema,
+		}
+	}
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return response, fmt.Errorf("failed to encode request body: %w", err)
+	}
+
+	err = batch.Retry(ctx, c.cfg.Attempts, c.cfg.Backoff, Retryable, func(ctx context.Context) error {
+		allowed, probe := c.breaker.allow()
+		if !allowed {
+			return ErrCircuitOpen
+		}
+		response.Attempts++
+		answer, err := c.attempt(ctx, payload)
+		c.breaker.done(probe, err)
+		if answer != nil {
+			answer.Attempts = response.Attempts
+			response = answer
+		}
+		return err
+	})
+	return response, err
+}
+
+// attempt makes one request within its own timeout.
+func (c *Client) attempt(ctx context.Context, payload []byte) (*Response, error) {
+	if c.cfg.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.cfg.Timeout)
+		defer cancel()
+	}
+
+	url := fmt.Sprintf("%s/models/%s:generateContent", c.cfg.BaseURL, c.cfg.Model)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(payload))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("x-goog-api-key", c.cfg.APIKey)
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, requestError(ctx, err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, requestError(ctx, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, &ProviderError{StatusCode: resp.StatusCode, Body: string(data)}
+	}
+	return parseResponse(data)
+}
+
+// requestError tells a timed out attempt from a cancelled call and a
+// network failure.
+func requestError(ctx context.Context, err error) error {
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return fmt.Errorf("%w: %v", ErrTimeout, err)
+	}
+	if errors.Is(ctx.Err(), context.Canceled) {
+		return ctx.Err()
+	}
+	return &NetworkError{Err: err}
+}
+
+type generateContentResponse struct {
+	Candidates []struct {
+		Content struct {
+			Parts []struct {
+				Text string `json:"text"`
+			} `json:"parts"`
+		} `json:"content"`
+		FinishReason  string         `json:"finishReason"`
+		SafetyRatings []SafetyRating `json:"safetyRatings"`
+	} `json:"candidates"`
+	PromptFeedback struct {
+		BlockReason   string         `json:"blockReason"`
+		SafetyRatings []SafetyRating `json:"safetyRatings"`
+	} `json:"promptFeedback"`
+	UsageMetadata struct {
+		PromptTokenCount     int64 `json:"promptTokenCount"`
+		CandidatesTokenCount int64 `json:"candidatesTokenCount"`
+		TotalTokenCount      int64 `json:"totalTokenCount"`
+	} `json:"usageMetadata"`
+}
+
+// SafetyRating is how likely the provider found content to be harmful.
+type SafetyRating struct {
+	Category    string `json:"category"`
+	Probability string `json:"probability"`
+	Blocked     bool   `json:"blocked,omitempty"`
+}
+
+// parseResponse reads a generateContent answer. A blocked prompt or
+// response and an answer cut off or without text are errors.
+func parseResponse(data []byte) (*Response, error) {
+	var out generateContentResponse
+	if err := json.Unmarshal(data, &out); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrMalformedResponse, err)
+	}
+	response := &Response{Usage: Usage{
+		PromptTokens:   out.UsageMetadata.PromptTokenCount,
+		ResponseTokens: out.UsageMetadata.CandidatesTokenCount,
+		TotalTokens:    out.UsageMetadata.TotalTokenCount,
+	}}
+
+	if reason := out.PromptFeedback.BlockReason; reason != "" {
+		return response, &BlockedError{Reason: reason, Prompt: true, Ratings: out.PromptFeedback.SafetyRatings}
+	}
+	if len(out.Candidates) == 0 {
+		return response, ErrEmptyResponse
+	}
+	candidate := out.Candidates[0]
+	response.FinishReason = candidate.FinishReason
+	for _, part := range candidate.Content.Parts {
+		response.Text += part.Text
+	}
+
+	switch candidate.FinishReason {
+	case "", "STOP":
+	case "MAX_TOKENS":
+		return response, ErrTruncated
+	case "SAFETY", "RECITATION", "BLOCKLIST", "PROHIBITED_CONTENT", "SPII", "LANGUAGE":
+		return response, &BlockedError{Reason: candidate.FinishReason, Ratings: candidate.SafetyRatings}
+	default:
+		return response, fmt.Errorf("%w: finish reason %s", ErrEmptyResponse, candidate.FinishReason)
+	}
+	if response.Text == "" {
+		return response, ErrEmptyResponse
+	}
+	return response, nil
+}
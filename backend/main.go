@@ -1,45 +1,33 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"os"
-	"time"
+	"os/signal"
+	"syscall"
 
-	"github.com/Maheshkarri4444/Examify/routes"
-	"github.com/gin-contrib/cors"
-	"github.com/gin-gonic/gin"
-	"github.com/joho/godotenv"
+	"github.com/Maheshkarri4444/Examify/app"
+	"github.com/Maheshkarri4444/Examify/config"
 )
 
 func main() {
 	fmt.Println("welcome to examify backend")
-	err := godotenv.Load()
+
+	cfg, err := config.Load(os.Args[1:])
 	if err != nil {
-		log.Fatal("Error loading .env file")
+		log.Fatal(err)
 	}
 
-	r := gin.Default()
-	r.Use(cors.New(cors.Config{
-		AllowOrigins:     []string{"http://localhost:5173"}, // Change to your frontend URL
-		AllowMethods:     []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
-		AllowHeaders:     []string{"Origin", "Content-Type", "Authorization"},
-		ExposeHeaders:    []string{"Content-Length"},
-		AllowCredentials: true,
-		MaxAge:           12 * time.Hour,
-	}))
-
-	routes.AuthRoutes(r)
-	routes.ExamRoutes(r)
-	routes.AiRoutes(r)
-
-	r.SetTrustedProxies(nil) // Only for development
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = "8080"
+	application, err := app.New(ctx, cfg)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := application.Run(ctx); err != nil {
+		log.Fatal(err)
 	}
-	fmt.Println("Server running on the port: ", port)
-	r.Run(":" + port)
-
 }
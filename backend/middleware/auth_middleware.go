@@ -4,22 +4,57 @@ import (
 	"context"
 	"fmt"
 	"net/http"
-	"os"
+	"sync"
 	"time"
 
-	"github.com/Maheshkarri4444/Examify/config"
+	"github.com/Maheshkarri4444/Examify/models"
+	"github.com/Maheshkarri4444/Examify/store"
 	"github.com/dgrijalva/jwt-go"
 	"github.com/gin-gonic/gin"
-	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
-	"go.mongodb.org/mongo-driver/mongo"
 )
 
-var userCollection *mongo.Collection = config.GetCollection(config.Client, "users")
+const (
+	// MonitorAudience is the audience of the tickets that open an exam
+	// monitor stream.
+	MonitorAudience  = "monitor"
+	MonitorTicketTTL = time.Minute
+)
+
+// Auth builds the authentication middlewares. Users are looked up on every
+// request so role changes apply immediately.
+type Auth struct {
+	users     store.UserStore
+	jwtSecret []byte
+
+	mu sync.Mutex
+	// usedTickets holds the IDs of the monitor tickets used so far, until
+	// they expire. Streams come from this process's event bus, so a ticket
+	// is only ever checked here.
+	usedTickets map[string]time.Time
+}
+
+func NewAuth(users store.UserStore, jwtSecret string) *Auth {
+	return &Auth{users: users, jwtSecret: []byte(jwtSecret), usedTickets: map[string]time.Time{}}
+}
 
-func VerifyJWT(tokenString string) (jwt.MapClaims, error) {
+// SignMonitorTicket issues a ticket that opens the monitor stream of one
+// exam, once, within MonitorTicketTTL.
+func SignMonitorTicket(jwtSecret, email string, examID primitive.ObjectID) (string, error) {
+	claims := jwt.MapClaims{
+		"email":   email,
+		"aud":     MonitorAudience,
+		"exam_id": examID.Hex(),
+		"jti":     primitive.NewObjectID().Hex(),
+		"exp":     time.Now().Add(MonitorTicketTTL).Unix(),
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	return token.SignedString([]byte(jwtSecret))
+}
+
+func (a *Auth) VerifyJWT(tokenString string) (jwt.MapClaims, error) {
 	token, err := jwt.Parse(tokenString, func(t *jwt.Token) (interface{}, error) {
-		return []byte(os.Getenv("JWT_SECRET")), nil
+		return a.jwtSecret, nil
 	})
 
 	if err != nil || !token.Valid {
@@ -34,7 +69,7 @@ func VerifyJWT(tokenString string) (jwt.MapClaims, error) {
 	return claims, nil
 }
 
-func AuthMiddleware(requiredRole string) gin.HandlerFunc {
+func (a *Auth) AuthMiddleware(allowedRoles ...string) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		tokenString := c.GetHeader("Authorization")
 		if tokenString == "" {
@@ -43,62 +78,132 @@ func AuthMiddleware(requiredRole string) gin.HandlerFunc {
 			return
 		}
 
-		claims, err := VerifyJWT(tokenString)
+		claims, err := a.VerifyJWT(tokenString)
 		if err != nil {
 			fmt.Println("err: ", err)
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
 			c.Abort()
 			return
 		}
-
-		email, ok := claims["email"].(string)
-		if !ok {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid email in token"})
+		// Tickets are only good for what they were issued for
+		if _, ok := claims["aud"]; ok {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
 			c.Abort()
 			return
 		}
 
-		// Fetch user from the database using email
-		var user struct {
-			ID          primitive.ObjectID `bson:"_id"`
-			Name        string             `bson:"name,omitempty" json:"name"`
-			Email       string             `bson:"email" json:"email"`
-			Image       string             `bson:"image,omitempty" json:"image,omitempty"` // Google profile image URL
-			Role        string             `bson:"role"`
-			ContainerID primitive.ObjectID `bson:"contianer_id"`
+		if a.signIn(c, claims, allowedRoles) {
+			c.Next()
 		}
+	}
+}
 
-		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-		defer cancel()
-		err = userCollection.FindOne(ctx, bson.M{"email": email}).Decode(&user)
-		if err != nil {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found"})
+// MonitorTicket authenticates an exam monitor stream with the ticket passed
+// as ?ticket=, EventSource can't set an Authorization header. A ticket
+// opens one exam's stream once and expires after a minute, so one that ends
+// up in an access log is of no use. Only teachers and admins get through.
+func (a *Auth) MonitorTicket() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		claims, err := a.VerifyJWT(c.Query("ticket"))
+		if err != nil || !claims.VerifyAudience(MonitorAudience, true) || claims["exam_id"] != c.Param("examid") {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid ticket"})
 			c.Abort()
 			return
 		}
-		// fmt.Println("userrole: ", user.Role)
-		// fmt.Println("required role: ", requiredRole)
-		// Check if user has the required role
-		if user.Role != requiredRole {
-			c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+		id, _ := claims["jti"].(string)
+		exp, _ := claims["exp"].(float64)
+		if id == "" || !a.useTicket(id, time.Unix(int64(exp), 0)) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Ticket already used"})
 			c.Abort()
 			return
 		}
 
-		// Set user ID and role in context
-		c.Set("user_id", user.ID)
-		c.Set("name", user.Name)
-		c.Set("email", user.Email)
-		c.Set("role", user.Role)
-		c.Set("image", user.Image)
-		c.Set("container_id", user.ContainerID)
-		c.Next()
+		if a.signIn(c, claims, []string{models.RoleTeacher, models.RoleAdmin}) {
+			c.Next()
+		}
 	}
 }
-func StudentMiddleware() gin.HandlerFunc {
-	return AuthMiddleware("student")
+
+// useTicket records a ticket as used and reports whether it was the first
+// use.
+func (a *Auth) useTicket(id string, expires time.Time) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	now := time.Now()
+	for used, until := range a.usedTickets {
+		if until.Before(now) {
+			delete(a.usedTickets, used)
+		}
+	}
+	if _, ok := a.usedTickets[id]; ok {
+		return false
+	}
+	a.usedTickets[id] = expires
+	return true
+}
+
+// signIn loads the user the claims are for into the context. It writes the
+// error response and returns false when the user can't go on.
+func (a *Auth) signIn(c *gin.Context, claims jwt.MapClaims, allowedRoles []string) bool {
+	email, ok := claims["email"].(string)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid email in token"})
+		c.Abort()
+		return false
+	}
+
+	// Fetch user from the database using email
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	user, err := a.users.GetByEmail(ctx, email)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found"})
+		c.Abort()
+		return false
+	}
+	// Check if user has one of the allowed roles
+	if !hasRole(user.Role, allowedRoles) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+		c.Abort()
+		return false
+	}
+
+	// Set user ID and role in context
+	c.Set("user_id", user.ID)
+	c.Set("name", user.Name)
+	c.Set("email", user.Email)
+	c.Set("role", user.Role)
+	c.Set("image", user.Image)
+	c.Set("container_id", user.ContainerID)
+	if admin, ok := claims["impersonated_by"].(string); ok && admin != "" {
+		c.Set("impersonated_by", admin)
+	}
+	return true
+}
+
+func hasRole(role string, allowedRoles []string) bool {
+	for _, r := range allowedRoles {
+		if role == r {
+			return true
+		}
+	}
+	return false
+}
+
+func (a *Auth) StudentMiddleware() gin.HandlerFunc {
+	return a.AuthMiddleware(models.RoleStudent)
+}
+
+// TeacherMiddleware also lets admins through, they own a teacher container.
+func (a *Auth) TeacherMiddleware() gin.HandlerFunc {
+	return a.AuthMiddleware(models.RoleTeacher, models.RoleAdmin)
+}
+
+// SignedInMiddleware lets any signed in user through, whatever the role.
+func (a *Auth) SignedInMiddleware() gin.HandlerFunc {
+	return a.AuthMiddleware(models.RoleStudent, models.RoleTeacher, models.RoleAdmin)
 }
 
-func TeacherMiddleware() gin.HandlerFunc {
-	return AuthMiddleware("teacher")
+func (a *Auth) AdminMiddleware() gin.HandlerFunc {
+	return a.AuthMiddleware(models.RoleAdmin)
 }
@@ -0,0 +1,178 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Maheshkarri4444/Examify/models"
+	"github.com/Maheshkarri4444/Examify/store"
+	"github.com/dgrijalva/jwt-go"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const testSecret = "secret"
+
+func init() {
+	gin.SetMode(gin.TestMode)
+}
+
+func newTestAuth(t *testing.T) *Auth {
+	t.Helper()
+	stores := store.NewMemoryStores()
+	for email, role := range map[string]string{
+		"teacher@example.com": models.RoleTeacher,
+		"student@example.com": models.RoleStudent,
+	} {
+		if err := stores.Users.Create(context.Background(), &models.User{Email: email, Role: role}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return NewAuth(stores.Users, testSecret)
+}
+
+func sign(t *testing.T, claims jwt.MapClaims) string {
+	t.Helper()
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(testSecret))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+// openStream requests the monitor stream of examID with ticket.
+func openStream(auth *Auth, examID, ticket string) int {
+	r := gin.New()
+	r.GET("/monitor/:examid/stream", auth.MonitorTicket(), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/monitor/"+examID+"/stream?ticket="+ticket, nil))
+	return w.Code
+}
+
+func TestMonitorTicket(t *testing.T) {
+	examID := primitive.NewObjectID()
+	ticket := func(t *testing.T, email string) string {
+		ticket, err := SignMonitorTicket(testSecret, email, examID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return ticket
+	}
+	tests := []struct {
+		name     string
+		ticket   func(t *testing.T) string
+		examID   string
+		wantCode int
+	}{
+		{
+			name:     "valid",
+			ticket:   func(t *testing.T) string { return ticket(t, "teacher@example.com") },
+			wantCode: http.StatusOK,
+		},
+		{
+			name:     "other exam",
+			ticket:   func(t *testing.T) string { return ticket(t, "teacher@example.com") },
+			examID:   primitive.NewObjectID().Hex(),
+			wantCode: http.StatusUnauthorized,
+		},
+		{
+			name: "login token",
+			ticket: func(t *testing.T) string {
+				return sign(t, jwt.MapClaims{"email": "teacher@example.com", "exp": time.Now().Add(time.Hour).Unix()})
+			},
+			wantCode: http.StatusUnauthorized,
+		},
+		{
+			name: "expired",
+			ticket: func(t *testing.T) string {
+				return sign(t, jwt.MapClaims{
+					"email": "teacher@example.com", "aud": MonitorAudience, "exam_id": examID.Hex(),
+					"jti": "old", "exp": time.Now().Add(-time.Second).Unix(),
+				})
+			},
+			wantCode: http.StatusUnauthorized,
+		},
+		{
+			name: "no ticket ID",
+			ticket: func(t *testing.T) string {
+				return sign(t, jwt.MapClaims{
+					"email": "teacher@example.com", "aud": MonitorAudience, "exam_id": examID.Hex(),
+					"exp": time.Now().Add(time.Minute).Unix(),
+				})
+			},
+			wantCode: http.StatusUnauthorized,
+		},
+		{
+			name: "signed with another secret",
+			ticket: func(t *testing.T) string {
+				ticket, err := SignMonitorTicket("other", "teacher@example.com", examID)
+				if err != nil {
+					t.Fatal(err)
+				}
+				return ticket
+			},
+			wantCode: http.StatusUnauthorized,
+		},
+		{
+			name:     "student",
+			ticket:   func(t *testing.T) string { return ticket(t, "student@example.com") },
+			wantCode: http.StatusForbidden,
+		},
+		{
+			name:     "missing",
+			ticket:   func(t *testing.T) string { return "" },
+			wantCode: http.StatusUnauthorized,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := tt.examID
+			if path == "" {
+				path = examID.Hex()
+			}
+			if code := openStream(newTestAuth(t), path, tt.ticket(t)); code != tt.wantCode {
+				t.Errorf("code = %d, want %d", code, tt.wantCode)
+			}
+		})
+	}
+}
+
+func TestMonitorTicketWorksOnce(t *testing.T) {
+	auth := newTestAuth(t)
+	examID := primitive.NewObjectID()
+	ticket, err := SignMonitorTicket(testSecret, "teacher@example.com", examID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, want := range []int{http.StatusOK, http.StatusUnauthorized} {
+		if code := openStream(auth, examID.Hex(), ticket); code != want {
+			t.Errorf("use %d: code = %d, want %d", i+1, code, want)
+		}
+	}
+}
+
+func TestAuthMiddlewareRefusesTickets(t *testing.T) {
+	auth := newTestAuth(t)
+	ticket, err := SignMonitorTicket(testSecret, "teacher@example.com", primitive.NewObjectID())
+	if err != nil {
+		t.Fatal(err)
+	}
+	login := sign(t, jwt.MapClaims{"email": "teacher@example.com", "exp": time.Now().Add(time.Hour).Unix()})
+
+	for token, want := range map[string]int{ticket: http.StatusUnauthorized, login: http.StatusOK} {
+		r := gin.New()
+		r.GET("/", auth.TeacherMiddleware(), func(c *gin.Context) { c.Status(http.StatusOK) })
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("Authorization", token)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if w.Code != want {
+			t.Errorf("code = %d, want %d", w.Code, want)
+		}
+	}
+}
@@ -0,0 +1,14 @@
+package models
+
+import "go.mongodb.org/mongo-driver/bson/primitive"
+
+// AIGradingCacheEntry is a grading stored under the hash of everything that
+// went into it, so the same answer to the same question is graded once.
+// The grading is uncalibrated, each exam applies its own calibration.
+type AIGradingCacheEntry struct {
+	Key       string             `bson:"_id" json:"key"`
+	Grading   AIGrading          `bson:"grading" json:"grading"`
+	CreatedAt primitive.DateTime `bson:"created_at" json:"created_at"`
+	// ExpiresAt is when MongoDB's TTL monitor drops the entry.
+	ExpiresAt primitive.DateTime `bson:"expires_at" json:"expires_at"`
+}
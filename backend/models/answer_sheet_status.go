@@ -0,0 +1,159 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// SheetStatus is the lifecycle state of an answer sheet.
+//
+//	assigned ──► started ──► submitted ──► evaluated
+//	               │ ▲          ▲
+//	               ▼ │          │
+//	             paused ────────┘
+//
+// Started and paused sheets expire when their time runs out, and any sheet
+// that is not evaluated yet can be voided. Submitted and expired sheets can be
+// reopened by staff, which moves them back to started.
+type SheetStatus string
+
+const (
+	SheetAssigned  SheetStatus = "assigned"
+	SheetStarted   SheetStatus = "started"
+	SheetPaused    SheetStatus = "paused"
+	SheetSubmitted SheetStatus = "submitted"
+	SheetExpired   SheetStatus = "expired"
+	SheetVoided    SheetStatus = "voided"
+	SheetEvaluated SheetStatus = "evaluated"
+)
+
+var sheetTransitions = map[SheetStatus][]SheetStatus{
+	SheetAssigned:  {SheetStarted, SheetVoided},
+	SheetStarted:   {SheetPaused, SheetSubmitted, SheetExpired, SheetVoided},
+	SheetPaused:    {SheetStarted, SheetSubmitted, SheetExpired, SheetVoided},
+	SheetSubmitted: {SheetEvaluated, SheetStarted, SheetVoided},
+	SheetExpired:   {SheetEvaluated, SheetStarted, SheetVoided},
+	SheetVoided:    {},
+	SheetEvaluated: {},
+}
+
+// legacySheetStatuses maps the free-form statuses stored before the state
+// machine existed to their states.
+var legacySheetStatuses = map[string]SheetStatus{
+	"didnotstart": SheetAssigned,
+	"internal":    SheetAssigned,
+	"ended":       SheetSubmitted,
+}
+
+// LegacySheetStatuses returns the old status strings that mean status.
+func LegacySheetStatuses(status SheetStatus) []string {
+	var legacy []string
+	for old, s := range legacySheetStatuses {
+		if s == status {
+			legacy = append(legacy, old)
+		}
+	}
+	return legacy
+}
+
+// Valid reports whether s is a known state.
+func (s SheetStatus) Valid() bool {
+	_, ok := sheetTransitions[s]
+	return ok
+}
+
+// CanTransition reports whether a sheet may move from s to next.
+func (s SheetStatus) CanTransition(next SheetStatus) bool {
+	for _, allowed := range sheetTransitions[s] {
+		if allowed == next {
+			return true
+		}
+	}
+	return false
+}
+
+// Submitted reports whether the answers of a sheet in this state are final.
+func (s SheetStatus) Submitted() bool {
+	return s == SheetSubmitted || s == SheetExpired || s == SheetEvaluated
+}
+
+// Closed reports whether the student can no longer work on the sheet.
+func (s SheetStatus) Closed() bool {
+	return s.Submitted() || s == SheetVoided
+}
+
+// SheetStatusesBefore returns every state that may move to next.
+func SheetStatusesBefore(next SheetStatus) []SheetStatus {
+	var from []SheetStatus
+	for s := range sheetTransitions {
+		if s.CanTransition(next) {
+			from = append(from, s)
+		}
+	}
+	return from
+}
+
+// SheetTransition is one entry of an answer sheet's history.
+type SheetTransition struct {
+	From   SheetStatus        `bson:"from" json:"from"`
+	To     SheetStatus        `bson:"to" json:"to"`
+	At     primitive.DateTime `bson:"at" json:"at"`
+	By     string             `bson:"by" json:"by"`
+	Reason string             `bson:"reason,omitempty" json:"reason,omitempty"`
+}
+
+// SheetTiming is the server side clock of an attempt. Untimed sheets, like
+// those of internal exams, have no deadline.
+type SheetTiming struct {
+	StartedAt    *primitive.DateTime `bson:"started_at,omitempty" json:"started_at,omitempty"`
+	Deadline     *primitive.DateTime `bson:"deadline,omitempty" json:"deadline,omitempty"`
+	PausedAt     *primitive.DateTime `bson:"paused_at,omitempty" json:"paused_at,omitempty"`
+	ExtraMinutes int64               `bson:"extra_minutes" json:"extra_minutes"`
+}
+
+// TimingAfter returns the sheet's timing once it moves to status next at now.
+// Starting sets the deadline from the duration, resuming pushes it back by
+// the time spent paused, and reopening a closed sheet leaves it untimed.
+func (a *AnswerSheet) TimingAfter(next SheetStatus, now time.Time) SheetTiming {
+	t := a.SheetTiming
+	at := primitive.NewDateTimeFromTime(now)
+	switch {
+	case next == SheetPaused:
+		t.PausedAt = &at
+	case next == SheetStarted && a.Status == SheetAssigned:
+		t.StartedAt = &at
+		if a.Duration > 0 {
+			deadline := primitive.NewDateTimeFromTime(now.Add(time.Duration(a.Duration) * time.Minute))
+			t.Deadline = &deadline
+		}
+	case next == SheetStarted && a.Status == SheetPaused:
+		if t.Deadline != nil && t.PausedAt != nil {
+			deadline := primitive.NewDateTimeFromTime(t.Deadline.Time().Add(now.Sub(t.PausedAt.Time())))
+			t.Deadline = &deadline
+		}
+		t.PausedAt = nil
+	case next == SheetStarted:
+		t.Deadline = nil
+		t.PausedAt = nil
+	default:
+		t.PausedAt = nil
+	}
+	return t
+}
+
+// Remaining returns the time left on a timed sheet, which stands still while
+// the sheet is paused. It reports false for untimed sheets.
+func (a *AnswerSheet) Remaining(now time.Time) (time.Duration, bool) {
+	if a.Deadline == nil {
+		return 0, false
+	}
+	if a.PausedAt != nil {
+		now = a.PausedAt.Time()
+	}
+	left := a.Deadline.Time().Sub(now)
+	if left < 0 {
+		left = 0
+	}
+	return left, true
+}
@@ -0,0 +1,162 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestCanTransition(t *testing.T) {
+	all := []SheetStatus{SheetAssigned, SheetStarted, SheetPaused, SheetSubmitted, SheetExpired, SheetVoided, SheetEvaluated}
+	allowed := map[SheetStatus][]SheetStatus{
+		SheetAssigned:  {SheetStarted, SheetVoided},
+		SheetStarted:   {SheetPaused, SheetSubmitted, SheetExpired, SheetVoided},
+		SheetPaused:    {SheetStarted, SheetSubmitted, SheetExpired, SheetVoided},
+		SheetSubmitted: {SheetEvaluated, SheetStarted, SheetVoided},
+		SheetExpired:   {SheetEvaluated, SheetStarted, SheetVoided},
+	}
+	for _, from := range all {
+		want := map[SheetStatus]bool{}
+		for _, to := range allowed[from] {
+			want[to] = true
+		}
+		for _, to := range all {
+			if got := from.CanTransition(to); got != want[to] {
+				t.Errorf("%s -> %s: CanTransition = %v, want %v", from, to, got, want[to])
+			}
+		}
+		if from.CanTransition("ended") {
+			t.Errorf("%s -> ended: a legacy status must not be a target", from)
+		}
+	}
+	if SheetStatus("ended").Valid() || !SheetStarted.Valid() {
+		t.Error("Valid must only accept the states of the machine")
+	}
+}
+
+func TestSheetStatusFlags(t *testing.T) {
+	tests := []struct {
+		status    SheetStatus
+		submitted bool
+		closed    bool
+	}{
+		{SheetAssigned, false, false},
+		{SheetStarted, false, false},
+		{SheetPaused, false, false},
+		{SheetSubmitted, true, true},
+		{SheetExpired, true, true},
+		{SheetEvaluated, true, true},
+		{SheetVoided, false, true},
+	}
+	for _, tt := range tests {
+		if got := tt.status.Submitted(); got != tt.submitted {
+			t.Errorf("%s: Submitted = %v, want %v", tt.status, got, tt.submitted)
+		}
+		if got := tt.status.Closed(); got != tt.closed {
+			t.Errorf("%s: Closed = %v, want %v", tt.status, got, tt.closed)
+		}
+	}
+}
+
+func TestTimingAfter(t *testing.T) {
+	start := time.Date(2026, 3, 2, 9, 0, 0, 0, time.UTC)
+	at := func(d time.Duration) *primitive.DateTime {
+		dt := primitive.NewDateTimeFromTime(start.Add(d))
+		return &dt
+	}
+	tests := []struct {
+		name         string
+		sheet        AnswerSheet
+		next         SheetStatus
+		now          time.Duration // after start
+		wantDeadline *primitive.DateTime
+		wantPausedAt *primitive.DateTime
+	}{
+		{
+			name:         "start a timed sheet",
+			sheet:        AnswerSheet{Status: SheetAssigned, Duration: 60},
+			next:         SheetStarted,
+			wantDeadline: at(time.Hour),
+		},
+		{
+			name:  "start an untimed sheet",
+			sheet: AnswerSheet{Status: SheetAssigned},
+			next:  SheetStarted,
+		},
+		{
+			name:         "pause",
+			sheet:        AnswerSheet{Status: SheetStarted, SheetTiming: SheetTiming{Deadline: at(time.Hour)}},
+			next:         SheetPaused,
+			now:          10 * time.Minute,
+			wantDeadline: at(time.Hour),
+			wantPausedAt: at(10 * time.Minute),
+		},
+		{
+			name:         "resume pushes the deadline back",
+			sheet:        AnswerSheet{Status: SheetPaused, SheetTiming: SheetTiming{Deadline: at(time.Hour), PausedAt: at(10 * time.Minute)}},
+			next:         SheetStarted,
+			now:          25 * time.Minute,
+			wantDeadline: at(75 * time.Minute),
+		},
+		{
+			name:         "submit while paused",
+			sheet:        AnswerSheet{Status: SheetPaused, SheetTiming: SheetTiming{Deadline: at(time.Hour), PausedAt: at(10 * time.Minute)}},
+			next:         SheetSubmitted,
+			now:          20 * time.Minute,
+			wantDeadline: at(time.Hour),
+		},
+		{
+			name:  "reopen leaves it untimed",
+			sheet: AnswerSheet{Status: SheetExpired, SheetTiming: SheetTiming{Deadline: at(time.Hour)}},
+			next:  SheetStarted,
+			now:   2 * time.Hour,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.sheet.TimingAfter(tt.next, start.Add(tt.now))
+			if !sameTime(got.Deadline, tt.wantDeadline) {
+				t.Errorf("deadline = %v, want %v", got.Deadline, tt.wantDeadline)
+			}
+			if !sameTime(got.PausedAt, tt.wantPausedAt) {
+				t.Errorf("paused at = %v, want %v", got.PausedAt, tt.wantPausedAt)
+			}
+		})
+	}
+}
+
+func TestRemaining(t *testing.T) {
+	start := time.Date(2026, 3, 2, 9, 0, 0, 0, time.UTC)
+	deadline := primitive.NewDateTimeFromTime(start.Add(time.Hour))
+	pausedAt := primitive.NewDateTimeFromTime(start.Add(20 * time.Minute))
+
+	tests := []struct {
+		name      string
+		timing    SheetTiming
+		now       time.Duration
+		want      time.Duration
+		wantTimed bool
+	}{
+		{name: "untimed", now: time.Hour},
+		{name: "running", timing: SheetTiming{Deadline: &deadline}, now: 15 * time.Minute, want: 45 * time.Minute, wantTimed: true},
+		{name: "paused stands still", timing: SheetTiming{Deadline: &deadline, PausedAt: &pausedAt}, now: 50 * time.Minute, want: 40 * time.Minute, wantTimed: true},
+		{name: "past the deadline", timing: SheetTiming{Deadline: &deadline}, now: 2 * time.Hour, want: 0, wantTimed: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sheet := AnswerSheet{SheetTiming: tt.timing}
+			got, timed := sheet.Remaining(start.Add(tt.now))
+			if got != tt.want || timed != tt.wantTimed {
+				t.Errorf("Remaining = %v, %v, want %v, %v", got, timed, tt.want, tt.wantTimed)
+			}
+		})
+	}
+}
+
+func sameTime(a, b *primitive.DateTime) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
@@ -0,0 +1,74 @@
+package models
+
+import (
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// JobStatus is where a background job is in its life:
+//
+//	queued -> running -> succeeded
+//	            |  '---> queued again to retry after a failure
+//	            |  '---> dead once its attempts are used up
+//	            '------> cancelled
+//
+// Dead and cancelled jobs can be requeued by an admin.
+type JobStatus string
+
+const (
+	JobQueued    JobStatus = "queued"
+	JobRunning   JobStatus = "running"
+	JobSucceeded JobStatus = "succeeded"
+	JobCancelled JobStatus = "cancelled"
+	JobDead      JobStatus = "dead"
+)
+
+// Finished reports whether the job will not run again on its own.
+func (s JobStatus) Finished() bool {
+	return s == JobSucceeded || s == JobCancelled || s == JobDead
+}
+
+type JobProgress struct {
+	Total  int `bson:"total" json:"total"`
+	Done   int `bson:"done" json:"done"`
+	Failed int `bson:"failed" json:"failed"`
+}
+
+// Job is a unit of background work kept in the jobs collection so it
+// survives restarts. A worker leases a job for a short time and keeps
+// extending the lease while it runs; a job whose lease runs out is picked up
+// by another worker.
+type Job struct {
+	ID      primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Kind    string             `bson:"kind" json:"kind"`
+	Status  JobStatus          `bson:"status" json:"status"`
+	Payload bson.Raw           `bson:"payload,omitempty" json:"-"`
+	// IdempotencyKey makes enqueueing the same request twice return the
+	// first job.
+	IdempotencyKey string `bson:"idempotency_key,omitempty" json:"idempotency_key,omitempty"`
+	// UniqueKey allows only one unfinished job per key. ActiveKey holds it
+	// while the job is unfinished, a unique index does the rest.
+	UniqueKey string `bson:"unique_key,omitempty" json:"unique_key,omitempty"`
+	ActiveKey string `bson:"active_key,omitempty" json:"-"`
+
+	ExamID    primitive.ObjectID `bson:"exam_id,omitempty" json:"exam_id,omitempty"`
+	CreatedBy string             `bson:"created_by" json:"created_by"`
+
+	Attempts        int                    `bson:"attempts" json:"attempts"`
+	MaxAttempts     int                    `bson:"max_attempts" json:"max_attempts"`
+	RunAt           primitive.DateTime     `bson:"run_at" json:"run_at"`
+	LeaseOwner      string                 `bson:"lease_owner,omitempty" json:"-"`
+	LeaseUntil      *primitive.DateTime    `bson:"lease_until,omitempty" json:"lease_until,omitempty"`
+	CancelRequested bool                   `bson:"cancel_requested" json:"cancel_requested"`
+	Progress        JobProgress            `bson:"progress" json:"progress"`
+	Result          map[string]interface{} `bson:"result,omitempty" json:"result,omitempty"`
+	LastError       string                 `bson:"last_error,omitempty" json:"last_error,omitempty"`
+
+	CreatedAt  primitive.DateTime  `bson:"created_at" json:"created_at"`
+	UpdatedAt  primitive.DateTime  `bson:"updated_at" json:"updated_at"`
+	StartedAt  *primitive.DateTime `bson:"started_at,omitempty" json:"started_at,omitempty"`
+	FinishedAt *primitive.DateTime `bson:"finished_at,omitempty" json:"finished_at,omitempty"`
+	// ExpireAt lets MongoDB remove succeeded and cancelled jobs after a
+	// while. Dead jobs are kept until someone looks at them.
+	ExpireAt *primitive.DateTime `bson:"expire_at,omitempty" json:"-"`
+}
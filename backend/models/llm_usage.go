@@ -0,0 +1,29 @@
+package models
+
+import "go.mongodb.org/mongo-driver/bson/primitive"
+
+const (
+	LLMCallOK    = "ok"
+	LLMCallError = "error"
+)
+
+// LLMCall records one request to the LLM provider, for quotas and cost
+// reporting. Token counts are the provider's.
+type LLMCall struct {
+	ID             primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	UserEmail      string             `bson:"user_email" json:"user_email"`
+	UserRole       string             `bson:"user_role,omitempty" json:"user_role,omitempty"`
+	ExamID         primitive.ObjectID `bson:"exam_id,omitempty" json:"exam_id,omitempty"`
+	Purpose        string             `bson:"purpose" json:"purpose"`
+	Model          string             `bson:"model" json:"model"`
+	PromptTokens   int64              `bson:"prompt_tokens" json:"prompt_tokens"`
+	ResponseTokens int64              `bson:"response_tokens" json:"response_tokens"`
+	TotalTokens    int64              `bson:"total_tokens" json:"total_tokens"`
+	LatencyMS      int64              `bson:"latency_ms" json:"latency_ms"`
+	// Attempts counts the requests, retries included.
+	Attempts     int                `bson:"attempts" json:"attempts"`
+	FinishReason string             `bson:"finish_reason,omitempty" json:"finish_reason,omitempty"`
+	Status       string             `bson:"status" json:"status"`
+	Error        string             `bson:"error,omitempty" json:"error,omitempty"`
+	CreatedAt    primitive.DateTime `bson:"created_at" json:"created_at"`
+}
@@ -1,6 +1,16 @@
 package models
 
-import "go.mongodb.org/mongo-driver/bson/primitive"
+import (
+	"math"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	RoleStudent = "student"
+	RoleTeacher = "teacher"
+	RoleAdmin   = "admin"
+)
 
 type User struct {
 	ID          primitive.ObjectID `bson:"_id,omitempty" json:"id"`
@@ -8,7 +18,7 @@ type User struct {
 	Email       string             `bson:"email" json:"email"`
 	GoogleID    string             `bson:"google_id,omitempty" json:"google_id,omitempty"`
 	Image       string             `bson:"image,omitempty" json:"image,omitempty"` // Google profile image URL
-	Role        string             `bson:"role" json:"role" validate:"oneof=teacher student"`
+	Role        string             `bson:"role" json:"role" validate:"oneof=teacher student admin"`
 	ContainerID primitive.ObjectID `bson:"contianer_id" json:"container_id"`
 	CreatedAt   primitive.DateTime `json:"created_at" bson:"created_at"`
 	UpdatedAt   primitive.DateTime `json:"updated_at" bson:"updated_at"`
@@ -31,6 +41,15 @@ type TeacherContainer struct {
 	} `bson:"exams" json:"exams"`
 }
 
+const (
+	ExamExternal = "external"
+	// ExamInternal exams have no timer, the teacher opens and closes them.
+	ExamInternal = "internal"
+	// ExamViva exams have no answer sheets, the teacher records oral marks
+	// straight into the evaluation.
+	ExamViva = "viva"
+)
+
 type Exam struct {
 	ID             primitive.ObjectID   `bson:"_id,omitempty" json:"id"`
 	ExamName       string               `bson:"exam_name" json:"exam_name"`
@@ -40,12 +59,61 @@ type Exam struct {
 	Questions      []Question           `bson:"questions" json:"questions"`
 	Sets           []primitive.ObjectID `bson:"sets" json:"sets"`
 	AnswerSheets   []primitive.ObjectID `bson:"answer_sheets" json:"answer_sheets"`
+	// SetCounter counts the sets handed out since the sets were generated,
+	// it drives the round-robin distribution.
+	SetCounter int64 `bson:"set_counter" json:"set_counter"`
+	// Open is set while the teacher lets students work on an internal exam.
+	Open bool `bson:"open" json:"open"`
+	// Paused is set while the teacher holds the whole exam, nobody can start.
+	Paused bool `bson:"paused" json:"paused"`
+	// AICalibration corrects the AI's suggested scores for this exam.
+	AICalibration *AICalibration `bson:"ai_calibration,omitempty" json:"ai_calibration,omitempty"`
 }
 
+// AICalibration maps the AI's score to score*Scale + Offset. Teachers set it
+// after comparing the AI's past suggestions with their own marks.
+type AICalibration struct {
+	Scale     float64            `bson:"scale" json:"scale"`
+	Offset    float64            `bson:"offset" json:"offset"`
+	UpdatedBy string             `bson:"updated_by" json:"updated_by,omitempty"`
+	UpdatedAt primitive.DateTime `bson:"updated_at" json:"updated_at,omitempty"`
+}
+
+// QuestionTypes are the kinds of answer a question can ask for, each gets its
+// own editor on the answer sheet.
+var QuestionTypes = []string{"html", "css", "js", "jquery", "php", "nodejs", "mongodb", "python", "java", "text", "none"}
+
+var QuestionLevels = []string{"easy", "medium", "hard"}
+
 type Question struct {
 	Question string   `bson:"question" json:"question"`
 	Types    []string `bson:"types" json:"types" validate:"dive,oneof=html css js jquery php nodejs mongodb python java text none"`
 	Level    string   `bson:"level" json:"level" validate:"oneof=easy medium hard"`
+	// ReferenceAnswer and GradingNotes are the answer key of the question.
+	// They are for the teacher and the AI grader, students never get them.
+	ReferenceAnswer string `bson:"reference_answer,omitempty" json:"reference_answer,omitempty"`
+	GradingNotes    string `bson:"grading_notes,omitempty" json:"grading_notes,omitempty"`
+}
+
+// withoutAnswerKeys returns a copy of questions that is safe to show to
+// students.
+func withoutAnswerKeys(questions []Question) []Question {
+	if questions == nil {
+		return nil
+	}
+	cleaned := make([]Question, len(questions))
+	for i, q := range questions {
+		q.ReferenceAnswer = ""
+		q.GradingNotes = ""
+		cleaned[i] = q
+	}
+	return cleaned
+}
+
+// WithoutAnswerKeys returns the exam as students may see it.
+func (e Exam) WithoutAnswerKeys() Exam {
+	e.Questions = withoutAnswerKeys(e.Questions)
+	return e
 }
 
 type QuestionPaper struct {
@@ -54,6 +122,12 @@ type QuestionPaper struct {
 	Questions []Question         `bson:"questions" json:"questions"`
 }
 
+// WithoutAnswerKeys returns the paper as students may see it.
+func (p QuestionPaper) WithoutAnswerKeys() QuestionPaper {
+	p.Questions = withoutAnswerKeys(p.Questions)
+	return p
+}
+
 type AnswerSheet struct {
 	ID          primitive.ObjectID `bson:"_id,omitempty" json:"id"`
 	StudentName string             `bson:"student_name" json:"student_name"`
@@ -63,17 +137,52 @@ type AnswerSheet struct {
 	ExamType    string             `bson:"exam_type" json:"exam_type" validate:"oneof=external internal viva"`
 	QPaperID    primitive.ObjectID `bson:"qpaper_id" json:"qpaper_id"`
 	Set         int                `bson:"set" json:"set"`
-	Data        []struct {
-		Question string `bson:"question" json:"question"`
-		Answers  []struct {
-			Type string `bson:"type" json:"type"`
-			Ans  string `bson:"ans" json:"ans"`
-		} `bson:"answers" json:"answers"`
-	} `bson:"data" json:"data"`
-	Status    string   `bson:"status" json:"status" validate:"oneof=didnotstart started ended internal"`
-	Submitted bool     `bson:"submitted" json:"submitted" default:"false"`
-	AIScore   *float64 `bson:"ai_score,omitempty" json:"ai_score,omitempty"`
-	Duration  int64    `bson:"duration" json:"duration"` // Duration field added
+	Data        []AnswerData       `bson:"data" json:"data"`
+	Status      SheetStatus        `bson:"status" json:"status" validate:"oneof=assigned started paused submitted expired voided evaluated"`
+	Submitted   bool               `bson:"submitted" json:"submitted" default:"false"`
+	AIScore     *float64           `bson:"ai_score,omitempty" json:"ai_score,omitempty"`
+	Duration    int64              `bson:"duration" json:"duration"` // Duration field added
+	Seat        *SeatAssignment    `bson:"seat,omitempty" json:"seat,omitempty"`
+	Transitions []SheetTransition  `bson:"transitions" json:"transitions"`
+	SheetTiming `bson:",inline"`
+	// Session is the browser that currently holds the sheet, kept alive by
+	// heartbeats.
+	Session *SheetSession `bson:"session,omitempty" json:"session,omitempty"`
+}
+
+// SheetSession identifies the one device a student works on during an exam.
+type SheetSession struct {
+	ID          string             `bson:"id" json:"id"`
+	Fingerprint string             `bson:"fingerprint" json:"fingerprint"`
+	IP          string             `bson:"ip" json:"ip"`
+	UserAgent   string             `bson:"user_agent" json:"user_agent"`
+	StartedAt   primitive.DateTime `bson:"started_at" json:"started_at"`
+	LastSeen    primitive.DateTime `bson:"last_seen" json:"last_seen"`
+}
+
+// Seat is a position in a lab, rows and seats are numbered from 1.
+type Seat struct {
+	Row    int `bson:"row" json:"row" binding:"min=1"`
+	Number int `bson:"number" json:"number" binding:"min=1"`
+}
+
+// SeatingMap lists the seats of one lab room for one exam slot.
+type SeatingMap struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	ExamID    primitive.ObjectID `bson:"exam_id" json:"exam_id"`
+	Slot      string             `bson:"slot" json:"slot"`
+	Room      string             `bson:"room" json:"room"`
+	Seats     []Seat             `bson:"seats" json:"seats"`
+	UpdatedBy string             `bson:"updated_by" json:"updated_by,omitempty"`
+	UpdatedAt primitive.DateTime `bson:"updated_at" json:"updated_at,omitempty"`
+}
+
+// SeatAssignment is where a student sat for an attempt.
+type SeatAssignment struct {
+	Slot   string `bson:"slot" json:"slot"`
+	Room   string `bson:"room" json:"room"`
+	Row    int    `bson:"row" json:"row"`
+	Number int    `bson:"number" json:"number"`
 }
 
 type Evaluation struct {
@@ -82,19 +191,136 @@ type Evaluation struct {
 	StudentName   string             `bson:"student_name" json:"student_name"`
 	Email         string             `bson:"email" json:"email"`
 	ExamName      string             `bson:"exam_name" json:"exam_name"`
-	ExamID        primitive.ObjectID `bson:"exam_id" json"exam_id"`
+	ExamID        primitive.ObjectID `bson:"exam_id" json:"exam_id"`
 	QPaperID      primitive.ObjectID `bson:"qpaper_id" json:"qpaper_id"`
 	Set           int                `bson:"set" json:"set"`
 	AIScore       *float64           `bson:"ai_score,omitempty" json:"ai_score,omitempty"`
-	Data          []struct {
-		Question string `bson:"question" json:"question"`
-		Answers  []struct {
-			Type string `bson:"type" json:"type"`
-			Ans  string `bson:"ans" json:"ans"`
-		} `bson:"answers" json:"answers"`
-		AIEvaluation string `bson:"ai_evaluation" json:"ai_evaluation"`
-		Marks        int    `bson:"marks" json:"marks"`
-	} `bson:"data" json:"data"`
-	TotalMarks int  `bson:"total_marks" json:"total_marks"`
-	Evaluated  bool `bson:"evaluated" json:"evaluated"`
+	Data          []EvaluationData   `bson:"data" json:"data"`
+	TotalMarks    int                `bson:"total_marks" json:"total_marks"`
+	Evaluated     bool               `bson:"evaluated" json:"evaluated"`
+	Version       int                `bson:"version" json:"version"` // latest finalized version, 0 until first finalized
+	ExamType      string             `bson:"exam_type,omitempty" json:"exam_type,omitempty"`
+	Examiner      string             `bson:"examiner,omitempty" json:"examiner,omitempty"` // teacher who ran the viva
+}
+
+type Answer struct {
+	Type string `bson:"type" json:"type"`
+	Ans  string `bson:"ans" json:"ans"`
+}
+
+type AnswerData struct {
+	Question string   `bson:"question" json:"question"`
+	Answers  []Answer `bson:"answers" json:"answers"`
+}
+
+// MaxQuestionMarks is what a question is marked out of.
+const MaxQuestionMarks = 100
+
+type EvaluationData struct {
+	Question     string     `bson:"question" json:"question"`
+	Answers      []Answer   `bson:"answers" json:"answers"`
+	AIEvaluation string     `bson:"ai_evaluation" json:"ai_evaluation"`
+	AIScore      *int       `bson:"ai_score,omitempty" json:"ai_score,omitempty"` // suggested percentage, marks stay the teacher's call
+	AIGrading    *AIGrading `bson:"ai_grading,omitempty" json:"ai_grading,omitempty"`
+	Marks        int        `bson:"marks" json:"marks"`
+	Remarks      string     `bson:"remarks,omitempty" json:"remarks,omitempty"`
+}
+
+// AIGrading is the structured suggestion the AI made for one answer.
+// AIEvaluation and AIScore repeat its feedback and score for older clients.
+type AIGrading struct {
+	Score int `bson:"score" json:"score"` // percentage
+	// RawScore is the AI's own score when Score was calibrated.
+	RawScore   *int          `bson:"raw_score,omitempty" json:"raw_score,omitempty"`
+	Criteria   []AICriterion `bson:"criteria" json:"criteria"`
+	Feedback   string        `bson:"feedback" json:"feedback"`
+	Confidence float64       `bson:"confidence" json:"confidence"` // 0 to 1, as judged by the model
+	Model      string        `bson:"model" json:"model"`
+	// Template and TemplateVersion name the prompt template it was asked
+	// with.
+	Template        string `bson:"template,omitempty" json:"template,omitempty"`
+	TemplateVersion int    `bson:"template_version,omitempty" json:"template_version,omitempty"`
+	// Cached is set when the grading was reused for an identical answer.
+	Cached   bool               `bson:"cached,omitempty" json:"cached,omitempty"`
+	GradedAt primitive.DateTime `bson:"graded_at" json:"graded_at"`
+}
+
+// Calibrate applies the exam's calibration to the score, a nil calibration
+// leaves it alone.
+func (g *AIGrading) Calibrate(calibration *AICalibration) {
+	if calibration == nil {
+		return
+	}
+	raw := g.Score
+	g.RawScore = &raw
+	g.Score = min(100, max(0, int(math.Round(float64(raw)*calibration.Scale+calibration.Offset))))
+}
+
+// UncalibratedScore is the AI's own score, before any calibration.
+func (g *AIGrading) UncalibratedScore() int {
+	if g.RawScore != nil {
+		return *g.RawScore
+	}
+	return g.Score
+}
+
+type AICriterion struct {
+	Name      string  `bson:"name" json:"name"`
+	Points    float64 `bson:"points" json:"points"`
+	MaxPoints float64 `bson:"max_points" json:"max_points"`
+	Comment   string  `bson:"comment,omitempty" json:"comment,omitempty"`
+}
+
+// EvaluationVersion is an immutable snapshot of an evaluation, taken each
+// time marks are finalized or revised after a re-evaluation.
+type EvaluationVersion struct {
+	ID             primitive.ObjectID  `bson:"_id,omitempty" json:"id"`
+	EvaluationID   primitive.ObjectID  `bson:"evaluation_id" json:"evaluation_id"`
+	ExamID         primitive.ObjectID  `bson:"exam_id" json:"exam_id"`
+	Email          string              `bson:"email" json:"email"`
+	Version        int                 `bson:"version" json:"version"`
+	Data           interface{}         `bson:"data" json:"data"`
+	TotalMarks     int                 `bson:"total_marks" json:"total_marks"`
+	Reason         string              `bson:"reason" json:"reason"`
+	ReEvaluationID *primitive.ObjectID `bson:"re_evaluation_id,omitempty" json:"re_evaluation_id,omitempty"`
+	CreatedBy      string              `bson:"created_by" json:"created_by"`
+	CreatedAt      primitive.DateTime  `bson:"created_at" json:"created_at"`
+}
+
+type ReEvaluationRequest struct {
+	ID            primitive.ObjectID  `bson:"_id,omitempty" json:"id"`
+	EvaluationID  primitive.ObjectID  `bson:"evaluation_id" json:"evaluation_id"`
+	ExamID        primitive.ObjectID  `bson:"exam_id" json:"exam_id"`
+	ExamName      string              `bson:"exam_name" json:"exam_name"`
+	StudentName   string              `bson:"student_name" json:"student_name"`
+	Email         string              `bson:"email" json:"email"`
+	QuestionIndex int                 `bson:"question_index" json:"question_index"`
+	Question      string              `bson:"question" json:"question"`
+	Reason        string              `bson:"reason" json:"reason"`
+	Status        string              `bson:"status" json:"status" validate:"oneof=pending approved rejected"`
+	OriginalMarks int                 `bson:"original_marks" json:"original_marks"`
+	RevisedMarks  *int                `bson:"revised_marks,omitempty" json:"revised_marks,omitempty"`
+	Response      string              `bson:"response,omitempty" json:"response,omitempty"`
+	ResolvedBy    string              `bson:"resolved_by,omitempty" json:"resolved_by,omitempty"`
+	CreatedAt     primitive.DateTime  `bson:"created_at" json:"created_at"`
+	ResolvedAt    *primitive.DateTime `bson:"resolved_at,omitempty" json:"resolved_at,omitempty"`
+}
+
+type AuditLog struct {
+	ID             primitive.ObjectID     `bson:"_id,omitempty" json:"id"`
+	ActorEmail     string                 `bson:"actor_email" json:"actor_email"`
+	ActorRole      string                 `bson:"actor_role" json:"actor_role"`
+	ImpersonatedBy string                 `bson:"impersonated_by,omitempty" json:"impersonated_by,omitempty"`
+	Action         string                 `bson:"action" json:"action"`
+	TargetID       primitive.ObjectID     `bson:"target_id" json:"target_id"`
+	ExamID         primitive.ObjectID     `bson:"exam_id,omitempty" json:"exam_id,omitempty"`
+	StudentEmail   string                 `bson:"student_email,omitempty" json:"student_email,omitempty"`
+	Changes        map[string]AuditChange `bson:"changes,omitempty" json:"changes,omitempty"`
+	Details        map[string]interface{} `bson:"details,omitempty" json:"details,omitempty"`
+	CreatedAt      primitive.DateTime     `bson:"created_at" json:"created_at"`
+}
+
+type AuditChange struct {
+	Before interface{} `bson:"before" json:"before"`
+	After  interface{} `bson:"after" json:"after"`
 }
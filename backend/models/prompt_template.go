@@ -0,0 +1,19 @@
+package models
+
+import "go.mongodb.org/mongo-driver/bson/primitive"
+
+// PromptTemplate is one version of a named grading prompt, a Go
+// text/template. ExamType and AnswerType narrow where it is used, empty
+// matches any. Versions never change, saving a template adds the next one.
+type PromptTemplate struct {
+	ID         primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Name       string             `bson:"name" json:"name"`
+	Version    int                `bson:"version" json:"version"`
+	ExamType   string             `bson:"exam_type,omitempty" json:"exam_type,omitempty"`
+	AnswerType string             `bson:"answer_type,omitempty" json:"answer_type,omitempty"`
+	Body       string             `bson:"body" json:"body"`
+	// Retired takes the template out of use from this version on.
+	Retired   bool               `bson:"retired,omitempty" json:"retired,omitempty"`
+	CreatedBy string             `bson:"created_by" json:"created_by"`
+	CreatedAt primitive.DateTime `bson:"created_at" json:"created_at"`
+}
@@ -0,0 +1,20 @@
+package models
+
+import "go.mongodb.org/mongo-driver/bson/primitive"
+
+const (
+	QuestionSourceManual = "manual"
+	QuestionSourceAI     = "ai"
+)
+
+// BankQuestion is a question a teacher keeps to reuse across exams. The bank
+// belongs to the teacher's container.
+type BankQuestion struct {
+	ID          primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	ContainerID primitive.ObjectID `bson:"container_id" json:"-"`
+	Question    `bson:",inline"`
+	Topic       string             `bson:"topic,omitempty" json:"topic,omitempty"`
+	Source      string             `bson:"source" json:"source"`
+	CreatedBy   string             `bson:"created_by" json:"created_by"`
+	CreatedAt   primitive.DateTime `bson:"created_at" json:"created_at"`
+}
@@ -0,0 +1,328 @@
+// Package queue runs durable background jobs. Jobs are stored through
+// store.JobStore, so work that was queued or running when the server stopped
+// is picked up again after a restart. Workers lease one job at a time and
+// keep extending the lease while it runs; failed jobs are retried with
+// backoff until their attempts are used up and then dead-lettered.
+package queue
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/Maheshkarri4444/Examify/models"
+	"github.com/Maheshkarri4444/Examify/store"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	defaultAttempts = 5
+	leaseFor        = time.Minute
+	pollEvery       = 2 * time.Second
+	retryBase       = 30 * time.Second
+	retryMax        = 30 * time.Minute
+)
+
+var (
+	errCancelRequested = errors.New("job cancelled")
+	errLeaseLost       = errors.New("job lease lost")
+)
+
+// Func does the work of one job kind. It must stop when ctx is done and
+// should be safe to run again, a job is retried from the start.
+type Func func(ctx context.Context, task *Task) error
+
+type permanentError struct{ err error }
+
+func (e permanentError) Error() string { return e.err.Error() }
+func (e permanentError) Unwrap() error { return e.err }
+
+// Permanent marks an error retrying won't fix, the job is dead-lettered
+// right away.
+func Permanent(err error) error {
+	return permanentError{err}
+}
+
+type kind struct {
+	fn          Func
+	maxAttempts int
+}
+
+type Queue struct {
+	jobs    store.JobStore
+	kinds   map[string]kind
+	owner   string
+	workers int
+	wake    chan struct{}
+	// lease and poll are leaseFor and pollEvery, tests shorten them.
+	lease time.Duration
+	poll  time.Duration
+
+	ctx  context.Context
+	stop context.CancelFunc
+	wg   sync.WaitGroup
+}
+
+func New(jobs store.JobStore, workers int) *Queue {
+	if workers < 1 {
+		workers = 1
+	}
+	host, _ := os.Hostname()
+	ctx, stop := context.WithCancel(context.Background())
+	return &Queue{
+		jobs:    jobs,
+		kinds:   map[string]kind{},
+		owner:   fmt.Sprintf("%s:%d:%s", host, os.Getpid(), primitive.NewObjectID().Hex()),
+		workers: workers,
+		wake:    make(chan struct{}, 1),
+		lease:   leaseFor,
+		poll:    pollEvery,
+		ctx:     ctx,
+		stop:    stop,
+	}
+}
+
+// Register sets the function that runs jobs of a kind. It must be called
+// before Start. maxAttempts of 0 uses the default.
+func (q *Queue) Register(name string, maxAttempts int, fn Func) {
+	if maxAttempts <= 0 {
+		maxAttempts = defaultAttempts
+	}
+	q.kinds[name] = kind{fn: fn, maxAttempts: maxAttempts}
+}
+
+// Enqueue stores a new job of job.Kind with payload, if any, encoded as
+// BSON. Only Kind, ExamID, CreatedBy, IdempotencyKey and UniqueKey are taken
+// from job. When the keys match an existing job, that job is returned with
+// false.
+func (q *Queue) Enqueue(ctx context.Context, job models.Job, payload interface{}) (*models.Job, bool, error) {
+	k, ok := q.kinds[job.Kind]
+	if !ok {
+		return nil, false, fmt.Errorf("queue: unknown job kind %q", job.Kind)
+	}
+	var raw bson.Raw
+	if payload != nil {
+		var err error
+		if raw, err = bson.Marshal(payload); err != nil {
+			return nil, false, fmt.Errorf("queue: encoding %s payload: %w", job.Kind, err)
+		}
+	}
+
+	t := primitive.NewDateTimeFromTime(time.Now())
+	saved, created, err := q.jobs.Enqueue(ctx, &models.Job{
+		Kind:           job.Kind,
+		Status:         models.JobQueued,
+		Payload:        raw,
+		IdempotencyKey: job.IdempotencyKey,
+		UniqueKey:      job.UniqueKey,
+		ExamID:         job.ExamID,
+		CreatedBy:      job.CreatedBy,
+		MaxAttempts:    k.maxAttempts,
+		RunAt:          t,
+		CreatedAt:      t,
+		UpdatedAt:      t,
+	})
+	if created {
+		select {
+		case q.wake <- struct{}{}:
+		default:
+		}
+	}
+	return saved, created, err
+}
+
+// Start runs the workers until Shutdown.
+func (q *Queue) Start() {
+	kinds := make([]string, 0, len(q.kinds))
+	for name := range q.kinds {
+		kinds = append(kinds, name)
+	}
+	for i := 0; i < q.workers; i++ {
+		q.wg.Add(1)
+		go q.work(kinds)
+	}
+}
+
+// Shutdown stops the workers and waits for them. Jobs that were running go
+// back to the queue without using up an attempt.
+func (q *Queue) Shutdown() {
+	q.stop()
+	q.wg.Wait()
+}
+
+func (q *Queue) work(kinds []string) {
+	defer q.wg.Done()
+	for q.ctx.Err() == nil {
+		job, err := q.jobs.Lease(q.ctx, q.owner, kinds, time.Now().Add(q.lease))
+		if err == nil {
+			q.run(job)
+			continue
+		}
+		if !errors.Is(err, store.ErrNotFound) && q.ctx.Err() == nil {
+			fmt.Println("queue lease error: ", err)
+		}
+		select {
+		case <-q.ctx.Done():
+		case <-q.wake:
+		case <-time.After(q.poll):
+		}
+	}
+}
+
+func (q *Queue) run(job *models.Job) {
+	task := &Task{Job: job, progress: job.Progress}
+	ctx, cancel := context.WithCancelCause(q.ctx)
+	defer cancel(nil)
+
+	var err error
+	switch {
+	case job.CancelRequested:
+		cancel(errCancelRequested)
+		err = errCancelRequested
+	case job.Attempts > job.MaxAttempts:
+		// Its workers kept dying, most likely the job itself is the problem
+		err = Permanent(errors.New("lease expired on the last attempt"))
+	default:
+		done := make(chan struct{})
+		go q.heartbeat(ctx, cancel, task, done)
+		err = q.call(ctx, task)
+		close(done)
+	}
+
+	outcome := store.JobOutcome{Progress: task.Progress(), Result: task.result}
+	var permanent permanentError
+	switch cause := context.Cause(ctx); {
+	case errors.Is(cause, errLeaseLost):
+		return
+	case err == nil:
+		// Finished before the cancel or the shutdown got to it, its result
+		// stands
+		outcome.Status = models.JobSucceeded
+	case errors.Is(cause, errCancelRequested):
+		outcome.Status = models.JobCancelled
+	case q.ctx.Err() != nil:
+		outcome.Status = models.JobQueued
+		outcome.RunAt = time.Now()
+		outcome.Refund = true
+	case errors.As(err, &permanent) || job.Attempts >= job.MaxAttempts:
+		outcome.Status = models.JobDead
+		outcome.Error = err.Error()
+	default:
+		outcome.Status = models.JobQueued
+		outcome.RunAt = time.Now().Add(backoff(job.Attempts))
+		outcome.Error = err.Error()
+	}
+
+	// The queue context may be cancelled already, the outcome still has to
+	// be saved.
+	saveCtx, cancelSave := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancelSave()
+	if err := q.jobs.Finish(saveCtx, job.ID, q.owner, outcome); err != nil {
+		fmt.Println("queue finish error: ", job.Kind, job.ID.Hex(), err)
+	}
+}
+
+// call runs the job's function, turning a panic into an error.
+func (q *Queue) call(ctx context.Context, task *Task) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	k, ok := q.kinds[task.Job.Kind]
+	if !ok {
+		return Permanent(fmt.Errorf("unknown job kind %q", task.Job.Kind))
+	}
+	return k.fn(ctx, task)
+}
+
+// heartbeat extends the lease and saves progress until done is closed. It
+// cancels the job when someone asked for it or the lease was lost.
+func (q *Queue) heartbeat(ctx context.Context, cancel context.CancelCauseFunc, task *Task, done <-chan struct{}) {
+	ticker := time.NewTicker(q.lease / 3)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		hbCtx, cancelHB := context.WithTimeout(ctx, 10*time.Second)
+		job, err := q.jobs.Heartbeat(hbCtx, task.Job.ID, q.owner, time.Now().Add(q.lease), task.Progress())
+		cancelHB()
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			cancel(errLeaseLost)
+		case err != nil:
+			fmt.Println("queue heartbeat error: ", task.Job.Kind, task.Job.ID.Hex(), err)
+		case job.CancelRequested:
+			cancel(errCancelRequested)
+		}
+	}
+}
+
+// backoff doubles from retryBase with each attempt, with jitter so jobs
+// that failed together don't come back together.
+func backoff(attempts int) time.Duration {
+	wait := retryMax
+	if attempts < 16 {
+		wait = min(retryBase<<(attempts-1), retryMax)
+	}
+	return wait/2 + time.Duration(rand.Int63n(int64(wait/2)+1))
+}
+
+// Task is the job a Func is running, with its progress.
+type Task struct {
+	Job *models.Job
+
+	mu       sync.Mutex
+	progress models.JobProgress
+	result   map[string]interface{}
+}
+
+// Decode reads the job's payload into v.
+func (t *Task) Decode(v interface{}) error {
+	if err := bson.Unmarshal(t.Job.Payload, v); err != nil {
+		return Permanent(fmt.Errorf("decoding %s payload: %w", t.Job.Kind, err))
+	}
+	return nil
+}
+
+func (t *Task) Progress() models.JobProgress {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.progress
+}
+
+// SetTotal sets the number of steps the job has to do and restarts counting,
+// a retried job starts over.
+func (t *Task) SetTotal(n int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.progress = models.JobProgress{Total: n}
+}
+
+// StepDone counts a finished step, a non nil err counts it as failed.
+func (t *Task) StepDone(err error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.progress.Done++
+	if err != nil {
+		t.progress.Failed++
+	}
+}
+
+// SetResult is saved with the job when the function returns.
+func (t *Task) SetResult(result map[string]interface{}) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.result = result
+}
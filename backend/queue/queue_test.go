@@ -0,0 +1,355 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Maheshkarri4444/Examify/models"
+	"github.com/Maheshkarri4444/Examify/store"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const testLease = 150 * time.Millisecond
+
+// newTestQueue returns a queue on the in-memory job store with a short
+// lease. It is shut down when the test ends.
+func newTestQueue(t *testing.T, jobs store.JobStore, workers int) *Queue {
+	t.Helper()
+	q := New(jobs, workers)
+	q.lease = testLease
+	q.poll = 10 * time.Millisecond
+	t.Cleanup(q.Shutdown)
+	return q
+}
+
+// waitFor polls the job until done returns true.
+func waitFor(t *testing.T, jobs store.JobStore, id primitive.ObjectID, done func(*models.Job) bool) *models.Job {
+	t.Helper()
+	deadline := time.Now().Add(3 * time.Second)
+	for {
+		job, err := jobs.GetByID(context.Background(), id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if done(job) {
+			return job
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("job is still %s after %d attempts", job.Status, job.Attempts)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestRun(t *testing.T) {
+	tests := []struct {
+		name        string
+		maxAttempts int
+		fn          Func
+		wantStatus  models.JobStatus
+		wantError   string
+		wantRetry   bool
+	}{
+		{
+			name: "succeeds",
+			fn: func(ctx context.Context, task *Task) error {
+				task.SetTotal(2)
+				task.StepDone(nil)
+				task.StepDone(errors.New("one failed"))
+				task.SetResult(map[string]interface{}{"summary": "graded"})
+				return nil
+			},
+			wantStatus: models.JobSucceeded,
+		},
+		{
+			name:       "fails",
+			fn:         func(ctx context.Context, task *Task) error { return errors.New("model overloaded") },
+			wantStatus: models.JobQueued,
+			wantError:  "model overloaded",
+			wantRetry:  true,
+		},
+		{
+			name:       "panics",
+			fn:         func(ctx context.Context, task *Task) error { panic("nil map") },
+			wantStatus: models.JobQueued,
+			wantError:  "panic: nil map",
+			wantRetry:  true,
+		},
+		{
+			name:       "fails for good",
+			fn:         func(ctx context.Context, task *Task) error { return Permanent(errors.New("exam deleted")) },
+			wantStatus: models.JobDead,
+			wantError:  "exam deleted",
+		},
+		{
+			name:        "last attempt fails",
+			maxAttempts: 1,
+			fn:          func(ctx context.Context, task *Task) error { return errors.New("model overloaded") },
+			wantStatus:  models.JobDead,
+			wantError:   "model overloaded",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			jobs := store.NewMemoryStores().Jobs
+			q := newTestQueue(t, jobs, 1)
+			q.Register("grade", tt.maxAttempts, tt.fn)
+			job, _, err := q.Enqueue(context.Background(), models.Job{Kind: "grade"}, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			q.Start()
+
+			got := waitFor(t, jobs, job.ID, func(job *models.Job) bool { return job.Attempts == 1 && job.Status != models.JobRunning })
+			if got.Status != tt.wantStatus || got.LastError != tt.wantError {
+				t.Errorf("status = %s, error = %q, want %s, %q", got.Status, got.LastError, tt.wantStatus, tt.wantError)
+			}
+			if retryAt := got.RunAt.Time(); tt.wantRetry && time.Until(retryAt) < retryBase/4 {
+				t.Errorf("retry at %v, want a backoff of about %v", retryAt, retryBase)
+			}
+			if tt.wantStatus == models.JobSucceeded {
+				if got.Progress != (models.JobProgress{Total: 2, Done: 2, Failed: 1}) || got.Result["summary"] != "graded" {
+					t.Errorf("progress = %+v, result = %v", got.Progress, got.Result)
+				}
+			}
+		})
+	}
+}
+
+func TestHeartbeatKeepsTheLease(t *testing.T) {
+	jobs := store.NewMemoryStores().Jobs
+	q := newTestQueue(t, jobs, 2)
+	var calls atomic.Int32
+	q.Register("grade", 0, func(ctx context.Context, task *Task) error {
+		calls.Add(1)
+		// Outlive the lease several times over, the heartbeat must keep the
+		// other worker away
+		select {
+		case <-time.After(4 * testLease):
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	})
+	job, _, err := q.Enqueue(context.Background(), models.Job{Kind: "grade"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	q.Start()
+
+	got := waitFor(t, jobs, job.ID, func(job *models.Job) bool { return job.Status.Finished() })
+	if got.Status != models.JobSucceeded || calls.Load() != 1 || got.Attempts != 1 {
+		t.Errorf("status = %s after %d calls and %d attempts, want one successful run", got.Status, calls.Load(), got.Attempts)
+	}
+}
+
+func TestCancelRunningJob(t *testing.T) {
+	tests := []struct {
+		name       string
+		result     error // what the job returns once it is cancelled
+		wantStatus models.JobStatus
+	}{
+		{name: "stops", result: context.Canceled, wantStatus: models.JobCancelled},
+		{name: "finishes anyway", result: nil, wantStatus: models.JobSucceeded},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			jobs := store.NewMemoryStores().Jobs
+			q := newTestQueue(t, jobs, 1)
+			started := make(chan struct{})
+			q.Register("grade", 0, func(ctx context.Context, task *Task) error {
+				close(started)
+				<-ctx.Done()
+				task.SetResult(map[string]interface{}{"summary": "graded"})
+				return tt.result
+			})
+			job, _, err := q.Enqueue(context.Background(), models.Job{Kind: "grade"}, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			q.Start()
+
+			<-started
+			if _, err := jobs.Cancel(context.Background(), job.ID); err != nil {
+				t.Fatal(err)
+			}
+			got := waitFor(t, jobs, job.ID, func(job *models.Job) bool { return job.Status.Finished() })
+			if got.Status != tt.wantStatus {
+				t.Errorf("status = %s, want %s", got.Status, tt.wantStatus)
+			}
+			if tt.wantStatus == models.JobSucceeded && got.Result["summary"] != "graded" {
+				t.Errorf("result = %v, want it kept", got.Result)
+			}
+		})
+	}
+}
+
+func TestCancelBeforeRunning(t *testing.T) {
+	jobs := store.NewMemoryStores().Jobs
+	q := newTestQueue(t, jobs, 1)
+	var calls atomic.Int32
+	q.Register("grade", 0, func(ctx context.Context, task *Task) error {
+		calls.Add(1)
+		return nil
+	})
+	job, _, err := q.Enqueue(context.Background(), models.Job{Kind: "grade"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Leased and asked to stop by the time a worker gets to it
+	if _, err := jobs.Lease(context.Background(), "crashed", []string{"grade"}, time.Now().Add(-time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := jobs.Cancel(context.Background(), job.ID); err != nil {
+		t.Fatal(err)
+	}
+	q.Start()
+
+	got := waitFor(t, jobs, job.ID, func(job *models.Job) bool { return job.Status.Finished() })
+	if got.Status != models.JobCancelled || calls.Load() != 0 {
+		t.Errorf("status = %s after %d calls, want %s without running", got.Status, calls.Load(), models.JobCancelled)
+	}
+}
+
+// stolenLease answers every heartbeat as if another worker took the job.
+type stolenLease struct{ store.JobStore }
+
+func (s stolenLease) Heartbeat(ctx context.Context, id primitive.ObjectID, owner string, until time.Time, progress models.JobProgress) (*models.Job, error) {
+	return nil, store.ErrNotFound
+}
+
+func TestLostLeaseStopsTheJob(t *testing.T) {
+	jobs := store.NewMemoryStores().Jobs
+	q := newTestQueue(t, stolenLease{jobs}, 1)
+	stopped := make(chan error, 1)
+	q.Register("grade", 0, func(ctx context.Context, task *Task) error {
+		<-ctx.Done()
+		select {
+		case stopped <- context.Cause(ctx):
+		default:
+		}
+		return ctx.Err()
+	})
+	job, _, err := q.Enqueue(context.Background(), models.Job{Kind: "grade"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	q.Start()
+
+	select {
+	case cause := <-stopped:
+		if !errors.Is(cause, errLeaseLost) {
+			t.Errorf("cause = %v, want %v", cause, errLeaseLost)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("the job kept running without its lease")
+	}
+	// The new owner finishes the job, this worker must not touch it
+	time.Sleep(testLease / 2)
+	if got, _ := jobs.GetByID(context.Background(), job.ID); got.Status != models.JobRunning {
+		t.Errorf("status = %s, want it left %s", got.Status, models.JobRunning)
+	}
+}
+
+func TestExpiredLease(t *testing.T) {
+	tests := []struct {
+		name        string
+		maxAttempts int
+		wantStatus  models.JobStatus
+		wantCalls   int32
+	}{
+		{name: "retried", maxAttempts: 3, wantStatus: models.JobSucceeded, wantCalls: 1},
+		{name: "dead-lettered after the last attempt", maxAttempts: 1, wantStatus: models.JobDead},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			jobs := store.NewMemoryStores().Jobs
+			q := newTestQueue(t, jobs, 1)
+			var calls atomic.Int32
+			q.Register("grade", tt.maxAttempts, func(ctx context.Context, task *Task) error {
+				calls.Add(1)
+				return nil
+			})
+			job, _, err := q.Enqueue(context.Background(), models.Job{Kind: "grade"}, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			// A worker that died while running it
+			if _, err := jobs.Lease(context.Background(), "crashed", []string{"grade"}, time.Now().Add(-time.Second)); err != nil {
+				t.Fatal(err)
+			}
+			q.Start()
+
+			got := waitFor(t, jobs, job.ID, func(job *models.Job) bool { return job.Status.Finished() })
+			if got.Status != tt.wantStatus || calls.Load() != tt.wantCalls || got.Attempts != 2 {
+				t.Errorf("status = %s after %d calls and %d attempts, want %s after %d calls", got.Status, calls.Load(), got.Attempts, tt.wantStatus, tt.wantCalls)
+			}
+			if tt.wantStatus == models.JobDead && !strings.Contains(got.LastError, "lease expired") {
+				t.Errorf("error = %q, want the lease to be blamed", got.LastError)
+			}
+		})
+	}
+}
+
+func TestShutdown(t *testing.T) {
+	tests := []struct {
+		name         string
+		result       error // what the job returns once the queue is stopping
+		wantStatus   models.JobStatus
+		wantAttempts int
+	}{
+		{name: "interrupted job goes back", result: context.Canceled, wantStatus: models.JobQueued, wantAttempts: 0},
+		{name: "finished job stays done", result: nil, wantStatus: models.JobSucceeded, wantAttempts: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			jobs := store.NewMemoryStores().Jobs
+			q := newTestQueue(t, jobs, 1)
+			started := make(chan struct{})
+			q.Register("grade", 0, func(ctx context.Context, task *Task) error {
+				close(started)
+				<-ctx.Done()
+				return tt.result
+			})
+			job, _, err := q.Enqueue(context.Background(), models.Job{Kind: "grade"}, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			q.Start()
+
+			<-started
+			q.Shutdown()
+			got, err := jobs.GetByID(context.Background(), job.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.Status != tt.wantStatus || got.Attempts != tt.wantAttempts {
+				t.Errorf("status = %s with %d attempts, want %s with %d", got.Status, got.Attempts, tt.wantStatus, tt.wantAttempts)
+			}
+		})
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	tests := []struct {
+		attempts int
+		max      time.Duration
+	}{
+		{1, retryBase},
+		{2, 2 * retryBase},
+		{3, 4 * retryBase},
+		{10, retryMax},
+		{40, retryMax},
+	}
+	for _, tt := range tests {
+		for i := 0; i < 20; i++ {
+			if got := backoff(tt.attempts); got < tt.max/2 || got > tt.max {
+				t.Errorf("backoff(%d) = %v, want between %v and %v", tt.attempts, got, tt.max/2, tt.max)
+			}
+		}
+	}
+}
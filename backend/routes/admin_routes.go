@@ -0,0 +1,29 @@
+package routes
+
+import (
+	"github.com/Maheshkarri4444/Examify/controllers"
+	"github.com/Maheshkarri4444/Examify/middleware"
+	"github.com/gin-gonic/gin"
+)
+
+func AdminRoutes(r *gin.Engine, h *controllers.Handler, auth *middleware.Auth) {
+	admin := r.Group("/admin", auth.AdminMiddleware())
+	{
+		admin.GET("/users", h.ListUsers)
+		admin.PUT("/users/:id/role", h.UpdateUserRole)
+		admin.POST("/users/:id/impersonate", h.ImpersonateUser)
+
+		admin.PUT("/exams/:id/reassign", h.ReassignExam)
+		admin.POST("/answer-sheets/:id/force-close", h.ForceCloseAnswerSheet)
+		admin.POST("/answer-sheets/:id/reopen", h.ReopenAnswerSheet)
+
+		admin.GET("/stats", h.GetSystemStats)
+
+		admin.GET("/jobs", h.ListJobs)
+		admin.POST("/jobs/:id/requeue", h.RequeueJob)
+
+		admin.GET("/ai-usage", h.GetAIUsageReport)
+		admin.POST("/prompt-templates", h.CreatePromptTemplate)
+	}
+
+}
@@ -2,13 +2,15 @@ package routes
 
 import (
 	"github.com/Maheshkarri4444/Examify/controllers"
+	"github.com/Maheshkarri4444/Examify/middleware"
 	"github.com/gin-gonic/gin"
 )
 
-func AiRoutes(r *gin.Engine) {
-	auth := r.Group("/ai")
+func AiRoutes(r *gin.Engine, h *controllers.Handler, auth *middleware.Auth) {
+	// Students sitting an exam must not have a model at hand
+	ai := r.Group("/ai", auth.TeacherMiddleware())
 	{
-		auth.POST("/generate", controllers.GetChatResponse)
+		ai.POST("/generate", h.GetChatResponse)
 	}
 
 }
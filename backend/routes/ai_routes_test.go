@@ -0,0 +1,63 @@
+package routes
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Maheshkarri4444/Examify/config"
+	"github.com/Maheshkarri4444/Examify/controllers"
+	"github.com/Maheshkarri4444/Examify/events"
+	"github.com/Maheshkarri4444/Examify/middleware"
+	"github.com/Maheshkarri4444/Examify/models"
+	"github.com/Maheshkarri4444/Examify/store"
+	"github.com/dgrijalva/jwt-go"
+	"github.com/gin-gonic/gin"
+)
+
+func TestAIGenerateIsForTeachers(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	const secret = "secret"
+	stores := store.NewMemoryStores()
+	h := controllers.NewHandler(&config.Config{JobWorkers: 1, JWTSecret: secret}, stores, events.NewBus())
+	t.Cleanup(h.Shutdown)
+	r := gin.New()
+	AiRoutes(r, h, middleware.NewAuth(stores.Users, secret))
+
+	tests := []struct {
+		role     string
+		wantCode int
+	}{
+		{role: models.RoleStudent, wantCode: http.StatusForbidden},
+		// Let through, the empty prompt is refused by the handler
+		{role: models.RoleTeacher, wantCode: http.StatusBadRequest},
+		{role: models.RoleAdmin, wantCode: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.role, func(t *testing.T) {
+			email := tt.role + "@example.com"
+			if err := stores.Users.Create(context.Background(), &models.User{Email: email, Role: tt.role}); err != nil {
+				t.Fatal(err)
+			}
+			token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+				"email": email,
+				"exp":   time.Now().Add(time.Hour).Unix(),
+			}).SignedString([]byte(secret))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			req := httptest.NewRequest(http.MethodPost, "/ai/generate", strings.NewReader(`{}`))
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("Authorization", token)
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			if w.Code != tt.wantCode {
+				t.Errorf("code = %d, want %d: %s", w.Code, tt.wantCode, w.Body)
+			}
+		})
+	}
+}
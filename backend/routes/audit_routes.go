@@ -0,0 +1,15 @@
+package routes
+
+import (
+	"github.com/Maheshkarri4444/Examify/controllers"
+	"github.com/Maheshkarri4444/Examify/middleware"
+	"github.com/gin-gonic/gin"
+)
+
+func AuditRoutes(r *gin.Engine, h *controllers.Handler, auth *middleware.Auth) {
+	audit := r.Group("/audit", auth.TeacherMiddleware())
+	{
+		audit.GET("/logs", h.GetAuditLogs)
+	}
+
+}
@@ -5,11 +5,11 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
-func AuthRoutes(r *gin.Engine) {
+func AuthRoutes(r *gin.Engine, h *controllers.Handler) {
 	auth := r.Group("/auth")
 	{
-		auth.GET("/google", controllers.GoogleLogin)
-		auth.GET("/googlecallback", controllers.GoogleCallback)
+		auth.GET("/google", h.GoogleLogin)
+		auth.GET("/googlecallback", h.GoogleCallback)
 	}
 
 }
@@ -6,33 +6,81 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
-func ExamRoutes(r *gin.Engine) {
+func ExamRoutes(r *gin.Engine, h *controllers.Handler, auth *middleware.Auth) {
 	exam := r.Group("/exam")
 	{
-		exam.POST("/create-exam", middleware.TeacherMiddleware(), controllers.CreateExam)
-		exam.PUT("/update-exam", middleware.TeacherMiddleware(), controllers.UpdateExam)
-		exam.POST("/exam/create-sets", controllers.CreateSetsForExam)
-		exam.GET("/qpaper/:id", controllers.GetQuestionPaperByID)
-		exam.GET("/getallexams", controllers.GetAllExams)
-		exam.GET("/getexambyid", controllers.GetExamById)
-
-		exam.GET("/getexamsbycontainer", middleware.TeacherMiddleware(), controllers.GetExamsByTeacherContainer)
-		exam.GET("/getfinishedexamsbycontainer", middleware.TeacherMiddleware(), controllers.GetFinishedExamsByTeacherContainerID)
-
-		exam.GET("/getexamsbydate", middleware.StudentMiddleware(), controllers.GetAvailableExamsByDate)
-		exam.POST("/assignsetandcreateanswersheet/:qpaperid", middleware.StudentMiddleware(), controllers.AssignSetAndCreateAnswerSheet)
-
-		exam.POST("/start-exam/:answerSheetId", middleware.StudentMiddleware(), controllers.StartExam)
-		exam.POST("/submit-exam/:answerSheetId", middleware.StudentMiddleware(), controllers.SubmitExam)
-		exam.GET("/answer-sheet/:id", middleware.StudentMiddleware(), controllers.GetAnswerSheetByID)
-
-		exam.GET("/getallanswersheetsbyexamid/:examid", middleware.TeacherMiddleware(), controllers.GetAllAnswerSheetsByExamID)
-		exam.GET("/createevaluation/:answersheetid", middleware.TeacherMiddleware(), controllers.CreateEvaluationByAnswerSheetID)
-		exam.GET("/getevaluation/:evaluationid", middleware.TeacherMiddleware(), controllers.GetEvaluationByID)
-		exam.PUT("/updateevaluation/:evaluationId", middleware.TeacherMiddleware(), controllers.UpdateEvaluation)
-
-		exam.GET("/getevaluatedexams", middleware.TeacherMiddleware(), controllers.GetEvaluatedExamsByTeacherContainer)
-		exam.GET("/getstudentsandmarks/:examid", middleware.TeacherMiddleware(), controllers.GetAllStudentDetailsAndMarksByExamID)
+		exam.POST("/create-exam", auth.TeacherMiddleware(), h.CreateExam)
+		exam.PUT("/update-exam", auth.TeacherMiddleware(), h.UpdateExam)
+		exam.POST("/exam/create-sets", auth.TeacherMiddleware(), h.CreateSetsForExam)
+		exam.GET("/qpaper/:id", auth.SignedInMiddleware(), h.GetQuestionPaperByID)
+		exam.GET("/getallexams", auth.SignedInMiddleware(), h.GetAllExams)
+		exam.GET("/getexambyid", auth.SignedInMiddleware(), h.GetExamById)
+
+		exam.GET("/getexamsbycontainer", auth.TeacherMiddleware(), h.GetExamsByTeacherContainer)
+		exam.GET("/getfinishedexamsbycontainer", auth.TeacherMiddleware(), h.GetFinishedExamsByTeacherContainerID)
+
+		exam.PUT("/seating/:examid", auth.TeacherMiddleware(), h.UpsertSeatingMap)
+		exam.GET("/seating/:examid", auth.TeacherMiddleware(), h.GetSeatingMaps)
+
+		exam.POST("/internal/:examid/open", auth.TeacherMiddleware(), h.OpenInternalExam)
+		exam.POST("/internal/:examid/close", auth.TeacherMiddleware(), h.CloseInternalExam)
+
+		exam.POST("/monitor/:examid/ticket", auth.TeacherMiddleware(), h.IssueMonitorTicket)
+		exam.GET("/monitor/:examid/stream", auth.MonitorTicket(), h.MonitorExam)
+		exam.POST("/control/:examid/pause", auth.TeacherMiddleware(), h.PauseExam)
+		exam.POST("/control/:examid/resume", auth.TeacherMiddleware(), h.ResumeExam)
+		exam.POST("/control/sheet/:id/pause", auth.TeacherMiddleware(), h.PauseAnswerSheet)
+		exam.POST("/control/sheet/:id/resume", auth.TeacherMiddleware(), h.ResumeAnswerSheet)
+		exam.POST("/control/sheet/:id/extend", auth.TeacherMiddleware(), h.ExtendAnswerSheet)
+		exam.POST("/control/sheet/:id/submit", auth.TeacherMiddleware(), h.ForceSubmitAnswerSheet)
+		exam.POST("/control/sheet/:id/void", auth.TeacherMiddleware(), h.VoidAnswerSheet)
+
+		exam.GET("/viva/:examid", auth.TeacherMiddleware(), h.GetVivaEvaluations)
+		exam.POST("/viva/:examid/start", auth.TeacherMiddleware(), h.StartVivaSession)
+		exam.PUT("/viva/evaluation/:evaluationid/question/:index", auth.TeacherMiddleware(), h.RecordVivaMarks)
+
+		exam.GET("/getexamsbydate", auth.StudentMiddleware(), h.GetAvailableExamsByDate)
+		exam.POST("/assignsetandcreateanswersheet/:qpaperid", auth.StudentMiddleware(), h.AssignSetAndCreateAnswerSheet)
+		exam.GET("/seating-options/:examid", auth.StudentMiddleware(), h.GetSeatingOptions)
+
+		exam.POST("/start-exam/:answerSheetId", auth.StudentMiddleware(), h.StartExam)
+		exam.POST("/submit-exam/:answerSheetId", auth.StudentMiddleware(), h.SubmitExam)
+		exam.GET("/answer-sheet/:id", auth.StudentMiddleware(), h.GetAnswerSheetByID)
+		exam.POST("/proctoring/:answerSheetId", auth.StudentMiddleware(), h.RecordProctoringEvent)
+		exam.POST("/heartbeat/:answerSheetId", auth.StudentMiddleware(), h.Heartbeat)
+
+		exam.GET("/getallanswersheetsbyexamid/:examid", auth.TeacherMiddleware(), h.GetAllAnswerSheetsByExamID)
+		exam.GET("/createevaluation/:answersheetid", auth.TeacherMiddleware(), h.CreateEvaluationByAnswerSheetID)
+		exam.GET("/getevaluation/:evaluationid", auth.TeacherMiddleware(), h.GetEvaluationByID)
+		exam.PUT("/updateevaluation/:evaluationId", auth.TeacherMiddleware(), h.UpdateEvaluation)
+		exam.POST("/evaluation/:evaluationid/question/:index/ai-grade", auth.TeacherMiddleware(), h.GradeQuestionWithAI)
+
+		exam.POST("/question-drafts/generate", auth.TeacherMiddleware(), h.GenerateQuestionDrafts)
+		exam.POST("/question-drafts/accept", auth.TeacherMiddleware(), h.AcceptQuestionDrafts)
+		exam.GET("/question-bank", auth.TeacherMiddleware(), h.GetQuestionBank)
+		exam.DELETE("/question-bank/:id", auth.TeacherMiddleware(), h.DeleteBankQuestion)
+
+		exam.POST("/ai-pre-evaluation/:examid", auth.TeacherMiddleware(), h.StartAIPreEvaluation)
+		exam.GET("/ai-pre-evaluation/:examid", auth.TeacherMiddleware(), h.GetAIPreEvaluation)
+		exam.POST("/ai-pre-evaluation/jobs/:jobid/cancel", auth.TeacherMiddleware(), h.CancelAIPreEvaluation)
+
+		exam.GET("/ai-calibration/:examid", auth.TeacherMiddleware(), h.GetAICalibrationReport)
+		exam.PUT("/ai-calibration/:examid", auth.TeacherMiddleware(), h.SetAICalibration)
+
+		exam.GET("/prompt-templates", auth.TeacherMiddleware(), h.GetPromptTemplates)
+		exam.GET("/prompt-templates/:name", auth.TeacherMiddleware(), h.GetPromptTemplateVersions)
+		exam.POST("/prompt-templates/preview", auth.TeacherMiddleware(), h.PreviewGradingPrompt)
+
+		exam.GET("/getevaluatedexams", auth.TeacherMiddleware(), h.GetEvaluatedExamsByTeacherContainer)
+		exam.GET("/getstudentsandmarks/:examid", auth.TeacherMiddleware(), h.GetAllStudentDetailsAndMarksByExamID)
+
+		exam.GET("/my-results", auth.StudentMiddleware(), h.GetMyResults)
+		exam.POST("/re-evaluation/:evaluationid", auth.StudentMiddleware(), h.CreateReEvaluationRequest)
+		exam.GET("/my-re-evaluations", auth.StudentMiddleware(), h.GetMyReEvaluationRequests)
+
+		exam.GET("/re-evaluations", auth.TeacherMiddleware(), h.GetReEvaluationQueue)
+		exam.PUT("/re-evaluations/:id", auth.TeacherMiddleware(), h.ResolveReEvaluationRequest)
+		exam.GET("/evaluation-versions/:evaluationid", auth.TeacherMiddleware(), h.GetEvaluationVersions)
 		//student
 		//getexamsbydate
 		//getsetandcreateanswersheet-post //searches that exam id in student container
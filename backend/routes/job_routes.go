@@ -0,0 +1,16 @@
+package routes
+
+import (
+	"github.com/Maheshkarri4444/Examify/controllers"
+	"github.com/Maheshkarri4444/Examify/middleware"
+	"github.com/gin-gonic/gin"
+)
+
+func JobRoutes(r *gin.Engine, h *controllers.Handler, auth *middleware.Auth) {
+	jobs := r.Group("/jobs", auth.SignedInMiddleware())
+	{
+		jobs.GET("/:id", h.GetJob)
+		jobs.POST("/:id/cancel", h.CancelJob)
+	}
+
+}
@@ -0,0 +1,1406 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Maheshkarri4444/Examify/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// memoryDB holds every collection of the in-memory stores behind one lock.
+// Documents are copied on the way in and out so callers can't mutate them.
+type memoryDB struct {
+	mu                sync.Mutex
+	users             map[primitive.ObjectID]models.User
+	studentContainers map[primitive.ObjectID]models.StudentContainer
+	teacherContainers map[primitive.ObjectID]models.TeacherContainer
+	exams             map[primitive.ObjectID]models.Exam
+	papers            map[primitive.ObjectID]models.QuestionPaper
+	sheets            map[primitive.ObjectID]models.AnswerSheet
+	seating           map[primitive.ObjectID]models.SeatingMap
+	evaluations       map[primitive.ObjectID]models.Evaluation
+	versions          []models.EvaluationVersion
+	reEvaluations     map[primitive.ObjectID]models.ReEvaluationRequest
+	auditLogs         []models.AuditLog
+	jobs              map[primitive.ObjectID]models.Job
+	bank              map[primitive.ObjectID]models.BankQuestion
+	llmCalls          []models.LLMCall
+	prompts           []models.PromptTemplate
+	aiCache           map[string]models.AIGradingCacheEntry
+}
+
+// NewMemoryStores returns stores that keep all data in process memory. They
+// are meant for tests and local experiments, not production.
+func NewMemoryStores() *Stores {
+	db := &memoryDB{
+		users:             map[primitive.ObjectID]models.User{},
+		studentContainers: map[primitive.ObjectID]models.StudentContainer{},
+		teacherContainers: map[primitive.ObjectID]models.TeacherContainer{},
+		exams:             map[primitive.ObjectID]models.Exam{},
+		papers:            map[primitive.ObjectID]models.QuestionPaper{},
+		sheets:            map[primitive.ObjectID]models.AnswerSheet{},
+		seating:           map[primitive.ObjectID]models.SeatingMap{},
+		evaluations:       map[primitive.ObjectID]models.Evaluation{},
+		reEvaluations:     map[primitive.ObjectID]models.ReEvaluationRequest{},
+		jobs:              map[primitive.ObjectID]models.Job{},
+		bank:              map[primitive.ObjectID]models.BankQuestion{},
+		aiCache:           map[string]models.AIGradingCacheEntry{},
+	}
+	return &Stores{
+		Users:         &memoryUserStore{db},
+		Containers:    &memoryContainerStore{db},
+		Exams:         &memoryExamStore{db},
+		AnswerSheets:  &memoryAnswerSheetStore{db},
+		Seating:       &memorySeatingStore{db},
+		Evaluations:   &memoryEvaluationStore{db},
+		ReEvaluations: &memoryReEvaluationStore{db},
+		Audit:         &memoryAuditStore{db},
+		Jobs:          &memoryJobStore{db},
+		QuestionBank:  &memoryQuestionBankStore{db},
+		LLMUsage:      &memoryLLMUsageStore{db},
+		Prompts:       &memoryPromptTemplateStore{db},
+		AICache:       &memoryAIGradingCacheStore{db},
+	}
+}
+
+// clone deep copies a document through its bson representation, the same
+// way a round trip to MongoDB would.
+func clone[T any](v T) T {
+	var out T
+	data, err := bson.Marshal(v)
+	if err != nil {
+		panic(fmt.Sprintf("store: cannot copy %T: %v", v, err))
+	}
+	if err := bson.Unmarshal(data, &out); err != nil {
+		panic(fmt.Sprintf("store: cannot copy %T: %v", v, err))
+	}
+	return out
+}
+
+type memoryUserStore struct{ db *memoryDB }
+
+func (s *memoryUserStore) Create(ctx context.Context, user *models.User) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+	if user.ID.IsZero() {
+		user.ID = primitive.NewObjectID()
+	}
+	s.db.users[user.ID] = clone(*user)
+	return nil
+}
+
+func (s *memoryUserStore) GetByID(ctx context.Context, id primitive.ObjectID) (*models.User, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+	user, ok := s.db.users[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	user = clone(user)
+	return &user, nil
+}
+
+func (s *memoryUserStore) GetByEmail(ctx context.Context, email string) (*models.User, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+	for _, user := range s.db.users {
+		if user.Email == email {
+			user = clone(user)
+			return &user, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (s *memoryUserStore) update(id primitive.ObjectID, apply func(*models.User)) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+	user, ok := s.db.users[id]
+	if !ok {
+		return ErrNotFound
+	}
+	apply(&user)
+	user.UpdatedAt = primitive.NewDateTimeFromTime(time.Now())
+	s.db.users[id] = user
+	return nil
+}
+
+func (s *memoryUserStore) UpdateProfile(ctx context.Context, id primitive.ObjectID, image string) error {
+	return s.update(id, func(u *models.User) { u.Image = image })
+}
+
+func (s *memoryUserStore) SetRole(ctx context.Context, id primitive.ObjectID, role string) error {
+	return s.update(id, func(u *models.User) { u.Role = role })
+}
+
+func (s *memoryUserStore) List(ctx context.Context, filter UserFilter) ([]models.User, int64, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	query := strings.ToLower(filter.Query)
+	users := []models.User{}
+	for _, user := range s.db.users {
+		if filter.Role != "" && user.Role != filter.Role {
+			continue
+		}
+		if query != "" && !strings.Contains(strings.ToLower(user.Email), query) && !strings.Contains(strings.ToLower(user.Name), query) {
+			continue
+		}
+		users = append(users, clone(user))
+	}
+	sort.Slice(users, func(i, j int) bool { return users[i].Email < users[j].Email })
+
+	total := int64(len(users))
+	if filter.Skip >= total {
+		return []models.User{}, total, nil
+	}
+	users = users[filter.Skip:]
+	if filter.Limit > 0 && int64(len(users)) > filter.Limit {
+		users = users[:filter.Limit]
+	}
+	return users, total, nil
+}
+
+func (s *memoryUserStore) CountByRole(ctx context.Context, role string) (int64, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+	var count int64
+	for _, user := range s.db.users {
+		if user.Role == role {
+			count++
+		}
+	}
+	return count, nil
+}
+
+type memoryContainerStore struct{ db *memoryDB }
+
+func (s *memoryContainerStore) EnsureStudentContainer(ctx context.Context, id primitive.ObjectID) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+	if _, ok := s.db.studentContainers[id]; !ok {
+		s.db.studentContainers[id] = models.StudentContainer{ID: id}
+	}
+	return nil
+}
+
+func (s *memoryContainerStore) EnsureTeacherContainer(ctx context.Context, id primitive.ObjectID) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+	if _, ok := s.db.teacherContainers[id]; !ok {
+		s.db.teacherContainers[id] = models.TeacherContainer{ID: id}
+	}
+	return nil
+}
+
+func (s *memoryContainerStore) GetStudentContainer(ctx context.Context, id primitive.ObjectID) (*models.StudentContainer, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+	container, ok := s.db.studentContainers[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	container = clone(container)
+	return &container, nil
+}
+
+func (s *memoryContainerStore) GetTeacherContainer(ctx context.Context, id primitive.ObjectID) (*models.TeacherContainer, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+	container, ok := s.db.teacherContainers[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	container = clone(container)
+	return &container, nil
+}
+
+func (s *memoryContainerStore) findTeacherByExam(examID primitive.ObjectID) (models.TeacherContainer, int, bool) {
+	for _, container := range s.db.teacherContainers {
+		for i, e := range container.Exams {
+			if e.ExamID == examID {
+				return container, i, true
+			}
+		}
+	}
+	return models.TeacherContainer{}, -1, false
+}
+
+func (s *memoryContainerStore) FindTeacherContainerByExam(ctx context.Context, examID primitive.ObjectID) (*models.TeacherContainer, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+	container, _, ok := s.findTeacherByExam(examID)
+	if !ok {
+		return nil, ErrNotFound
+	}
+	container = clone(container)
+	return &container, nil
+}
+
+func (s *memoryContainerStore) TeacherOwnsExam(ctx context.Context, containerID, examID primitive.ObjectID) (bool, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+	for _, e := range s.db.teacherContainers[containerID].Exams {
+		if e.ExamID == examID {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (s *memoryContainerStore) AddExamToTeacher(ctx context.Context, containerID, examID primitive.ObjectID, evaluationIDs []primitive.ObjectID) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+	container, ok := s.db.teacherContainers[containerID]
+	if !ok {
+		return ErrNotFound
+	}
+	container.Exams = append(container.Exams, struct {
+		ExamID       primitive.ObjectID   `bson:"exam_id" json:"exam_id"`
+		EvaluationID []primitive.ObjectID `bson:"evaluation_id" json:"evaluation_id"`
+	}{ExamID: examID, EvaluationID: append([]primitive.ObjectID{}, evaluationIDs...)})
+	s.db.teacherContainers[containerID] = container
+	return nil
+}
+
+func (s *memoryContainerStore) RemoveExamFromTeacher(ctx context.Context, containerID, examID primitive.ObjectID) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+	container, ok := s.db.teacherContainers[containerID]
+	if !ok {
+		return ErrNotFound
+	}
+	exams := container.Exams[:0]
+	for _, e := range container.Exams {
+		if e.ExamID != examID {
+			exams = append(exams, e)
+		}
+	}
+	container.Exams = exams
+	s.db.teacherContainers[containerID] = container
+	return nil
+}
+
+func (s *memoryContainerStore) AddEvaluationToExam(ctx context.Context, examID, evaluationID primitive.ObjectID) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+	container, i, ok := s.findTeacherByExam(examID)
+	if !ok {
+		return ErrNotFound
+	}
+	container.Exams[i].EvaluationID = append(container.Exams[i].EvaluationID, evaluationID)
+	s.db.teacherContainers[container.ID] = container
+	return nil
+}
+
+type memoryExamStore struct{ db *memoryDB }
+
+func (s *memoryExamStore) Create(ctx context.Context, exam *models.Exam) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+	if exam.ID.IsZero() {
+		exam.ID = primitive.NewObjectID()
+	}
+	s.db.exams[exam.ID] = clone(*exam)
+	return nil
+}
+
+func (s *memoryExamStore) GetByID(ctx context.Context, id primitive.ObjectID) (*models.Exam, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+	exam, ok := s.db.exams[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	exam = clone(exam)
+	return &exam, nil
+}
+
+func (s *memoryExamStore) Update(ctx context.Context, exam *models.Exam) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+	existing, ok := s.db.exams[exam.ID]
+	if !ok {
+		return ErrNotFound
+	}
+	updated := clone(*exam)
+	existing.ExamName = updated.ExamName
+	existing.ExamType = updated.ExamType
+	existing.AvailableDates = updated.AvailableDates
+	existing.Duration = updated.Duration
+	existing.Questions = updated.Questions
+	s.db.exams[exam.ID] = existing
+	return nil
+}
+
+func (s *memoryExamStore) filter(match func(models.Exam) bool) []models.Exam {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+	exams := []models.Exam{}
+	for _, exam := range s.db.exams {
+		if match(exam) {
+			exams = append(exams, clone(exam))
+		}
+	}
+	sort.Slice(exams, func(i, j int) bool { return exams[i].ID.Hex() < exams[j].ID.Hex() })
+	return exams
+}
+
+func (s *memoryExamStore) List(ctx context.Context) ([]models.Exam, error) {
+	return s.filter(func(models.Exam) bool { return true }), nil
+}
+
+func (s *memoryExamStore) ListByIDs(ctx context.Context, ids []primitive.ObjectID) ([]models.Exam, error) {
+	wanted := map[primitive.ObjectID]bool{}
+	for _, id := range ids {
+		wanted[id] = true
+	}
+	return s.filter(func(e models.Exam) bool { return wanted[e.ID] }), nil
+}
+
+func (s *memoryExamStore) ListAvailableBetween(ctx context.Context, start, end time.Time) ([]models.Exam, error) {
+	return s.filter(func(e models.Exam) bool {
+		for _, d := range e.AvailableDates {
+			if t := d.Time(); !t.Before(start) && t.Before(end) {
+				return true
+			}
+		}
+		return false
+	}), nil
+}
+
+func (s *memoryExamStore) AddQuestions(ctx context.Context, id primitive.ObjectID, questions []models.Question) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+	exam, ok := s.db.exams[id]
+	if !ok {
+		return ErrNotFound
+	}
+	exam.Questions = append(exam.Questions, questions...)
+	s.db.exams[id] = clone(exam)
+	return nil
+}
+
+func (s *memoryExamStore) SetAICalibration(ctx context.Context, id primitive.ObjectID, calibration *models.AICalibration) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+	exam, ok := s.db.exams[id]
+	if !ok {
+		return ErrNotFound
+	}
+	exam.AICalibration = calibration
+	s.db.exams[id] = clone(exam)
+	return nil
+}
+
+func (s *memoryExamStore) SetSets(ctx context.Context, id primitive.ObjectID, sets []primitive.ObjectID) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+	exam, ok := s.db.exams[id]
+	if !ok {
+		return ErrNotFound
+	}
+	exam.Sets = append([]primitive.ObjectID{}, sets...)
+	exam.SetCounter = 0
+	s.db.exams[id] = exam
+	return nil
+}
+
+func (s *memoryExamStore) NextSetCounter(ctx context.Context, id primitive.ObjectID) (int64, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+	exam, ok := s.db.exams[id]
+	if !ok {
+		return 0, ErrNotFound
+	}
+	exam.SetCounter++
+	s.db.exams[id] = exam
+	return exam.SetCounter, nil
+}
+
+func (s *memoryExamStore) SetOpen(ctx context.Context, id primitive.ObjectID, open bool) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+	exam, ok := s.db.exams[id]
+	if !ok {
+		return ErrNotFound
+	}
+	exam.Open = open
+	s.db.exams[id] = exam
+	return nil
+}
+
+func (s *memoryExamStore) SetPaused(ctx context.Context, id primitive.ObjectID, paused bool) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+	exam, ok := s.db.exams[id]
+	if !ok {
+		return ErrNotFound
+	}
+	exam.Paused = paused
+	s.db.exams[id] = exam
+	return nil
+}
+
+func (s *memoryExamStore) Count(ctx context.Context) (int64, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+	return int64(len(s.db.exams)), nil
+}
+
+func (s *memoryExamStore) CreateQuestionPaper(ctx context.Context, paper *models.QuestionPaper) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+	if paper.ID.IsZero() {
+		paper.ID = primitive.NewObjectID()
+	}
+	s.db.papers[paper.ID] = clone(*paper)
+	return nil
+}
+
+func (s *memoryExamStore) GetQuestionPaper(ctx context.Context, id primitive.ObjectID) (*models.QuestionPaper, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+	paper, ok := s.db.papers[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	paper = clone(paper)
+	return &paper, nil
+}
+
+func (s *memoryExamStore) SetPaperQuestions(ctx context.Context, id primitive.ObjectID, questions []models.Question) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+	paper, ok := s.db.papers[id]
+	if !ok {
+		return ErrNotFound
+	}
+	paper.Questions = questions
+	s.db.papers[id] = clone(paper)
+	return nil
+}
+
+type memoryAnswerSheetStore struct{ db *memoryDB }
+
+func (f AnswerSheetFilter) matches(sheet models.AnswerSheet) bool {
+	return (f.ExamID == nil || sheet.ExamID == *f.ExamID) &&
+		(len(f.Statuses) == 0 || contains(f.Statuses, sheet.Status)) &&
+		(f.Submitted == nil || sheet.Submitted == *f.Submitted)
+}
+
+func contains[T comparable](list []T, value T) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *memoryAnswerSheetStore) Create(ctx context.Context, sheet *models.AnswerSheet) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+	if sheet.ID.IsZero() {
+		sheet.ID = primitive.NewObjectID()
+	}
+	s.db.sheets[sheet.ID] = clone(*sheet)
+	return nil
+}
+
+func (s *memoryAnswerSheetStore) Assign(ctx context.Context, sheet *models.AnswerSheet, containerID primitive.ObjectID, pick PickSet) (*models.AnswerSheet, bool, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+	for _, existing := range s.db.sheets {
+		if existing.ExamID == sheet.ExamID && existing.Email == sheet.Email {
+			existing = clone(existing)
+			return &existing, false, nil
+		}
+	}
+	if pick != nil {
+		nearby := []models.AnswerSheet{}
+		for _, other := range s.db.sheets {
+			if sheet.Seat == nil || other.ExamID != sheet.ExamID || other.Seat == nil ||
+				other.Seat.Slot != sheet.Seat.Slot || other.Seat.Room != sheet.Seat.Room {
+				continue
+			}
+			if other.Seat.Row >= sheet.Seat.Row-1 && other.Seat.Row <= sheet.Seat.Row+1 {
+				nearby = append(nearby, clone(other))
+			}
+		}
+		if err := pick(sheet, nearby); err != nil {
+			return nil, false, err
+		}
+	}
+	if sheet.Seat != nil {
+		for _, existing := range s.db.sheets {
+			if existing.ExamID == sheet.ExamID && existing.Seat != nil && *existing.Seat == *sheet.Seat {
+				return nil, false, ErrConflict
+			}
+		}
+	}
+	exam, ok := s.db.exams[sheet.ExamID]
+	if !ok {
+		return nil, false, ErrNotFound
+	}
+	container, ok := s.db.studentContainers[containerID]
+	if !ok {
+		return nil, false, ErrNotFound
+	}
+
+	if sheet.ID.IsZero() {
+		sheet.ID = primitive.NewObjectID()
+	}
+	s.db.sheets[sheet.ID] = clone(*sheet)
+	exam.AnswerSheets = append(exam.AnswerSheets, sheet.ID)
+	s.db.exams[exam.ID] = exam
+	container.QuestionPapers = append(container.QuestionPapers, struct {
+		ExamID          primitive.ObjectID `bson:"exam_id" json:"exam_id"`
+		QuestionPaperID primitive.ObjectID `bson:"question_paper_id" json:"question_paper_id"`
+		AnswerSheetID   primitive.ObjectID `bson:"answer_sheet_id" json:"answer_sheet_id"`
+	}{ExamID: sheet.ExamID, QuestionPaperID: sheet.QPaperID, AnswerSheetID: sheet.ID})
+	s.db.studentContainers[containerID] = container
+	return sheet, true, nil
+}
+
+func (s *memoryAnswerSheetStore) GetByID(ctx context.Context, id primitive.ObjectID) (*models.AnswerSheet, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+	sheet, ok := s.db.sheets[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	sheet = clone(sheet)
+	return &sheet, nil
+}
+
+func (s *memoryAnswerSheetStore) GetByExamAndEmail(ctx context.Context, examID primitive.ObjectID, email string) (*models.AnswerSheet, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+	for _, sheet := range s.db.sheets {
+		if sheet.ExamID == examID && sheet.Email == email {
+			sheet = clone(sheet)
+			return &sheet, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (s *memoryAnswerSheetStore) List(ctx context.Context, filter AnswerSheetFilter) ([]models.AnswerSheet, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+	sheets := []models.AnswerSheet{}
+	for _, sheet := range s.db.sheets {
+		if filter.matches(sheet) {
+			sheets = append(sheets, clone(sheet))
+		}
+	}
+	sort.Slice(sheets, func(i, j int) bool { return sheets[i].ID.Hex() < sheets[j].ID.Hex() })
+	return sheets, nil
+}
+
+func (s *memoryAnswerSheetStore) Count(ctx context.Context, filter AnswerSheetFilter) (int64, error) {
+	sheets, err := s.List(ctx, filter)
+	return int64(len(sheets)), err
+}
+
+func (s *memoryAnswerSheetStore) Transition(ctx context.Context, id primitive.ObjectID, change SheetChange) (*models.AnswerSheet, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+	sheet, ok := s.db.sheets[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	if !sheet.Status.CanTransition(change.To) {
+		return nil, ErrConflict
+	}
+	held := sheet.Session != nil && sheet.Session.ID != change.SessionID &&
+		!sheet.Session.LastSeen.Time().Before(change.StaleBefore)
+	if held && !change.StaleBefore.IsZero() {
+		return nil, ErrConflict
+	}
+	previous := clone(sheet)
+	at := now()
+	sheet.SheetTiming = sheet.TimingAfter(change.To, at.Time())
+	sheet.Transitions = append(sheet.Transitions, models.SheetTransition{
+		From: sheet.Status, To: change.To, At: at, By: change.By, Reason: change.Reason,
+	})
+	sheet.Status = change.To
+	sheet.Submitted = change.To.Submitted()
+	if change.Data != nil {
+		sheet.Data = clone(struct{ D []models.AnswerData }{change.Data}).D
+		sheet.AIScore = change.AIScore
+	}
+	s.db.sheets[id] = sheet
+	return &previous, nil
+}
+
+func (s *memoryAnswerSheetStore) Extend(ctx context.Context, id primitive.ObjectID, minutes int64) (*models.AnswerSheet, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+	sheet, ok := s.db.sheets[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	if (sheet.Status != models.SheetStarted && sheet.Status != models.SheetPaused) || sheet.Deadline == nil {
+		return nil, ErrConflict
+	}
+	previous := clone(sheet)
+	deadline := primitive.NewDateTimeFromTime(sheet.Deadline.Time().Add(time.Duration(minutes) * time.Minute))
+	sheet.Deadline = &deadline
+	sheet.ExtraMinutes += minutes
+	s.db.sheets[id] = sheet
+	return &previous, nil
+}
+
+func (s *memoryAnswerSheetStore) Heartbeat(ctx context.Context, id primitive.ObjectID, beat SheetHeartbeat) (*models.AnswerSheet, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+	sheet, ok := s.db.sheets[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	if sheet.Status != models.SheetStarted && (sheet.Status != models.SheetPaused || beat.Data != nil) {
+		return nil, ErrConflict
+	}
+	held := sheet.Session != nil && sheet.Session.ID != beat.Session.ID &&
+		!sheet.Session.LastSeen.Time().Before(beat.StaleBefore)
+	if held && !beat.Takeover {
+		return nil, ErrConflict
+	}
+	previous := clone(sheet)
+	session := beat.Session
+	sheet.Session = &session
+	if beat.Data != nil {
+		sheet.Data = clone(struct{ D []models.AnswerData }{beat.Data}).D
+	}
+	s.db.sheets[id] = sheet
+	return &previous, nil
+}
+
+type memorySeatingStore struct{ db *memoryDB }
+
+func (s *memorySeatingStore) Upsert(ctx context.Context, seating *models.SeatingMap) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+	for id, existing := range s.db.seating {
+		if existing.ExamID == seating.ExamID && existing.Slot == seating.Slot && existing.Room == seating.Room {
+			seating.ID = id
+		}
+	}
+	if seating.ID.IsZero() {
+		seating.ID = primitive.NewObjectID()
+	}
+	s.db.seating[seating.ID] = clone(*seating)
+	return nil
+}
+
+func (s *memorySeatingStore) Get(ctx context.Context, examID primitive.ObjectID, slot, room string) (*models.SeatingMap, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+	for _, seating := range s.db.seating {
+		if seating.ExamID == examID && seating.Slot == slot && seating.Room == room {
+			seating = clone(seating)
+			return &seating, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (s *memorySeatingStore) ListByExam(ctx context.Context, examID primitive.ObjectID) ([]models.SeatingMap, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+	maps := []models.SeatingMap{}
+	for _, seating := range s.db.seating {
+		if seating.ExamID == examID {
+			maps = append(maps, clone(seating))
+		}
+	}
+	sort.Slice(maps, func(i, j int) bool {
+		if maps[i].Slot != maps[j].Slot {
+			return maps[i].Slot < maps[j].Slot
+		}
+		return maps[i].Room < maps[j].Room
+	})
+	return maps, nil
+}
+
+type memoryEvaluationStore struct{ db *memoryDB }
+
+func (f EvaluationFilter) matches(evaluation models.Evaluation) bool {
+	return (f.ExamID == nil || evaluation.ExamID == *f.ExamID) &&
+		(f.Email == "" || evaluation.Email == f.Email) &&
+		(f.Evaluated == nil || evaluation.Evaluated == *f.Evaluated)
+}
+
+func (s *memoryEvaluationStore) Create(ctx context.Context, evaluation *models.Evaluation) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+	if evaluation.ExamType == models.ExamViva {
+		for _, e := range s.db.evaluations {
+			if e.ExamType == models.ExamViva && e.ExamID == evaluation.ExamID && e.Email == evaluation.Email {
+				return ErrConflict
+			}
+		}
+	}
+	if evaluation.ID.IsZero() {
+		evaluation.ID = primitive.NewObjectID()
+	}
+	s.db.evaluations[evaluation.ID] = clone(*evaluation)
+	return nil
+}
+
+func (s *memoryEvaluationStore) GetByID(ctx context.Context, id primitive.ObjectID) (*models.Evaluation, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+	evaluation, ok := s.db.evaluations[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	evaluation = clone(evaluation)
+	return &evaluation, nil
+}
+
+func (s *memoryEvaluationStore) GetByAnswerSheet(ctx context.Context, answerSheetID primitive.ObjectID) (*models.Evaluation, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+	for _, evaluation := range s.db.evaluations {
+		if evaluation.AnswerSheetID == answerSheetID {
+			evaluation = clone(evaluation)
+			return &evaluation, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (s *memoryEvaluationStore) List(ctx context.Context, filter EvaluationFilter) ([]models.Evaluation, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+	evaluations := []models.Evaluation{}
+	for _, evaluation := range s.db.evaluations {
+		if filter.matches(evaluation) {
+			evaluations = append(evaluations, clone(evaluation))
+		}
+	}
+	sort.Slice(evaluations, func(i, j int) bool { return evaluations[i].ID.Hex() < evaluations[j].ID.Hex() })
+	return evaluations, nil
+}
+
+func (s *memoryEvaluationStore) Count(ctx context.Context, filter EvaluationFilter) (int64, error) {
+	evaluations, err := s.List(ctx, filter)
+	return int64(len(evaluations)), err
+}
+
+func (s *memoryEvaluationStore) UpdateGrading(ctx context.Context, id primitive.ObjectID, data []models.EvaluationData, totalMarks int, evaluated bool) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+	evaluation, ok := s.db.evaluations[id]
+	if !ok {
+		return ErrNotFound
+	}
+	evaluation.Data = clone(struct{ D []models.EvaluationData }{data}).D
+	evaluation.TotalMarks = totalMarks
+	evaluation.Evaluated = evaluated
+	s.db.evaluations[id] = evaluation
+	return nil
+}
+
+func (s *memoryEvaluationStore) SetQuestionMarks(ctx context.Context, id primitive.ObjectID, index, marks int) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+	evaluation, ok := s.db.evaluations[id]
+	if !ok {
+		return ErrNotFound
+	}
+	if index < 0 || index >= len(evaluation.Data) {
+		return fmt.Errorf("question index %d out of range", index)
+	}
+	evaluation.TotalMarks += marks - evaluation.Data[index].Marks
+	evaluation.Data[index].Marks = marks
+	s.db.evaluations[id] = evaluation
+	return nil
+}
+
+func (s *memoryEvaluationStore) SetAISuggestion(ctx context.Context, id primitive.ObjectID, index int, grading *models.AIGrading) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+	evaluation, ok := s.db.evaluations[id]
+	if !ok || evaluation.Evaluated || index < 0 || index >= len(evaluation.Data) {
+		return ErrNotFound
+	}
+	saved := clone(*grading)
+	score := saved.Score
+	evaluation.Data[index].AIEvaluation = saved.Feedback
+	evaluation.Data[index].AIScore = &score
+	evaluation.Data[index].AIGrading = &saved
+	s.db.evaluations[id] = evaluation
+	return nil
+}
+
+func (s *memoryEvaluationStore) GradeQuestion(ctx context.Context, id primitive.ObjectID, index, previousMarks, marks int, remarks string) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+	evaluation, ok := s.db.evaluations[id]
+	if !ok || evaluation.Evaluated || index < 0 || index >= len(evaluation.Data) || evaluation.Data[index].Marks != previousMarks {
+		return ErrNotFound
+	}
+	evaluation.TotalMarks += marks - previousMarks
+	evaluation.Data[index].Marks = marks
+	evaluation.Data[index].Remarks = remarks
+	s.db.evaluations[id] = evaluation
+	return nil
+}
+
+func (s *memoryEvaluationStore) IncrementVersion(ctx context.Context, id primitive.ObjectID) (*models.Evaluation, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+	evaluation, ok := s.db.evaluations[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	evaluation.Version++
+	s.db.evaluations[id] = evaluation
+	evaluation = clone(evaluation)
+	return &evaluation, nil
+}
+
+func (s *memoryEvaluationStore) CreateVersion(ctx context.Context, version *models.EvaluationVersion) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+	if version.ID.IsZero() {
+		version.ID = primitive.NewObjectID()
+	}
+	s.db.versions = append(s.db.versions, clone(*version))
+	return nil
+}
+
+func (s *memoryEvaluationStore) ListVersions(ctx context.Context, evaluationID primitive.ObjectID) ([]models.EvaluationVersion, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+	versions := []models.EvaluationVersion{}
+	for _, version := range s.db.versions {
+		if version.EvaluationID == evaluationID {
+			versions = append(versions, clone(version))
+		}
+	}
+	sort.Slice(versions, func(i, j int) bool { return versions[i].Version < versions[j].Version })
+	return versions, nil
+}
+
+type memoryReEvaluationStore struct{ db *memoryDB }
+
+func (s *memoryReEvaluationStore) Create(ctx context.Context, request *models.ReEvaluationRequest) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+	if request.ID.IsZero() {
+		request.ID = primitive.NewObjectID()
+	}
+	s.db.reEvaluations[request.ID] = clone(*request)
+	return nil
+}
+
+func (s *memoryReEvaluationStore) GetByID(ctx context.Context, id primitive.ObjectID) (*models.ReEvaluationRequest, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+	request, ok := s.db.reEvaluations[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	request = clone(request)
+	return &request, nil
+}
+
+func (s *memoryReEvaluationStore) HasPending(ctx context.Context, evaluationID primitive.ObjectID, questionIndex int) (bool, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+	for _, request := range s.db.reEvaluations {
+		if request.EvaluationID == evaluationID && request.QuestionIndex == questionIndex && request.Status == "pending" {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (s *memoryReEvaluationStore) List(ctx context.Context, filter ReEvaluationFilter) ([]models.ReEvaluationRequest, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	var exams map[primitive.ObjectID]bool
+	if filter.ExamIDs != nil {
+		exams = map[primitive.ObjectID]bool{}
+		for _, id := range filter.ExamIDs {
+			exams[id] = true
+		}
+	}
+
+	requests := []models.ReEvaluationRequest{}
+	for _, request := range s.db.reEvaluations {
+		if (filter.Email == "" || request.Email == filter.Email) &&
+			(exams == nil || exams[request.ExamID]) &&
+			(filter.Status == "" || request.Status == filter.Status) {
+			requests = append(requests, clone(request))
+		}
+	}
+	sort.Slice(requests, func(i, j int) bool { return requests[i].CreatedAt < requests[j].CreatedAt })
+	return requests, nil
+}
+
+func (s *memoryReEvaluationStore) Resolve(ctx context.Context, id primitive.ObjectID, resolution ReEvaluationResolution) (bool, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+	request, ok := s.db.reEvaluations[id]
+	if !ok || request.Status != "pending" {
+		return false, nil
+	}
+	resolvedAt := primitive.NewDateTimeFromTime(time.Now())
+	request.Status = resolution.Status
+	request.Response = resolution.Response
+	request.ResolvedBy = resolution.ResolvedBy
+	request.ResolvedAt = &resolvedAt
+	if resolution.RevisedMarks != nil {
+		marks := *resolution.RevisedMarks
+		request.RevisedMarks = &marks
+	}
+	s.db.reEvaluations[id] = request
+	return true, nil
+}
+
+func (s *memoryReEvaluationStore) Reopen(ctx context.Context, id primitive.ObjectID) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+	request, ok := s.db.reEvaluations[id]
+	if !ok {
+		return ErrNotFound
+	}
+	request.Status = "pending"
+	request.Response = ""
+	request.ResolvedBy = ""
+	request.ResolvedAt = nil
+	request.RevisedMarks = nil
+	s.db.reEvaluations[id] = request
+	return nil
+}
+
+type memoryAuditStore struct{ db *memoryDB }
+
+func (s *memoryAuditStore) Insert(ctx context.Context, entry *models.AuditLog) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+	if entry.ID.IsZero() {
+		entry.ID = primitive.NewObjectID()
+	}
+	s.db.auditLogs = append(s.db.auditLogs, clone(*entry))
+	return nil
+}
+
+func (s *memoryAuditStore) List(ctx context.Context, filter AuditFilter) ([]models.AuditLog, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+	logs := []models.AuditLog{}
+	for i := len(s.db.auditLogs) - 1; i >= 0; i-- {
+		entry := s.db.auditLogs[i]
+		if (filter.ExamID == nil || entry.ExamID == *filter.ExamID) &&
+			(filter.TargetID == nil || entry.TargetID == *filter.TargetID) &&
+			(filter.StudentEmail == "" || entry.StudentEmail == filter.StudentEmail) &&
+			(filter.Action == "" || entry.Action == filter.Action) {
+			logs = append(logs, clone(entry))
+		}
+		if filter.Limit > 0 && int64(len(logs)) >= filter.Limit {
+			break
+		}
+	}
+	return logs, nil
+}
+
+type memoryJobStore struct{ db *memoryDB }
+
+func (s *memoryJobStore) Enqueue(ctx context.Context, job *models.Job) (*models.Job, bool, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+	for _, existing := range s.db.jobs {
+		if (job.IdempotencyKey != "" && existing.IdempotencyKey == job.IdempotencyKey) ||
+			(job.UniqueKey != "" && existing.ActiveKey == job.UniqueKey) {
+			saved := clone(existing)
+			return &saved, false, nil
+		}
+	}
+	if job.ID.IsZero() {
+		job.ID = primitive.NewObjectID()
+	}
+	job.ActiveKey = job.UniqueKey
+	s.db.jobs[job.ID] = clone(*job)
+	return job, true, nil
+}
+
+func (s *memoryJobStore) GetByID(ctx context.Context, id primitive.ObjectID) (*models.Job, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+	job, ok := s.db.jobs[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	job = clone(job)
+	return &job, nil
+}
+
+func (s *memoryJobStore) List(ctx context.Context, filter JobFilter) ([]models.Job, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+	jobs := []models.Job{}
+	for _, job := range s.db.jobs {
+		if (filter.Kind == "" || job.Kind == filter.Kind) &&
+			(filter.ExamID == nil || job.ExamID == *filter.ExamID) &&
+			(len(filter.Statuses) == 0 || contains(filter.Statuses, job.Status)) &&
+			(filter.CreatedBy == "" || job.CreatedBy == filter.CreatedBy) {
+			jobs = append(jobs, clone(job))
+		}
+	}
+	sort.Slice(jobs, func(i, j int) bool {
+		if jobs[i].CreatedAt != jobs[j].CreatedAt {
+			return jobs[i].CreatedAt > jobs[j].CreatedAt
+		}
+		return jobs[i].ID.Hex() > jobs[j].ID.Hex()
+	})
+	if filter.Limit > 0 && int64(len(jobs)) > filter.Limit {
+		jobs = jobs[:filter.Limit]
+	}
+	return jobs, nil
+}
+
+func (s *memoryJobStore) Lease(ctx context.Context, owner string, kinds []string, until time.Time) (*models.Job, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+	t := now()
+	var next *models.Job
+	for id := range s.db.jobs {
+		job := s.db.jobs[id]
+		due := (job.Status == models.JobQueued && job.RunAt <= t) ||
+			(job.Status == models.JobRunning && job.LeaseUntil != nil && *job.LeaseUntil < t)
+		if due && contains(kinds, job.Kind) && (next == nil || job.RunAt < next.RunAt) {
+			next = &job
+		}
+	}
+	if next == nil {
+		return nil, ErrNotFound
+	}
+	leaseUntil := primitive.NewDateTimeFromTime(until)
+	next.Status = models.JobRunning
+	next.LeaseOwner = owner
+	next.LeaseUntil = &leaseUntil
+	next.StartedAt = &t
+	next.UpdatedAt = t
+	next.Attempts++
+	s.db.jobs[next.ID] = clone(*next)
+	return next, nil
+}
+
+// leased returns the running job owner holds, must be called with mu held.
+func (s *memoryJobStore) leased(id primitive.ObjectID, owner string) (models.Job, bool) {
+	job, ok := s.db.jobs[id]
+	return job, ok && job.Status == models.JobRunning && job.LeaseOwner == owner
+}
+
+func (s *memoryJobStore) Heartbeat(ctx context.Context, id primitive.ObjectID, owner string, until time.Time, progress models.JobProgress) (*models.Job, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+	job, ok := s.leased(id, owner)
+	if !ok {
+		return nil, ErrNotFound
+	}
+	leaseUntil := primitive.NewDateTimeFromTime(until)
+	job.LeaseUntil = &leaseUntil
+	job.Progress = progress
+	job.UpdatedAt = now()
+	s.db.jobs[id] = clone(job)
+	return &job, nil
+}
+
+func (s *memoryJobStore) Finish(ctx context.Context, id primitive.ObjectID, owner string, outcome JobOutcome) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+	job, ok := s.leased(id, owner)
+	if !ok {
+		return ErrNotFound
+	}
+	t := now()
+	job.Status = outcome.Status
+	job.Progress = outcome.Progress
+	job.UpdatedAt = t
+	job.LeaseOwner = ""
+	job.LeaseUntil = nil
+	if outcome.Error != "" {
+		job.LastError = outcome.Error
+	}
+	if outcome.Result != nil {
+		job.Result = outcome.Result
+	}
+	if outcome.Status == models.JobQueued {
+		job.RunAt = primitive.NewDateTimeFromTime(outcome.RunAt)
+		if outcome.Refund {
+			job.Attempts--
+		}
+	} else {
+		job.FinishedAt = &t
+		job.ActiveKey = ""
+	}
+	s.db.jobs[id] = clone(job)
+	return nil
+}
+
+func (s *memoryJobStore) Cancel(ctx context.Context, id primitive.ObjectID) (*models.Job, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+	job, ok := s.db.jobs[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	t := now()
+	switch job.Status {
+	case models.JobQueued:
+		job.Status = models.JobCancelled
+		job.FinishedAt = &t
+		job.ActiveKey = ""
+	case models.JobRunning:
+		job.CancelRequested = true
+	default:
+		return nil, ErrConflict
+	}
+	job.UpdatedAt = t
+	s.db.jobs[id] = clone(job)
+	return &job, nil
+}
+
+func (s *memoryJobStore) Requeue(ctx context.Context, id primitive.ObjectID) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+	job, ok := s.db.jobs[id]
+	if !ok {
+		return ErrNotFound
+	}
+	if job.Status != models.JobDead && job.Status != models.JobCancelled {
+		return ErrConflict
+	}
+	if job.UniqueKey != "" {
+		for _, other := range s.db.jobs {
+			if other.ActiveKey == job.UniqueKey {
+				return ErrConflict
+			}
+		}
+	}
+	t := now()
+	job.Status = models.JobQueued
+	job.Attempts = 0
+	job.RunAt = t
+	job.CancelRequested = false
+	job.Progress = models.JobProgress{}
+	job.UpdatedAt = t
+	job.ActiveKey = job.UniqueKey
+	job.FinishedAt = nil
+	s.db.jobs[id] = clone(job)
+	return nil
+}
+
+type memoryQuestionBankStore struct{ db *memoryDB }
+
+func (s *memoryQuestionBankStore) Insert(ctx context.Context, questions []models.BankQuestion) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+	for i := range questions {
+		if questions[i].ID.IsZero() {
+			questions[i].ID = primitive.NewObjectID()
+		}
+		s.db.bank[questions[i].ID] = clone(questions[i])
+	}
+	return nil
+}
+
+func (s *memoryQuestionBankStore) List(ctx context.Context, filter QuestionBankFilter) ([]models.BankQuestion, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+	topic := strings.ToLower(filter.Topic)
+	questions := []models.BankQuestion{}
+	for _, q := range s.db.bank {
+		if q.ContainerID != filter.ContainerID ||
+			(topic != "" && !strings.Contains(strings.ToLower(q.Topic), topic)) ||
+			(filter.Level != "" && q.Level != filter.Level) ||
+			(filter.Type != "" && !contains(q.Types, filter.Type)) {
+			continue
+		}
+		questions = append(questions, clone(q))
+	}
+	sort.Slice(questions, func(i, j int) bool {
+		if questions[i].CreatedAt != questions[j].CreatedAt {
+			return questions[i].CreatedAt > questions[j].CreatedAt
+		}
+		return questions[i].ID.Hex() > questions[j].ID.Hex()
+	})
+	return questions, nil
+}
+
+func (s *memoryQuestionBankStore) Delete(ctx context.Context, containerID, id primitive.ObjectID) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+	q, ok := s.db.bank[id]
+	if !ok || q.ContainerID != containerID {
+		return ErrNotFound
+	}
+	delete(s.db.bank, id)
+	return nil
+}
+
+type memoryLLMUsageStore struct{ db *memoryDB }
+
+func (s *memoryLLMUsageStore) Record(ctx context.Context, call *models.LLMCall) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+	if call.ID.IsZero() {
+		call.ID = primitive.NewObjectID()
+	}
+	s.db.llmCalls = append(s.db.llmCalls, clone(*call))
+	return nil
+}
+
+func (s *memoryLLMUsageStore) Totals(ctx context.Context, filter LLMUsageFilter, group UsageGroup) ([]LLMUsageTotals, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+	byKey := map[string]*LLMUsageTotals{}
+	for _, call := range s.db.llmCalls {
+		created := call.CreatedAt.Time()
+		if (filter.UserEmail != "" && call.UserEmail != filter.UserEmail) ||
+			(filter.ExamID != nil && call.ExamID != *filter.ExamID) ||
+			(filter.Purpose != "" && call.Purpose != filter.Purpose) ||
+			(!filter.Since.IsZero() && created.Before(filter.Since)) ||
+			(!filter.Until.IsZero() && !created.Before(filter.Until)) {
+			continue
+		}
+
+		var key string
+		switch group {
+		case UsageTotal:
+		case UsageByUser:
+			key = call.UserEmail
+		case UsageByExam:
+			if !call.ExamID.IsZero() {
+				key = call.ExamID.Hex()
+			}
+		case UsageByPurpose:
+			key = call.Purpose
+		case UsageByDay:
+			key = created.UTC().Format("2006-01-02")
+		default:
+			return nil, fmt.Errorf("store: unknown usage group %q", group)
+		}
+
+		totals := byKey[key]
+		if totals == nil {
+			totals = &LLMUsageTotals{Key: key}
+			byKey[key] = totals
+		}
+		totals.Calls++
+		if call.Status != models.LLMCallOK {
+			totals.Errors++
+		}
+		totals.PromptTokens += call.PromptTokens
+		totals.ResponseTokens += call.ResponseTokens
+		totals.TotalTokens += call.TotalTokens
+		totals.LatencyMS += call.LatencyMS
+	}
+
+	totals := make([]LLMUsageTotals, 0, len(byKey))
+	for _, t := range byKey {
+		totals = append(totals, *t)
+	}
+	sort.Slice(totals, func(i, j int) bool {
+		if totals[i].TotalTokens != totals[j].TotalTokens {
+			return totals[i].TotalTokens > totals[j].TotalTokens
+		}
+		return totals[i].Key < totals[j].Key
+	})
+	return totals, nil
+}
+
+type memoryPromptTemplateStore struct{ db *memoryDB }
+
+func (s *memoryPromptTemplateStore) Create(ctx context.Context, t *models.PromptTemplate) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+	t.Version = 1
+	for _, existing := range s.db.prompts {
+		if existing.Name == t.Name && existing.Version >= t.Version {
+			t.Version = existing.Version + 1
+		}
+	}
+	if t.ID.IsZero() {
+		t.ID = primitive.NewObjectID()
+	}
+	s.db.prompts = append(s.db.prompts, clone(*t))
+	return nil
+}
+
+func (s *memoryPromptTemplateStore) Get(ctx context.Context, name string, version int) (*models.PromptTemplate, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+	for _, t := range s.db.prompts {
+		if t.Name == name && t.Version == version {
+			t = clone(t)
+			return &t, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (s *memoryPromptTemplateStore) Latest(ctx context.Context) ([]models.PromptTemplate, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+	latest := map[string]models.PromptTemplate{}
+	for _, t := range s.db.prompts {
+		if current, ok := latest[t.Name]; !ok || t.Version > current.Version {
+			latest[t.Name] = t
+		}
+	}
+	templates := make([]models.PromptTemplate, 0, len(latest))
+	for _, t := range latest {
+		templates = append(templates, clone(t))
+	}
+	sort.Slice(templates, func(i, j int) bool { return templates[i].Name < templates[j].Name })
+	return templates, nil
+}
+
+func (s *memoryPromptTemplateStore) Versions(ctx context.Context, name string) ([]models.PromptTemplate, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+	templates := []models.PromptTemplate{}
+	for _, t := range s.db.prompts {
+		if t.Name == name {
+			templates = append(templates, clone(t))
+		}
+	}
+	sort.Slice(templates, func(i, j int) bool { return templates[i].Version > templates[j].Version })
+	return templates, nil
+}
+
+type memoryAIGradingCacheStore struct{ db *memoryDB }
+
+func (s *memoryAIGradingCacheStore) Get(ctx context.Context, key string) (*models.AIGradingCacheEntry, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+	entry, ok := s.db.aiCache[key]
+	if !ok || entry.ExpiresAt <= now() {
+		return nil, ErrNotFound
+	}
+	entry = clone(entry)
+	return &entry, nil
+}
+
+func (s *memoryAIGradingCacheStore) Put(ctx context.Context, entry *models.AIGradingCacheEntry) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+	s.db.aiCache[entry.Key] = clone(*entry)
+	return nil
+}
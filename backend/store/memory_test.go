@@ -0,0 +1,427 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Maheshkarri4444/Examify/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// seedExam creates an exam and a student container for each email.
+func seedExam(t *testing.T, stores *Stores, emails ...string) (primitive.ObjectID, map[string]primitive.ObjectID) {
+	t.Helper()
+	ctx := context.Background()
+	exam := &models.Exam{ExamName: "lab"}
+	if err := stores.Exams.Create(ctx, exam); err != nil {
+		t.Fatal(err)
+	}
+	containers := map[string]primitive.ObjectID{}
+	for _, email := range emails {
+		containers[email] = primitive.NewObjectID()
+		if err := stores.Containers.EnsureStudentContainer(ctx, containers[email]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return exam.ID, containers
+}
+
+func TestAssign(t *testing.T) {
+	seat := func(row, number int) *models.SeatAssignment {
+		return &models.SeatAssignment{Slot: "morning", Room: "lab1", Row: row, Number: number}
+	}
+
+	stores := NewMemoryStores()
+	examID, containers := seedExam(t, stores, "a@example.com", "b@example.com", "c@example.com")
+	ctx := context.Background()
+
+	// The steps run in order against the same exam
+	steps := []struct {
+		name        string
+		email       string
+		container   primitive.ObjectID
+		exam        primitive.ObjectID
+		seat        *models.SeatAssignment
+		wantCreated bool
+		wantErr     error
+		wantSeat    *models.SeatAssignment // of the returned sheet
+	}{
+		{name: "first sheet", email: "a@example.com", seat: seat(1, 1), wantCreated: true, wantSeat: seat(1, 1)},
+		{name: "same student again", email: "a@example.com", seat: seat(1, 2), wantSeat: seat(1, 1)},
+		{name: "seat taken", email: "b@example.com", seat: seat(1, 1), wantErr: ErrConflict},
+		{name: "free seat", email: "b@example.com", seat: seat(1, 2), wantCreated: true, wantSeat: seat(1, 2)},
+		{name: "no seat", email: "c@example.com", wantCreated: true},
+		{name: "unknown exam", email: "c@example.com", exam: primitive.NewObjectID(), wantErr: ErrNotFound},
+		{name: "unknown container", email: "d@example.com", container: primitive.NewObjectID(), wantErr: ErrNotFound},
+	}
+	for _, step := range steps {
+		t.Run(step.name, func(t *testing.T) {
+			exam, container := examID, containers[step.email]
+			if !step.exam.IsZero() {
+				exam = step.exam
+			}
+			if !step.container.IsZero() {
+				container = step.container
+			}
+
+			sheet := &models.AnswerSheet{ExamID: exam, Email: step.email, Seat: step.seat}
+			got, created, err := stores.AnswerSheets.Assign(ctx, sheet, container, nil)
+			if !errors.Is(err, step.wantErr) {
+				t.Fatalf("err = %v, want %v", err, step.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if created != step.wantCreated {
+				t.Errorf("created = %v, want %v", created, step.wantCreated)
+			}
+			if (got.Seat == nil) != (step.wantSeat == nil) || (got.Seat != nil && *got.Seat != *step.wantSeat) {
+				t.Errorf("seat = %v, want %v", got.Seat, step.wantSeat)
+			}
+		})
+	}
+
+	sheets, err := stores.AnswerSheets.List(ctx, AnswerSheetFilter{ExamID: &examID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sheets) != 3 {
+		t.Errorf("got %d sheets, want 3", len(sheets))
+	}
+	exam, _ := stores.Exams.GetByID(ctx, examID)
+	if len(exam.AnswerSheets) != 3 {
+		t.Errorf("exam links %d sheets, want 3", len(exam.AnswerSheets))
+	}
+	container, _ := stores.Containers.GetStudentContainer(ctx, containers["a@example.com"])
+	if len(container.QuestionPapers) != 1 {
+		t.Errorf("student container links %d sheets, want 1", len(container.QuestionPapers))
+	}
+}
+
+func TestAssignConcurrentRequestsCreateOneSheet(t *testing.T) {
+	stores := NewMemoryStores()
+	examID, containers := seedExam(t, stores, "a@example.com")
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	created := 0
+	ids := map[primitive.ObjectID]bool{}
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sheet := &models.AnswerSheet{ExamID: examID, Email: "a@example.com"}
+			got, ok, err := stores.AnswerSheets.Assign(context.Background(), sheet, containers["a@example.com"], nil)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			if ok {
+				created++
+			}
+			ids[got.ID] = true
+		}()
+	}
+	wg.Wait()
+
+	if created != 1 || len(ids) != 1 {
+		t.Errorf("created %d sheets and returned %d, want one of each", created, len(ids))
+	}
+}
+
+func TestAssignPicksFromNearbySheets(t *testing.T) {
+	stores := NewMemoryStores()
+	examID, containers := seedExam(t, stores, "a@example.com", "b@example.com", "c@example.com", "d@example.com")
+	ctx := context.Background()
+
+	seated := []struct {
+		email string
+		seat  models.SeatAssignment
+	}{
+		{"a@example.com", models.SeatAssignment{Slot: "morning", Room: "lab1", Row: 1, Number: 1}},
+		{"b@example.com", models.SeatAssignment{Slot: "morning", Room: "lab1", Row: 4, Number: 1}},
+		{"c@example.com", models.SeatAssignment{Slot: "morning", Room: "lab2", Row: 2, Number: 1}},
+	}
+	for _, s := range seated {
+		seat := s.seat
+		sheet := &models.AnswerSheet{ExamID: examID, Email: s.email, Seat: &seat}
+		if _, _, err := stores.AnswerSheets.Assign(ctx, sheet, containers[s.email], nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var nearby []models.AnswerSheet
+	pick := func(sheet *models.AnswerSheet, sheets []models.AnswerSheet) error {
+		nearby = sheets
+		sheet.Set = 2
+		return nil
+	}
+	seat := models.SeatAssignment{Slot: "morning", Room: "lab1", Row: 2, Number: 2}
+	got, _, err := stores.AnswerSheets.Assign(ctx, &models.AnswerSheet{ExamID: examID, Email: "d@example.com", Seat: &seat}, containers["d@example.com"], pick)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(nearby) != 1 || nearby[0].Email != "a@example.com" {
+		t.Errorf("pick saw %v, want only the sheet one row ahead in the same room", nearby)
+	}
+	if got.Set != 2 {
+		t.Errorf("set = %d, want the one pick chose", got.Set)
+	}
+
+	refuse := func(sheet *models.AnswerSheet, sheets []models.AnswerSheet) error { return ErrConflict }
+	_, _, err = stores.AnswerSheets.Assign(ctx, &models.AnswerSheet{ExamID: examID, Email: "e@example.com", Seat: &seat}, containers["d@example.com"], refuse)
+	if !errors.Is(err, ErrConflict) {
+		t.Errorf("err = %v, want pick's error", err)
+	}
+}
+
+func TestTransition(t *testing.T) {
+	tests := []struct {
+		name    string
+		from    models.SheetStatus
+		to      models.SheetStatus
+		wantErr error
+	}{
+		{name: "start", from: models.SheetAssigned, to: models.SheetStarted},
+		{name: "pause", from: models.SheetStarted, to: models.SheetPaused},
+		{name: "submit while paused", from: models.SheetPaused, to: models.SheetSubmitted},
+		{name: "reopen", from: models.SheetSubmitted, to: models.SheetStarted},
+		{name: "evaluate", from: models.SheetExpired, to: models.SheetEvaluated},
+		{name: "submit before starting", from: models.SheetAssigned, to: models.SheetSubmitted, wantErr: ErrConflict},
+		{name: "restart an evaluated sheet", from: models.SheetEvaluated, to: models.SheetStarted, wantErr: ErrConflict},
+		{name: "anything after void", from: models.SheetVoided, to: models.SheetStarted, wantErr: ErrConflict},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stores := NewMemoryStores()
+			ctx := context.Background()
+			sheet := &models.AnswerSheet{ExamID: primitive.NewObjectID(), Email: "a@example.com", Status: tt.from}
+			if err := stores.AnswerSheets.Create(ctx, sheet); err != nil {
+				t.Fatal(err)
+			}
+
+			previous, err := stores.AnswerSheets.Transition(ctx, sheet.ID, SheetChange{To: tt.to, By: "teacher@example.com"})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+
+			got, _ := stores.AnswerSheets.GetByID(ctx, sheet.ID)
+			if err != nil {
+				if got.Status != tt.from || len(got.Transitions) != 0 {
+					t.Errorf("refused transition changed the sheet to %s", got.Status)
+				}
+				return
+			}
+			if previous.Status != tt.from {
+				t.Errorf("previous status = %s, want %s", previous.Status, tt.from)
+			}
+			if got.Status != tt.to || got.Submitted != tt.to.Submitted() {
+				t.Errorf("status = %s, submitted = %v, want %s", got.Status, got.Submitted, tt.to)
+			}
+			if n := len(got.Transitions); n != 1 || got.Transitions[0].From != tt.from || got.Transitions[0].To != tt.to {
+				t.Errorf("transitions = %+v, want one from %s to %s", got.Transitions, tt.from, tt.to)
+			}
+		})
+	}
+
+	t.Run("unknown sheet", func(t *testing.T) {
+		_, err := NewMemoryStores().AnswerSheets.Transition(context.Background(), primitive.NewObjectID(), SheetChange{To: models.SheetStarted})
+		if !errors.Is(err, ErrNotFound) {
+			t.Errorf("err = %v, want %v", err, ErrNotFound)
+		}
+	})
+}
+
+func TestTransitionConcurrentChangesApplyOnce(t *testing.T) {
+	stores := NewMemoryStores()
+	ctx := context.Background()
+	sheet := &models.AnswerSheet{ExamID: primitive.NewObjectID(), Email: "a@example.com", Status: models.SheetStarted}
+	if err := stores.AnswerSheets.Create(ctx, sheet); err != nil {
+		t.Fatal(err)
+	}
+
+	// Submitting from two tabs while the deadline expires: whichever lands
+	// first closes the sheet and the others must see the new status
+	changes := []models.SheetStatus{models.SheetSubmitted, models.SheetExpired, models.SheetSubmitted, models.SheetExpired}
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	applied := 0
+	for _, to := range changes {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := stores.AnswerSheets.Transition(ctx, sheet.ID, SheetChange{To: to})
+			if err != nil && !errors.Is(err, ErrConflict) {
+				t.Error(err)
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			if err == nil {
+				applied++
+			}
+		}()
+	}
+	wg.Wait()
+
+	got, _ := stores.AnswerSheets.GetByID(ctx, sheet.ID)
+	if applied != 1 || len(got.Transitions) != 1 {
+		t.Errorf("%d changes applied with %d transitions recorded, want exactly one", applied, len(got.Transitions))
+	}
+}
+
+func TestCreateVivaConflict(t *testing.T) {
+	stores := NewMemoryStores()
+	ctx := context.Background()
+	examID := primitive.NewObjectID()
+	viva := func() *models.Evaluation {
+		return &models.Evaluation{ExamID: examID, Email: "student@example.com", ExamType: models.ExamViva}
+	}
+	if err := stores.Evaluations.Create(ctx, viva()); err != nil {
+		t.Fatal(err)
+	}
+	if err := stores.Evaluations.Create(ctx, viva()); err != ErrConflict {
+		t.Errorf("second viva: err = %v, want %v", err, ErrConflict)
+	}
+	written := &models.Evaluation{ExamID: examID, Email: "student@example.com", AnswerSheetID: primitive.NewObjectID()}
+	if err := stores.Evaluations.Create(ctx, written); err != nil {
+		t.Errorf("evaluation of an answer sheet: err = %v, want nil", err)
+	}
+}
+
+func TestHeartbeat(t *testing.T) {
+	at := time.Date(2026, 3, 2, 9, 0, 0, 0, time.UTC)
+	answers := []models.AnswerData{{Question: "q1", Answers: []models.Answer{{Type: "js", Ans: "1"}}}}
+	tests := []struct {
+		name     string
+		status   models.SheetStatus
+		held     bool // by another session that is still alive
+		takeover bool
+		data     []models.AnswerData
+		wantErr  error
+		wantSave bool
+	}{
+		{name: "started", status: models.SheetStarted},
+		{name: "started with answers", status: models.SheetStarted, data: answers, wantSave: true},
+		{name: "paused", status: models.SheetPaused},
+		{name: "paused with answers", status: models.SheetPaused, data: answers, wantErr: ErrConflict},
+		{name: "submitted", status: models.SheetSubmitted, wantErr: ErrConflict},
+		{name: "assigned", status: models.SheetAssigned, wantErr: ErrConflict},
+		{name: "held elsewhere", status: models.SheetStarted, held: true, wantErr: ErrConflict},
+		{name: "taken over", status: models.SheetStarted, held: true, takeover: true, data: answers, wantSave: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stores := NewMemoryStores()
+			ctx := context.Background()
+			sheet := &models.AnswerSheet{ExamID: primitive.NewObjectID(), Email: "a@example.com", Status: tt.status}
+			if tt.held {
+				sheet.Session = &models.SheetSession{ID: "laptop", LastSeen: primitive.NewDateTimeFromTime(at)}
+			}
+			if err := stores.AnswerSheets.Create(ctx, sheet); err != nil {
+				t.Fatal(err)
+			}
+
+			_, err := stores.AnswerSheets.Heartbeat(ctx, sheet.ID, SheetHeartbeat{
+				Session:     models.SheetSession{ID: "phone", LastSeen: primitive.NewDateTimeFromTime(at)},
+				StaleBefore: at.Add(-45 * time.Second),
+				Takeover:    tt.takeover,
+				Data:        tt.data,
+			})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+
+			got, _ := stores.AnswerSheets.GetByID(ctx, sheet.ID)
+			if saved := len(got.Data) > 0; saved != tt.wantSave {
+				t.Errorf("answers saved = %v, want %v", saved, tt.wantSave)
+			}
+			if err == nil && (got.Session == nil || got.Session.ID != "phone") {
+				t.Errorf("session = %+v, want the heartbeat's", got.Session)
+			}
+		})
+	}
+}
+
+func TestGradeQuestion(t *testing.T) {
+	tests := []struct {
+		name      string
+		finalized bool
+		index     int
+		previous  int
+		wantErr   error
+		wantTotal int
+	}{
+		{name: "grades", previous: 4, wantTotal: 15},
+		{name: "marks changed since they were read", previous: 5, wantErr: ErrNotFound, wantTotal: 10},
+		{name: "finalized", finalized: true, previous: 4, wantErr: ErrNotFound, wantTotal: 10},
+		{name: "bad index", index: 2, previous: 0, wantErr: ErrNotFound, wantTotal: 10},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stores := NewMemoryStores()
+			ctx := context.Background()
+			evaluation := &models.Evaluation{
+				Data:       []models.EvaluationData{{Question: "q1", Marks: 4}, {Question: "q2", Marks: 6}},
+				TotalMarks: 10,
+				Evaluated:  tt.finalized,
+			}
+			if err := stores.Evaluations.Create(ctx, evaluation); err != nil {
+				t.Fatal(err)
+			}
+
+			if err := stores.Evaluations.GradeQuestion(ctx, evaluation.ID, tt.index, tt.previous, 9, "good"); err != tt.wantErr {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+			got, _ := stores.Evaluations.GetByID(ctx, evaluation.ID)
+			if got.TotalMarks != tt.wantTotal {
+				t.Errorf("total marks = %d, want %d", got.TotalMarks, tt.wantTotal)
+			}
+		})
+	}
+}
+
+func TestTransitionSession(t *testing.T) {
+	at := time.Date(2026, 3, 2, 9, 0, 0, 0, time.UTC)
+	session := func(id string, lastSeen time.Time) *models.SheetSession {
+		return &models.SheetSession{ID: id, LastSeen: primitive.NewDateTimeFromTime(lastSeen)}
+	}
+	tests := []struct {
+		name        string
+		session     *models.SheetSession
+		staleBefore time.Time
+		wantErr     error
+	}{
+		{name: "no session", staleBefore: at},
+		{name: "own session", session: session("laptop", at), staleBefore: at},
+		{name: "another live session", session: session("phone", at), staleBefore: at, wantErr: ErrConflict},
+		{name: "another stale session", session: session("phone", at.Add(-time.Second)), staleBefore: at},
+		{name: "not checked", session: session("phone", at)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stores := NewMemoryStores()
+			ctx := context.Background()
+			sheet := &models.AnswerSheet{ExamID: primitive.NewObjectID(), Email: "a@example.com", Status: models.SheetStarted, Session: tt.session}
+			if err := stores.AnswerSheets.Create(ctx, sheet); err != nil {
+				t.Fatal(err)
+			}
+
+			change := SheetChange{To: models.SheetSubmitted, SessionID: "laptop", StaleBefore: tt.staleBefore}
+			if _, err := stores.AnswerSheets.Transition(ctx, sheet.ID, change); !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+			want := models.SheetSubmitted
+			if tt.wantErr != nil {
+				want = models.SheetStarted
+			}
+			if got, _ := stores.AnswerSheets.GetByID(ctx, sheet.ID); got.Status != want {
+				t.Errorf("status = %s, want %s", got.Status, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,1365 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"time"
+
+	"github.com/Maheshkarri4444/Examify/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// NewMongoStores backs every store with a collection of db.
+func NewMongoStores(db *mongo.Database) *Stores {
+	return &Stores{
+		Users:         &mongoUserStore{users: db.Collection("users")},
+		Containers:    &mongoContainerStore{students: db.Collection("student_containers"), teachers: db.Collection("teacher_containers")},
+		Exams:         &mongoExamStore{exams: db.Collection("exams"), papers: db.Collection("question_papers")},
+		AnswerSheets:  &mongoAnswerSheetStore{client: db.Client(), sheets: db.Collection("answersheets"), exams: db.Collection("exams"), students: db.Collection("student_containers")},
+		Seating:       &mongoSeatingStore{maps: db.Collection("seating_maps")},
+		Evaluations:   &mongoEvaluationStore{evaluations: db.Collection("evaluations"), versions: db.Collection("evaluation_versions")},
+		ReEvaluations: &mongoReEvaluationStore{requests: db.Collection("re_evaluation_requests")},
+		Audit:         &mongoAuditStore{logs: db.Collection("audit_logs")},
+		Jobs:          &mongoJobStore{jobs: db.Collection("jobs")},
+		QuestionBank:  &mongoQuestionBankStore{questions: db.Collection("question_bank")},
+		LLMUsage:      &mongoLLMUsageStore{calls: db.Collection("llm_calls")},
+		Prompts:       &mongoPromptTemplateStore{templates: db.Collection("prompt_templates")},
+		AICache:       &mongoAIGradingCacheStore{entries: db.Collection("ai_grading_cache")},
+	}
+}
+
+// EnsureMongoIndexes creates the indexes the stores rely on for correctness.
+// It is safe to call on every startup.
+func EnsureMongoIndexes(ctx context.Context, db *mongo.Database) error {
+	_, err := db.Collection("answersheets").Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "exam_id", Value: 1}, {Key: "email", Value: 1}},
+		Options: options.Index().SetUnique(true).SetName("exam_id_email_unique"),
+	})
+	if err != nil {
+		return fmt.Errorf("answersheets (exam_id, email) index, remove duplicate attempts first: %w", err)
+	}
+
+	_, err = db.Collection("answersheets").Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{
+			{Key: "exam_id", Value: 1},
+			{Key: "seat.slot", Value: 1},
+			{Key: "seat.room", Value: 1},
+			{Key: "seat.row", Value: 1},
+			{Key: "seat.number", Value: 1},
+		},
+		Options: options.Index().SetUnique(true).SetName("exam_id_seat_unique").
+			SetPartialFilterExpression(bson.M{"seat": bson.M{"$exists": true}}),
+	})
+	if err != nil {
+		return fmt.Errorf("answersheets seat index: %w", err)
+	}
+
+	_, err = db.Collection("seating_maps").Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "exam_id", Value: 1}, {Key: "slot", Value: 1}, {Key: "room", Value: 1}},
+		Options: options.Index().SetUnique(true).SetName("exam_id_slot_room_unique"),
+	})
+	if err != nil {
+		return fmt.Errorf("seating_maps index: %w", err)
+	}
+
+	_, err = db.Collection("jobs").Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "idempotency_key", Value: 1}},
+			Options: options.Index().SetUnique(true).SetSparse(true).SetName("idempotency_key_unique"),
+		},
+		{
+			Keys:    bson.D{{Key: "active_key", Value: 1}},
+			Options: options.Index().SetUnique(true).SetSparse(true).SetName("active_key_unique"),
+		},
+		{
+			Keys:    bson.D{{Key: "status", Value: 1}, {Key: "run_at", Value: 1}},
+			Options: options.Index().SetName("status_run_at"),
+		},
+		{
+			Keys:    bson.D{{Key: "expire_at", Value: 1}},
+			Options: options.Index().SetExpireAfterSeconds(0).SetName("expire_at_ttl"),
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("jobs indexes: %w", err)
+	}
+
+	// Quotas sum the calls of a user or an exam since midnight on every call
+	_, err = db.Collection("llm_calls").Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "user_email", Value: 1}, {Key: "created_at", Value: 1}},
+			Options: options.Index().SetName("user_email_created_at"),
+		},
+		{
+			Keys:    bson.D{{Key: "exam_id", Value: 1}, {Key: "created_at", Value: 1}},
+			Options: options.Index().SetName("exam_id_created_at").SetSparse(true),
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("llm_calls indexes: %w", err)
+	}
+
+	_, err = db.Collection("prompt_templates").Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "name", Value: 1}, {Key: "version", Value: -1}},
+		Options: options.Index().SetUnique(true).SetName("name_version_unique"),
+	})
+	if err != nil {
+		return fmt.Errorf("prompt_templates index: %w", err)
+	}
+
+	// A viva has no answer sheet to hang uniqueness on, so the evaluation
+	// itself is unique per student
+	_, err = db.Collection("evaluations").Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "exam_id", Value: 1}, {Key: "email", Value: 1}},
+		Options: options.Index().SetUnique(true).SetName("viva_exam_id_email_unique").
+			SetPartialFilterExpression(bson.M{"exam_type": models.ExamViva}),
+	})
+	if err != nil {
+		return fmt.Errorf("evaluations viva index, remove duplicate vivas first: %w", err)
+	}
+
+	_, err = db.Collection("ai_grading_cache").Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "expires_at", Value: 1}},
+		Options: options.Index().SetExpireAfterSeconds(0).SetName("expires_at_ttl"),
+	})
+	if err != nil {
+		return fmt.Errorf("ai_grading_cache index: %w", err)
+	}
+	return nil
+}
+
+// MigrateLegacySheetStatuses rewrites answer sheet statuses stored before
+// the state machine existed. It only touches sheets that still need it.
+func MigrateLegacySheetStatuses(ctx context.Context, db *mongo.Database) error {
+	sheets := db.Collection("answersheets")
+	for _, status := range []models.SheetStatus{models.SheetAssigned, models.SheetSubmitted} {
+		legacy := models.LegacySheetStatuses(status)
+		_, err := sheets.UpdateMany(ctx,
+			bson.M{"status": bson.M{"$in": legacy}},
+			bson.M{"$set": bson.M{"status": status, "submitted": status.Submitted()}},
+		)
+		if err != nil {
+			return fmt.Errorf("migrating answer sheet statuses %v: %w", legacy, err)
+		}
+	}
+	return nil
+}
+
+func findOne(ctx context.Context, coll *mongo.Collection, filter interface{}, out interface{}) error {
+	err := coll.FindOne(ctx, filter).Decode(out)
+	if err == mongo.ErrNoDocuments {
+		return ErrNotFound
+	}
+	return err
+}
+
+func findAll[T any](ctx context.Context, coll *mongo.Collection, filter interface{}, opts ...*options.FindOptions) ([]T, error) {
+	cursor, err := coll.Find(ctx, filter, opts...)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	results := []T{}
+	if err := cursor.All(ctx, &results); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+func updateOne(ctx context.Context, coll *mongo.Collection, filter, update interface{}) error {
+	res, err := coll.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func now() primitive.DateTime {
+	return primitive.NewDateTimeFromTime(time.Now())
+}
+
+type mongoUserStore struct {
+	users *mongo.Collection
+}
+
+func (s *mongoUserStore) Create(ctx context.Context, user *models.User) error {
+	if user.ID.IsZero() {
+		user.ID = primitive.NewObjectID()
+	}
+	_, err := s.users.InsertOne(ctx, user)
+	return err
+}
+
+func (s *mongoUserStore) GetByID(ctx context.Context, id primitive.ObjectID) (*models.User, error) {
+	var user models.User
+	if err := findOne(ctx, s.users, bson.M{"_id": id}, &user); err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+func (s *mongoUserStore) GetByEmail(ctx context.Context, email string) (*models.User, error) {
+	var user models.User
+	if err := findOne(ctx, s.users, bson.M{"email": email}, &user); err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+func (s *mongoUserStore) UpdateProfile(ctx context.Context, id primitive.ObjectID, image string) error {
+	return updateOne(ctx, s.users, bson.M{"_id": id}, bson.M{"$set": bson.M{"image": image, "updated_at": now()}})
+}
+
+func (s *mongoUserStore) SetRole(ctx context.Context, id primitive.ObjectID, role string) error {
+	return updateOne(ctx, s.users, bson.M{"_id": id}, bson.M{"$set": bson.M{"role": role, "updated_at": now()}})
+}
+
+func (s *mongoUserStore) List(ctx context.Context, filter UserFilter) ([]models.User, int64, error) {
+	query := bson.M{}
+	if filter.Role != "" {
+		query["role"] = filter.Role
+	}
+	if filter.Query != "" {
+		pattern := primitive.Regex{Pattern: regexp.QuoteMeta(filter.Query), Options: "i"}
+		query["$or"] = bson.A{bson.M{"email": pattern}, bson.M{"name": pattern}}
+	}
+
+	total, err := s.users.CountDocuments(ctx, query)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	opts := options.Find().SetSort(bson.M{"email": 1}).SetSkip(filter.Skip)
+	if filter.Limit > 0 {
+		opts.SetLimit(filter.Limit)
+	}
+	users, err := findAll[models.User](ctx, s.users, query, opts)
+	return users, total, err
+}
+
+func (s *mongoUserStore) CountByRole(ctx context.Context, role string) (int64, error) {
+	return s.users.CountDocuments(ctx, bson.M{"role": role})
+}
+
+type mongoContainerStore struct {
+	students *mongo.Collection
+	teachers *mongo.Collection
+}
+
+func (s *mongoContainerStore) EnsureStudentContainer(ctx context.Context, id primitive.ObjectID) error {
+	_, err := s.students.UpdateOne(ctx,
+		bson.M{"_id": id},
+		bson.M{"$setOnInsert": bson.M{"question_papers": bson.A{}}},
+		options.Update().SetUpsert(true),
+	)
+	return err
+}
+
+func (s *mongoContainerStore) EnsureTeacherContainer(ctx context.Context, id primitive.ObjectID) error {
+	_, err := s.teachers.UpdateOne(ctx,
+		bson.M{"_id": id},
+		bson.M{"$setOnInsert": bson.M{"exams": bson.A{}}},
+		options.Update().SetUpsert(true),
+	)
+	return err
+}
+
+func (s *mongoContainerStore) GetStudentContainer(ctx context.Context, id primitive.ObjectID) (*models.StudentContainer, error) {
+	var container models.StudentContainer
+	if err := findOne(ctx, s.students, bson.M{"_id": id}, &container); err != nil {
+		return nil, err
+	}
+	return &container, nil
+}
+
+func (s *mongoContainerStore) GetTeacherContainer(ctx context.Context, id primitive.ObjectID) (*models.TeacherContainer, error) {
+	var container models.TeacherContainer
+	if err := findOne(ctx, s.teachers, bson.M{"_id": id}, &container); err != nil {
+		return nil, err
+	}
+	return &container, nil
+}
+
+func (s *mongoContainerStore) FindTeacherContainerByExam(ctx context.Context, examID primitive.ObjectID) (*models.TeacherContainer, error) {
+	var container models.TeacherContainer
+	if err := findOne(ctx, s.teachers, bson.M{"exams.exam_id": examID}, &container); err != nil {
+		return nil, err
+	}
+	return &container, nil
+}
+
+func (s *mongoContainerStore) TeacherOwnsExam(ctx context.Context, containerID, examID primitive.ObjectID) (bool, error) {
+	count, err := s.teachers.CountDocuments(ctx, bson.M{"_id": containerID, "exams.exam_id": examID})
+	return count > 0, err
+}
+
+func (s *mongoContainerStore) AddExamToTeacher(ctx context.Context, containerID, examID primitive.ObjectID, evaluationIDs []primitive.ObjectID) error {
+	if evaluationIDs == nil {
+		evaluationIDs = []primitive.ObjectID{}
+	}
+	return updateOne(ctx, s.teachers,
+		bson.M{"_id": containerID},
+		bson.M{"$push": bson.M{"exams": bson.M{"exam_id": examID, "evaluation_id": evaluationIDs}}},
+	)
+}
+
+func (s *mongoContainerStore) RemoveExamFromTeacher(ctx context.Context, containerID, examID primitive.ObjectID) error {
+	return updateOne(ctx, s.teachers,
+		bson.M{"_id": containerID},
+		bson.M{"$pull": bson.M{"exams": bson.M{"exam_id": examID}}},
+	)
+}
+
+func (s *mongoContainerStore) AddEvaluationToExam(ctx context.Context, examID, evaluationID primitive.ObjectID) error {
+	return updateOne(ctx, s.teachers,
+		bson.M{"exams.exam_id": examID},
+		bson.M{"$push": bson.M{"exams.$.evaluation_id": evaluationID}},
+	)
+}
+
+type mongoExamStore struct {
+	exams  *mongo.Collection
+	papers *mongo.Collection
+}
+
+func (s *mongoExamStore) Create(ctx context.Context, exam *models.Exam) error {
+	if exam.ID.IsZero() {
+		exam.ID = primitive.NewObjectID()
+	}
+	_, err := s.exams.InsertOne(ctx, exam)
+	return err
+}
+
+func (s *mongoExamStore) GetByID(ctx context.Context, id primitive.ObjectID) (*models.Exam, error) {
+	var exam models.Exam
+	if err := findOne(ctx, s.exams, bson.M{"_id": id}, &exam); err != nil {
+		return nil, err
+	}
+	return &exam, nil
+}
+
+func (s *mongoExamStore) Update(ctx context.Context, exam *models.Exam) error {
+	return updateOne(ctx, s.exams, bson.M{"_id": exam.ID}, bson.M{
+		"$set": bson.M{
+			"exam_name":       exam.ExamName,
+			"exam_type":       exam.ExamType,
+			"available_dates": exam.AvailableDates,
+			"duration":        exam.Duration,
+			"questions":       exam.Questions,
+		},
+	})
+}
+
+func (s *mongoExamStore) List(ctx context.Context) ([]models.Exam, error) {
+	return findAll[models.Exam](ctx, s.exams, bson.M{})
+}
+
+func (s *mongoExamStore) ListByIDs(ctx context.Context, ids []primitive.ObjectID) ([]models.Exam, error) {
+	return findAll[models.Exam](ctx, s.exams, bson.M{"_id": bson.M{"$in": ids}})
+}
+
+func (s *mongoExamStore) ListAvailableBetween(ctx context.Context, start, end time.Time) ([]models.Exam, error) {
+	return findAll[models.Exam](ctx, s.exams, bson.M{
+		"available_dates": bson.M{
+			"$elemMatch": bson.M{
+				"$gte": primitive.NewDateTimeFromTime(start),
+				"$lt":  primitive.NewDateTimeFromTime(end),
+			},
+		},
+	})
+}
+
+func (s *mongoExamStore) AddQuestions(ctx context.Context, id primitive.ObjectID, questions []models.Question) error {
+	return updateOne(ctx, s.exams, bson.M{"_id": id}, bson.M{"$push": bson.M{"questions": bson.M{"$each": questions}}})
+}
+
+func (s *mongoExamStore) SetAICalibration(ctx context.Context, id primitive.ObjectID, calibration *models.AICalibration) error {
+	update := bson.M{"$set": bson.M{"ai_calibration": calibration}}
+	if calibration == nil {
+		update = bson.M{"$unset": bson.M{"ai_calibration": ""}}
+	}
+	return updateOne(ctx, s.exams, bson.M{"_id": id}, update)
+}
+
+func (s *mongoExamStore) SetSets(ctx context.Context, id primitive.ObjectID, sets []primitive.ObjectID) error {
+	return updateOne(ctx, s.exams, bson.M{"_id": id}, bson.M{"$set": bson.M{"sets": sets, "set_counter": 0}})
+}
+
+func (s *mongoExamStore) NextSetCounter(ctx context.Context, id primitive.ObjectID) (int64, error) {
+	update := mongo.Pipeline{{{Key: "$set", Value: bson.M{
+		"set_counter": bson.M{"$add": bson.A{
+			bson.M{"$ifNull": bson.A{"$set_counter", bson.M{"$size": bson.M{"$ifNull": bson.A{"$answer_sheets", bson.A{}}}}}},
+			1,
+		}},
+	}}}}
+
+	var exam models.Exam
+	err := s.exams.FindOneAndUpdate(ctx, bson.M{"_id": id}, update,
+		options.FindOneAndUpdate().SetReturnDocument(options.After).SetProjection(bson.M{"set_counter": 1}),
+	).Decode(&exam)
+	if err == mongo.ErrNoDocuments {
+		return 0, ErrNotFound
+	}
+	if err != nil {
+		return 0, err
+	}
+	return exam.SetCounter, nil
+}
+
+func (s *mongoExamStore) SetOpen(ctx context.Context, id primitive.ObjectID, open bool) error {
+	return updateOne(ctx, s.exams, bson.M{"_id": id}, bson.M{"$set": bson.M{"open": open}})
+}
+
+func (s *mongoExamStore) SetPaused(ctx context.Context, id primitive.ObjectID, paused bool) error {
+	return updateOne(ctx, s.exams, bson.M{"_id": id}, bson.M{"$set": bson.M{"paused": paused}})
+}
+
+func (s *mongoExamStore) Count(ctx context.Context) (int64, error) {
+	return s.exams.CountDocuments(ctx, bson.M{})
+}
+
+func (s *mongoExamStore) CreateQuestionPaper(ctx context.Context, paper *models.QuestionPaper) error {
+	if paper.ID.IsZero() {
+		paper.ID = primitive.NewObjectID()
+	}
+	_, err := s.papers.InsertOne(ctx, paper)
+	return err
+}
+
+func (s *mongoExamStore) GetQuestionPaper(ctx context.Context, id primitive.ObjectID) (*models.QuestionPaper, error) {
+	var paper models.QuestionPaper
+	if err := findOne(ctx, s.papers, bson.M{"_id": id}, &paper); err != nil {
+		return nil, err
+	}
+	return &paper, nil
+}
+
+func (s *mongoExamStore) SetPaperQuestions(ctx context.Context, id primitive.ObjectID, questions []models.Question) error {
+	return updateOne(ctx, s.papers, bson.M{"_id": id}, bson.M{"$set": bson.M{"questions": questions}})
+}
+
+type mongoAnswerSheetStore struct {
+	client   *mongo.Client
+	sheets   *mongo.Collection
+	exams    *mongo.Collection
+	students *mongo.Collection
+}
+
+func answerSheetQuery(filter AnswerSheetFilter) bson.M {
+	query := bson.M{}
+	if filter.ExamID != nil {
+		query["exam_id"] = *filter.ExamID
+	}
+	if len(filter.Statuses) > 0 {
+		query["status"] = bson.M{"$in": filter.Statuses}
+	}
+	if filter.Submitted != nil {
+		query["submitted"] = *filter.Submitted
+	}
+	return query
+}
+
+func (s *mongoAnswerSheetStore) Create(ctx context.Context, sheet *models.AnswerSheet) error {
+	if sheet.ID.IsZero() {
+		sheet.ID = primitive.NewObjectID()
+	}
+	_, err := s.sheets.InsertOne(ctx, sheet)
+	return err
+}
+
+func (s *mongoAnswerSheetStore) Assign(ctx context.Context, sheet *models.AnswerSheet, containerID primitive.ObjectID, pick PickSet) (*models.AnswerSheet, bool, error) {
+	if sheet.ID.IsZero() {
+		sheet.ID = primitive.NewObjectID()
+	}
+
+	session, err := s.client.StartSession()
+	if err != nil {
+		return nil, false, err
+	}
+	defer session.EndSession(ctx)
+
+	_, err = session.WithTransaction(ctx, func(sc mongo.SessionContext) (interface{}, error) {
+		// Every assignment also pushes to the exam, so of two concurrent
+		// ones the later conflicts and runs again, then seeing the earlier
+		// sheet among the neighbours
+		if pick != nil {
+			var nearby []models.AnswerSheet
+			if sheet.Seat != nil {
+				var err error
+				if nearby, err = s.nearSeat(sc, sheet); err != nil {
+					return nil, err
+				}
+			}
+			if err := pick(sheet, nearby); err != nil {
+				return nil, err
+			}
+		}
+		if _, err := s.sheets.InsertOne(sc, sheet); err != nil {
+			return nil, err
+		}
+		if err := updateOne(sc, s.exams, bson.M{"_id": sheet.ExamID}, bson.M{"$push": bson.M{"answer_sheets": sheet.ID}}); err != nil {
+			return nil, fmt.Errorf("linking answer sheet to exam: %w", err)
+		}
+		err := updateOne(sc, s.students, bson.M{"_id": containerID}, bson.M{"$push": bson.M{"question_papers": bson.M{
+			"exam_id":           sheet.ExamID,
+			"question_paper_id": sheet.QPaperID,
+			"answer_sheet_id":   sheet.ID,
+		}}})
+		if err != nil {
+			return nil, fmt.Errorf("linking answer sheet to student: %w", err)
+		}
+		return nil, nil
+	})
+	if mongo.IsDuplicateKeyError(err) {
+		existing, err := s.GetByExamAndEmail(ctx, sheet.ExamID, sheet.Email)
+		if err == ErrNotFound {
+			// The duplicate was the seat, not the student
+			return nil, false, ErrConflict
+		}
+		if err != nil {
+			return nil, false, err
+		}
+		return existing, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	return sheet, true, nil
+}
+
+func (s *mongoAnswerSheetStore) GetByID(ctx context.Context, id primitive.ObjectID) (*models.AnswerSheet, error) {
+	var sheet models.AnswerSheet
+	if err := findOne(ctx, s.sheets, bson.M{"_id": id}, &sheet); err != nil {
+		return nil, err
+	}
+	return &sheet, nil
+}
+
+func (s *mongoAnswerSheetStore) GetByExamAndEmail(ctx context.Context, examID primitive.ObjectID, email string) (*models.AnswerSheet, error) {
+	var sheet models.AnswerSheet
+	if err := findOne(ctx, s.sheets, bson.M{"exam_id": examID, "email": email}, &sheet); err != nil {
+		return nil, err
+	}
+	return &sheet, nil
+}
+
+// nearSeat returns the sheets of other students seated within one row of
+// the sheet's seat.
+func (s *mongoAnswerSheetStore) nearSeat(ctx context.Context, sheet *models.AnswerSheet) ([]models.AnswerSheet, error) {
+	return findAll[models.AnswerSheet](ctx, s.sheets, bson.M{
+		"exam_id":   sheet.ExamID,
+		"email":     bson.M{"$ne": sheet.Email},
+		"seat.slot": sheet.Seat.Slot,
+		"seat.room": sheet.Seat.Room,
+		"seat.row":  bson.M{"$gte": sheet.Seat.Row - 1, "$lte": sheet.Seat.Row + 1},
+	})
+}
+
+func (s *mongoAnswerSheetStore) List(ctx context.Context, filter AnswerSheetFilter) ([]models.AnswerSheet, error) {
+	return findAll[models.AnswerSheet](ctx, s.sheets, answerSheetQuery(filter))
+}
+
+func (s *mongoAnswerSheetStore) Count(ctx context.Context, filter AnswerSheetFilter) (int64, error) {
+	return s.sheets.CountDocuments(ctx, answerSheetQuery(filter))
+}
+
+func (s *mongoAnswerSheetStore) Transition(ctx context.Context, id primitive.ObjectID, change SheetChange) (*models.AnswerSheet, error) {
+	current, err := s.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if !current.Status.CanTransition(change.To) {
+		return nil, ErrConflict
+	}
+
+	at := now()
+	timing := current.TimingAfter(change.To, at.Time())
+	set := bson.M{
+		"status":     change.To,
+		"submitted":  change.To.Submitted(),
+		"started_at": timing.StartedAt,
+		"deadline":   timing.Deadline,
+		"paused_at":  timing.PausedAt,
+	}
+	if change.Data != nil {
+		set["data"] = change.Data
+		set["ai_score"] = change.AIScore
+	}
+	transition := models.SheetTransition{From: current.Status, To: change.To, At: at, By: change.By, Reason: change.Reason}
+
+	// Compare and set on the status and deadline read above, a concurrent
+	// transition or extension makes this match nothing
+	filter := bson.M{"_id": id, "status": current.Status, "deadline": current.Deadline}
+	if !change.StaleBefore.IsZero() {
+		filter["$or"] = bson.A{
+			bson.M{"session": nil},
+			bson.M{"session.id": change.SessionID},
+			bson.M{"session.last_seen": bson.M{"$lt": primitive.NewDateTimeFromTime(change.StaleBefore)}},
+		}
+	}
+	res, err := s.sheets.UpdateOne(ctx, filter, bson.M{"$set": set, "$push": bson.M{"transitions": transition}})
+	if err != nil {
+		return nil, err
+	}
+	if res.MatchedCount == 0 {
+		return nil, ErrConflict
+	}
+	return current, nil
+}
+
+func (s *mongoAnswerSheetStore) Extend(ctx context.Context, id primitive.ObjectID, minutes int64) (*models.AnswerSheet, error) {
+	update := mongo.Pipeline{{{Key: "$set", Value: bson.M{
+		"deadline":      bson.M{"$add": bson.A{"$deadline", minutes * int64(time.Minute/time.Millisecond)}},
+		"extra_minutes": bson.M{"$add": bson.A{bson.M{"$ifNull": bson.A{"$extra_minutes", 0}}, minutes}},
+	}}}}
+
+	var previous models.AnswerSheet
+	err := s.sheets.FindOneAndUpdate(ctx,
+		bson.M{
+			"_id":      id,
+			"status":   bson.M{"$in": bson.A{models.SheetStarted, models.SheetPaused}},
+			"deadline": bson.M{"$ne": nil},
+		},
+		update,
+	).Decode(&previous)
+	if err == mongo.ErrNoDocuments {
+		if _, err := s.GetByID(ctx, id); err != nil {
+			return nil, err
+		}
+		return nil, ErrConflict
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &previous, nil
+}
+
+func (s *mongoAnswerSheetStore) Heartbeat(ctx context.Context, id primitive.ObjectID, beat SheetHeartbeat) (*models.AnswerSheet, error) {
+	filter := bson.M{
+		"_id":    id,
+		"status": bson.M{"$in": bson.A{models.SheetStarted, models.SheetPaused}},
+	}
+	if beat.Data != nil {
+		filter["status"] = models.SheetStarted
+	}
+	if !beat.Takeover {
+		filter["$or"] = bson.A{
+			bson.M{"session": nil},
+			bson.M{"session.id": beat.Session.ID},
+			bson.M{"session.last_seen": bson.M{"$lt": primitive.NewDateTimeFromTime(beat.StaleBefore)}},
+		}
+	}
+	set := bson.M{"session": beat.Session}
+	if beat.Data != nil {
+		set["data"] = beat.Data
+	}
+
+	var previous models.AnswerSheet
+	err := s.sheets.FindOneAndUpdate(ctx, filter, bson.M{"$set": set}).Decode(&previous)
+	if err == mongo.ErrNoDocuments {
+		if _, err := s.GetByID(ctx, id); err != nil {
+			return nil, err
+		}
+		return nil, ErrConflict
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &previous, nil
+}
+
+type mongoSeatingStore struct {
+	maps *mongo.Collection
+}
+
+func (s *mongoSeatingStore) Upsert(ctx context.Context, seating *models.SeatingMap) error {
+	filter := bson.M{"exam_id": seating.ExamID, "slot": seating.Slot, "room": seating.Room}
+	var saved models.SeatingMap
+	err := s.maps.FindOneAndUpdate(ctx, filter,
+		bson.M{
+			"$set": bson.M{
+				"seats":      seating.Seats,
+				"updated_by": seating.UpdatedBy,
+				"updated_at": seating.UpdatedAt,
+			},
+			"$setOnInsert": bson.M{"_id": primitive.NewObjectID()},
+		},
+		options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After),
+	).Decode(&saved)
+	if err != nil {
+		return err
+	}
+	seating.ID = saved.ID
+	return nil
+}
+
+func (s *mongoSeatingStore) Get(ctx context.Context, examID primitive.ObjectID, slot, room string) (*models.SeatingMap, error) {
+	var seating models.SeatingMap
+	if err := findOne(ctx, s.maps, bson.M{"exam_id": examID, "slot": slot, "room": room}, &seating); err != nil {
+		return nil, err
+	}
+	return &seating, nil
+}
+
+func (s *mongoSeatingStore) ListByExam(ctx context.Context, examID primitive.ObjectID) ([]models.SeatingMap, error) {
+	return findAll[models.SeatingMap](ctx, s.maps, bson.M{"exam_id": examID},
+		options.Find().SetSort(bson.D{{Key: "slot", Value: 1}, {Key: "room", Value: 1}}))
+}
+
+type mongoEvaluationStore struct {
+	evaluations *mongo.Collection
+	versions    *mongo.Collection
+}
+
+func evaluationQuery(filter EvaluationFilter) bson.M {
+	query := bson.M{}
+	if filter.ExamID != nil {
+		query["exam_id"] = *filter.ExamID
+	}
+	if filter.Email != "" {
+		query["email"] = filter.Email
+	}
+	if filter.Evaluated != nil {
+		query["evaluated"] = *filter.Evaluated
+	}
+	return query
+}
+
+func (s *mongoEvaluationStore) Create(ctx context.Context, evaluation *models.Evaluation) error {
+	if evaluation.ID.IsZero() {
+		evaluation.ID = primitive.NewObjectID()
+	}
+	_, err := s.evaluations.InsertOne(ctx, evaluation)
+	if mongo.IsDuplicateKeyError(err) {
+		return ErrConflict
+	}
+	return err
+}
+
+func (s *mongoEvaluationStore) GetByID(ctx context.Context, id primitive.ObjectID) (*models.Evaluation, error) {
+	var evaluation models.Evaluation
+	if err := findOne(ctx, s.evaluations, bson.M{"_id": id}, &evaluation); err != nil {
+		return nil, err
+	}
+	return &evaluation, nil
+}
+
+func (s *mongoEvaluationStore) GetByAnswerSheet(ctx context.Context, answerSheetID primitive.ObjectID) (*models.Evaluation, error) {
+	var evaluation models.Evaluation
+	if err := findOne(ctx, s.evaluations, bson.M{"answer_sheet_id": answerSheetID}, &evaluation); err != nil {
+		return nil, err
+	}
+	return &evaluation, nil
+}
+
+func (s *mongoEvaluationStore) List(ctx context.Context, filter EvaluationFilter) ([]models.Evaluation, error) {
+	return findAll[models.Evaluation](ctx, s.evaluations, evaluationQuery(filter))
+}
+
+func (s *mongoEvaluationStore) Count(ctx context.Context, filter EvaluationFilter) (int64, error) {
+	return s.evaluations.CountDocuments(ctx, evaluationQuery(filter))
+}
+
+func (s *mongoEvaluationStore) UpdateGrading(ctx context.Context, id primitive.ObjectID, data []models.EvaluationData, totalMarks int, evaluated bool) error {
+	return updateOne(ctx, s.evaluations, bson.M{"_id": id}, bson.M{
+		"$set": bson.M{
+			"data":        data,
+			"total_marks": totalMarks,
+			"evaluated":   evaluated,
+		},
+	})
+}
+
+func (s *mongoEvaluationStore) SetQuestionMarks(ctx context.Context, id primitive.ObjectID, index, marks int) error {
+	evaluation, err := s.GetByID(ctx, id)
+	if err != nil {
+		return err
+	}
+	if index < 0 || index >= len(evaluation.Data) {
+		return fmt.Errorf("question index %d out of range", index)
+	}
+	// Only apply the change if the marks are still the ones we read
+	key := fmt.Sprintf("data.%d.marks", index)
+	return updateOne(ctx, s.evaluations,
+		bson.M{"_id": id, key: evaluation.Data[index].Marks},
+		bson.M{
+			"$set": bson.M{key: marks},
+			"$inc": bson.M{"total_marks": marks - evaluation.Data[index].Marks},
+		},
+	)
+}
+
+func (s *mongoEvaluationStore) SetAISuggestion(ctx context.Context, id primitive.ObjectID, index int, grading *models.AIGrading) error {
+	prefix := fmt.Sprintf("data.%d.", index)
+	return updateOne(ctx, s.evaluations,
+		bson.M{"_id": id, "evaluated": false, prefix + "question": bson.M{"$exists": true}},
+		bson.M{"$set": bson.M{
+			prefix + "ai_evaluation": grading.Feedback,
+			prefix + "ai_score":      grading.Score,
+			prefix + "ai_grading":    grading,
+		}},
+	)
+}
+
+func (s *mongoEvaluationStore) GradeQuestion(ctx context.Context, id primitive.ObjectID, index, previousMarks, marks int, remarks string) error {
+	prefix := fmt.Sprintf("data.%d.", index)
+	return updateOne(ctx, s.evaluations,
+		bson.M{"_id": id, "evaluated": false, prefix + "marks": previousMarks},
+		bson.M{
+			"$set": bson.M{prefix + "marks": marks, prefix + "remarks": remarks},
+			"$inc": bson.M{"total_marks": marks - previousMarks},
+		},
+	)
+}
+
+func (s *mongoEvaluationStore) IncrementVersion(ctx context.Context, id primitive.ObjectID) (*models.Evaluation, error) {
+	var evaluation models.Evaluation
+	err := s.evaluations.FindOneAndUpdate(ctx,
+		bson.M{"_id": id},
+		bson.M{"$inc": bson.M{"version": 1}},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&evaluation)
+	if err == mongo.ErrNoDocuments {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &evaluation, nil
+}
+
+func (s *mongoEvaluationStore) CreateVersion(ctx context.Context, version *models.EvaluationVersion) error {
+	if version.ID.IsZero() {
+		version.ID = primitive.NewObjectID()
+	}
+	_, err := s.versions.InsertOne(ctx, version)
+	return err
+}
+
+func (s *mongoEvaluationStore) ListVersions(ctx context.Context, evaluationID primitive.ObjectID) ([]models.EvaluationVersion, error) {
+	return findAll[models.EvaluationVersion](ctx, s.versions,
+		bson.M{"evaluation_id": evaluationID},
+		options.Find().SetSort(bson.M{"version": 1}),
+	)
+}
+
+type mongoReEvaluationStore struct {
+	requests *mongo.Collection
+}
+
+func (s *mongoReEvaluationStore) Create(ctx context.Context, request *models.ReEvaluationRequest) error {
+	if request.ID.IsZero() {
+		request.ID = primitive.NewObjectID()
+	}
+	_, err := s.requests.InsertOne(ctx, request)
+	return err
+}
+
+func (s *mongoReEvaluationStore) GetByID(ctx context.Context, id primitive.ObjectID) (*models.ReEvaluationRequest, error) {
+	var request models.ReEvaluationRequest
+	if err := findOne(ctx, s.requests, bson.M{"_id": id}, &request); err != nil {
+		return nil, err
+	}
+	return &request, nil
+}
+
+func (s *mongoReEvaluationStore) HasPending(ctx context.Context, evaluationID primitive.ObjectID, questionIndex int) (bool, error) {
+	count, err := s.requests.CountDocuments(ctx, bson.M{
+		"evaluation_id":  evaluationID,
+		"question_index": questionIndex,
+		"status":         "pending",
+	})
+	return count > 0, err
+}
+
+func (s *mongoReEvaluationStore) List(ctx context.Context, filter ReEvaluationFilter) ([]models.ReEvaluationRequest, error) {
+	query := bson.M{}
+	if filter.Email != "" {
+		query["email"] = filter.Email
+	}
+	if filter.ExamIDs != nil {
+		query["exam_id"] = bson.M{"$in": filter.ExamIDs}
+	}
+	if filter.Status != "" {
+		query["status"] = filter.Status
+	}
+	return findAll[models.ReEvaluationRequest](ctx, s.requests, query, options.Find().SetSort(bson.M{"created_at": 1}))
+}
+
+func (s *mongoReEvaluationStore) Resolve(ctx context.Context, id primitive.ObjectID, resolution ReEvaluationResolution) (bool, error) {
+	set := bson.M{
+		"status":      resolution.Status,
+		"response":    resolution.Response,
+		"resolved_by": resolution.ResolvedBy,
+		"resolved_at": now(),
+	}
+	if resolution.RevisedMarks != nil {
+		set["revised_marks"] = *resolution.RevisedMarks
+	}
+	res, err := s.requests.UpdateOne(ctx, bson.M{"_id": id, "status": "pending"}, bson.M{"$set": set})
+	if err != nil {
+		return false, err
+	}
+	return res.ModifiedCount > 0, nil
+}
+
+func (s *mongoReEvaluationStore) Reopen(ctx context.Context, id primitive.ObjectID) error {
+	return updateOne(ctx, s.requests, bson.M{"_id": id}, bson.M{
+		"$set":   bson.M{"status": "pending"},
+		"$unset": bson.M{"response": "", "resolved_by": "", "resolved_at": "", "revised_marks": ""},
+	})
+}
+
+type mongoAuditStore struct {
+	logs *mongo.Collection
+}
+
+func (s *mongoAuditStore) Insert(ctx context.Context, entry *models.AuditLog) error {
+	if entry.ID.IsZero() {
+		entry.ID = primitive.NewObjectID()
+	}
+	_, err := s.logs.InsertOne(ctx, entry)
+	return err
+}
+
+func (s *mongoAuditStore) List(ctx context.Context, filter AuditFilter) ([]models.AuditLog, error) {
+	query := bson.M{}
+	if filter.ExamID != nil {
+		query["exam_id"] = *filter.ExamID
+	}
+	if filter.TargetID != nil {
+		query["target_id"] = *filter.TargetID
+	}
+	if filter.StudentEmail != "" {
+		query["student_email"] = filter.StudentEmail
+	}
+	if filter.Action != "" {
+		query["action"] = filter.Action
+	}
+
+	opts := options.Find().SetSort(bson.M{"created_at": -1})
+	if filter.Limit > 0 {
+		opts.SetLimit(filter.Limit)
+	}
+	return findAll[models.AuditLog](ctx, s.logs, query, opts)
+}
+
+// finishedJobRetention is how long succeeded and cancelled jobs stay around.
+const finishedJobRetention = 7 * 24 * time.Hour
+
+type mongoJobStore struct {
+	jobs *mongo.Collection
+}
+
+func (s *mongoJobStore) Enqueue(ctx context.Context, job *models.Job) (*models.Job, bool, error) {
+	if job.ID.IsZero() {
+		job.ID = primitive.NewObjectID()
+	}
+	job.ActiveKey = job.UniqueKey
+	_, err := s.jobs.InsertOne(ctx, job)
+	if !mongo.IsDuplicateKeyError(err) {
+		if err != nil {
+			return nil, false, err
+		}
+		return job, true, nil
+	}
+
+	var keys bson.A
+	if job.IdempotencyKey != "" {
+		keys = append(keys, bson.M{"idempotency_key": job.IdempotencyKey})
+	}
+	if job.UniqueKey != "" {
+		keys = append(keys, bson.M{"active_key": job.UniqueKey})
+	}
+	var existing models.Job
+	if len(keys) == 0 {
+		return nil, false, ErrConflict
+	}
+	if err := findOne(ctx, s.jobs, bson.M{"$or": keys}, &existing); err != nil {
+		if err == ErrNotFound {
+			// The other job finished in between
+			return nil, false, ErrConflict
+		}
+		return nil, false, err
+	}
+	return &existing, false, nil
+}
+
+func (s *mongoJobStore) GetByID(ctx context.Context, id primitive.ObjectID) (*models.Job, error) {
+	var job models.Job
+	if err := findOne(ctx, s.jobs, bson.M{"_id": id}, &job); err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+func (s *mongoJobStore) List(ctx context.Context, filter JobFilter) ([]models.Job, error) {
+	query := bson.M{}
+	if filter.Kind != "" {
+		query["kind"] = filter.Kind
+	}
+	if filter.ExamID != nil {
+		query["exam_id"] = *filter.ExamID
+	}
+	if len(filter.Statuses) > 0 {
+		query["status"] = bson.M{"$in": filter.Statuses}
+	}
+	if filter.CreatedBy != "" {
+		query["created_by"] = filter.CreatedBy
+	}
+
+	opts := options.Find().SetSort(bson.M{"created_at": -1})
+	if filter.Limit > 0 {
+		opts.SetLimit(filter.Limit)
+	}
+	return findAll[models.Job](ctx, s.jobs, query, opts)
+}
+
+func (s *mongoJobStore) Lease(ctx context.Context, owner string, kinds []string, until time.Time) (*models.Job, error) {
+	t := now()
+	var job models.Job
+	err := s.jobs.FindOneAndUpdate(ctx,
+		bson.M{
+			"kind": bson.M{"$in": kinds},
+			"$or": bson.A{
+				bson.M{"status": models.JobQueued, "run_at": bson.M{"$lte": t}},
+				bson.M{"status": models.JobRunning, "lease_until": bson.M{"$lt": t}},
+			},
+		},
+		bson.M{
+			"$set": bson.M{
+				"status":      models.JobRunning,
+				"lease_owner": owner,
+				"lease_until": primitive.NewDateTimeFromTime(until),
+				"started_at":  t,
+				"updated_at":  t,
+			},
+			"$inc": bson.M{"attempts": 1},
+		},
+		options.FindOneAndUpdate().SetSort(bson.M{"run_at": 1}).SetReturnDocument(options.After),
+	).Decode(&job)
+	if err == mongo.ErrNoDocuments {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+func (s *mongoJobStore) Heartbeat(ctx context.Context, id primitive.ObjectID, owner string, until time.Time, progress models.JobProgress) (*models.Job, error) {
+	var job models.Job
+	err := s.jobs.FindOneAndUpdate(ctx,
+		bson.M{"_id": id, "status": models.JobRunning, "lease_owner": owner},
+		bson.M{"$set": bson.M{
+			"lease_until": primitive.NewDateTimeFromTime(until),
+			"progress":    progress,
+			"updated_at":  now(),
+		}},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&job)
+	if err == mongo.ErrNoDocuments {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+func (s *mongoJobStore) Finish(ctx context.Context, id primitive.ObjectID, owner string, outcome JobOutcome) error {
+	t := now()
+	set := bson.M{"status": outcome.Status, "progress": outcome.Progress, "updated_at": t}
+	unset := bson.M{"lease_owner": "", "lease_until": ""}
+	update := bson.M{}
+	if outcome.Error != "" {
+		set["last_error"] = outcome.Error
+	}
+	if outcome.Result != nil {
+		set["result"] = outcome.Result
+	}
+	if outcome.Status == models.JobQueued {
+		set["run_at"] = primitive.NewDateTimeFromTime(outcome.RunAt)
+		if outcome.Refund {
+			update["$inc"] = bson.M{"attempts": -1}
+		}
+	} else {
+		set["finished_at"] = t
+		unset["active_key"] = ""
+		if outcome.Status != models.JobDead {
+			set["expire_at"] = primitive.NewDateTimeFromTime(time.Now().Add(finishedJobRetention))
+		}
+	}
+	update["$set"] = set
+	update["$unset"] = unset
+
+	return updateOne(ctx, s.jobs, bson.M{"_id": id, "status": models.JobRunning, "lease_owner": owner}, update)
+}
+
+func (s *mongoJobStore) Cancel(ctx context.Context, id primitive.ObjectID) (*models.Job, error) {
+	t := now()
+	var job models.Job
+	err := s.jobs.FindOneAndUpdate(ctx,
+		bson.M{"_id": id, "status": models.JobQueued},
+		bson.M{
+			"$set": bson.M{
+				"status":      models.JobCancelled,
+				"finished_at": t,
+				"updated_at":  t,
+				"expire_at":   primitive.NewDateTimeFromTime(time.Now().Add(finishedJobRetention)),
+			},
+			"$unset": bson.M{"active_key": ""},
+		},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&job)
+	if err != mongo.ErrNoDocuments {
+		if err != nil {
+			return nil, err
+		}
+		return &job, nil
+	}
+
+	err = s.jobs.FindOneAndUpdate(ctx,
+		bson.M{"_id": id, "status": models.JobRunning},
+		bson.M{"$set": bson.M{"cancel_requested": true, "updated_at": t}},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&job)
+	if err == mongo.ErrNoDocuments {
+		if _, err := s.GetByID(ctx, id); err != nil {
+			return nil, err
+		}
+		return nil, ErrConflict
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+func (s *mongoJobStore) Requeue(ctx context.Context, id primitive.ObjectID) error {
+	job, err := s.GetByID(ctx, id)
+	if err != nil {
+		return err
+	}
+	if job.Status != models.JobDead && job.Status != models.JobCancelled {
+		return ErrConflict
+	}
+
+	t := now()
+	set := bson.M{
+		"status":           models.JobQueued,
+		"attempts":         0,
+		"run_at":           t,
+		"cancel_requested": false,
+		"progress":         models.JobProgress{},
+		"updated_at":       t,
+	}
+	if job.UniqueKey != "" {
+		set["active_key"] = job.UniqueKey
+	}
+	err = updateOne(ctx, s.jobs,
+		bson.M{"_id": id, "status": job.Status},
+		bson.M{"$set": set, "$unset": bson.M{"finished_at": "", "expire_at": ""}},
+	)
+	if mongo.IsDuplicateKeyError(err) || err == ErrNotFound {
+		return ErrConflict
+	}
+	return err
+}
+
+type mongoQuestionBankStore struct {
+	questions *mongo.Collection
+}
+
+func (s *mongoQuestionBankStore) Insert(ctx context.Context, questions []models.BankQuestion) error {
+	docs := make([]interface{}, len(questions))
+	for i := range questions {
+		if questions[i].ID.IsZero() {
+			questions[i].ID = primitive.NewObjectID()
+		}
+		docs[i] = questions[i]
+	}
+	_, err := s.questions.InsertMany(ctx, docs)
+	return err
+}
+
+func (s *mongoQuestionBankStore) List(ctx context.Context, filter QuestionBankFilter) ([]models.BankQuestion, error) {
+	query := bson.M{"container_id": filter.ContainerID}
+	if filter.Topic != "" {
+		query["topic"] = primitive.Regex{Pattern: regexp.QuoteMeta(filter.Topic), Options: "i"}
+	}
+	if filter.Level != "" {
+		query["level"] = filter.Level
+	}
+	if filter.Type != "" {
+		query["types"] = filter.Type
+	}
+	return findAll[models.BankQuestion](ctx, s.questions, query, options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}, {Key: "_id", Value: -1}}))
+}
+
+func (s *mongoQuestionBankStore) Delete(ctx context.Context, containerID, id primitive.ObjectID) error {
+	res, err := s.questions.DeleteOne(ctx, bson.M{"_id": id, "container_id": containerID})
+	if err != nil {
+		return err
+	}
+	if res.DeletedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+type mongoLLMUsageStore struct {
+	calls *mongo.Collection
+}
+
+func (s *mongoLLMUsageStore) Record(ctx context.Context, call *models.LLMCall) error {
+	if call.ID.IsZero() {
+		call.ID = primitive.NewObjectID()
+	}
+	_, err := s.calls.InsertOne(ctx, call)
+	return err
+}
+
+func (s *mongoLLMUsageStore) Totals(ctx context.Context, filter LLMUsageFilter, group UsageGroup) ([]LLMUsageTotals, error) {
+	match := bson.M{}
+	if filter.UserEmail != "" {
+		match["user_email"] = filter.UserEmail
+	}
+	if filter.ExamID != nil {
+		match["exam_id"] = *filter.ExamID
+	}
+	if filter.Purpose != "" {
+		match["purpose"] = filter.Purpose
+	}
+	created := bson.M{}
+	if !filter.Since.IsZero() {
+		created["$gte"] = primitive.NewDateTimeFromTime(filter.Since)
+	}
+	if !filter.Until.IsZero() {
+		created["$lt"] = primitive.NewDateTimeFromTime(filter.Until)
+	}
+	if len(created) > 0 {
+		match["created_at"] = created
+	}
+
+	var key interface{}
+	switch group {
+	case UsageTotal:
+		key = ""
+	case UsageByUser:
+		key = "$user_email"
+	case UsageByExam:
+		key = bson.M{"$ifNull": bson.A{bson.M{"$toString": "$exam_id"}, ""}}
+	case UsageByPurpose:
+		key = "$purpose"
+	case UsageByDay:
+		key = bson.M{"$dateToString": bson.M{"format": "%Y-%m-%d", "date": "$created_at", "timezone": "UTC"}}
+	default:
+		return nil, fmt.Errorf("store: unknown usage group %q", group)
+	}
+
+	cursor, err := s.calls.Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$match", Value: match}},
+		{{Key: "$group", Value: bson.M{
+			"_id":             key,
+			"calls":           bson.M{"$sum": 1},
+			"errors":          bson.M{"$sum": bson.M{"$cond": bson.A{bson.M{"$eq": bson.A{"$status", models.LLMCallOK}}, 0, 1}}},
+			"prompt_tokens":   bson.M{"$sum": "$prompt_tokens"},
+			"response_tokens": bson.M{"$sum": "$response_tokens"},
+			"total_tokens":    bson.M{"$sum": "$total_tokens"},
+			"latency_ms":      bson.M{"$sum": "$latency_ms"},
+		}}},
+		{{Key: "$sort", Value: bson.D{{Key: "total_tokens", Value: -1}, {Key: "_id", Value: 1}}}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	totals := []LLMUsageTotals{}
+	if err := cursor.All(ctx, &totals); err != nil {
+		return nil, err
+	}
+	return totals, nil
+}
+
+type mongoPromptTemplateStore struct {
+	templates *mongo.Collection
+}
+
+func (s *mongoPromptTemplateStore) Create(ctx context.Context, t *models.PromptTemplate) error {
+	latest, err := findAll[models.PromptTemplate](ctx, s.templates, bson.M{"name": t.Name},
+		options.Find().SetSort(bson.D{{Key: "version", Value: -1}}).SetLimit(1))
+	if err != nil {
+		return err
+	}
+	t.Version = 1
+	if len(latest) > 0 {
+		t.Version = latest[0].Version + 1
+	}
+	if t.ID.IsZero() {
+		t.ID = primitive.NewObjectID()
+	}
+	_, err = s.templates.InsertOne(ctx, t)
+	if mongo.IsDuplicateKeyError(err) {
+		return ErrConflict
+	}
+	return err
+}
+
+func (s *mongoPromptTemplateStore) Get(ctx context.Context, name string, version int) (*models.PromptTemplate, error) {
+	var t models.PromptTemplate
+	if err := findOne(ctx, s.templates, bson.M{"name": name, "version": version}, &t); err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+func (s *mongoPromptTemplateStore) Latest(ctx context.Context) ([]models.PromptTemplate, error) {
+	cursor, err := s.templates.Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$sort", Value: bson.D{{Key: "name", Value: 1}, {Key: "version", Value: -1}}}},
+		{{Key: "$group", Value: bson.M{"_id": "$name", "latest": bson.M{"$first": "$$ROOT"}}}},
+		{{Key: "$replaceRoot", Value: bson.M{"newRoot": "$latest"}}},
+		{{Key: "$sort", Value: bson.D{{Key: "name", Value: 1}}}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	templates := []models.PromptTemplate{}
+	if err := cursor.All(ctx, &templates); err != nil {
+		return nil, err
+	}
+	return templates, nil
+}
+
+func (s *mongoPromptTemplateStore) Versions(ctx context.Context, name string) ([]models.PromptTemplate, error) {
+	return findAll[models.PromptTemplate](ctx, s.templates, bson.M{"name": name}, options.Find().SetSort(bson.D{{Key: "version", Value: -1}}))
+}
+
+type mongoAIGradingCacheStore struct {
+	entries *mongo.Collection
+}
+
+func (s *mongoAIGradingCacheStore) Get(ctx context.Context, key string) (*models.AIGradingCacheEntry, error) {
+	// The TTL monitor only runs every minute or so
+	var entry models.AIGradingCacheEntry
+	if err := findOne(ctx, s.entries, bson.M{"_id": key, "expires_at": bson.M{"$gt": now()}}, &entry); err != nil {
+		return nil, err
+	}
+	return &entry, nil
+}
+
+func (s *mongoAIGradingCacheStore) Put(ctx context.Context, entry *models.AIGradingCacheEntry) error {
+	_, err := s.entries.ReplaceOne(ctx, bson.M{"_id": entry.Key}, entry, options.Replace().SetUpsert(true))
+	return err
+}
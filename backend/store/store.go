@@ -0,0 +1,362 @@
+// Package store hides how Examify persists its data. Controllers only talk to
+// the interfaces below; NewMongoStores backs them with MongoDB and
+// NewMemoryStores keeps everything in memory for offline tests.
+package store
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/Maheshkarri4444/Examify/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// ErrNotFound is returned when the requested document does not exist.
+var ErrNotFound = errors.New("not found")
+
+// ErrConflict is returned when a write would break a uniqueness rule.
+var ErrConflict = errors.New("conflict")
+
+type UserFilter struct {
+	Role  string
+	Query string // case insensitive match on name or email
+	Skip  int64
+	Limit int64
+}
+
+type UserStore interface {
+	Create(ctx context.Context, user *models.User) error
+	GetByID(ctx context.Context, id primitive.ObjectID) (*models.User, error)
+	GetByEmail(ctx context.Context, email string) (*models.User, error)
+	UpdateProfile(ctx context.Context, id primitive.ObjectID, image string) error
+	SetRole(ctx context.Context, id primitive.ObjectID, role string) error
+	List(ctx context.Context, filter UserFilter) ([]models.User, int64, error)
+	CountByRole(ctx context.Context, role string) (int64, error)
+}
+
+type ContainerStore interface {
+	EnsureStudentContainer(ctx context.Context, id primitive.ObjectID) error
+	EnsureTeacherContainer(ctx context.Context, id primitive.ObjectID) error
+	GetStudentContainer(ctx context.Context, id primitive.ObjectID) (*models.StudentContainer, error)
+	GetTeacherContainer(ctx context.Context, id primitive.ObjectID) (*models.TeacherContainer, error)
+	FindTeacherContainerByExam(ctx context.Context, examID primitive.ObjectID) (*models.TeacherContainer, error)
+	TeacherOwnsExam(ctx context.Context, containerID, examID primitive.ObjectID) (bool, error)
+	AddExamToTeacher(ctx context.Context, containerID, examID primitive.ObjectID, evaluationIDs []primitive.ObjectID) error
+	RemoveExamFromTeacher(ctx context.Context, containerID, examID primitive.ObjectID) error
+	AddEvaluationToExam(ctx context.Context, examID, evaluationID primitive.ObjectID) error
+}
+
+type ExamStore interface {
+	Create(ctx context.Context, exam *models.Exam) error
+	GetByID(ctx context.Context, id primitive.ObjectID) (*models.Exam, error)
+	// Update overwrites the teacher editable fields of an exam.
+	Update(ctx context.Context, exam *models.Exam) error
+	List(ctx context.Context) ([]models.Exam, error)
+	ListByIDs(ctx context.Context, ids []primitive.ObjectID) ([]models.Exam, error)
+	// ListAvailableBetween returns exams with an available date in [start, end).
+	ListAvailableBetween(ctx context.Context, start, end time.Time) ([]models.Exam, error)
+	// SetSets replaces the question paper sets and restarts the distribution.
+	SetSets(ctx context.Context, id primitive.ObjectID, sets []primitive.ObjectID) error
+	// NextSetCounter atomically increments the exam's SetCounter and returns
+	// the new value. Exams created before the counter existed start from
+	// their number of answer sheets.
+	NextSetCounter(ctx context.Context, id primitive.ObjectID) (int64, error)
+	// AddQuestions appends questions to the exam's question pool.
+	AddQuestions(ctx context.Context, id primitive.ObjectID, questions []models.Question) error
+	// SetAICalibration replaces the exam's calibration, nil removes it.
+	SetAICalibration(ctx context.Context, id primitive.ObjectID, calibration *models.AICalibration) error
+	SetOpen(ctx context.Context, id primitive.ObjectID, open bool) error
+	SetPaused(ctx context.Context, id primitive.ObjectID, paused bool) error
+	Count(ctx context.Context) (int64, error)
+
+	CreateQuestionPaper(ctx context.Context, paper *models.QuestionPaper) error
+	GetQuestionPaper(ctx context.Context, id primitive.ObjectID) (*models.QuestionPaper, error)
+	// SetPaperQuestions replaces the questions of a question paper.
+	SetPaperQuestions(ctx context.Context, id primitive.ObjectID, questions []models.Question) error
+}
+
+type AnswerSheetFilter struct {
+	ExamID    *primitive.ObjectID
+	Statuses  []models.SheetStatus // any of
+	Submitted *bool
+}
+
+// SheetChange moves an answer sheet to another state.
+type SheetChange struct {
+	To     models.SheetStatus
+	By     string
+	Reason string
+	// Data and AIScore replace the stored answers when Data is not nil.
+	Data    []models.AnswerData
+	AIScore *float64
+	// When StaleBefore is set the change is only made for SessionID: the
+	// sheet must have no session, be held by SessionID, or have been last
+	// seen before StaleBefore, as with SheetHeartbeat.
+	SessionID   string
+	StaleBefore time.Time
+}
+
+// SheetHeartbeat keeps a session's hold on a running answer sheet.
+type SheetHeartbeat struct {
+	Session models.SheetSession
+	// Another session can take the sheet over once it was last seen before
+	// StaleBefore, or at any time with Takeover.
+	StaleBefore time.Time
+	Takeover    bool
+	// Data autosaves the answers when not nil.
+	Data []models.AnswerData
+}
+
+// PickSet chooses the question paper of a sheet being assigned. nearby are
+// the sheets of other students seated in the same slot and room within one
+// row of it. It may run more than once and must not use the stores.
+type PickSet func(sheet *models.AnswerSheet, nearby []models.AnswerSheet) error
+
+type AnswerSheetStore interface {
+	Create(ctx context.Context, sheet *models.AnswerSheet) error
+	// Assign stores a new sheet for a student, links it to the exam and to
+	// the student's container in one transaction. The sheet's paper is
+	// chosen by pick, if not nil, inside that transaction. A student gets
+	// one sheet per exam: if one already exists it is returned with false
+	// and nothing is written. ErrConflict means the sheet's seat is taken.
+	Assign(ctx context.Context, sheet *models.AnswerSheet, containerID primitive.ObjectID, pick PickSet) (*models.AnswerSheet, bool, error)
+	GetByID(ctx context.Context, id primitive.ObjectID) (*models.AnswerSheet, error)
+	GetByExamAndEmail(ctx context.Context, examID primitive.ObjectID, email string) (*models.AnswerSheet, error)
+	List(ctx context.Context, filter AnswerSheetFilter) ([]models.AnswerSheet, error)
+	Count(ctx context.Context, filter AnswerSheetFilter) (int64, error)
+	// Transition applies the change only if the sheet's current state allows
+	// it, updates its timing (see AnswerSheet.TimingAfter), appends it to the
+	// sheet's history and returns the sheet as it was before. ErrConflict
+	// means the transition is not allowed from the current state, including
+	// when another request changed the state or deadline first, or that
+	// another session holds the sheet.
+	Transition(ctx context.Context, id primitive.ObjectID, change SheetChange) (*models.AnswerSheet, error)
+	// Extend moves the deadline of a timed started or paused sheet back by
+	// the given minutes and returns the sheet as it was before. ErrConflict
+	// means the sheet is closed or untimed.
+	Extend(ctx context.Context, id primitive.ObjectID, minutes int64) (*models.AnswerSheet, error)
+	// Heartbeat records the session on a started or paused sheet and returns
+	// the sheet as it was before. A heartbeat with Data needs the sheet
+	// started. ErrConflict means the sheet is not in the status the
+	// heartbeat needs or another session still holds it.
+	Heartbeat(ctx context.Context, id primitive.ObjectID, beat SheetHeartbeat) (*models.AnswerSheet, error)
+}
+
+type SeatingStore interface {
+	// Upsert replaces the map of a room for an exam slot.
+	Upsert(ctx context.Context, seating *models.SeatingMap) error
+	Get(ctx context.Context, examID primitive.ObjectID, slot, room string) (*models.SeatingMap, error)
+	ListByExam(ctx context.Context, examID primitive.ObjectID) ([]models.SeatingMap, error)
+}
+
+type EvaluationFilter struct {
+	ExamID    *primitive.ObjectID
+	Email     string
+	Evaluated *bool
+}
+
+type EvaluationStore interface {
+	// Create returns ErrConflict for a second viva of the same student in
+	// an exam.
+	Create(ctx context.Context, evaluation *models.Evaluation) error
+	GetByID(ctx context.Context, id primitive.ObjectID) (*models.Evaluation, error)
+	GetByAnswerSheet(ctx context.Context, answerSheetID primitive.ObjectID) (*models.Evaluation, error)
+	List(ctx context.Context, filter EvaluationFilter) ([]models.Evaluation, error)
+	Count(ctx context.Context, filter EvaluationFilter) (int64, error)
+	UpdateGrading(ctx context.Context, id primitive.ObjectID, data []models.EvaluationData, totalMarks int, evaluated bool) error
+	// SetQuestionMarks changes the marks of one question and moves the
+	// total by the same amount.
+	SetQuestionMarks(ctx context.Context, id primitive.ObjectID, index, marks int) error
+	// SetAISuggestion stores the AI's grading of one question of an
+	// evaluation that is not finalized yet. ErrNotFound means there is no
+	// such unfinalized evaluation.
+	SetAISuggestion(ctx context.Context, id primitive.ObjectID, index int, grading *models.AIGrading) error
+	// GradeQuestion is SetQuestionMarks that also replaces the remarks, for
+	// an evaluation that is not finalized yet and whose question still has
+	// previousMarks. ErrNotFound means there is no such evaluation.
+	GradeQuestion(ctx context.Context, id primitive.ObjectID, index, previousMarks, marks int, remarks string) error
+	// IncrementVersion bumps the version and returns the updated evaluation.
+	IncrementVersion(ctx context.Context, id primitive.ObjectID) (*models.Evaluation, error)
+
+	CreateVersion(ctx context.Context, version *models.EvaluationVersion) error
+	ListVersions(ctx context.Context, evaluationID primitive.ObjectID) ([]models.EvaluationVersion, error)
+}
+
+type ReEvaluationFilter struct {
+	Email   string
+	ExamIDs []primitive.ObjectID
+	Status  string
+}
+
+// ReEvaluationResolution is what a teacher decides on a pending request.
+type ReEvaluationResolution struct {
+	Status       string
+	RevisedMarks *int
+	Response     string
+	ResolvedBy   string
+}
+
+type ReEvaluationStore interface {
+	Create(ctx context.Context, request *models.ReEvaluationRequest) error
+	GetByID(ctx context.Context, id primitive.ObjectID) (*models.ReEvaluationRequest, error)
+	HasPending(ctx context.Context, evaluationID primitive.ObjectID, questionIndex int) (bool, error)
+	List(ctx context.Context, filter ReEvaluationFilter) ([]models.ReEvaluationRequest, error)
+	// Resolve moves a pending request to its final status. It reports false
+	// when the request was no longer pending.
+	Resolve(ctx context.Context, id primitive.ObjectID, resolution ReEvaluationResolution) (bool, error)
+	// Reopen moves a resolved request back to pending, for when applying
+	// the resolution failed.
+	Reopen(ctx context.Context, id primitive.ObjectID) error
+}
+
+type QuestionBankFilter struct {
+	ContainerID primitive.ObjectID
+	Topic       string // case insensitive match on the topic
+	Level       string
+	Type        string
+}
+
+// QuestionBankStore keeps the questions teachers reuse across exams.
+type QuestionBankStore interface {
+	Insert(ctx context.Context, questions []models.BankQuestion) error
+	// List returns the newest questions first.
+	List(ctx context.Context, filter QuestionBankFilter) ([]models.BankQuestion, error)
+	// Delete removes a question of the container's bank.
+	Delete(ctx context.Context, containerID, id primitive.ObjectID) error
+}
+
+type PromptTemplateStore interface {
+	// Create stores t as the next version of its name. It returns
+	// ErrConflict when another version was saved at the same time.
+	Create(ctx context.Context, t *models.PromptTemplate) error
+	Get(ctx context.Context, name string, version int) (*models.PromptTemplate, error)
+	// Latest lists the newest version of every template, by name.
+	Latest(ctx context.Context) ([]models.PromptTemplate, error)
+	// Versions lists every version of a template, newest first.
+	Versions(ctx context.Context, name string) ([]models.PromptTemplate, error)
+}
+
+// AIGradingCacheStore keeps gradings by content hash. Expired entries are
+// never returned.
+type AIGradingCacheStore interface {
+	Get(ctx context.Context, key string) (*models.AIGradingCacheEntry, error)
+	// Put stores the entry, replacing one with the same key.
+	Put(ctx context.Context, entry *models.AIGradingCacheEntry) error
+}
+
+type LLMUsageFilter struct {
+	UserEmail string
+	ExamID    *primitive.ObjectID
+	Purpose   string
+	Since     time.Time // zero for no lower bound
+	Until     time.Time // exclusive, zero for no upper bound
+}
+
+// UsageGroup is what LLM usage totals are broken down by.
+type UsageGroup string
+
+const (
+	UsageTotal     UsageGroup = ""
+	UsageByUser    UsageGroup = "user"
+	UsageByExam    UsageGroup = "exam"
+	UsageByPurpose UsageGroup = "purpose"
+	UsageByDay     UsageGroup = "day"
+)
+
+// LLMUsageTotals sums the calls of one group. Key is the user's email, the
+// exam ID in hex, the purpose or the UTC day as YYYY-MM-DD, and empty for
+// UsageTotal.
+type LLMUsageTotals struct {
+	Key            string `bson:"_id" json:"key"`
+	Calls          int64  `bson:"calls" json:"calls"`
+	Errors         int64  `bson:"errors" json:"errors"`
+	PromptTokens   int64  `bson:"prompt_tokens" json:"prompt_tokens"`
+	ResponseTokens int64  `bson:"response_tokens" json:"response_tokens"`
+	TotalTokens    int64  `bson:"total_tokens" json:"total_tokens"`
+	LatencyMS      int64  `bson:"latency_ms" json:"latency_ms"` // summed
+}
+
+// LLMUsageStore is append-only.
+type LLMUsageStore interface {
+	Record(ctx context.Context, call *models.LLMCall) error
+	// Totals sums the matching calls by group, largest token use first.
+	Totals(ctx context.Context, filter LLMUsageFilter, group UsageGroup) ([]LLMUsageTotals, error)
+}
+
+type AuditFilter struct {
+	ExamID       *primitive.ObjectID
+	TargetID     *primitive.ObjectID
+	StudentEmail string
+	Action       string
+	Limit        int64
+}
+
+// AuditStore is append-only.
+type AuditStore interface {
+	Insert(ctx context.Context, entry *models.AuditLog) error
+	List(ctx context.Context, filter AuditFilter) ([]models.AuditLog, error)
+}
+
+type JobFilter struct {
+	Kind      string
+	ExamID    *primitive.ObjectID
+	Statuses  []models.JobStatus
+	CreatedBy string
+	Limit     int64
+}
+
+// JobOutcome is how a worker leaves a leased job. Status JobQueued puts it
+// back in the queue to run again at RunAt; Refund does so without counting
+// the attempt, for jobs interrupted by a shutdown.
+type JobOutcome struct {
+	Status   models.JobStatus
+	RunAt    time.Time
+	Refund   bool
+	Error    string
+	Result   map[string]interface{}
+	Progress models.JobProgress
+}
+
+type JobStore interface {
+	// Enqueue inserts a queued job. When a job with the same idempotency
+	// key, or an unfinished job with the same unique key, already exists
+	// that job is returned with false instead.
+	Enqueue(ctx context.Context, job *models.Job) (*models.Job, bool, error)
+	GetByID(ctx context.Context, id primitive.ObjectID) (*models.Job, error)
+	// List returns the newest jobs first.
+	List(ctx context.Context, filter JobFilter) ([]models.Job, error)
+	// Lease hands the next due job of one of kinds to owner until until and
+	// counts an attempt. Running jobs whose lease ran out are handed out
+	// again. ErrNotFound means nothing is due.
+	Lease(ctx context.Context, owner string, kinds []string, until time.Time) (*models.Job, error)
+	// Heartbeat extends the lease of a running job and saves its progress.
+	// ErrNotFound means owner no longer holds the lease.
+	Heartbeat(ctx context.Context, id primitive.ObjectID, owner string, until time.Time, progress models.JobProgress) (*models.Job, error)
+	// Finish ends a lease. ErrNotFound means owner no longer held it.
+	Finish(ctx context.Context, id primitive.ObjectID, owner string, outcome JobOutcome) error
+	// Cancel cancels a queued job at once and asks the worker of a running
+	// one to stop. ErrConflict means the job is already finished.
+	Cancel(ctx context.Context, id primitive.ObjectID) (*models.Job, error)
+	// Requeue gives a dead or cancelled job a fresh set of attempts.
+	// ErrConflict means the job is in another status or an unfinished job
+	// with its unique key exists.
+	Requeue(ctx context.Context, id primitive.ObjectID) error
+}
+
+// Stores groups every store the HTTP handlers need.
+type Stores struct {
+	Users         UserStore
+	Containers    ContainerStore
+	Exams         ExamStore
+	AnswerSheets  AnswerSheetStore
+	Seating       SeatingStore
+	Evaluations   EvaluationStore
+	ReEvaluations ReEvaluationStore
+	Audit         AuditStore
+	Jobs          JobStore
+	QuestionBank  QuestionBankStore
+	LLMUsage      LLMUsageStore
+	Prompts       PromptTemplateStore
+	AICache       AIGradingCacheStore
+}
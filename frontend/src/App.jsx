@@ -17,6 +17,7 @@ import StudentResults from './components/student/StudentResults';
 import ExamEntryPoint from './components/student/ExamEntryPoint';
 import ExamSession from './components/student/ExamSession';
 import ViewEvaluation from './components/ViewEvaluation';
+import ExamMonitor from './components/ExamMonitor';
 import PreventBack from './pages/PreventBack';
 
 function App() {
@@ -31,6 +32,7 @@ function App() {
             <Route index element={<Navigate to="exams" replace />} />
             <Route path="exams" element={<ExamsList />} />
             <Route path="exams/:id" element={<ExamDetails />} />
+            <Route path="exams/:id/monitor" element={<ExamMonitor />} />
             <Route path="exams/create" element={<CreateExam />} />
             <Route path="evaluations" element={<TeacherEvaluations />} />
             <Route path="evaluations/:examId" element={<AnswerSheetsList />} />
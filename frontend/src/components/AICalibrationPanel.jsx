@@ -0,0 +1,154 @@
+import React, { useState } from 'react';
+import { toast } from 'react-hot-toast';
+import Allapi from '../utils/common';
+
+const formatStats = (stats) => {
+  if (!stats || stats.count === 0) {
+    return 'no data yet';
+  }
+  const correlation = stats.correlation !== undefined ? `, r ${stats.correlation}` : '';
+  return `${stats.count} graded, MAE ${stats.mean_absolute_error}, bias ${stats.bias > 0 ? '+' : ''}${stats.bias}${correlation}`;
+};
+
+function AICalibrationPanel({ examId }) {
+  const [open, setOpen] = useState(false);
+  const [report, setReport] = useState(null);
+  const [scale, setScale] = useState(1);
+  const [offset, setOffset] = useState(0);
+  const [saving, setSaving] = useState(false);
+
+  const fetchReport = async () => {
+    try {
+      const response = await fetch(Allapi.getAICalibration.url(examId), {
+        headers: {
+          'Authorization': `${localStorage.getItem('token')}`
+        }
+      });
+      const data = await response.json();
+      if (response.ok) {
+        setReport(data);
+        setScale(data.calibration?.scale ?? 1);
+        setOffset(data.calibration?.offset ?? 0);
+      } else {
+        toast.error(data.error || 'Failed to load the calibration report');
+      }
+    } catch (error) {
+      toast.error('Error loading the calibration report');
+      console.error('Error fetching calibration report:', error);
+    }
+  };
+
+  const handleToggle = () => {
+    if (!open && !report) {
+      fetchReport();
+    }
+    setOpen(!open);
+  };
+
+  const handleSave = async (newScale, newOffset) => {
+    setSaving(true);
+    try {
+      const response = await fetch(Allapi.setAICalibration.url(examId), {
+        method: Allapi.setAICalibration.method,
+        headers: {
+          'Content-Type': 'application/json',
+          'Authorization': `${localStorage.getItem('token')}`
+        },
+        body: JSON.stringify({ scale: parseFloat(newScale), offset: parseFloat(newOffset) || 0 })
+      });
+      const data = await response.json();
+      if (response.ok) {
+        toast.success('Calibration saved, it applies to new AI suggestions');
+        fetchReport();
+      } else {
+        toast.error(data.error || 'Failed to save calibration');
+      }
+    } catch (error) {
+      toast.error('Error saving calibration');
+      console.error('Error saving calibration:', error);
+    } finally {
+      setSaving(false);
+    }
+  };
+
+  return (
+    <div className="p-4 space-y-4 bg-gray-800 rounded-lg">
+      <button onClick={handleToggle} className="text-sm font-medium text-purple-400 hover:text-purple-300">
+        {open ? 'Hide AI calibration' : 'AI calibration'}
+      </button>
+
+      {open && report && (
+        <div className="space-y-4 text-sm text-gray-300">
+          <p>Compared on {report.evaluations} finalized evaluations.</p>
+          <p>Whole sheets: {formatStats(report.sheets)}</p>
+          <p>Questions: {formatStats(report.questions)}</p>
+
+          {report.by_type.length > 0 && (
+            <div>
+              <p className="font-medium text-white">By answer type</p>
+              {report.by_type.map(t => (
+                <p key={t.type}>{t.type}: {formatStats(t)}</p>
+              ))}
+            </div>
+          )}
+
+          {report.by_question.length > 0 && (
+            <div>
+              <p className="font-medium text-white">By question, largest error first</p>
+              {report.by_question.slice(0, 10).map(q => (
+                <p key={q.question} className="truncate">{q.question}: {formatStats(q)}</p>
+              ))}
+            </div>
+          )}
+
+          <div className="flex items-end space-x-4">
+            <div>
+              <label className="block text-gray-400">Scale</label>
+              <input
+                type="number"
+                step="0.05"
+                min="0.05"
+                max="5"
+                value={scale}
+                onChange={(e) => setScale(e.target.value)}
+                className="w-24 px-3 py-2 text-white bg-gray-700 border-2 border-gray-600 rounded-lg focus:border-blue-500 focus:outline-none"
+              />
+            </div>
+            <div>
+              <label className="block text-gray-400">Offset</label>
+              <input
+                type="number"
+                step="1"
+                min="-100"
+                max="100"
+                value={offset}
+                onChange={(e) => setOffset(e.target.value)}
+                className="w-24 px-3 py-2 text-white bg-gray-700 border-2 border-gray-600 rounded-lg focus:border-blue-500 focus:outline-none"
+              />
+            </div>
+            <button
+              onClick={() => handleSave(scale, offset)}
+              disabled={saving}
+              className="px-4 py-2 text-white bg-purple-600 rounded-md hover:bg-purple-700 disabled:opacity-50"
+            >
+              Save
+            </button>
+            {report.suggested_calibration && (
+              <button
+                onClick={() => {
+                  setScale(report.suggested_calibration.scale);
+                  setOffset(report.suggested_calibration.offset);
+                }}
+                className="px-4 py-2 text-purple-400 rounded-md bg-purple-500/20 hover:bg-purple-500/30"
+              >
+                Use suggested ({report.suggested_calibration.scale} x + {report.suggested_calibration.offset})
+              </button>
+            )}
+          </div>
+        </div>
+      )}
+    </div>
+  );
+}
+
+export default AICalibrationPanel;
@@ -1,8 +1,9 @@
 import React, { useState, useEffect } from 'react';
 import { useParams, useNavigate } from 'react-router-dom';
-import { ArrowLeft, FileText, User, Mail, CheckCircle, Clock, AlertTriangle, ChevronRight } from 'lucide-react';
+import { ArrowLeft, FileText, User, Mail, CheckCircle, Clock, AlertTriangle, ChevronRight, Sparkles, XCircle } from 'lucide-react';
 import { Toaster, toast } from 'react-hot-toast';
 import Allapi from '../utils/common';
+import AICalibrationPanel from './AICalibrationPanel';
 
 function AnswerSheetsList() {
   const { examId } = useParams();
@@ -11,6 +12,7 @@ function AnswerSheetsList() {
   const [exam, setExam] = useState(null);
   const [answerSheets, setAnswerSheets] = useState([]);
   const [searchTerm, setSearchTerm] = useState('');
+  const [aiJob, setAiJob] = useState(null);
 
   useEffect(() => {
     const fetchExamDetails = async () => {
@@ -56,6 +58,73 @@ function AnswerSheetsList() {
     fetchExamDetails();
   }, [examId]);
 
+  const fetchAIJob = async () => {
+    const response = await fetch(Allapi.getAIPreEvaluation.url(examId), {
+      headers: {
+        'Authorization': `${localStorage.getItem('token')}`
+      }
+    });
+    if (response.ok) {
+      setAiJob(await response.json());
+    }
+  };
+
+  useEffect(() => {
+    fetchAIJob().catch(() => {});
+  }, [examId]);
+
+  const aiJobActive = aiJob?.status === 'queued' || aiJob?.status === 'running';
+
+  // Poll while a pre-evaluation is queued or running
+  useEffect(() => {
+    if (!aiJobActive) return;
+    const timer = setInterval(() => {
+      fetchAIJob().catch(() => {});
+    }, 3000);
+    return () => clearInterval(timer);
+  }, [aiJobActive, examId]);
+
+  const handleStartAIPreEvaluation = async () => {
+    try {
+      const response = await fetch(Allapi.startAIPreEvaluation.url(examId), {
+        method: Allapi.startAIPreEvaluation.method,
+        headers: {
+          'Authorization': `${localStorage.getItem('token')}`,
+          'Idempotency-Key': crypto.randomUUID()
+        }
+      });
+      const data = await response.json();
+      if (data.job) {
+        setAiJob(data.job);
+      }
+      if (!response.ok) {
+        throw new Error(data.error || 'Failed to start pre-evaluation');
+      }
+      toast.success('AI pre-evaluation started');
+    } catch (error) {
+      toast.error(error.message);
+    }
+  };
+
+  const handleCancelAIPreEvaluation = async () => {
+    try {
+      const response = await fetch(Allapi.cancelAIPreEvaluation.url(aiJob.id), {
+        method: Allapi.cancelAIPreEvaluation.method,
+        headers: {
+          'Authorization': `${localStorage.getItem('token')}`
+        }
+      });
+      if (!response.ok) {
+        const data = await response.json();
+        throw new Error(data.error || 'Failed to cancel pre-evaluation');
+      }
+      await fetchAIJob();
+      toast.success('AI pre-evaluation cancelled');
+    } catch (error) {
+      toast.error(error.message);
+    }
+  };
+
   const handleCreateEvaluation = async (answerSheetId) => {
     try {
       setLoading(true);
@@ -100,8 +169,37 @@ function AnswerSheetsList() {
             </h1>
             <p className="text-gray-400">Answer Sheets</p>
           </div>
+          <div className="flex items-center ml-auto space-x-3">
+            {aiJob && (
+              <span className="text-sm text-gray-400">
+                {aiJob.status === 'running'
+                  ? `AI pre-evaluation: ${aiJob.progress.done}/${aiJob.progress.total}`
+                  : `AI pre-evaluation ${aiJob.status}: ${aiJob.progress.done}/${aiJob.progress.total}`}
+                {aiJob.progress.failed > 0 && ` (${aiJob.progress.failed} failed)`}
+              </span>
+            )}
+            {aiJobActive ? (
+              <button
+                onClick={handleCancelAIPreEvaluation}
+                className="inline-flex items-center px-4 py-2 text-sm font-medium text-white bg-red-600 rounded-md hover:bg-red-700"
+              >
+                <XCircle className="w-4 h-4 mr-2" />
+                Cancel
+              </button>
+            ) : (
+              <button
+                onClick={handleStartAIPreEvaluation}
+                className="inline-flex items-center px-4 py-2 text-sm font-medium text-white bg-purple-600 rounded-md hover:bg-purple-700"
+              >
+                <Sparkles className="w-4 h-4 mr-2" />
+                Pre-evaluate with AI
+              </button>
+            )}
+          </div>
         </div>
 
+        <AICalibrationPanel examId={examId} />
+
         {loading ? (
           <div className="flex items-center justify-center py-20">
             <div className="relative w-16 h-16">
@@ -179,9 +277,10 @@ function AnswerSheetsList() {
                               <>
                                 <AlertTriangle className="w-4 h-4 mr-2 text-amber-400" />
                                 <span className="px-2 py-1 text-xs font-medium rounded-full text-amber-400 bg-amber-500/20">
-                                  {sheet.status === 'didnotstart' ? 'Not Started' : 
+                                  {sheet.status === 'assigned' ? 'Not Started' : 
                                    sheet.status === 'started' ? 'In Progress' : 
-                                   sheet.status === 'internal' ? 'Internal' : 'Unknown'}
+                                   sheet.status === 'paused' ? 'Paused' : 
+                                   sheet.status === 'voided' ? 'Voided' : 'Unknown'}
                                 </span>
                               </>
                             )}
@@ -19,7 +19,9 @@ function CreateExam() {
   const [newQuestion, setNewQuestion] = useState({
     question: '',
     types: [],
-    level: 'medium'
+    level: 'medium',
+    reference_answer: '',
+    grading_notes: ''
   });
 
   const [selectedDate, setSelectedDate] = useState('');
@@ -79,7 +81,7 @@ function CreateExam() {
         ...prev,
         questions: [...prev.questions, { ...newQuestion }]
       }));
-      setNewQuestion({ question: '', types: [], level: 'medium' });
+      setNewQuestion({ question: '', types: [], level: 'medium', reference_answer: '', grading_notes: '' });
       setSelectedType('');
     }
   };
@@ -261,6 +263,22 @@ function CreateExam() {
                 placeholder="Enter question"
                 className="w-full px-4 py-2 text-white transition-colors duration-300 bg-gray-700 border-2 border-gray-600 rounded-lg focus:border-blue-500 focus:outline-none"
               />
+
+              <textarea
+                value={newQuestion.reference_answer}
+                onChange={(e) => setNewQuestion(prev => ({ ...prev, reference_answer: e.target.value }))}
+                placeholder="Reference answer (optional, never shown to students)"
+                rows="3"
+                className="w-full px-4 py-2 text-white transition-colors duration-300 bg-gray-700 border-2 border-gray-600 rounded-lg focus:border-blue-500 focus:outline-none"
+              />
+
+              <textarea
+                value={newQuestion.grading_notes}
+                onChange={(e) => setNewQuestion(prev => ({ ...prev, grading_notes: e.target.value }))}
+                placeholder="Grading notes (optional, e.g. what earns or loses marks)"
+                rows="2"
+                className="w-full px-4 py-2 text-white transition-colors duration-300 bg-gray-700 border-2 border-gray-600 rounded-lg focus:border-blue-500 focus:outline-none"
+              />
               
               <div className="flex space-x-4">
                 <select
@@ -328,6 +346,12 @@ function CreateExam() {
                   <div className="flex items-start justify-between">
                     <div className="space-y-2">
                       <p className="text-white">{q.question}</p>
+                      {q.reference_answer && (
+                        <p className="text-sm text-gray-400">Reference: {q.reference_answer}</p>
+                      )}
+                      {q.grading_notes && (
+                        <p className="text-sm text-gray-400">Notes: {q.grading_notes}</p>
+                      )}
                       <div className="flex flex-wrap gap-2">
                         {q.types.map((type, typeIndex) => (
                           <span
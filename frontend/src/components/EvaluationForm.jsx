@@ -14,6 +14,7 @@ function EvaluationForm() {
   const [totalMarks, setTotalMarks] = useState(0);
   const [saving, setSaving] = useState(false);
   const [aiEvaluating, setAiEvaluating] = useState(false);
+  const [promptPreview, setPromptPreview] = useState(null);
 
   useEffect(() => {
     const fetchEvaluation = async () => {
@@ -56,6 +57,7 @@ function EvaluationForm() {
       const currentQuestion = evaluation.data[activeQuestionIndex];
       // Only trigger AI evaluation if there are answers and no existing AI evaluation
       if (currentQuestion && 
+          !evaluation.evaluated &&
           currentQuestion.answers.some(a => a.ans.trim()) && 
           !currentQuestion.ai_evaluation) {
         handleAiEvaluate();
@@ -74,68 +76,60 @@ function EvaluationForm() {
     setTotalMarks(total);
   };
 
-  const handleAiEvaluate = async () => {
+  const handlePreviewPrompt = async () => {
+    if (promptPreview?.index === activeQuestionIndex) {
+      setPromptPreview(null);
+      return;
+    }
     try {
-      setAiEvaluating(true);
-      const currentQuestion = evaluation.data[activeQuestionIndex];
-      
-      // Prepare the prompt for AI
-      let prompt = `Please evaluate the following answer to this question:\n\n`;
-      prompt += `Question: ${currentQuestion.question}\n\n`;
-      
-      // Add all answers
-      prompt += `Answers:\n`;
-      currentQuestion.answers.forEach(answer => {
-        if (answer.ans.trim()) {
-          prompt += `${answer.type.toUpperCase()}: ${answer.ans}\n\n`;
-        }
-      });
-      
-      prompt += `Please provide two things:\n`;
-      prompt += `1. A brief evaluation of the answer quality and correctness (2-3 sentences maximum).\n`;
-      prompt += `2. A percentage score from 0-100% that represents how good the answer is.\n\n`;
-      prompt += `Format your response as follows:\n`;
-      prompt += `Evaluation: [Your brief evaluation]\n`;
-      prompt += `Score: [percentage]%`;
-      
-      // Call the AI API
-      const response = await fetch(`${Allapi.backapi}/ai/generate`, {
-        method: 'POST',
+      const response = await fetch(Allapi.previewGradingPrompt.url, {
+        method: Allapi.previewGradingPrompt.method,
         headers: {
           'Content-Type': 'application/json',
           'Authorization': `${localStorage.getItem('token')}`
         },
-        body: JSON.stringify({
-          prompt: prompt
-        })
+        body: JSON.stringify({ evaluation_id: evaluationId, index: activeQuestionIndex })
       });
-      
+      const data = await response.json();
       if (!response.ok) {
-        throw new Error('Failed to get AI evaluation');
+        throw new Error(data.error || 'Failed to preview the prompt');
       }
-      
-      const data = await response.json();
-      
-      // Update the evaluation with AI feedback
-      const updatedEvaluation = { ...evaluation };
-      updatedEvaluation.data[activeQuestionIndex].ai_evaluation = data.response;
-      
-      // Try to extract percentage score
-      try {
-        const scoreMatch = data.response.match(/Score:\s*(\d+)%/i);
-        if (scoreMatch && scoreMatch[1]) {
-          updatedEvaluation.data[activeQuestionIndex].ai_score = parseInt(scoreMatch[1]);
+      setPromptPreview({ ...data, index: activeQuestionIndex });
+    } catch (error) {
+      console.error('Error previewing prompt:', error);
+      toast.error(error.message || 'Failed to preview the prompt');
+    }
+  };
+
+  const handleAiEvaluate = async (fresh = false) => {
+    const questionIndex = activeQuestionIndex;
+    try {
+      setAiEvaluating(true);
+
+      const url = Allapi.aiGradeQuestion.url(evaluationId, questionIndex) + (fresh ? '?fresh=true' : '');
+      const response = await fetch(url, {
+        method: Allapi.aiGradeQuestion.method,
+        headers: {
+          'Authorization': `${localStorage.getItem('token')}`
         }
-      } catch (err) {
-        console.error('Error parsing AI score:', err);
+      });
+
+      const grading = await response.json();
+      if (!response.ok) {
+        throw new Error(grading.error || 'Failed to get AI evaluation');
       }
-      
+
+      // Update the evaluation with the structured AI grading
+      const updatedEvaluation = { ...evaluation };
+      updatedEvaluation.data[questionIndex].ai_grading = grading;
+      updatedEvaluation.data[questionIndex].ai_evaluation = grading.feedback;
+      updatedEvaluation.data[questionIndex].ai_score = grading.score;
       setEvaluation(updatedEvaluation);
-      
+
       toast.success('AI evaluation completed');
     } catch (error) {
       console.error('Error getting AI evaluation:', error);
-      toast.error('Failed to get AI evaluation');
+      toast.error(error.message || 'Failed to get AI evaluation');
     } finally {
       setAiEvaluating(false);
     }
@@ -312,6 +306,21 @@ function EvaluationForm() {
               ))}
             </div>
             
+            {/* Grading prompt preview */}
+            <div className="space-y-2">
+              <button onClick={handlePreviewPrompt} className="text-sm text-blue-400 hover:text-blue-300">
+                {promptPreview?.index === activeQuestionIndex ? 'Hide grading prompt' : 'Preview grading prompt'}
+              </button>
+              {promptPreview?.index === activeQuestionIndex && (
+                <div className="space-y-1">
+                  <p className="text-xs text-gray-400">Template {promptPreview.template} v{promptPreview.version}</p>
+                  <pre className="p-4 overflow-x-auto text-sm text-gray-300 whitespace-pre-wrap bg-gray-700 border border-gray-600 rounded-lg">
+                    {promptPreview.prompt}
+                  </pre>
+                </div>
+              )}
+            </div>
+
             {/* AI Evaluation Status */}
             {aiEvaluating && (
               <div className="flex items-center justify-center p-4 space-x-2 text-blue-400 rounded-lg bg-blue-500/10">
@@ -324,29 +333,56 @@ function EvaluationForm() {
             {currentQuestion.ai_evaluation && (
               <div className="space-y-4">
                 <div className="flex items-center justify-between">
-                  <h3 className="text-lg font-medium text-blue-400">AI Evaluation</h3>
+                  <div className="flex items-center space-x-3">
+                    <h3 className="text-lg font-medium text-blue-400">AI Evaluation</h3>
+                    {!evaluation.evaluated && (
+                      <button
+                        onClick={() => handleAiEvaluate(true)}
+                        disabled={aiEvaluating}
+                        className="text-sm text-gray-400 hover:text-blue-400 disabled:opacity-50"
+                      >
+                        Re-grade
+                      </button>
+                    )}
+                  </div>
                   {currentQuestion.ai_score && (
                     <div className="px-3 py-1 text-sm font-medium text-blue-400 rounded-full bg-blue-500/20">
                       AI Score: {currentQuestion.ai_score}%
+                      {currentQuestion.ai_grading?.raw_score !== undefined && (
+                        <span className="ml-1 text-gray-400">(calibrated from {currentQuestion.ai_grading.raw_score}%)</span>
+                      )}
                     </div>
                   )}
                 </div>
-                <div className="p-5 text-white border rounded-lg bg-blue-500/10 border-blue-500/30">
-                  {currentQuestion.ai_evaluation.includes('Evaluation:') ? (
-                    <div className="space-y-3">
-                      {currentQuestion.ai_evaluation.split(/\n+/).map((line, idx) => {
-                        // Skip the Score line as we're displaying it separately
-                        if (line.trim().startsWith('Score:')) return null;
-                        
-                        return (
-                          <p key={idx} className={line.trim().startsWith('Evaluation:') ? 'font-semibold' : ''}>
-                            {line}
-                          </p>
-                        );
-                      })}
-                    </div>
-                  ) : (
-                    <p>{currentQuestion.ai_evaluation}</p>
+                <div className="p-5 space-y-4 text-white border rounded-lg bg-blue-500/10 border-blue-500/30">
+                  <p className="whitespace-pre-line">{currentQuestion.ai_evaluation}</p>
+                  {currentQuestion.ai_grading && (
+                    <>
+                      <div className="space-y-2">
+                        {currentQuestion.ai_grading.criteria.map((criterion, idx) => (
+                          <div key={idx} className="flex items-start justify-between text-sm">
+                            <div>
+                              <span className="font-semibold">{criterion.name}</span>
+                              {criterion.comment && (
+                                <span className="ml-2 text-gray-300">{criterion.comment}</span>
+                              )}
+                            </div>
+                            <span className="ml-4 text-blue-400 whitespace-nowrap">
+                              {criterion.points} / {criterion.max_points}
+                            </span>
+                          </div>
+                        ))}
+                      </div>
+                      <p className="text-xs text-gray-400">
+                        Confidence: {Math.round(currentQuestion.ai_grading.confidence * 100)}%
+                        {currentQuestion.ai_grading.template && (
+                          <span className="ml-2">Template: {currentQuestion.ai_grading.template} v{currentQuestion.ai_grading.template_version || 0}</span>
+                        )}
+                        {currentQuestion.ai_grading.cached && (
+                          <span className="ml-2">Reused from an identical answer</span>
+                        )}
+                      </p>
+                    </>
                   )}
                 </div>
               </div>
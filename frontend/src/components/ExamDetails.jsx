@@ -1,8 +1,9 @@
 import React, { useState, useEffect } from 'react';
 import { useParams, useNavigate } from 'react-router-dom';
-import { ArrowLeft, Calendar, Clock, BookOpen, Save, Trash2, Plus, Shuffle, FileText, X } from 'lucide-react';
+import { ArrowLeft, Calendar, Clock, BookOpen, Save, Trash2, Plus, Shuffle, FileText, X, Sparkles } from 'lucide-react';
 import { Toaster, toast } from 'react-hot-toast';
 import Allapi from '../utils/common';
+import QuestionGenerator from './QuestionGenerator';
 
 const QUESTION_TYPES = ['html', 'css', 'js', 'jquery', 'php', 'nodejs', 'mongodb', 'python', 'java','text','none'];
 const DIFFICULTY_LEVELS = ['easy', 'medium', 'hard']
@@ -19,11 +20,15 @@ function ExamDetails() {
   const [setQuestions, setSetQuestions] = useState(null);
   const [showAddDateModal, setShowAddDateModal] = useState(false);
   const [showAddQuestionModal, setShowAddQuestionModal] = useState(false);
+  const [showGenerator, setShowGenerator] = useState(false);
+  const [reloadExam, setReloadExam] = useState(0);
   const [newDate, setNewDate] = useState('');
   const [newQuestion, setNewQuestion] = useState({
     question: '',
     level: 'medium',
     types: [],
+    reference_answer: '',
+    grading_notes: '',
     type: '' // Temporary field for input
   });
   const [setConfig, setSetConfig] = useState({
@@ -63,7 +68,7 @@ function ExamDetails() {
     if (id) {
       fetchExam();
     }
-  }, [id]);
+  }, [id, reloadExam]);
 
   const fetchQuestionPaper = async (setId) => {
     try {
@@ -156,6 +161,29 @@ function ExamDetails() {
     }
   };
 
+  const handleToggleOpen = async () => {
+    const api = exam.open ? Allapi.closeInternalExam : Allapi.openInternalExam;
+    try {
+      const response = await fetch(api.url(id), {
+        method: api.method,
+        headers: {
+          'Authorization': `${localStorage.getItem('token')}`
+        }
+      });
+      const result = await response.json();
+      if (response.ok) {
+        setExam({ ...exam, open: result.open });
+        setEditedExam({ ...editedExam, open: result.open });
+        toast.success(result.open ? 'Exam opened' : `Exam closed, ${result.submitted_sheets} answer sheets submitted`);
+      } else {
+        toast.error(result.error || 'Failed to update exam');
+      }
+    } catch (error) {
+      toast.error('Failed to update exam');
+      console.error('Failed to update exam:', error);
+    }
+  };
+
   const handleAddDate = () => {
     if (!newDate) {
       toast.error('Please select a date');
@@ -190,6 +218,8 @@ function ExamDetails() {
       question: '',
       level: 'medium',
       types: [],
+      reference_answer: '',
+      grading_notes: '',
       type: ''
     });
     setShowAddQuestionModal(false);
@@ -294,6 +324,22 @@ function ExamDetails() {
               </>
             ) : (
               <>
+                <button
+                  onClick={() => navigate(`/teacher/exams/${id}/monitor`)}
+                  className="flex items-center px-4 py-2 text-blue-400 transition-all duration-300 rounded-lg bg-blue-500/20 hover:bg-blue-500/30"
+                >
+                  Live Monitor
+                </button>
+                {exam.exam_type === 'internal' && (
+                  <button
+                    onClick={handleToggleOpen}
+                    className={`flex items-center px-4 py-2 transition-all duration-300 rounded-lg ${
+                      exam.open ? 'text-red-400 bg-red-500/20 hover:bg-red-500/30' : 'text-green-400 bg-green-500/20 hover:bg-green-500/30'
+                    }`}
+                  >
+                    {exam.open ? 'Close Exam' : 'Open Exam'}
+                  </button>
+                )}
                 <button
                   onClick={() => setShowCreateSets(true)}
                   className="flex items-center px-4 py-2 text-purple-400 transition-all duration-300 rounded-lg bg-purple-500/20 hover:bg-purple-500/30"
@@ -480,6 +526,14 @@ function ExamDetails() {
           </div>
         )}
 
+        {showGenerator && (
+          <QuestionGenerator
+            examId={id}
+            onClose={() => setShowGenerator(false)}
+            onAdded={() => setReloadExam(n => n + 1)}
+          />
+        )}
+
         {showAddQuestionModal && (
     <div className="fixed inset-0 z-50 flex items-center justify-center">
       <div className="fixed inset-0 bg-black/50" onClick={() => setShowAddQuestionModal(false)} />
@@ -506,6 +560,30 @@ function ExamDetails() {
               placeholder="Enter your question"
             />
           </div>
+          <div className="space-y-2">
+            <label className="block text-sm font-medium text-gray-300">
+              Reference Answer
+            </label>
+            <textarea
+              value={newQuestion.reference_answer}
+              onChange={(e) => setNewQuestion({ ...newQuestion, reference_answer: e.target.value })}
+              className="w-full px-3 py-2 bg-gray-700 border-2 border-gray-600 rounded-lg focus:border-blue-500 focus:outline-none"
+              rows="3"
+              placeholder="Optional, used for AI grading and never shown to students"
+            />
+          </div>
+          <div className="space-y-2">
+            <label className="block text-sm font-medium text-gray-300">
+              Grading Notes
+            </label>
+            <textarea
+              value={newQuestion.grading_notes}
+              onChange={(e) => setNewQuestion({ ...newQuestion, grading_notes: e.target.value })}
+              className="w-full px-3 py-2 bg-gray-700 border-2 border-gray-600 rounded-lg focus:border-blue-500 focus:outline-none"
+              rows="2"
+              placeholder="Optional, e.g. what earns or loses marks"
+            />
+          </div>
           <div className="space-y-2">
             <label className="block text-sm font-medium text-gray-300">
               Difficulty Level
@@ -670,6 +748,15 @@ function ExamDetails() {
         <div className="p-6 space-y-4 bg-gray-800 border-2 rounded-xl border-blue-500/20">
           <div className="flex items-center justify-between">
             <h2 className="text-xl font-semibold text-white">Questions</h2>
+            {!isEditing && (
+              <button
+                onClick={() => setShowGenerator(true)}
+                className="flex items-center px-4 py-2 text-purple-400 transition-all duration-300 rounded-lg bg-purple-500/20 hover:bg-purple-500/30"
+              >
+                <Sparkles className="w-5 h-5 mr-2" />
+                Generate with AI
+              </button>
+            )}
             {isEditing && (
               <button
                 onClick={() => setShowAddQuestionModal(true)}
@@ -699,6 +786,41 @@ function ExamDetails() {
                     ) : (
                       <p className="text-white">{question.question}</p>
                     )}
+                    {isEditing ? (
+                      <>
+                        <textarea
+                          value={question.reference_answer || ''}
+                          onChange={(e) => {
+                            const newQuestions = [...editedExam.questions];
+                            newQuestions[index] = { ...question, reference_answer: e.target.value };
+                            setEditedExam({ ...editedExam, questions: newQuestions });
+                          }}
+                          placeholder="Reference answer (never shown to students)"
+                          rows="2"
+                          className="w-full px-3 py-2 text-white bg-gray-600 border-2 border-gray-500 rounded-lg focus:border-blue-500 focus:outline-none"
+                        />
+                        <textarea
+                          value={question.grading_notes || ''}
+                          onChange={(e) => {
+                            const newQuestions = [...editedExam.questions];
+                            newQuestions[index] = { ...question, grading_notes: e.target.value };
+                            setEditedExam({ ...editedExam, questions: newQuestions });
+                          }}
+                          placeholder="Grading notes"
+                          rows="2"
+                          className="w-full px-3 py-2 text-white bg-gray-600 border-2 border-gray-500 rounded-lg focus:border-blue-500 focus:outline-none"
+                        />
+                      </>
+                    ) : (
+                      <>
+                        {question.reference_answer && (
+                          <p className="text-sm text-gray-400">Reference: {question.reference_answer}</p>
+                        )}
+                        {question.grading_notes && (
+                          <p className="text-sm text-gray-400">Notes: {question.grading_notes}</p>
+                        )}
+                      </>
+                    )}
                     <div className="flex flex-wrap gap-2">
                       {question.types.map((type, typeIndex) => (
                         <span
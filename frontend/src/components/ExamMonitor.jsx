@@ -0,0 +1,172 @@
+import React, { useState, useEffect } from 'react';
+import { useParams, useNavigate } from 'react-router-dom';
+import { ArrowLeft, Activity, AlertTriangle } from 'lucide-react';
+import Allapi from '../utils/common';
+
+const STATUS_STYLES = {
+  assigned: 'bg-gray-500/20 text-gray-300',
+  started: 'bg-green-500/20 text-green-400',
+  paused: 'bg-amber-500/20 text-amber-400',
+  submitted: 'bg-blue-500/20 text-blue-400',
+  expired: 'bg-red-500/20 text-red-400',
+  voided: 'bg-red-500/20 text-red-400',
+  evaluated: 'bg-purple-500/20 text-purple-400',
+};
+
+function ExamMonitor() {
+  const { id } = useParams();
+  const navigate = useNavigate();
+  const [sheets, setSheets] = useState({});
+  const [feed, setFeed] = useState([]);
+  const [connected, setConnected] = useState(false);
+
+  useEffect(() => {
+    let source = null;
+    let retry = null;
+    let closed = false;
+
+    // EventSource can't send headers, so the stream is opened with a single
+    // use ticket. A dropped stream needs a new one to reconnect.
+    const reconnect = () => {
+      if (source) source.close();
+      if (closed) return;
+      setConnected(false);
+      retry = setTimeout(connect, 3000);
+    };
+
+    const listen = () => {
+      const addToFeed = (event) => setFeed((prev) => [event, ...prev].slice(0, 100));
+
+      source.addEventListener('snapshot', (e) => {
+        const snapshot = JSON.parse(e.data);
+        const bySheet = {};
+        (snapshot.data || []).forEach((sheet) => { bySheet[sheet.id] = sheet; });
+        setSheets(bySheet);
+        setConnected(true);
+      });
+      source.addEventListener('sheet.assigned', (e) => {
+        const event = JSON.parse(e.data);
+        setSheets((prev) => ({ ...prev, [event.answer_sheet_id]: event.data }));
+        addToFeed(event);
+      });
+      source.addEventListener('sheet.transition', (e) => {
+        const event = JSON.parse(e.data);
+        setSheets((prev) => prev[event.answer_sheet_id]
+          ? { ...prev, [event.answer_sheet_id]: { ...prev[event.answer_sheet_id], status: event.data.to } }
+          : prev);
+        addToFeed(event);
+      });
+      source.addEventListener('heartbeat', (e) => {
+        const event = JSON.parse(e.data);
+        setSheets((prev) => prev[event.answer_sheet_id]
+          ? { ...prev, [event.answer_sheet_id]: { ...prev[event.answer_sheet_id], last_seen: event.at } }
+          : prev);
+      });
+      ['sheet.extended', 'proctoring', 'exam.updated'].forEach((type) =>
+        source.addEventListener(type, (e) => addToFeed(JSON.parse(e.data)))
+      );
+      source.onerror = reconnect;
+    };
+
+    const connect = async () => {
+      try {
+        const response = await fetch(Allapi.monitorTicket.url(id), {
+          method: Allapi.monitorTicket.method,
+          headers: {
+            'Authorization': `${localStorage.getItem('token')}`
+          }
+        });
+        const result = await response.json();
+        if (!response.ok) throw new Error(result.error);
+        if (closed) return;
+        source = new EventSource(Allapi.monitorExam.url(id, result.ticket));
+      } catch (error) {
+        reconnect();
+        return;
+      }
+      listen();
+    };
+
+    connect();
+    return () => {
+      closed = true;
+      clearTimeout(retry);
+      if (source) source.close();
+    };
+  }, [id]);
+
+  const rows = Object.values(sheets);
+
+  return (
+    <div className="space-y-6 animate-fade-slide-up">
+      <div className="flex items-center justify-between">
+        <div className="flex items-center space-x-4">
+          <button
+            onClick={() => navigate(`/teacher/exams/${id}`)}
+            className="p-2 text-gray-400 transition-colors duration-300 hover:text-white"
+          >
+            <ArrowLeft className="w-6 h-6" />
+          </button>
+          <h1 className="text-3xl font-bold text-white">Live Monitor</h1>
+        </div>
+        <span className={`flex items-center px-3 py-1 text-sm rounded-full ${connected ? 'bg-green-500/20 text-green-400' : 'bg-red-500/20 text-red-400'}`}>
+          <Activity className="w-4 h-4 mr-2" />
+          {connected ? 'Live' : 'Reconnecting...'}
+        </span>
+      </div>
+
+      <div className="grid gap-6 lg:grid-cols-3">
+        <div className="overflow-hidden bg-gray-800 rounded-xl lg:col-span-2">
+          <table className="w-full text-left">
+            <thead className="bg-gray-700">
+              <tr>
+                <th className="px-4 py-3 text-sm text-gray-300">Student</th>
+                <th className="px-4 py-3 text-sm text-gray-300">Seat</th>
+                <th className="px-4 py-3 text-sm text-gray-300">Status</th>
+                <th className="px-4 py-3 text-sm text-gray-300">Last seen</th>
+              </tr>
+            </thead>
+            <tbody className="divide-y divide-gray-700">
+              {rows.map((sheet) => (
+                <tr key={sheet.id}>
+                  <td className="px-4 py-3">
+                    <p className="text-white">{sheet.student_name}</p>
+                    <p className="text-sm text-gray-400">{sheet.email}</p>
+                  </td>
+                  <td className="px-4 py-3 text-gray-300">
+                    {sheet.seat ? `${sheet.seat.room} R${sheet.seat.row} S${sheet.seat.number}` : '-'}
+                  </td>
+                  <td className="px-4 py-3">
+                    <span className={`px-2 py-1 text-xs font-medium rounded-full ${STATUS_STYLES[sheet.status] || ''}`}>
+                      {sheet.status}
+                    </span>
+                  </td>
+                  <td className="px-4 py-3 text-sm text-gray-400">
+                    {sheet.last_seen ? new Date(sheet.last_seen).toLocaleTimeString() : '-'}
+                  </td>
+                </tr>
+              ))}
+            </tbody>
+          </table>
+        </div>
+
+        <div className="p-4 space-y-3 overflow-y-auto bg-gray-800 rounded-xl max-h-[70vh]">
+          <h2 className="text-lg font-semibold text-white">Activity</h2>
+          {feed.map((event, index) => (
+            <div key={index} className="flex items-start space-x-2 text-sm">
+              {event.type === 'proctoring' && <AlertTriangle className="w-4 h-4 mt-0.5 text-amber-400" />}
+              <div>
+                <p className="text-gray-200">
+                  {event.student_email || 'Exam'}: {event.type === 'sheet.transition' ? `${event.data.from} → ${event.data.to}` : event.type === 'proctoring' ? event.data.type : event.type}
+                </p>
+                <p className="text-xs text-gray-500">{new Date(event.at).toLocaleTimeString()}</p>
+              </div>
+            </div>
+          ))}
+        </div>
+      </div>
+    </div>
+  );
+}
+
+export default ExamMonitor;
@@ -0,0 +1,219 @@
+import React, { useState } from 'react';
+import { X, Sparkles } from 'lucide-react';
+import { toast } from 'react-hot-toast';
+import Allapi from '../utils/common';
+
+const QUESTION_TYPES = ['html', 'css', 'js', 'jquery', 'php', 'nodejs', 'mongodb', 'python', 'java', 'text', 'none'];
+const DIFFICULTY_LEVELS = ['easy', 'medium', 'hard'];
+
+function QuestionGenerator({ examId, onClose, onAdded }) {
+  const [topic, setTopic] = useState('');
+  const [types, setTypes] = useState([]);
+  const [counts, setCounts] = useState({ easy: 2, medium: 2, hard: 1 });
+  const [drafts, setDrafts] = useState([]);
+  const [selected, setSelected] = useState([]);
+  const [generating, setGenerating] = useState(false);
+  const [saving, setSaving] = useState(false);
+
+  const toggleType = (type) => {
+    setTypes(prev => prev.includes(type) ? prev.filter(t => t !== type) : [...prev, type]);
+  };
+
+  const toggleDraft = (index) => {
+    setSelected(prev => prev.includes(index) ? prev.filter(i => i !== index) : [...prev, index]);
+  };
+
+  const handleGenerate = async () => {
+    if (!topic.trim()) {
+      toast.error('Please enter a topic');
+      return;
+    }
+    if (types.length === 0) {
+      toast.error('Please pick at least one answer type');
+      return;
+    }
+
+    setGenerating(true);
+    try {
+      const response = await fetch(Allapi.generateQuestionDrafts.url, {
+        method: Allapi.generateQuestionDrafts.method,
+        headers: {
+          'Content-Type': 'application/json',
+          'Authorization': `${localStorage.getItem('token')}`
+        },
+        body: JSON.stringify({ topic, types, ...counts })
+      });
+      const data = await response.json();
+      if (response.ok) {
+        setDrafts(data.questions || []);
+        setSelected((data.questions || []).map((_, i) => i));
+      } else {
+        toast.error(data.error || 'Failed to generate questions');
+      }
+    } catch (error) {
+      toast.error('Error generating questions');
+      console.error('Error generating questions:', error);
+    } finally {
+      setGenerating(false);
+    }
+  };
+
+  const handleAccept = async (target) => {
+    if (selected.length === 0) {
+      toast.error('Please select at least one question');
+      return;
+    }
+
+    setSaving(true);
+    try {
+      const response = await fetch(Allapi.acceptQuestionDrafts.url, {
+        method: Allapi.acceptQuestionDrafts.method,
+        headers: {
+          'Content-Type': 'application/json',
+          'Authorization': `${localStorage.getItem('token')}`
+        },
+        body: JSON.stringify({
+          target,
+          exam_id: examId,
+          topic,
+          questions: [...selected].sort((a, b) => a - b).map(i => drafts[i])
+        })
+      });
+      const data = await response.json();
+      if (response.ok) {
+        toast.success(data.message);
+        if (target === 'exam') {
+          onAdded();
+        }
+        setDrafts(drafts.filter((_, i) => !selected.includes(i)));
+        setSelected([]);
+      } else {
+        toast.error(data.error || 'Failed to save questions');
+      }
+    } catch (error) {
+      toast.error('Error saving questions');
+      console.error('Error saving questions:', error);
+    } finally {
+      setSaving(false);
+    }
+  };
+
+  return (
+    <div className="fixed inset-0 z-50 flex items-center justify-center">
+      <div className="fixed inset-0 bg-black/50" onClick={onClose} />
+      <div className="relative w-full max-w-3xl max-h-[90vh] overflow-y-auto p-6 mx-4 space-y-4 bg-gray-800 border-2 rounded-xl border-blue-500/20 animate-slide-up">
+        <div className="flex items-center justify-between">
+          <h2 className="text-xl font-semibold text-white">Generate Questions with AI</h2>
+          <button onClick={onClose} className="text-gray-400 hover:text-white">
+            <X className="w-5 h-5" />
+          </button>
+        </div>
+
+        <div className="space-y-4">
+          <input
+            type="text"
+            value={topic}
+            onChange={(e) => setTopic(e.target.value)}
+            placeholder="Topic, e.g. Flexbox layouts"
+            className="w-full px-3 py-2 text-white bg-gray-700 border-2 border-gray-600 rounded-lg focus:border-blue-500 focus:outline-none"
+          />
+
+          <div className="flex flex-wrap gap-2">
+            {QUESTION_TYPES.map(type => (
+              <button
+                key={type}
+                type="button"
+                onClick={() => toggleType(type)}
+                className={`px-3 py-1 text-sm rounded-full ${
+                  types.includes(type) ? 'bg-blue-500 text-white' : 'bg-blue-500/20 text-blue-400'
+                }`}
+              >
+                {type}
+              </button>
+            ))}
+          </div>
+
+          <div className="grid grid-cols-3 gap-4">
+            {DIFFICULTY_LEVELS.map(level => (
+              <div key={level} className="space-y-1">
+                <label className="block text-sm font-medium text-gray-300 capitalize">{level}</label>
+                <input
+                  type="number"
+                  min="0"
+                  max="20"
+                  value={counts[level]}
+                  onChange={(e) => setCounts({ ...counts, [level]: parseInt(e.target.value) || 0 })}
+                  className="w-full px-3 py-2 text-white bg-gray-700 border-2 border-gray-600 rounded-lg focus:border-blue-500 focus:outline-none"
+                />
+              </div>
+            ))}
+          </div>
+
+          <button
+            onClick={handleGenerate}
+            disabled={generating}
+            className="flex items-center justify-center w-full px-4 py-2 text-white transition-all duration-300 bg-purple-500 rounded-lg hover:bg-purple-600 disabled:opacity-50"
+          >
+            <Sparkles className="w-5 h-5 mr-2" />
+            {generating ? 'Generating...' : 'Generate Drafts'}
+          </button>
+        </div>
+
+        {drafts.length > 0 && (
+          <div className="space-y-3">
+            {drafts.map((draft, index) => (
+              <label key={index} className="flex items-start p-4 space-x-3 bg-gray-700 rounded-lg cursor-pointer">
+                <input
+                  type="checkbox"
+                  checked={selected.includes(index)}
+                  onChange={() => toggleDraft(index)}
+                  className="mt-1"
+                />
+                <div className="space-y-2">
+                  <p className="text-white">{draft.question}</p>
+                  <div className="flex flex-wrap gap-2">
+                    {draft.types.map(type => (
+                      <span key={type} className="px-2 py-1 text-sm text-blue-400 rounded-full bg-blue-500/20">
+                        {type}
+                      </span>
+                    ))}
+                    <span className={`px-2 py-1 text-sm rounded-full ${
+                      draft.level === 'easy' ? 'bg-green-500/20 text-green-400' :
+                      draft.level === 'medium' ? 'bg-yellow-500/20 text-yellow-400' :
+                      'bg-red-500/20 text-red-400'
+                    }`}>
+                      {draft.level}
+                    </span>
+                  </div>
+                  <p className="text-sm text-gray-400 whitespace-pre-wrap">Reference: {draft.reference_answer}</p>
+                  {draft.grading_notes && (
+                    <p className="text-sm text-gray-400">Notes: {draft.grading_notes}</p>
+                  )}
+                </div>
+              </label>
+            ))}
+
+            <div className="flex space-x-4">
+              <button
+                onClick={() => handleAccept('exam')}
+                disabled={saving}
+                className="flex-1 px-4 py-2 text-white transition-all duration-300 bg-blue-500 rounded-lg hover:bg-blue-600 disabled:opacity-50"
+              >
+                Add to Exam
+              </button>
+              <button
+                onClick={() => handleAccept('bank')}
+                disabled={saving}
+                className="flex-1 px-4 py-2 text-blue-400 transition-all duration-300 rounded-lg bg-blue-500/20 hover:bg-blue-500/30 disabled:opacity-50"
+              >
+                Save to Question Bank
+              </button>
+            </div>
+          </div>
+        )}
+      </div>
+    </div>
+  );
+}
+
+export default QuestionGenerator;
@@ -12,9 +12,10 @@ function ExamEntryPoint() {
   const [answerSheet, setAnswerSheet] = useState(null);
   const [questionPaper, setQuestionPaper] = useState(null);
   const [showQuestions, setShowQuestions] = useState(false);
+  const [seatOptions, setSeatOptions] = useState(null);
+  const [seat, setSeat] = useState({ slot: '', room: '', row: '', number: '' });
 
-  useEffect(() => {
-    const assignQuestionPaper = async () => {
+  const assignQuestionPaper = async (selectedSeat) => {
       try {
         setLoading(true);
   
@@ -29,13 +30,16 @@ function ExamEntryPoint() {
         const response = await fetch(`${Allapi.assignSetAndCreateAnswerSheet.url}/${id}`, {
           method: Allapi.assignSetAndCreateAnswerSheet.method,
           headers: {
-            'Authorization': `${localStorage.getItem('token')}`
-          }
+            'Authorization': `${localStorage.getItem('token')}`,
+            'Content-Type': 'application/json'
+          },
+          body: selectedSeat ? JSON.stringify({ seat: selectedSeat }) : undefined
         });
   
         if (response.ok) {
           const data = await response.json();
           // console.log("answersheet data: ",data)
+          setSeatOptions(null);
           setAnswerSheet(data);
   
           // Store answerSheet in localStorage
@@ -54,7 +58,21 @@ function ExamEntryPoint() {
           }
         } else {
           const error = await response.json();
-          toast.error(error.error || 'Failed to assign question paper');
+          if (error.code === 'seat_required') {
+            // The lab has a seating plan, ask where the student is sitting
+            const optionsResponse = await fetch(Allapi.getSeatingOptions.url(id), {
+              headers: {
+                'Authorization': `${localStorage.getItem('token')}`
+              }
+            });
+            const options = optionsResponse.ok ? await optionsResponse.json() : [];
+            setSeatOptions(options);
+            if (options.length > 0) {
+              setSeat((prev) => ({ ...prev, slot: prev.slot || options[0].slot, room: prev.room || options[0].room }));
+            }
+          } else {
+            toast.error(error.error || 'Failed to assign question paper');
+          }
         }
       } catch (error) {
         console.error('Error assigning question paper:', error);
@@ -62,12 +80,24 @@ function ExamEntryPoint() {
       } finally {
         setLoading(false);
       }
-    };
-  
+  };
+
+  useEffect(() => {
     if (id) {
       assignQuestionPaper();
     }
   }, [id]);
+
+  const submitSeat = (e) => {
+    e.preventDefault();
+    const row = parseInt(seat.row, 10);
+    const number = parseInt(seat.number, 10);
+    if (!seat.slot || !seat.room || !row || !number) {
+      toast.error('Please fill in your slot, room, row and seat');
+      return;
+    }
+    assignQuestionPaper({ slot: seat.slot, room: seat.room, row, number });
+  };
   
 
   const fetchQuestionPaper = async () => {
@@ -141,6 +171,60 @@ function ExamEntryPoint() {
     );
   }
 
+  if (!answerSheet && seatOptions) {
+    const slots = [...new Set(seatOptions.map((o) => o.slot))];
+    const rooms = seatOptions.filter((o) => o.slot === seat.slot).map((o) => o.room);
+    return (
+      <>
+        <Toaster position="top-right" />
+        <form onSubmit={submitSeat} className="max-w-md p-8 mx-auto space-y-4 bg-gray-800 border-2 rounded-xl border-green-500/20">
+          <h2 className="text-xl font-semibold text-white">Where are you sitting?</h2>
+          <p className="text-gray-400">Enter the seat you are sitting at in the lab.</p>
+          <select
+            value={seat.slot}
+            onChange={(e) => setSeat({ ...seat, slot: e.target.value, room: '' })}
+            className="w-full p-2 text-white bg-gray-700 rounded-lg"
+          >
+            <option value="">Slot</option>
+            {slots.map((slot) => <option key={slot} value={slot}>{slot}</option>)}
+          </select>
+          <select
+            value={seat.room}
+            onChange={(e) => setSeat({ ...seat, room: e.target.value })}
+            className="w-full p-2 text-white bg-gray-700 rounded-lg"
+          >
+            <option value="">Room</option>
+            {rooms.map((room) => <option key={room} value={room}>{room}</option>)}
+          </select>
+          <div className="flex gap-4">
+            <input
+              type="number"
+              min="1"
+              placeholder="Row"
+              value={seat.row}
+              onChange={(e) => setSeat({ ...seat, row: e.target.value })}
+              className="w-full p-2 text-white bg-gray-700 rounded-lg"
+            />
+            <input
+              type="number"
+              min="1"
+              placeholder="Seat"
+              value={seat.number}
+              onChange={(e) => setSeat({ ...seat, number: e.target.value })}
+              className="w-full p-2 text-white bg-gray-700 rounded-lg"
+            />
+          </div>
+          <button
+            type="submit"
+            className="w-full px-4 py-2 text-white transition-all duration-300 bg-green-500 rounded-lg hover:bg-green-600"
+          >
+            Continue
+          </button>
+        </form>
+      </>
+    );
+  }
+
   if (!answerSheet) {
     return (
       <div className="flex flex-col items-center justify-center h-full space-y-4">
@@ -216,14 +300,13 @@ function ExamEntryPoint() {
                   View Questions
                 </button>
               
-                {(answerSheet.exam_type != 'internal' ) && (
               <button
                 onClick={startExam}
                 className="flex items-center px-6 py-3 text-white transition-all duration-300 bg-green-500 rounded-lg hover:bg-green-600"
               >
                 <Play className="w-5 h-5 mr-2" />
                 Start Exam
-              </button>)}
+              </button>
             </div>
           </div>
         </div>
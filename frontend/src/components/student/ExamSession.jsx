@@ -1,6 +1,6 @@
 import React, { useState, useEffect, useRef } from 'react';
 import { useParams, useNavigate } from 'react-router-dom';
-import { Clock, User, Mail, FileText, Save, Brain } from 'lucide-react';
+import { Clock, User, Mail, FileText, Save } from 'lucide-react';
 import { Toaster, toast } from 'react-hot-toast';
 import Allapi from '../../utils/common';
 
@@ -10,8 +10,7 @@ function ExamSession() {
   const [answerSheetId, setAnswerSheetId] = useState();
   const navigate = useNavigate();
   const [loading, setLoading] = useState(false);
-  const [aiEvaluating, setAiEvaluating] = useState(false);
-  const [aiScore, setAiScore] = useState(null);
+  const [submitting, setSubmitting] = useState(false);
   const [questionPaper, setQuestionPaper] = useState(null);
   const [answerSheet, setAnswerSheet] = useState(null);
   const [answers, setAnswers] = useState([]);
@@ -19,6 +18,15 @@ function ExamSession() {
   const [activeTypeIndex, setActiveTypeIndex] = useState(0);
   const [timeLeft, setTimeLeft] = useState(0);
   const timerRef = useRef(null);
+  const answersRef = useRef([]);
+
+  // One random session per attempt and tab, the server only lets one
+  // session work on an answer sheet at a time
+  const sessionKey = `examSession-${id}`;
+  if (!sessionStorage.getItem(sessionKey)) {
+    sessionStorage.setItem(sessionKey, crypto.randomUUID());
+  }
+  const sessionId = sessionStorage.getItem(sessionKey);
 
   useEffect(() => {
     const fetchExamData = async () => {
@@ -43,8 +51,8 @@ function ExamSession() {
         // console.log("answer sheet data: ", answerSheetData);
         setAnswerSheetId(answerSheetData.id);
         
-        // Initialize time left
-        setTimeLeft(answerSheetData.duration * 60); // Convert minutes to seconds
+        // Initialize time left from the server's deadline
+        setTimeLeft(answerSheetData.remaining_seconds ?? answerSheetData.duration * 60);
         
         // Fetch question paper
         const questionPaperResponse = await fetch(`${Allapi.getQuestionPaper.url(qpaper_id)}`, {
@@ -72,7 +80,9 @@ function ExamSession() {
           };
         });
         
-        setAnswers(initialAnswers);
+        // Resume with the answers the server saved from earlier heartbeats
+        const savedAnswers = answerSheetData.data || [];
+        setAnswers(savedAnswers.length === initialAnswers.length ? savedAnswers : initialAnswers);
       } catch (error) {
         console.error('Error fetching exam data:', error);
         toast.error('Failed to load exam');
@@ -89,6 +99,83 @@ function ExamSession() {
       }
     };
   }, [id, qpaper_id, navigate]);
+
+  useEffect(() => {
+    answersRef.current = answers;
+  }, [answers]);
+
+  // Heartbeats keep this device's hold on the answer sheet and autosave
+  useEffect(() => {
+    if (loading) {
+      return;
+    }
+    const fingerprint = [
+      navigator.platform,
+      navigator.language,
+      `${window.screen.width}x${window.screen.height}`,
+      Intl.DateTimeFormat().resolvedOptions().timeZone,
+    ].join('|');
+
+    const beat = async (takeover = false) => {
+      try {
+        const response = await fetch(Allapi.heartbeat.url(id), {
+          method: Allapi.heartbeat.method,
+          headers: {
+            'Content-Type': 'application/json',
+            'Authorization': `${localStorage.getItem('token')}`
+          },
+          body: JSON.stringify({ session_id: sessionId, fingerprint, takeover, answers: answersRef.current })
+        });
+        const result = await response.json();
+        if (response.ok) {
+          if (result.remaining_seconds !== undefined) {
+            setTimeLeft(result.remaining_seconds);
+          }
+          return;
+        }
+        if (result.code === 'session_conflict') {
+          if (window.confirm('This exam is open on another device. Continue here instead?')) {
+            beat(true);
+          }
+        } else if (result.code === 'expired' || result.code === 'not_running') {
+          toast.error(result.error);
+          navigate('/student/exams');
+        }
+      } catch (error) {
+        // Offline, the next beat retries
+      }
+    };
+
+    beat();
+    const interval = setInterval(beat, 15000);
+    return () => clearInterval(interval);
+  }, [id, loading, sessionId, navigate]);
+
+  // Report tab switches and clipboard use to the teacher's live monitor
+  useEffect(() => {
+    const report = (type) => {
+      fetch(Allapi.reportProctoring.url(id), {
+        method: Allapi.reportProctoring.method,
+        headers: {
+          'Content-Type': 'application/json',
+          'Authorization': `${localStorage.getItem('token')}`
+        },
+        body: JSON.stringify({ type })
+      }).catch(() => {});
+    };
+    const onVisibility = () => report(document.hidden ? 'tab_hidden' : 'tab_visible');
+    const onCopy = () => report('copy');
+    const onPaste = () => report('paste');
+
+    document.addEventListener('visibilitychange', onVisibility);
+    document.addEventListener('copy', onCopy);
+    document.addEventListener('paste', onPaste);
+    return () => {
+      document.removeEventListener('visibilitychange', onVisibility);
+      document.removeEventListener('copy', onCopy);
+      document.removeEventListener('paste', onPaste);
+    };
+  }, [id]);
   
   useEffect(() => {
     if (timeLeft > 0 && !loading) {
@@ -96,7 +183,7 @@ function ExamSession() {
         setTimeLeft(prev => {
           if (prev <= 1) {
             clearInterval(timerRef.current);
-            handleSubmitWithAI();
+            handleSubmit();
             return 0;
           }
           return prev - 1;
@@ -125,56 +212,12 @@ function ExamSession() {
     setAnswers(newAnswers);
   };
 
-  const generateAIScore = async () => {
-    try {
-      // Prepare the prompt with questions and answers
-      const prompt = `Please evaluate these exam answers and provide only a numerical score out of 100. No explanation needed, just the number.\n\nQuestions and Answers:\n${answers.map(a => 
-        `Question: ${a.question}\nAnswers: ${a.answers.map(ans => 
-          `\n${ans.type}: ${ans.ans}`
-        ).join('')}\n`
-      ).join('\n')}`;
-
-      const response = await fetch(`${Allapi.backapi}/ai/generate`, {
-        method: 'POST',
-        headers: {
-          'Content-Type': 'application/json',
-          'Authorization': `${localStorage.getItem('token')}`
-        },
-        body: JSON.stringify({ prompt })
-      });
-
-      if (!response.ok) {
-        throw new Error('Failed to generate AI score');
-      }
-
-      const data = await response.json();
-      // Extract just the number from the AI response
-      const score = parseFloat(data.response.match(/\d+/)[0]);
-      return Math.min(100, Math.max(0, score)); // Ensure score is between 0 and 100
-    } catch (error) {
-      console.error('Error generating AI score:', error);
-      throw error;
-    }
-  };
-  
-  const handleSubmitWithAI = async () => {
-    try {
-      setAiEvaluating(true);
-      const score = await generateAIScore();
-      setAiScore(score);
-      
-      // Wait for 2 seconds to show the score
-      await new Promise(resolve => setTimeout(resolve, 2000));
-      
-      // Submit exam with AI score
-      await submitExam(score);
-    } catch (error) {
-      toast.error('Failed to evaluate answers');
-      setAiEvaluating(false);
-    }
+  const handleSubmit = () => {
+    setSubmitting(true);
+    submitExam();
   };
 
-  const submitExam = async (score) => {
+  const submitExam = async () => {
     try {
       const response = await fetch(`${Allapi.backapi}/exam/submit-exam/${answerSheetId}`, {
         method: 'POST',
@@ -184,7 +227,7 @@ function ExamSession() {
         },
         body: JSON.stringify({ 
           answers,
-          ai_score: score
+          session_id: sessionId
         })
       });
 
@@ -199,7 +242,7 @@ function ExamSession() {
       console.error('Error submitting exam:', error);
       toast.error('Failed to submit exam');
     } finally {
-      setAiEvaluating(false);
+      setSubmitting(false);
     }
   };
   
@@ -214,24 +257,11 @@ function ExamSession() {
     );
   }
 
-  if (aiEvaluating) {
+  if (submitting) {
     return (
       <div className="flex flex-col items-center justify-center min-h-screen space-y-6">
-        {aiScore === null ? (
-          <>
-            <Brain className="w-16 h-16 text-green-500 animate-pulse" />
-            <h2 className="text-2xl font-bold text-white">AI is evaluating your answers...</h2>
-            <div className="w-48 h-2 bg-gray-700 rounded-full">
-              <div className="h-full bg-green-500 rounded-full animate-[width] duration-1000" style={{ width: '60%' }}></div>
-            </div>
-          </>
-        ) : (
-          <>
-            <div className="text-6xl font-bold text-green-500">{aiScore}</div>
-            <p className="text-xl text-white">Your AI-Generated Score</p>
-            <p className="text-gray-400">Submitting your exam...</p>
-          </>
-        )}
+        <div className="w-16 h-16 border-4 border-green-500 rounded-full border-t-transparent animate-spin"></div>
+        <h2 className="text-2xl font-bold text-white">Submitting your exam...</h2>
       </div>
     );
   }
@@ -274,12 +304,14 @@ function ExamSession() {
             </div>
           </div>
           
+          {/* Internal exams have no timer, the teacher closes them */}
+          {answerSheet?.duration > 0 && (
           <div className={`flex items-center px-4 py-2 space-x-2 rounded-lg ${
             timeLeft < 300 ? 'bg-red-500/20 text-red-400' : 'bg-green-500/20 text-green-400'
           }`}>
             <Clock className="w-5 h-5" />
             <span className="font-mono text-lg font-bold">{formatTime(timeLeft)}</span>
-          </div>
+          </div>)}
         </div>
         
         {/* Question navigation */}
@@ -369,7 +401,7 @@ function ExamSession() {
               </button>
             ) : (
               <button
-                onClick={handleSubmitWithAI}
+                onClick={handleSubmit}
                 className="flex items-center px-6 py-2 text-white transition-all duration-300 bg-green-500 rounded-lg hover:bg-green-600"
               >
                 <Save className="w-5 h-5 mr-2" />
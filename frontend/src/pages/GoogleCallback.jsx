@@ -102,14 +102,19 @@ function GoogleCallback() {
       }
 
       try {
-        const code = new URLSearchParams(location.search).get('code');
+        const params = new URLSearchParams(location.search);
+        const code = params.get('code');
+        const state = params.get('state');
         if (!code) {
           throw new Error('No authorization code received');
         }
 
         processedRef.current = true;
 
-        const response = await fetch(`${Allapi.googleCallback.url}?code=${code}`);
+        const query = new URLSearchParams({ code, state: state || '' });
+        const response = await fetch(`${Allapi.googleCallback.url}?${query}`, {
+          credentials: 'include',
+        });
         const data = await response.json();
         
         if (data.token && data.user) {
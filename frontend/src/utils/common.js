@@ -45,6 +45,78 @@ const Allapi = {
     url: `${backapi}/exam/assignsetandcreateanswersheet`,
     method: "POST",
   },
+  getSeatingOptions: {
+    url: (id) => `${backapi}/exam/seating-options/${id}`,
+    method: "GET",
+  },
+  openInternalExam: {
+    url: (id) => `${backapi}/exam/internal/${id}/open`,
+    method: "POST",
+  },
+  closeInternalExam: {
+    url: (id) => `${backapi}/exam/internal/${id}/close`,
+    method: "POST",
+  },
+  monitorTicket: {
+    url: (id) => `${backapi}/exam/monitor/${id}/ticket`,
+    method: "POST",
+  },
+  monitorExam: {
+    url: (id, ticket) => `${backapi}/exam/monitor/${id}/stream?ticket=${encodeURIComponent(ticket)}`,
+    method: "GET",
+  },
+  heartbeat: {
+    url: (id) => `${backapi}/exam/heartbeat/${id}`,
+    method: "POST",
+  },
+  reportProctoring: {
+    url: (id) => `${backapi}/exam/proctoring/${id}`,
+    method: "POST",
+  },
+  aiGradeQuestion: {
+    url: (evaluationId, index) => `${backapi}/exam/evaluation/${evaluationId}/question/${index}/ai-grade`,
+    method: "POST",
+  },
+  startAIPreEvaluation: {
+    url: (id) => `${backapi}/exam/ai-pre-evaluation/${id}`,
+    method: "POST",
+  },
+  getAIPreEvaluation: {
+    url: (id) => `${backapi}/exam/ai-pre-evaluation/${id}`,
+    method: "GET",
+  },
+  cancelAIPreEvaluation: {
+    url: (jobId) => `${backapi}/exam/ai-pre-evaluation/jobs/${jobId}/cancel`,
+    method: "POST",
+  },
+  getAICalibration: {
+    url: (examId) => `${backapi}/exam/ai-calibration/${examId}`,
+    method: "GET",
+  },
+  setAICalibration: {
+    url: (examId) => `${backapi}/exam/ai-calibration/${examId}`,
+    method: "PUT",
+  },
+  previewGradingPrompt: {
+    url: `${backapi}/exam/prompt-templates/preview`,
+    method: "POST",
+  },
+  generateQuestionDrafts: {
+    url: `${backapi}/exam/question-drafts/generate`,
+    method: "POST",
+  },
+  acceptQuestionDrafts: {
+    url: `${backapi}/exam/question-drafts/accept`,
+    method: "POST",
+  },
+  getQuestionBank: {
+    url: `${backapi}/exam/question-bank`,
+    method: "GET",
+  },
+  deleteBankQuestion: {
+    url: (id) => `${backapi}/exam/question-bank/${id}`,
+    method: "DELETE",
+  },
   startExam: {
     url: `${backapi}/exam/start-exam`,
     method: "POST",